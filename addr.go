@@ -0,0 +1,80 @@
+package testcli
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// ExtractAddr waits up to timeout for the command to print the address it's
+// listening on, and for that address to accept connections. regex must
+// match the address on stdout, with its first capture group (or the whole
+// match if it has none) being a host:port; an empty host means localhost.
+// The test fails, showing the captured output, if either doesn't happen in
+// time.
+func (c *Cmd) ExtractAddr(regex string, timeout time.Duration) *net.TCPAddr {
+	c.t.Helper()
+	c.validateHasStarted()
+	re := regexp.MustCompile(regex)
+	deadline := time.Now().Add(timeout)
+
+	var hostport string
+	err := c.waitFor(fmt.Sprintf("stdout to match %q", regex), func() bool {
+		c.stdout.mu.Lock()
+		m := re.FindStringSubmatch(c.stdout.text())
+		c.stdout.mu.Unlock()
+		if m == nil {
+			return false
+		}
+		hostport = m[0]
+		if len(m) > 1 {
+			hostport = m[1]
+		}
+		return true
+	}, 10*time.Millisecond, timeout)
+	if err != nil {
+		c.fatalf("%s", err)
+	}
+
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		c.fatalf("Failed to parse address %q: %s", hostport, err)
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	addr, err := net.ResolveTCPAddr("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		c.fatalf("Failed to resolve address %q: %s", hostport, err)
+	}
+
+	if err := c.waitForPort(addr.String(), time.Until(deadline)); err != nil {
+		c.fatalf("%s", err)
+	}
+	return addr
+}
+
+// BaseURLClient returns an HTTP client sending requests with relative URLs,
+// like client.Get("/healthz"), to http://addr.
+func BaseURLClient(addr net.Addr) *http.Client {
+	base := &url.URL{Scheme: "http", Host: addr.String(), Path: "/"}
+	return &http.Client{Transport: baseURLTransport{base: base, next: http.DefaultTransport}}
+}
+
+type baseURLTransport struct {
+	base *url.URL
+	next http.RoundTripper
+}
+
+func (t baseURLTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.IsAbs() {
+		return t.next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.URL = t.base.ResolveReference(req.URL)
+	req.Host = req.URL.Host
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,46 @@
+package testcli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestServerHelperProcess serves HTTP on a random port, printing its address.
+func TestServerHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Printf("listening on %s\n", l.Addr())
+	http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "hello from %s", r.URL.Path)
+	}))
+}
+
+func TestExtractAddr(t *testing.T) {
+	c := ReexecCommand(t, "TestServerHelperProcess")
+	c.Start()
+	defer c.Kill()
+
+	addr := c.ExtractAddr(`listening on (\S+)`, 5*time.Second)
+	if !addr.IP.IsLoopback() || addr.Port == 0 {
+		t.Fatalf("Expected a loopback address with a port, got %s", addr)
+	}
+
+	resp, err := BaseURLClient(addr).Get("/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != "hello from /healthz" {
+		t.Fatalf("Expected %q, got %q", "hello from /healthz", body)
+	}
+}
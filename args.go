@@ -0,0 +1,136 @@
+package testcli
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// CommandString constructs a *Cmd from a command line, split into arguments
+// the way a POSIX shell would, without any expansion: words are separated by
+// unquoted blanks, single quotes preserve everything, double quotes
+// preserve everything but the backslash escapes \", \\, \$ and \`, and a
+// backslash outside of quotes escapes the next character. Use Args() to see
+// the result.
+func CommandString(t *testing.T, line string) *Cmd {
+	t.Helper()
+	args, err := splitCommandLine(line)
+	if err != nil {
+		t.Fatalf("Can't parse command line %q: %s", line, err)
+	}
+	if len(args) == 0 {
+		t.Fatalf("Can't parse command line %q: no command", line)
+	}
+	return Command(t, args[0], args[1:]...)
+}
+
+// splitCommandLine splits line into arguments, see CommandString().
+func splitCommandLine(line string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		case ch == '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			arg.WriteString(line[i+1 : i+1+end])
+			i += end + 1
+			inArg = true
+		case ch == '"':
+			closed := false
+			for i++; i < len(line); i++ {
+				if line[i] == '"' {
+					closed = true
+					break
+				}
+				if line[i] == '\\' && i+1 < len(line) && strings.IndexByte("\"\\$`", line[i+1]) >= 0 {
+					i++
+				}
+				arg.WriteByte(line[i])
+			}
+			if !closed {
+				return nil, errors.New("unterminated double quote")
+			}
+			inArg = true
+		case ch == '\\':
+			if i+1 == len(line) {
+				return nil, errors.New("trailing backslash")
+			}
+			i++
+			if line[i] != '\n' {
+				arg.WriteByte(line[i])
+			}
+			inArg = true
+		default:
+			arg.WriteByte(ch)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// Args returns the command's argv, program name included, as it is or will
+// be passed to the process: after parsing by CommandString() and the
+// arguments added by options like WithInputFile().
+func (c *Cmd) Args() []string {
+	return append([]string(nil), c.cmd.Args...)
+}
+
+// AssertArgs fails the test unless the command's argv, program name
+// included, is exactly want.
+func (c *Cmd) AssertArgs(want ...string) {
+	c.t.Helper()
+	if got := c.Args(); !reflect.DeepEqual(got, want) {
+		c.t.Fatalf("Expected argv %q, got %q", want, got)
+	}
+}
+
+// Repro returns a shell command line reproducing the command from the
+// current directory: changing to its directory, setting the environment
+// variables which differ from ours, or all of them after `env -i` with
+// WithEnvPassthrough(), and running it with its arguments quoted.
+func (c *Cmd) Repro() string {
+	var repro string
+	if c.cmd.Dir != "" {
+		repro = "cd " + shellQuote(c.cmd.Dir) + " && "
+	}
+	// Without WithEnvPassthrough(), only the variables which differ from
+	// ours are needed.
+	env, prefix := envDelta(c.environ(), os.Environ()), "env"
+	if c.envPassthrough != nil {
+		env, prefix = envDelta(c.environ(), nil), "env -i"
+	}
+	if len(env) > 0 || c.envPassthrough != nil {
+		repro += prefix
+		for _, kv := range env {
+			repro += " " + shellQuote(kv)
+		}
+		repro += " "
+	}
+	return repro + quoteArgs(c.cmd.Args)
+}
+
+// quoteArgs joins args into a command line a POSIX shell splits back into
+// args.
+func quoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,61 @@
+package testcli
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCommandString(t *testing.T) {
+	c := CommandString(t, `printf '%s|' "two words" 'it'\''s' "say \"hi\"" '' "a
+b" back\ slash`)
+	c.AssertArgs("printf", "%s|", "two words", "it's", `say "hi"`, "", "a\nb", "back slash")
+	c.Run()
+
+	expected := "two words|it's|say \"hi\"||a\nb|back slash|"
+	if c.Stdout() != expected {
+		t.Fatalf("Expected %q to be %q", c.Stdout(), expected)
+	}
+}
+
+func TestSplitCommandLineErrors(t *testing.T) {
+	for _, line := range []string{`echo 'open`, `echo "open`, `echo \`} {
+		if _, err := splitCommandLine(line); err == nil {
+			t.Errorf("Expected %q not to parse", line)
+		}
+	}
+}
+
+func TestArgsAfterOptions(t *testing.T) {
+	c := Command(t, "cat", "--input=")
+	c.Apply(WithInputFile("--input", []byte("x")))
+	c.AssertArgs("cat", "--input="+c.InputFiles()[0])
+}
+
+func TestReproRoundTrip(t *testing.T) {
+	args := []string{"printf", `[%s]`, "with space", `"quotes'`, "", "new\nline", "$HOME"}
+	c := Command(t, args[0], args[1:]...)
+	c.SetEnv(append(os.Environ(), "TESTCLI_REPRO=a b"))
+	c.cmd.Dir = os.TempDir()
+
+	repro := c.Repro()
+	if !strings.HasPrefix(repro, "cd ") || !strings.Contains(repro, "env 'TESTCLI_REPRO=a b' ") {
+		t.Fatalf("Expected the directory and environment in %q", repro)
+	}
+
+	// The shell must run exactly the same argv.
+	sh := Command(t, "/bin/sh", "-c", repro)
+	sh.Run()
+	direct := Command(t, args[0], args[1:]...)
+	direct.Run()
+	if sh.Stdout() != direct.Stdout() {
+		t.Fatalf("Expected %q to be %q", sh.Stdout(), direct.Stdout())
+	}
+
+	parsed, err := splitCommandLine(quoteArgs(args))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromString := Command(t, parsed[0], parsed[1:]...)
+	fromString.AssertArgs(args...)
+}
@@ -0,0 +1,174 @@
+package testcli
+
+import "fmt"
+
+// The Assert methods fail the test at once, and return the command so that
+// they can be chained, see New(). The Check methods let it go on and report
+// whether they passed. Both show the command and its captured output along
+// with the failure.
+
+// AssertStdoutContains fails the test unless StdoutContains(str, opts...).
+func (c *Cmd) AssertStdoutContains(str string, opts ...MatchOption) *Cmd {
+	c.t.Helper()
+	c.assert(c.checkStdoutContains(str, opts))
+	return c
+}
+
+// CheckStdoutContains marks the test as failed unless
+// StdoutContains(str, opts...).
+func (c *Cmd) CheckStdoutContains(str string, opts ...MatchOption) bool {
+	c.t.Helper()
+	return c.check(c.checkStdoutContains(str, opts))
+}
+
+func (c *Cmd) checkStdoutContains(str string, opts []MatchOption) error {
+	c.t.Helper()
+	if !c.StdoutContains(str, opts...) {
+		return fmt.Errorf("Expected stdout to contain %q", str)
+	}
+	return nil
+}
+
+// AssertStderrContains fails the test unless StderrContains(str, opts...).
+func (c *Cmd) AssertStderrContains(str string, opts ...MatchOption) *Cmd {
+	c.t.Helper()
+	c.assert(c.checkStderrContains(str, opts))
+	return c
+}
+
+// CheckStderrContains marks the test as failed unless
+// StderrContains(str, opts...).
+func (c *Cmd) CheckStderrContains(str string, opts ...MatchOption) bool {
+	c.t.Helper()
+	return c.check(c.checkStderrContains(str, opts))
+}
+
+func (c *Cmd) checkStderrContains(str string, opts []MatchOption) error {
+	c.t.Helper()
+	if !c.StderrContains(str, opts...) {
+		return fmt.Errorf("Expected stderr to contain %q", str)
+	}
+	return nil
+}
+
+// AssertStdoutMatches fails the test unless StdoutMatches(regex, opts...).
+func (c *Cmd) AssertStdoutMatches(regex string, opts ...MatchOption) *Cmd {
+	c.t.Helper()
+	c.assert(c.checkStdoutMatches(regex, opts))
+	return c
+}
+
+// CheckStdoutMatches marks the test as failed unless
+// StdoutMatches(regex, opts...).
+func (c *Cmd) CheckStdoutMatches(regex string, opts ...MatchOption) bool {
+	c.t.Helper()
+	return c.check(c.checkStdoutMatches(regex, opts))
+}
+
+func (c *Cmd) checkStdoutMatches(regex string, opts []MatchOption) error {
+	c.t.Helper()
+	if !c.StdoutMatches(regex, opts...) {
+		return fmt.Errorf("Expected stdout to match %q", regex)
+	}
+	return nil
+}
+
+// AssertStderrMatches fails the test unless StderrMatches(regex, opts...).
+func (c *Cmd) AssertStderrMatches(regex string, opts ...MatchOption) *Cmd {
+	c.t.Helper()
+	c.assert(c.checkStderrMatches(regex, opts))
+	return c
+}
+
+// CheckStderrMatches marks the test as failed unless
+// StderrMatches(regex, opts...).
+func (c *Cmd) CheckStderrMatches(regex string, opts ...MatchOption) bool {
+	c.t.Helper()
+	return c.check(c.checkStderrMatches(regex, opts))
+}
+
+func (c *Cmd) checkStderrMatches(regex string, opts []MatchOption) error {
+	c.t.Helper()
+	if !c.StderrMatches(regex, opts...) {
+		return fmt.Errorf("Expected stderr to match %q", regex)
+	}
+	return nil
+}
+
+// AssertSuccess fails the test unless the finished command succeeded.
+func (c *Cmd) AssertSuccess() *Cmd {
+	c.t.Helper()
+	c.assert(c.checkSuccess())
+	return c
+}
+
+// CheckSuccess marks the test as failed unless the finished command
+// succeeded.
+func (c *Cmd) CheckSuccess() bool {
+	c.t.Helper()
+	return c.check(c.checkSuccess())
+}
+
+func (c *Cmd) checkSuccess() error {
+	c.t.Helper()
+	if !c.Success() {
+		return fmt.Errorf("Expected the command to succeed, got %v", c.exitError)
+	}
+	return nil
+}
+
+// AssertFailure fails the test unless the finished command failed.
+func (c *Cmd) AssertFailure() *Cmd {
+	c.t.Helper()
+	c.assert(c.checkFailure())
+	return c
+}
+
+// CheckFailure marks the test as failed unless the finished command failed.
+func (c *Cmd) CheckFailure() bool {
+	c.t.Helper()
+	return c.check(c.checkFailure())
+}
+
+func (c *Cmd) checkFailure() error {
+	c.t.Helper()
+	if !c.Failure() {
+		return fmt.Errorf("Expected the command to fail, but it succeeded")
+	}
+	return nil
+}
+
+// AssertExitCode fails the test unless the finished command exited with
+// code, see ExpectExitCode().
+func (c *Cmd) AssertExitCode(code int) *Cmd {
+	c.t.Helper()
+	c.ExpectExitCode(code)
+	return c
+}
+
+// CheckExitCode marks the test as failed unless the finished command exited
+// with code.
+func (c *Cmd) CheckExitCode(code int) bool {
+	c.t.Helper()
+	c.validateIsFinished()
+	return c.check(c.checkExitCode(code))
+}
+
+// assert fails the test with err, if not nil.
+func (c *Cmd) assert(err error) {
+	c.t.Helper()
+	if err != nil {
+		c.fatalf("%s", err)
+	}
+}
+
+// check marks the test as failed with err, if not nil, and reports whether
+// it was nil.
+func (c *Cmd) check(err error) bool {
+	c.t.Helper()
+	if err != nil {
+		c.errorf("%s", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package testcli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAssertHelpersPass(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", "echo out; echo err >&2; exit 3")
+	c.Run()
+	c.AssertStdoutContains("OUT")
+	c.AssertStderrContains("err")
+	c.AssertStdoutMatches(`^out\n$`)
+	c.AssertStderrMatches(`err`)
+	c.AssertFailure()
+	c.AssertExitCode(3)
+	if !c.CheckStdoutContains("out") || !c.CheckFailure() || !c.CheckExitCode(3) {
+		t.Fatalf("Expected the checks to pass")
+	}
+
+	c = Command(t, "true")
+	c.Run()
+	c.AssertSuccess()
+}
+
+// TestCheckHelpersHelperProcess fails two checks, then an assertion.
+func TestCheckHelpersHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	c := Command(t, "/bin/sh", "-c", "echo captured-out; echo captured-err >&2")
+	c.Run()
+	c.CheckStdoutContains("missing-out")
+	c.CheckStderrMatches(`missing-err`)
+	c.AssertFailure()
+	c.AssertSuccess()
+}
+
+func TestCheckHelpersAccumulate(t *testing.T) {
+	c := ReexecCommand(t, "TestCheckHelpersHelperProcess")
+	c.Run()
+	if c.Success() {
+		t.Fatalf("Expected the test to fail")
+	}
+	out := c.Stdout()
+	for _, want := range []string{
+		`Expected stdout to contain "missing-out"`,
+		`Expected stderr to match "missing-err"`,
+		"Expected the command to fail, but it succeeded",
+		"captured-out",
+		"captured-err",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("Expected %q to contain %q", out, want)
+		}
+	}
+	if strings.Contains(out, "Expected the command to succeed") {
+		t.Fatalf("Expected AssertFailure() to stop the test, got %q", out)
+	}
+}
@@ -0,0 +1,149 @@
+package testcli
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Attached is a process started by someone else, see AttachPID().
+type Attached struct {
+	t   *testing.T
+	pid int
+	log *output
+
+	// exited is closed once the process is seen gone, quit once it or the
+	// test is done.
+	exited chan struct{}
+	quit   chan struct{}
+}
+
+// AttachOption configures a process attached by AttachPID().
+type AttachOption func(*Attached)
+
+// WithLogFile follows the file at path, where the process writes its logs,
+// for the log assertions. It may not exist yet.
+func WithLogFile(path string) AttachOption {
+	return func(a *Attached) {
+		a.log = &output{mu: &sync.Mutex{}}
+		go follow(path, a.log, a.quit)
+	}
+}
+
+// AttachPID returns a handle on the process pid, started outside of the test,
+// e.g. by a service manager or a Makefile. Its stdout and stderr can't be
+// captured: assertions look at its log file instead, see WithLogFile().
+func AttachPID(t *testing.T, pid int, opts ...AttachOption) *Attached {
+	a := &Attached{
+		t:      t,
+		pid:    pid,
+		exited: make(chan struct{}),
+		quit:   make(chan struct{}),
+	}
+	stop := make(chan struct{})
+	t.Cleanup(func() {
+		close(stop)
+	})
+	go a.watch(stop)
+
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// watch polls the process until it exits or stop is closed.
+func (a *Attached) watch(stop chan struct{}) {
+	defer close(a.quit)
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for processAlive(a.pid) {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+	close(a.exited)
+}
+
+// Pid returns the process id.
+func (a *Attached) Pid() int {
+	return a.pid
+}
+
+// IsRunning reports whether the process is still running.
+func (a *Attached) IsRunning() bool {
+	select {
+	case <-a.exited:
+		return false
+	default:
+		return processAlive(a.pid)
+	}
+}
+
+// Signal sends a signal to the process.
+func (a *Attached) Signal(sig os.Signal) {
+	a.t.Helper()
+	p, err := os.FindProcess(a.pid)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	if err := signalProcess(p, sig); err != nil {
+		a.t.Fatal(err)
+	}
+}
+
+// WaitExited waits up to timeout for the process to exit, and reports
+// whether it did. Its exit status isn't known, since it's not our child.
+func (a *Attached) WaitExited(timeout time.Duration) bool {
+	select {
+	case <-a.exited:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Log returns what the process wrote to its log file so far.
+func (a *Attached) Log() string {
+	a.t.Helper()
+	a.validateHasLog()
+	a.log.mu.Lock()
+	defer a.log.mu.Unlock()
+	return a.log.text()
+}
+
+// LogContains determines if the log file contains `str`, this operation is
+// case insensitive.
+func (a *Attached) LogContains(str string) bool {
+	a.t.Helper()
+	a.validateHasLog()
+	return retryStringTest(strings.Contains, a.logView, strings.ToLower(str))
+}
+
+// LogMatches compares a regex to the log file.
+func (a *Attached) LogMatches(regex string) bool {
+	a.t.Helper()
+	a.validateHasLog()
+	re := regexp.MustCompile(regex)
+	return retryStringTest(func(got, want string) bool {
+		return re.MatchString(got)
+	}, a.logView, regex)
+}
+
+func (a *Attached) logView() string {
+	a.log.mu.Lock()
+	defer a.log.mu.Unlock()
+	return a.log.text()
+}
+
+func (a *Attached) validateHasLog() {
+	a.t.Helper()
+	if a.log == nil {
+		a.t.Fatal("Log assertions require WithLogFile()")
+	}
+}
@@ -0,0 +1,63 @@
+//go:build linux
+// +build linux
+
+package testcli
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestAttachPID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testcli-attach")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	log := filepath.Join(dir, "service.log")
+
+	// Started by "someone else".
+	service := exec.Command("/bin/sh", "-c", `trap 'echo stopping >> "$0"; exit 0' TERM; echo started >> "$0"; while :; do sleep 0.05; done`, log)
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+	go service.Wait()
+
+	a := AttachPID(t, service.Process.Pid, WithLogFile(log))
+	if !a.LogContains("started") {
+		t.Fatalf("Expected %q to contain %q", a.Log(), "started")
+	}
+	if !a.IsRunning() {
+		t.Fatalf("Expected the process to be running")
+	}
+
+	a.Signal(syscall.SIGTERM)
+	if !a.WaitExited(2 * time.Second) {
+		t.Fatalf("Expected the process to exit")
+	}
+	if a.IsRunning() {
+		t.Fatalf("Expected the process not to be running")
+	}
+	if !a.LogMatches(`started\nstopping`) {
+		t.Fatalf("Expected the log to end with %q, got %q", "stopping", a.Log())
+	}
+}
+
+func TestAttachPIDZombie(t *testing.T) {
+	// Exits, but isn't reaped.
+	zombie := exec.Command("true")
+	if err := zombie.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer zombie.Wait()
+
+	a := AttachPID(t, zombie.Process.Pid)
+	if !a.WaitExited(2 * time.Second) {
+		t.Fatalf("Expected a zombie process to count as exited")
+	}
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+// +build !windows
+
+package testcli
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// processAlive reports whether the process pid exists and isn't a zombie,
+// which it is once it exited until its parent reaps it.
+func processAlive(pid int) bool {
+	if err := syscall.Kill(pid, 0); err != nil && err != syscall.EPERM {
+		return false
+	}
+	stat, err := ioutil.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		// Not Linux, where zombies can't be told apart.
+		return true
+	}
+	// The state follows the command name, which is in parentheses and may
+	// contain anything.
+	fields := strings.Fields(string(stat[strings.LastIndex(string(stat), ")")+1:]))
+	return len(fields) == 0 || fields[0] != "Z"
+}
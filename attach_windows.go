@@ -0,0 +1,21 @@
+package testcli
+
+import "syscall"
+
+// stillActive is the exit code of a process which hasn't exited.
+const stillActive = 259
+
+// processAlive reports whether the process pid is still running.
+func processAlive(pid int) bool {
+	const processQueryLimitedInformation = 0x1000
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(h)
+	var code uint32
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
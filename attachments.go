@@ -0,0 +1,156 @@
+package testcli
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AttachmentsEnvVar, when set to a directory, makes every failure reported by
+// this package write an attachment describing the command there, for CI
+// systems to render: command line, environment overrides, exit code,
+// duration, the tail of the output and the paths of related files. The
+// failure message references it as [[ATTACHMENT|path]], which the JUnit
+// attachments plugin of Jenkins, among others, picks up.
+const AttachmentsEnvVar = "TESTCLI_ATTACHMENTS_DIR"
+
+// AttachmentsFormatEnvVar selects the format of the attachments, "json" (the
+// default) or "xml".
+const AttachmentsFormatEnvVar = "TESTCLI_ATTACHMENTS_FORMAT"
+
+// attachmentTailLines is how many lines of each output stream an attachment
+// holds.
+const attachmentTailLines = 50
+
+type attachment struct {
+	XMLName      xml.Name       `json:"-" xml:"testcli-failure"`
+	Test         string         `json:"test" xml:"test,attr"`
+	Message      string         `json:"message" xml:"message"`
+	Command      []string       `json:"command" xml:"command>arg"`
+	Dir          string         `json:"dir,omitempty" xml:"dir,omitempty"`
+	EnvOverrides []string       `json:"env_overrides,omitempty" xml:"env-overrides>var,omitempty"`
+	ExitCode     *int           `json:"exit_code,omitempty" xml:"exit-code,omitempty"`
+	Duration     float64        `json:"duration_seconds" xml:"duration-seconds"`
+	StdoutTail   string         `json:"stdout_tail" xml:"stdout-tail"`
+	StderrTail   string         `json:"stderr_tail" xml:"stderr-tail"`
+	Streams      []streamTail   `json:"streams,omitempty" xml:"streams>stream,omitempty"`
+	Artifacts    []string       `json:"artifacts,omitempty" xml:"artifacts>path,omitempty"`
+	Execution    *ExecutionInfo `json:"execution,omitempty" xml:"execution,omitempty"`
+}
+
+// streamTail is the tail of a named stream, see CaptureNamedPipe().
+type streamTail struct {
+	Name string `json:"name" xml:"name,attr"`
+	Tail string `json:"tail" xml:",chardata"`
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// writeAttachment writes the attachment describing the failure in the
+// directory set by AttachmentsEnvVar, if any, and returns its path.
+func (c *Cmd) writeAttachment(message string) (string, error) {
+	dir := os.Getenv(AttachmentsEnvVar)
+	if dir == "" {
+		return "", nil
+	}
+
+	a := attachment{
+		Test:         c.t.Name(),
+		Message:      message,
+		Command:      c.cmd.Args,
+		Dir:          c.cmd.Dir,
+		EnvOverrides: envDelta(c.cmd.Env, os.Environ()),
+		StdoutTail:   tailLines(c.stdout, attachmentTailLines),
+		StderrTail:   tailLines(c.stderr, attachmentTailLines),
+		Artifacts:    c.artifacts(),
+	}
+	for _, s := range c.streams {
+		a.Streams = append(a.Streams, streamTail{Name: s.pipe.name, Tail: tailLines(s.o, attachmentTailLines)})
+	}
+	if c.status == Finished {
+		code := c.exitCode()
+		a.ExitCode = &code
+	}
+	if !c.startedAt.IsZero() {
+		info := c.execInfo
+		a.Execution = &info
+		end := c.exitedAt
+		if end.IsZero() {
+			end = time.Now()
+		}
+		a.Duration = end.Sub(c.startedAt).Seconds()
+	}
+
+	var data []byte
+	var err error
+	ext := withDefault(os.Getenv(AttachmentsFormatEnvVar), "json")
+	if ext == "xml" {
+		data, err = xml.MarshalIndent(a, "", "  ")
+	} else {
+		ext = "json"
+		data, err = json.MarshalIndent(a, "", "  ")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	base := filepath.Join(dir, unsafeFileChars.ReplaceAllString(c.t.Name(), "_"))
+	path := base + "." + ext
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		path = base + "-" + strconv.Itoa(i) + "." + ext
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return path, nil
+}
+
+// artifacts returns the paths of the files related to the command.
+func (c *Cmd) artifacts() []string {
+	paths := append([]string(nil), c.inputFiles...)
+	if c.stdoutFallback != "" {
+		paths = append(paths, c.stdoutFallback)
+	}
+	for _, o := range []*output{c.stdout, c.stderr} {
+		if f, ok := o.save.(*os.File); ok {
+			paths = append(paths, f.Name())
+		}
+	}
+	return paths
+}
+
+// tailLines returns the last n lines of o.
+func tailLines(o *output, n int) string {
+	tail, _ := lastLines(o, n)
+	return tail
+}
+
+// lastLines returns the last n lines of o, and how many lines it left out.
+func lastLines(o *output, n int) (string, int) {
+	o.mu.Lock()
+	content := o.reportText()
+	o.mu.Unlock()
+	lines := strings.SplitAfter(content, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) <= n {
+		return content, 0
+	}
+	return strings.Join(lines[len(lines)-n:], ""), len(lines) - n
+}
@@ -0,0 +1,125 @@
+package testcli
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func attachmentsDir(t *testing.T, format string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "testcli-attachments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv(AttachmentsEnvVar, dir)
+	os.Setenv(AttachmentsFormatEnvVar, format)
+	t.Cleanup(func() {
+		os.Unsetenv(AttachmentsEnvVar)
+		os.Unsetenv(AttachmentsFormatEnvVar)
+		os.RemoveAll(dir)
+	})
+	return dir
+}
+
+func TestWriteAttachment(t *testing.T) {
+	dir := attachmentsDir(t, "")
+	c := Command(t, "/bin/sh", "-c", "seq 100; echo oops >&2; exit 2")
+	c.SetEnv(append(os.Environ(), "TESTCLI_FOO=bar"))
+	c.Run()
+
+	path, err := c.writeAttachment("boom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := filepath.Join(dir, "TestWriteAttachment.json"); path != expected {
+		t.Fatalf("Expected the attachment at %s, got %s", expected, path)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a attachment
+	if err := json.Unmarshal(data, &a); err != nil {
+		t.Fatal(err)
+	}
+	if a.Message != "boom" || a.ExitCode == nil || *a.ExitCode != 2 || a.StderrTail != "oops\n" {
+		t.Fatalf("Unexpected attachment %s", data)
+	}
+	if len(a.EnvOverrides) != 1 || a.EnvOverrides[0] != "TESTCLI_FOO=bar" {
+		t.Fatalf("Expected the environment overrides, got %v", a.EnvOverrides)
+	}
+	if lines := strings.Count(a.StdoutTail, "\n"); lines != attachmentTailLines || !strings.HasSuffix(a.StdoutTail, "100\n") {
+		t.Fatalf("Expected the last %d lines of stdout, got %q", attachmentTailLines, a.StdoutTail)
+	}
+
+	// A second failure doesn't overwrite the first one.
+	second, err := c.writeAttachment("again")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second != filepath.Join(dir, "TestWriteAttachment-2.json") {
+		t.Fatalf("Expected a new attachment, got %s", second)
+	}
+}
+
+func TestWriteAttachmentXML(t *testing.T) {
+	attachmentsDir(t, "xml")
+	c := Command(t, "sleep", "10")
+	c.Start()
+	defer c.Kill()
+
+	path, err := c.writeAttachment("still running")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a attachment
+	if err := xml.Unmarshal(data, &a); err != nil {
+		t.Fatal(err)
+	}
+	if a.Test != "TestWriteAttachmentXML" || a.ExitCode != nil || len(a.Command) != 2 {
+		t.Fatalf("Unexpected attachment %s", data)
+	}
+}
+
+func TestWriteAttachmentDisabled(t *testing.T) {
+	c := Command(t, "true")
+	c.Run()
+	if path, err := c.writeAttachment("boom"); path != "" || err != nil {
+		t.Fatalf("Expected no attachment without %s, got %q (%v)", AttachmentsEnvVar, path, err)
+	}
+}
+
+// TestAttachmentHelperProcess fails through the package.
+func TestAttachmentHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	c := Command(t, "false")
+	c.Start()
+	c.AssertExits(time.Second, 0)
+}
+
+func TestFailureReferencesAttachment(t *testing.T) {
+	dir := attachmentsDir(t, "")
+	c := ReexecCommand(t, "TestAttachmentHelperProcess")
+	c.Run()
+
+	path := filepath.Join(dir, "TestAttachmentHelperProcess.json")
+	if !c.StdoutContains("[[ATTACHMENT|" + path + "]]") {
+		t.Fatalf("Expected %q to reference the attachment", c.Stdout())
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+}
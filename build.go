@@ -0,0 +1,44 @@
+package testcli
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// Build builds the main package pkg, e.g. "./cmd/mycli", with `go build` and
+// flags, and returns the path of the binary, removed when tb finishes. It's
+// meant to be called once, e.g. from TestMain or before a fuzz target, rather
+// than for each command. Commands running a binary built with -race, -asan or
+// -msan fail on the reports they print, see DetectFailureReports().
+func Build(tb testing.TB, pkg string, flags ...string) string {
+	tb.Helper()
+	dir, err := ioutil.TempDir("", "testcli-build")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	binary := filepath.Join(dir, path.Base(pkg))
+	if runtime.GOOS == "windows" {
+		binary += ".exe"
+	}
+	args := append(append([]string{"build", "-o", binary}, flags...), pkg)
+	out, err := exec.Command("go", args...).CombinedOutput()
+	if err != nil {
+		tb.Fatalf("Failed to build %s: %s\n%s", pkg, err, out)
+	}
+	for _, flag := range flags {
+		if flag == "-race" || flag == "-asan" || flag == "-msan" {
+			sanitizedBinariesMu.Lock()
+			sanitizedBinaries[binary] = true
+			sanitizedBinariesMu.Unlock()
+		}
+	}
+	return binary
+}
@@ -0,0 +1,96 @@
+package testcli
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Builder configures a command through chained calls, so that the simple
+// cases stay one-liners:
+//
+//	testcli.New(t).Dir(tmp).Env("FOO=bar").Timeout(5*time.Second).
+//		Run("mycli", "sync").AssertSuccess().AssertStdoutContains("done")
+//
+// It only collects options, applied by Command(), Run() or Start() to the
+// Cmd they create, which is then used as any other. It configures a single
+// command: once it's created, the builder can't be changed anymore.
+type Builder struct {
+	t    *testing.T
+	opts []Option
+	cmd  *Cmd
+}
+
+// New returns a Builder of a command for the test t.
+func New(t *testing.T) *Builder {
+	return &Builder{t: t}
+}
+
+// With adds opts to the options of the command.
+func (b *Builder) With(opts ...Option) *Builder {
+	b.t.Helper()
+	b.validateNotCreated()
+	b.opts = append(b.opts, opts...)
+	return b
+}
+
+// Dir makes the command run in dir, see SetDir().
+func (b *Builder) Dir(dir string) *Builder {
+	b.t.Helper()
+	return b.With(func(c *Cmd) {
+		c.SetDir(dir)
+	})
+}
+
+// Env sets vars, a list of "key=value", in the environment of the command,
+// see AppendEnv().
+func (b *Builder) Env(vars ...string) *Builder {
+	b.t.Helper()
+	for _, kv := range vars {
+		if !strings.Contains(kv, "=") {
+			b.t.Fatalf("Expected an environment variable as key=value, got %q", kv)
+		}
+	}
+	return b.With(func(c *Cmd) {
+		c.AppendEnv(vars...)
+	})
+}
+
+// Timeout stops the command if it runs longer than d, see WithTimeout().
+func (b *Builder) Timeout(d time.Duration, term ...Termination) *Builder {
+	b.t.Helper()
+	return b.With(WithTimeout(d, term...))
+}
+
+// Command creates the command, without starting it.
+func (b *Builder) Command(name string, arg ...string) *Cmd {
+	b.t.Helper()
+	b.validateNotCreated()
+	b.cmd = Command(b.t, name, arg...)
+	b.cmd.Apply(b.opts...)
+	return b.cmd
+}
+
+// Start creates the command and starts it, see Cmd.Start().
+func (b *Builder) Start(name string, arg ...string) *Cmd {
+	b.t.Helper()
+	c := b.Command(name, arg...)
+	c.Start()
+	return c
+}
+
+// Run creates the command and runs it, see Cmd.Run().
+func (b *Builder) Run(name string, arg ...string) *Cmd {
+	b.t.Helper()
+	c := b.Command(name, arg...)
+	c.Run()
+	return c
+}
+
+// validateNotCreated fails the test if the command was already created.
+func (b *Builder) validateNotCreated() {
+	b.t.Helper()
+	if b.cmd != nil {
+		b.t.Fatalf("The builder already created %s, it must be configured before Command(), Run() or Start()", b.cmd.Repro())
+	}
+}
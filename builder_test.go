@@ -0,0 +1,36 @@
+package testcli
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuilder(t *testing.T) {
+	dir := t.TempDir()
+	c := New(t).Dir(dir).Env("FOO=bar").Timeout(5*time.Second).
+		Run("sh", "-c", `echo "$FOO in $(pwd)"`).
+		AssertSuccess().
+		AssertStdoutContains("bar in " + dir)
+	if c.Status() != Finished {
+		t.Fatalf("Expected the command to be finished, got %q", c.Status())
+	}
+}
+
+// TestBuilderHelperProcess configures a builder after running its command.
+func TestBuilderHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	b := New(t)
+	b.Run("true")
+	b.Env("FOO=bar")
+}
+
+func TestBuilderAfterRun(t *testing.T) {
+	c := ReexecCommand(t, "TestBuilderHelperProcess")
+	c.Run()
+	if c.Success() || !strings.Contains(c.Stdout(), "The builder already created true, it must be configured before") {
+		t.Fatalf("Expected Env() after Run() to fail the test, got %q", c.Stdout())
+	}
+}
@@ -0,0 +1,285 @@
+package testcli
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// NoCacheEnvVar disables RunCached() when set to a non-empty value: commands
+// always run, and the cache is left alone.
+const NoCacheEnvVar = "TESTCLI_NO_CACHE"
+
+// CacheSpec describes a command run by RunCached().
+type CacheSpec struct {
+	Spec
+	// Stdin is written to the command's stdin.
+	Stdin []byte
+	// FingerprintEnv lists the variables of the current environment the
+	// command depends on. Spec.Env and Spec.EnvVars, if set, are always part
+	// of the fingerprint.
+	FingerprintEnv []string
+	// InputFiles are files the command reads. Relative paths are relative to
+	// Spec.Dir, like the ones the command sees.
+	InputFiles []string
+	// OutputFiles are files the command writes. They are saved in the cache,
+	// and restored when the command doesn't run. Relative paths are relative
+	// to Spec.Dir.
+	OutputFiles []string
+}
+
+// RunCached runs a command whose results only depend on its inputs, unless
+// cacheDir holds the results of a previous run with the same fingerprint, in
+// which case they're replayed instead: stdout, stderr, exit code and output
+// files. The fingerprint covers the content of the binary, the arguments, the
+// working directory, the environment, stdin and the content of the input
+// files. See Replayed() and NoCacheEnvVar.
+func RunCached(t *testing.T, spec CacheSpec, cacheDir string) *Cmd {
+	t.Helper()
+	c := spec.Command(t)
+	if os.Getenv(NoCacheEnvVar) != "" {
+		spec.run(c)
+		return c
+	}
+
+	fingerprint, err := spec.fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := spec.dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputFiles := make([]string, len(spec.OutputFiles))
+	for i, path := range spec.OutputFiles {
+		outputFiles[i] = inDir(dir, path)
+	}
+	entry := filepath.Join(cacheDir, fingerprint)
+	if c.replay(entry, outputFiles) == nil {
+		return c
+	}
+
+	spec.run(c)
+	if err := c.record(entry, outputFiles); err != nil {
+		t.Logf("Not caching the results of %s: %s", c.cmd, err)
+	}
+	return c
+}
+
+// Replayed reports whether RunCached() replayed the command's results from
+// the cache rather than running it.
+func (c *Cmd) Replayed() bool {
+	return c.replayed
+}
+
+func (spec CacheSpec) run(c *Cmd) {
+	c.t.Helper()
+	if spec.Stdin != nil {
+		c.SetStdin(bytes.NewReader(spec.Stdin))
+	}
+	c.Run()
+}
+
+// dir returns the absolute working directory of the command.
+func (spec CacheSpec) dir() (string, error) {
+	if spec.Dir == "" {
+		return os.Getwd()
+	}
+	return filepath.Abs(spec.Dir)
+}
+
+// inDir resolves path relative to dir.
+func inDir(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// fingerprint identifies the command and everything it depends on.
+func (spec CacheSpec) fingerprint() (string, error) {
+	dir, err := spec.dir()
+	if err != nil {
+		return "", err
+	}
+	// Like os/exec, names with a separator are paths, relative to the
+	// working directory of the command, and the others are looked up in
+	// PATH.
+	binary := inDir(dir, spec.Name)
+	if !strings.ContainsRune(spec.Name, filepath.Separator) && !strings.ContainsRune(spec.Name, '/') {
+		if binary, err = exec.LookPath(spec.Name); err != nil {
+			return "", err
+		}
+	}
+	binaryHash, err := hashFile(binary)
+	if err != nil {
+		return "", err
+	}
+
+	var env []string
+	for _, key := range spec.FingerprintEnv {
+		env = append(env, key+"="+os.Getenv(key))
+	}
+	stdinHash := sha256.Sum256(spec.Stdin)
+	inputs := make(map[string]string, len(spec.InputFiles))
+	for _, path := range spec.InputFiles {
+		if inputs[path], err = hashFile(inDir(dir, path)); err != nil {
+			return "", err
+		}
+	}
+
+	var stdinFileHash string
+	if spec.StdinFile != "" {
+		if stdinFileHash, err = hashFile(spec.StdinFile); err != nil {
+			return "", err
+		}
+	}
+
+	// The fields added since are omitted when empty, so that fingerprints
+	// don't change for the specs which don't use them.
+	data, err := json.Marshal(struct {
+		Binary      string
+		Dir         string
+		Args        []string
+		Env         []string
+		CurrentEnv  []string
+		Stdin       string
+		Inputs      map[string]string
+		OutputFiles []string
+		EnvVars     []string `json:",omitempty"`
+		StdinFile   string   `json:",omitempty"`
+	}{binaryHash, dir, spec.Args, spec.Env, env, hex.EncodeToString(stdinHash[:]), inputs, spec.OutputFiles, spec.EnvVars, stdinFileHash})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// hashFile returns the hex-encoded SHA-256 of the content of the file at
+// path.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// record saves the results of the finished command in the cache entry.
+func (c *Cmd) record(entry string, outputFiles []string) error {
+	code := c.exitCode()
+	if code < 0 || errors.Is(c.exitError, ErrTimedOut) {
+		return fmt.Errorf("it didn't exit normally: %v", c.exitError)
+	}
+
+	// Built aside, then renamed, so that the entry is never seen incomplete.
+	if err := os.MkdirAll(filepath.Dir(entry), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempDir(filepath.Dir(entry), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	files := map[string][]byte{
+		"stdout":   []byte(c.stdout.content),
+		"stderr":   []byte(c.stderr.content),
+		"exitcode": []byte(strconv.Itoa(code)),
+	}
+	for i, path := range outputFiles {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files["output-"+strconv.Itoa(i)] = content
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(tmp, name), content, 0644); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmp, entry); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return nil
+}
+
+// replay fills in the results of the command from the cache entry, without
+// running it, and restores the output files.
+func (c *Cmd) replay(entry string, outputFiles []string) error {
+	read := func(name string) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join(entry, name))
+	}
+	stdout, err := read("stdout")
+	if err != nil {
+		return err
+	}
+	stderr, err := read("stderr")
+	if err != nil {
+		return err
+	}
+	codeText, err := read("exitcode")
+	if err != nil {
+		return err
+	}
+	code, err := strconv.Atoi(strings.TrimSpace(string(codeText)))
+	if err != nil {
+		return err
+	}
+	outputs := make([][]byte, len(outputFiles))
+	for i := range outputFiles {
+		if outputs[i], err = read("output-" + strconv.Itoa(i)); err != nil {
+			return err
+		}
+	}
+
+	for i, path := range outputFiles {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, outputs[i], 0644); err != nil {
+			return err
+		}
+	}
+
+	c.stdout.write(stdout)
+	c.stderr.write(stderr)
+	if code != 0 {
+		c.exitError = replayedExitError(code)
+	}
+	c.waitErr = c.exitError
+	c.replayed = true
+	close(c.done)
+	c.status = Finished
+	return nil
+}
+
+// replayedExitError is the error of a replayed command which exited with a
+// non-zero code.
+type replayedExitError int
+
+func (e replayedExitError) Error() string {
+	return "exit status " + strconv.Itoa(int(e))
+}
+
+func (e replayedExitError) ExitCode() int {
+	return int(e)
+}
@@ -0,0 +1,112 @@
+package testcli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunCached(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testcli-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	runs := filepath.Join(dir, "runs")
+	output := filepath.Join(dir, "generated", "out.txt")
+	body := `echo run >> "$1"; cat; echo err >&2; mkdir -p "$(dirname "$2")"; echo generated > "$2"; exit 3`
+	binary := writeScript(t, body)
+
+	spec := CacheSpec{
+		Spec:        Spec{Name: binary, Args: []string{runs, output}},
+		Stdin:       []byte("from stdin\n"),
+		OutputFiles: []string{output},
+	}
+	cacheDir := filepath.Join(dir, "cache")
+	run := func() *Cmd {
+		c := RunCached(t, spec, cacheDir)
+		if c.Stdout() != "from stdin\n" || c.Stderr() != "err\n" || c.exitCode() != 3 {
+			c.fatalf("Expected the results of the command, exit code %d", c.exitCode())
+		}
+		if content, err := ioutil.ReadFile(output); err != nil || string(content) != "generated\n" {
+			t.Fatalf("Expected the output file to be there, got %q (%v)", content, err)
+		}
+		return c
+	}
+	countRuns := func() int {
+		content, _ := ioutil.ReadFile(runs)
+		return strings.Count(string(content), "run\n")
+	}
+
+	if run().Replayed() {
+		t.Fatalf("Expected the first run not to be replayed")
+	}
+	os.RemoveAll(filepath.Dir(output))
+	if !run().Replayed() || countRuns() != 1 {
+		t.Fatalf("Expected the second run to be replayed, ran %d times", countRuns())
+	}
+
+	// Same path, different content.
+	if err := ioutil.WriteFile(binary, []byte("#!/bin/sh\n# changed\n"+body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	c := RunCached(t, spec, cacheDir)
+	if c.Replayed() || countRuns() != 2 {
+		t.Fatalf("Expected a change of the binary to invalidate the cache")
+	}
+
+	os.Setenv(NoCacheEnvVar, "1")
+	defer os.Unsetenv(NoCacheEnvVar)
+	if c := RunCached(t, spec, cacheDir); c.Replayed() || countRuns() != 3 {
+		t.Fatalf("Expected %s to disable the cache", NoCacheEnvVar)
+	}
+}
+
+func TestRunCachedFingerprint(t *testing.T) {
+	spec := CacheSpec{Spec: Spec{Name: "true", Args: []string{"a"}}}
+	base, err := spec.fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	variants := map[string]CacheSpec{
+		"args":  {Spec: Spec{Name: "true", Args: []string{"b"}}},
+		"env":   {Spec: Spec{Name: "true", Args: []string{"a"}, Env: []string{"K=v"}}},
+		"stdin": {Spec: Spec{Name: "true", Args: []string{"a"}}, Stdin: []byte("x")},
+		"dir":   {Spec: Spec{Name: "true", Args: []string{"a"}, Dir: t.TempDir()}},
+	}
+	for name, variant := range variants {
+		fingerprint, err := variant.fingerprint()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fingerprint == base {
+			t.Errorf("Expected a change of %s to change the fingerprint", name)
+		}
+	}
+}
+
+func TestRunCachedFingerprintRelativeToDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "input.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	binary := writeScript(t, "exit 0\n")
+	if err := os.Rename(binary, filepath.Join(dir, "script")); err != nil {
+		t.Fatal(err)
+	}
+
+	spec := CacheSpec{Spec: Spec{Name: "./script", Dir: dir}, InputFiles: []string{"input.txt"}}
+	before, err := spec.fingerprint()
+	if err != nil {
+		t.Fatalf("Expected the binary and the input files to be found in Dir: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "input.txt"), []byte("b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if after, err := spec.fingerprint(); err != nil || after == before {
+		t.Fatalf("Expected a change of an input file in Dir to change the fingerprint (%v)", err)
+	}
+}
@@ -0,0 +1,122 @@
+package testcli
+
+import (
+	"strconv"
+	"strings"
+)
+
+// WithCollapsedProgress makes the command's output read as it would look in
+// a terminal once the progress bars are done: the frames a line is
+// overwritten with, separated by "\r", collapse to the last one, and runs of
+// identical lines collapse to one annotated with their count, e.g.
+// "retrying ×12". Stdout(), the assertions, golden files and transcripts see
+// the collapsed output, so that thousands of intermediate frames don't bury
+// what matters. RawStdout() and RawStderr() return the output as captured.
+func WithCollapsedProgress() Option {
+	return func(c *Cmd) {
+		c.collapseProgress = true
+		for _, o := range []*output{c.stdout, c.stderr, c.combined} {
+			if o != nil {
+				o.mu.Lock()
+				o.collapse = true
+				o.mu.Unlock()
+			}
+		}
+	}
+}
+
+// RawStdout returns stdout as captured, without the collapsing of
+// WithCollapsedProgress() nor any other filtering.
+func (c *Cmd) RawStdout() string {
+	c.t.Helper()
+	c.validateHasStarted()
+	c.validateNotCombined()
+	return c.stdout.raw()
+}
+
+// RawStderr is RawStdout() for stderr.
+func (c *Cmd) RawStderr() string {
+	c.t.Helper()
+	c.validateHasStarted()
+	c.validateNotCombined()
+	return c.stderr.raw()
+}
+
+// raw returns the captured content, unfiltered.
+func (o *output) raw() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.spilled() {
+		return o.spilledText()
+	}
+	return o.content
+}
+
+// collapseProgress collapses the progress bars of s, see
+// WithCollapsedProgress(). "\r\n" ends a line rather than overwriting it,
+// unless the line has frames, and a line ending with empty frames, e.g. with
+// "\r", keeps its last visible frame. A final line without a newline is
+// collapsed too, and stays without one.
+func collapseProgress(s string) string {
+	if !strings.Contains(s, "\r") && !strings.Contains(s, "\n") {
+		return s
+	}
+	var b strings.Builder
+	prev, prevEOL, count := "", "", 0
+	flush := func() {
+		if count == 0 {
+			return
+		}
+		b.WriteString(prev)
+		if count > 1 {
+			b.WriteString(" ×" + strconv.Itoa(count))
+		}
+		b.WriteString(prevEOL)
+	}
+	for _, line := range strings.SplitAfter(s, "\n") {
+		if line == "" {
+			continue
+		}
+		body, eol := splitEOL(line)
+		// A progress bar ending its last frame with "\r" before moving on
+		// isn't a CRLF line ending.
+		if eol == "\r\n" && strings.Contains(body, "\r") {
+			eol = "\n"
+		}
+		body = lastFrame(body)
+		// Blank lines are left alone, they separate rather than repeat.
+		if count > 0 && body == prev && body != "" && prevEOL != "" {
+			count++
+			prevEOL = eol
+			continue
+		}
+		flush()
+		prev, prevEOL, count = body, eol, 1
+	}
+	flush()
+	return b.String()
+}
+
+// splitEOL splits line into its content and its line ending, "\n", "\r\n"
+// or none.
+func splitEOL(line string) (string, string) {
+	if strings.HasSuffix(line, "\r\n") {
+		return line[:len(line)-2], "\r\n"
+	}
+	if strings.HasSuffix(line, "\n") {
+		return line[:len(line)-1], "\n"
+	}
+	return line, ""
+}
+
+// lastFrame returns the last non-empty of the frames of line separated by
+// "\r", "" if there's none.
+func lastFrame(line string) string {
+	frames := strings.Split(line, "\r")
+	for i := len(frames) - 1; i >= 0; i-- {
+		if frames[i] != "" {
+			return frames[i]
+		}
+	}
+	return ""
+}
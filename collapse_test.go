@@ -0,0 +1,79 @@
+package testcli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCollapseProgress(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"empty", "", ""},
+		{"plain", "a\nb\n", "a\nb\n"},
+		{"frames", "10%\r50%\r100%\ndone\n", "100%\ndone\n"},
+		{"crlf ends lines", "a\r\nb\r\n", "a\r\nb\r\n"},
+		{"frames before crlf", "1/3\r2/3\r3/3\r\n", "3/3\n"},
+		{"leading cr", "\rloading\n", "loading\n"},
+		{"trailing cr keeps last frame", "10%\r20%\r\n", "20%\n"},
+		{"partial final frame", "ok\n10%\r20%\r30%", "ok\n30%"},
+		{"partial final frame ending with cr", "10%\r20%\r", "20%"},
+		{"empty frames", "\r\r\n", "\n"},
+		{"repeated lines", "retry\nretry\nretry\ndone\n", "retry ×3\ndone\n"},
+		{"repeated frames", "50%\r100%\n100%\n", "100% ×2\n"},
+		{"repeated partial line", "a\na\na", "a ×3"},
+		{"blank lines are kept", "a\n\n\nb\n", "a\n\n\nb\n"},
+		{"mixed endings keep the last", "x\r\nx\n", "x ×2\n"},
+		{"runs", "a\na\nb\na\n", "a ×2\nb\na\n"},
+		{"no newline", "plain", "plain"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := collapseProgress(test.in); got != test.want {
+				t.Fatalf("collapseProgress(%q) = %q, expected %q", test.in, got, test.want)
+			}
+		})
+	}
+}
+
+func TestWithCollapsedProgress(t *testing.T) {
+	script := `for i in 1 2 3 4 5; do printf 'downloading %d/5\r' $i; done; echo; echo done; echo done`
+	c := Command(t, "sh", "-c", script)
+	c.Apply(WithCollapsedProgress())
+	c.Run()
+	if got := c.Stdout(); got != "downloading 5/5\ndone ×2\n" {
+		t.Fatalf("Expected stdout to be collapsed, got %q", got)
+	}
+	if c.StdoutContains("downloading 3/5") {
+		t.Fatalf("Expected the intermediate frames to be hidden from the assertions")
+	}
+	if !c.StdoutMatchesGolden(goldenFile(t, "downloading 5/5\ndone ×2\n")) {
+		return
+	}
+	if raw := c.RawStdout(); !strings.HasPrefix(raw, "downloading 1/5\rdownloading 2/5\r") {
+		t.Fatalf("Expected the raw stdout to keep every frame, got %q", raw)
+	}
+}
+
+func TestCollapsedTranscript(t *testing.T) {
+	c := Command(t, "sh", "-c", `printf '1%%\r50%%\r100%%\n'`)
+	c.Apply(WithCollapsedProgress())
+	c.Run()
+	transcript, err := c.transcriptText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transcript != "100%\n" {
+		t.Fatalf("Expected the transcript to be collapsed, got %q", transcript)
+	}
+}
+
+func TestCollapsedCombinedOutput(t *testing.T) {
+	c := Command(t, "sh", "-c", `printf 'a\rb\n'; echo err >&2`)
+	c.Apply(WithCollapsedProgress())
+	c.CombineOutput()
+	c.Run()
+	if got := c.CombinedOutput(); got != "b\nerr\n" {
+		t.Fatalf("Expected the combined output to be collapsed, got %q", got)
+	}
+}
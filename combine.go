@@ -0,0 +1,61 @@
+package testcli
+
+import "sync"
+
+// CombineOutput makes the command write stdout and stderr to the same pipe,
+// captured as one, like exec.Cmd.CombinedOutput(): their writes keep the
+// order they were made in, e.g. progress on stderr between results on
+// stdout. See CombinedOutput(). The streams can't be told apart then, so
+// Stdout() and Stderr() fail the test, and the assertions on either stream
+// see nothing. It must be called before Run() or Start().
+func (c *Cmd) CombineOutput() {
+	if c.combined == nil {
+		c.combined = &output{mu: &sync.Mutex{}, seq: &c.seq, notify: c.outputChanged, collapse: c.collapseProgress}
+	}
+}
+
+// validateNotCombined fails the test if stdout and stderr are combined.
+func (c *Cmd) validateNotCombined() {
+	c.t.Helper()
+	if c.combined != nil {
+		c.t.Fatal("stdout and stderr are combined by CombineOutput(), see CombinedOutput()")
+	}
+}
+
+// validateCombined fails the test unless stdout and stderr are combined.
+func (c *Cmd) validateCombined() {
+	c.t.Helper()
+	if c.combined == nil {
+		c.t.Fatal("The combined output requires CombineOutput() to be called before the command starts")
+	}
+}
+
+// CombinedOutput returns what the command wrote to stdout and stderr, in
+// order. It requires CombineOutput().
+func (c *Cmd) CombinedOutput() string {
+	c.t.Helper()
+	c.validateHasStarted()
+	c.validateCombined()
+	c.combined.mu.Lock()
+	defer c.combined.mu.Unlock()
+	return c.combined.text()
+}
+
+// CombinedContains determines if the combined output contains `str`, this
+// operation is case insensitive unless CaseSensitive() is passed. It requires
+// CombineOutput().
+func (c *Cmd) CombinedContains(str string, opts ...MatchOption) bool {
+	c.t.Helper()
+	c.validateHasStarted()
+	c.validateCombined()
+	return c.outputContains(c.combined, "output", str, opts)
+}
+
+// CombinedMatches compares a regex to the combined output. It requires
+// CombineOutput().
+func (c *Cmd) CombinedMatches(regex string, opts ...MatchOption) bool {
+	c.t.Helper()
+	c.validateHasStarted()
+	c.validateCombined()
+	return c.outputMatches(c.combined, "output", regex, opts)
+}
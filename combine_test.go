@@ -0,0 +1,45 @@
+package testcli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCombineOutput(t *testing.T) {
+	c := Command(t, "sh", "-c", "echo out 1; echo err 1 >&2; echo out 2; echo err 2 >&2")
+	c.CombineOutput()
+	c.Run()
+
+	if got := c.CombinedOutput(); got != "out 1\nerr 1\nout 2\nerr 2\n" {
+		t.Fatalf("Expected the writes in order, got %q", got)
+	}
+	if !c.CombinedContains("ERR 1") {
+		t.Fatalf("Expected %q to contain %q", c.CombinedOutput(), "ERR 1")
+	}
+	if !c.CombinedMatches(`out 1\nerr 1\n`) {
+		t.Fatalf("Expected %q to match the interleaving", c.CombinedOutput())
+	}
+	if report := c.report(); !strings.Contains(report, "output:\nout 1\nerr 1\n") {
+		t.Fatalf("Expected the report to show the combined output, got %q", report)
+	}
+}
+
+// TestCombineOutputHelperProcess reads stdout of a command combining its
+// output.
+func TestCombineOutputHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	c := Command(t, "echo", "hello")
+	c.CombineOutput()
+	c.Run()
+	c.Stdout()
+}
+
+func TestCombineOutputHidesStreams(t *testing.T) {
+	c := ReexecCommand(t, "TestCombineOutputHelperProcess")
+	c.Run()
+	if c.Success() || !strings.Contains(c.Stdout(), "stdout and stderr are combined by CombineOutput()") {
+		t.Fatalf("Expected Stdout() to fail the test, got %q", c.Stdout())
+	}
+}
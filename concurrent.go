@@ -0,0 +1,103 @@
+package testcli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Overlap describes when RunConcurrently() starts the second command.
+type Overlap struct {
+	// StartBWhenAOutputs starts B once A printed it, on stdout or stderr. B
+	// starts right after A if empty.
+	StartBWhenAOutputs string
+	// Timeout bounds waiting for A to print it, 5 seconds by default.
+	Timeout time.Duration
+}
+
+// ConcurrentResults are the commands run by RunConcurrently(), both finished.
+type ConcurrentResults struct {
+	A, B *Cmd
+	// triggeredAt is when A printed the trigger.
+	triggeredAt time.Time
+}
+
+// RunConcurrently runs a and b overlapping, e.g. two instances of a command
+// working on the same files: it starts a, waits for the trigger described by
+// overlap, starts b while a is still running, and waits for both to exit.
+// The test fails if the trigger doesn't show up, or if a exited before b
+// started, since they then didn't overlap. See Timeline() to tell what
+// happened when.
+func RunConcurrently(t *testing.T, a, b Spec, overlap Overlap) ConcurrentResults {
+	t.Helper()
+	r := ConcurrentResults{A: a.Command(t), B: b.Command(t)}
+	r.A.Start()
+
+	if trigger := overlap.StartBWhenAOutputs; trigger != "" {
+		timeout := overlap.Timeout
+		if timeout == 0 {
+			timeout = Scaled(5 * time.Second)
+		}
+		err := r.A.waitFor(fmt.Sprintf("A to print %q before starting B", trigger), func() bool {
+			var ok bool
+			r.triggeredAt, ok = r.A.outputArrival(trigger)
+			return ok
+		}, 5*time.Millisecond, timeout)
+		if err != nil {
+			r.A.Kill()
+			r.A.fatalf("%s", err)
+		}
+	}
+
+	r.B.Start()
+	if exited, _, _ := r.A.Exited(); exited && r.A.exitedAt.Before(r.B.startedAt) {
+		r.B.Wait()
+		r.A.Wait()
+		t.Fatalf("Expected A to be running when B started, they didn't overlap:\n%s", r.Timeline())
+	}
+	r.B.Wait()
+	r.A.Wait()
+	return r
+}
+
+// outputArrival returns when s was printed, on stdout or stderr.
+func (c *Cmd) outputArrival(s string) (time.Time, bool) {
+	if at, ok := c.stdout.arrival(s); ok {
+		return at, true
+	}
+	return c.stderr.arrival(s)
+}
+
+// Timeline describes what happened when, relative to the start of A, e.g.
+//
+//	T+0ms A started
+//	T+15ms A printed the trigger
+//	T+16ms B started
+//	T+30ms B exited with code 1
+//	T+120ms A exited with code 0
+func (r ConcurrentResults) Timeline() string {
+	type event struct {
+		at   time.Time
+		what string
+	}
+	events := []event{{r.A.startedAt, "A started"}, {r.B.startedAt, "B started"}}
+	if !r.triggeredAt.IsZero() {
+		events = append(events, event{r.triggeredAt, "A printed the trigger"})
+	}
+	for name, c := range map[string]*Cmd{"A": r.A, "B": r.B} {
+		if !c.exitedAt.IsZero() {
+			events = append(events, event{c.exitedAt, fmt.Sprintf("%s exited with code %d", name, c.exitCode())})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].at.Before(events[j].at)
+	})
+
+	var lines []string
+	for _, e := range events {
+		lines = append(lines, fmt.Sprintf("T+%s %s", e.at.Sub(r.A.startedAt).Round(time.Millisecond), e.what))
+	}
+	return strings.Join(lines, "\n")
+}
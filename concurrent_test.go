@@ -0,0 +1,31 @@
+package testcli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRunConcurrently(t *testing.T) {
+	ws := NewWorkspace(t)
+	a := Spec{Name: "flock", Args: []string{"-n", "lock", "-c", "echo acquired lock; sleep 0.3"}, Dir: ws.Root()}
+	b := Spec{Name: "/bin/sh", Args: []string{"-c", "flock -n lock -c true || { echo locked >&2; exit 1; }"}, Dir: ws.Root()}
+
+	r := RunConcurrently(t, a, b, Overlap{StartBWhenAOutputs: "acquired lock"})
+	if !r.A.Success() {
+		t.Fatalf("Expected A to succeed, got %v", r.A.Error())
+	}
+	if !r.B.Failure() || !r.B.StderrContains("locked") {
+		t.Fatalf("Expected B to fail fast on the lock, got %q", r.B.Stderr())
+	}
+
+	timeline := r.Timeline()
+	order := []string{"A started", "A printed the trigger", "B started", "B exited with code 1", "A exited with code 0"}
+	pos := 0
+	for _, event := range order {
+		i := strings.Index(timeline[pos:], event)
+		if i < 0 {
+			t.Fatalf("Expected %q after position %d in the timeline:\n%s", event, pos, timeline)
+		}
+		pos += i
+	}
+}
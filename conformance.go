@@ -0,0 +1,153 @@
+package testcli
+
+import (
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestExecutorConformance checks that the executors returned by newExecutor
+// behave like the local one: lifecycle ordering, exact capture of the output
+// streams, stdin and environment passing, kill and signal semantics, and
+// concurrent access to a running command. The commands it runs (sh, cat,
+// sleep, printf, true) must be available wherever the executor runs them.
+//
+// It's meant to be called from the executor's own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		testcli.TestExecutorConformance(t, func() testcli.Executor {
+//			return myExecutor{}
+//		})
+//	}
+func TestExecutorConformance(t *testing.T, newExecutor func() Executor) {
+	command := func(t *testing.T, name string, arg ...string) *Cmd {
+		c := Command(t, name, arg...)
+		c.SetExecutor(newExecutor())
+		return c
+	}
+
+	t.Run("lifecycle", func(t *testing.T) {
+		c := command(t, "true")
+		if c.status != Initialized {
+			t.Fatalf("Expected status %q before running, got %q", Initialized, c.status)
+		}
+		c.Run()
+		if !c.Success() {
+			c.fatalf("Expected to succeed, but failed with error: %s", c.Error())
+		}
+
+		c = command(t, "sh", "-c", "exit 3")
+		c.Run()
+		if code := c.exitCode(); code != 3 {
+			c.fatalf("Expected exit code 3, got %d (%v)", code, c.Error())
+		}
+
+		c = command(t, "testcli-no-such-command")
+		c.Run()
+		if !c.Failure() {
+			c.fatalf("Expected to fail, but succeeded")
+		}
+
+		c = command(t, "sh", "-c", "sleep 0.1; echo done")
+		c.Start()
+		if c.status != Running {
+			t.Fatalf("Expected status %q after Start(), got %q", Running, c.status)
+		}
+		c.Wait()
+		if !c.Success() || c.Stdout() != "done\n" {
+			c.fatalf("Expected to succeed printing %q", "done\n")
+		}
+	})
+
+	t.Run("capture", func(t *testing.T) {
+		c := command(t, "printf", `a\nb`)
+		c.Run()
+		if got := c.Stdout(); got != "a\nb" {
+			c.fatalf("Expected stdout to be %q, got %q", "a\nb", got)
+		}
+
+		c = command(t, "printf", `\000\001\377\r\n`)
+		c.Run()
+		if got := c.Stdout(); got != "\x00\x01\xff\r\n" {
+			c.fatalf("Expected stdout to be %q, got %q", "\x00\x01\xff\r\n", got)
+		}
+
+		c = command(t, "sh", "-c", "echo out; echo err >&2")
+		c.Run()
+		if c.Stdout() != "out\n" || c.Stderr() != "err\n" {
+			c.fatalf("Expected stdout %q and stderr %q", "out\n", "err\n")
+		}
+	})
+
+	t.Run("stdin", func(t *testing.T) {
+		c := command(t, "cat")
+		c.SetStdin(strings.NewReader("line 1\nline 2"))
+		c.Run()
+		if got := c.Stdout(); got != "line 1\nline 2" {
+			c.fatalf("Expected stdout to be %q, got %q", "line 1\nline 2", got)
+		}
+	})
+
+	t.Run("env", func(t *testing.T) {
+		c := command(t, "sh", "-c", `printf %s "$TESTCLI_FOO"`)
+		c.SetEnv([]string{"TESTCLI_FOO=bar"})
+		c.Run()
+		if got := c.Stdout(); got != "bar" {
+			c.fatalf("Expected stdout to be %q, got %q", "bar", got)
+		}
+	})
+
+	t.Run("kill", func(t *testing.T) {
+		c := command(t, "sleep", "10")
+		c.Start()
+		start := time.Now()
+		c.Kill()
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			c.fatalf("Expected Kill() to return promptly, took %s", elapsed)
+		}
+		if !c.Failure() {
+			c.fatalf("Expected killed command to fail")
+		}
+		if code := c.exitCode(); code != -1 {
+			c.fatalf("Expected exit code -1 for a killed command, got %d", code)
+		}
+	})
+
+	t.Run("signal", func(t *testing.T) {
+		c := command(t, "sh", "-c", `trap 'echo term; exit 0' TERM; echo ready; while :; do sleep 0.05; done`)
+		c.Start()
+		if !c.StdoutContains("ready") {
+			c.Kill()
+			c.fatalf("Expected %q to contain %q", c.Stdout(), "ready")
+		}
+		if err := c.signal(syscall.SIGTERM); err != nil {
+			c.Kill()
+			c.fatalf("Failed to send SIGTERM: %s", err)
+		}
+		c.Wait()
+		if !c.Success() || !strings.Contains(c.Stdout(), "term") {
+			c.fatalf("Expected the TERM handler to run and exit 0")
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		c := command(t, "sh", "-c", "for i in 1 2 3 4 5; do echo line $i; sleep 0.05; done")
+		c.Start()
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.Stdout()
+				c.StdoutContains("line 5")
+			}()
+		}
+		wg.Wait()
+		c.Wait()
+		if got := c.Stdout(); strings.Count(got, "\n") != 5 {
+			c.fatalf("Expected 5 lines, got %q", got)
+		}
+	})
+}
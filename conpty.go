@@ -0,0 +1,89 @@
+package testcli
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrConPTYNotSupported is returned by the executor of ConPTYExecutor() on
+// platforms without a pseudo console, that is everywhere but Windows 10 1809
+// and later.
+var ErrConPTYNotSupported = errors.New("ConPTY is not supported on this platform")
+
+// conPTYColumns and conPTYRows are the size of the pseudo console. It's wide,
+// so that long lines aren't wrapped.
+const (
+	conPTYColumns = 512
+	conPTYRows    = 64
+)
+
+// States of vtStripper.
+const (
+	vtText = iota
+	// vtEscape follows ESC.
+	vtEscape
+	// vtIntermediate follows ESC and intermediate bytes, e.g. "ESC (".
+	vtIntermediate
+	// vtCSI is within a control sequence, "ESC [" up to its final byte.
+	vtCSI
+	// vtOSC is within an operating system command, "ESC ]" up to BEL or ST.
+	vtOSC
+	// vtOSCEscape follows ESC within an operating system command.
+	vtOSCEscape
+)
+
+// vtStripper writes to w what's written to it without the VT sequences a
+// pseudo console adds, e.g. to move the cursor or set the window title, even
+// when they're split across writes.
+type vtStripper struct {
+	w     io.Writer
+	state int
+}
+
+func (s *vtStripper) Write(p []byte) (int, error) {
+	text := make([]byte, 0, len(p))
+	for _, b := range p {
+		switch s.state {
+		case vtText:
+			if b == 0x1b {
+				s.state = vtEscape
+			} else {
+				text = append(text, b)
+			}
+		case vtEscape:
+			switch {
+			case b == '[':
+				s.state = vtCSI
+			case b == ']':
+				s.state = vtOSC
+			case b >= 0x20 && b <= 0x2f:
+				s.state = vtIntermediate
+			default:
+				s.state = vtText
+			}
+		case vtIntermediate:
+			if b < 0x20 || b > 0x2f {
+				s.state = vtText
+			}
+		case vtCSI:
+			if b >= 0x40 && b <= 0x7e {
+				s.state = vtText
+			}
+		case vtOSC:
+			if b == 0x07 {
+				s.state = vtText
+			} else if b == 0x1b {
+				s.state = vtOSCEscape
+			}
+		case vtOSCEscape:
+			s.state = vtOSC
+			if b == '\\' {
+				s.state = vtText
+			}
+		}
+	}
+	if _, err := s.w.Write(text); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package testcli
+
+import "os/exec"
+
+// ConPTYExecutor returns an Executor running commands attached to a pseudo
+// console, ConPTY, on Windows. Anywhere else, starting a command with it
+// fails with ErrConPTYNotSupported.
+func ConPTYExecutor() Executor {
+	return conPTYExecutor{}
+}
+
+type conPTYExecutor struct{}
+
+func (conPTYExecutor) Start(cmd *exec.Cmd) (Process, error) {
+	closeFile(cmd.Stdout)
+	closeFile(cmd.Stderr)
+	return nil, ErrConPTYNotSupported
+}
@@ -0,0 +1,40 @@
+package testcli
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"testing"
+)
+
+func TestVTStripper(t *testing.T) {
+	var out bytes.Buffer
+	s := &vtStripper{w: &out}
+	writes := []string{
+		"\x1b[?25l\x1b[2J\x1b[m\x1b[H",
+		"\x1b]0;C:\\app.exe\x07hello",
+		" \x1b[3",
+		"2mworld\x1b[0m\r\n",
+		"\x1b]0;title\x1b\\\x1b(Bdone\r\n",
+	}
+	for _, w := range writes {
+		if n, err := s.Write([]byte(w)); err != nil || n != len(w) {
+			t.Fatalf("Expected to write %d bytes, wrote %d: %v", len(w), n, err)
+		}
+	}
+	if expected := "hello world\r\ndone\r\n"; out.String() != expected {
+		t.Fatalf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestConPTYExecutorNotSupported(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("ConPTY is supported on Windows")
+	}
+	c := Command(t, "echo", "hello")
+	c.SetExecutor(ConPTYExecutor())
+	c.Run()
+	if !errors.Is(c.Error(), ErrConPTYNotSupported) {
+		t.Fatalf("Expected the error to match ErrConPTYNotSupported, got %v", c.Error())
+	}
+}
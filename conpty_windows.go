@@ -0,0 +1,224 @@
+package testcli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+)
+
+var (
+	createPseudoConsole               = syscall.NewLazyDLL("kernel32.dll").NewProc("CreatePseudoConsole")
+	closePseudoConsole                = syscall.NewLazyDLL("kernel32.dll").NewProc("ClosePseudoConsole")
+	initializeProcThreadAttributeList = syscall.NewLazyDLL("kernel32.dll").NewProc("InitializeProcThreadAttributeList")
+	updateProcThreadAttribute         = syscall.NewLazyDLL("kernel32.dll").NewProc("UpdateProcThreadAttribute")
+	deleteProcThreadAttributeList     = syscall.NewLazyDLL("kernel32.dll").NewProc("DeleteProcThreadAttributeList")
+)
+
+const (
+	extendedStartupInfoPresent       = 0x00080000
+	createUnicodeEnvironment         = 0x00000400
+	procThreadAttributePseudoConsole = 0x00020016
+)
+
+// startupInfoEx is STARTUPINFOEXW, which passes the pseudo console to
+// CreateProcess.
+type startupInfoEx struct {
+	syscall.StartupInfo
+	attributeList *byte
+}
+
+// ConPTYExecutor returns an Executor running commands attached to a pseudo
+// console, ConPTY, which needs Windows 10 1809 or later. Output written to
+// the console directly, e.g. with WriteConsole, is then captured too, which
+// pipes miss. Like on a terminal, stdout and stderr are both captured as
+// stdout, with the VT sequences the pseudo console adds stripped, and lines
+// end with "\r\n". The console is 512 columns wide, longer lines wrap. Stdin
+// is typed into the console, the command never sees it end. Anywhere else,
+// starting a command with it fails with ErrConPTYNotSupported.
+func ConPTYExecutor() Executor {
+	return conPTYExecutor{}
+}
+
+type conPTYExecutor struct{}
+
+func (conPTYExecutor) Start(cmd *exec.Cmd) (Process, error) {
+	stdout, _ := cmd.Stdout.(*os.File)
+	closeFile(cmd.Stderr)
+	if err := createPseudoConsole.Find(); err != nil {
+		closeFile(stdout)
+		return nil, fmt.Errorf("%w: %v", ErrConPTYNotSupported, err)
+	}
+
+	// The pseudo console reads what's typed from inputReader, and writes
+	// what it renders to outputWriter.
+	inputReader, inputWriter, err := os.Pipe()
+	if err != nil {
+		closeFile(stdout)
+		return nil, err
+	}
+	outputReader, outputWriter, err := os.Pipe()
+	if err != nil {
+		inputReader.Close()
+		inputWriter.Close()
+		closeFile(stdout)
+		return nil, err
+	}
+
+	var console syscall.Handle
+	size := uintptr(conPTYColumns) | uintptr(conPTYRows)<<16
+	r, _, _ := createPseudoConsole.Call(size, inputReader.Fd(), outputWriter.Fd(), 0, uintptr(unsafe.Pointer(&console)))
+	// The pseudo console has its own copies.
+	inputReader.Close()
+	outputWriter.Close()
+	if r != 0 {
+		inputWriter.Close()
+		outputReader.Close()
+		closeFile(stdout)
+		return nil, fmt.Errorf("CreatePseudoConsole: HRESULT 0x%08x", r)
+	}
+
+	p := &conPTYProcess{console: console, input: inputWriter, copied: make(chan struct{})}
+	go func() {
+		defer close(p.copied)
+		io.Copy(&vtStripper{w: stdout}, outputReader)
+		outputReader.Close()
+		closeFile(stdout)
+	}()
+
+	pid, err := startInConsole(cmd, console)
+	if err != nil {
+		p.closeConsole()
+		return nil, err
+	}
+	if p.process, err = os.FindProcess(pid); err != nil {
+		p.closeConsole()
+		return nil, err
+	}
+	if cmd.Stdin != nil {
+		go io.Copy(inputWriter, cmd.Stdin)
+	}
+	return p, nil
+}
+
+// startInConsole starts cmd attached to console, and returns its pid.
+func startInConsole(cmd *exec.Cmd, console syscall.Handle) (int, error) {
+	var size uintptr
+	initializeProcThreadAttributeList.Call(0, 1, 0, uintptr(unsafe.Pointer(&size)))
+	list := make([]byte, size)
+	if r, _, err := initializeProcThreadAttributeList.Call(uintptr(unsafe.Pointer(&list[0])), 1, 0, uintptr(unsafe.Pointer(&size))); r == 0 {
+		return 0, fmt.Errorf("InitializeProcThreadAttributeList: %w", err)
+	}
+	defer deleteProcThreadAttributeList.Call(uintptr(unsafe.Pointer(&list[0])))
+	if r, _, err := updateProcThreadAttribute.Call(uintptr(unsafe.Pointer(&list[0])), 0, procThreadAttributePseudoConsole,
+		uintptr(console), unsafe.Sizeof(console), 0, 0); r == 0 {
+		return 0, fmt.Errorf("UpdateProcThreadAttribute: %w", err)
+	}
+
+	si := &startupInfoEx{attributeList: &list[0]}
+	si.Cb = uint32(unsafe.Sizeof(*si))
+	// No standard handles: the command would otherwise get ours instead of
+	// the console's.
+	si.Flags = syscall.STARTF_USESTDHANDLES
+
+	path, err := syscall.UTF16PtrFromString(cmd.Path)
+	if err != nil {
+		return 0, err
+	}
+	commandLine, err := syscall.UTF16PtrFromString(conPTYCommandLine(cmd.Args))
+	if err != nil {
+		return 0, err
+	}
+	var dir *uint16
+	if cmd.Dir != "" {
+		if dir, err = syscall.UTF16PtrFromString(cmd.Dir); err != nil {
+			return 0, err
+		}
+	}
+	var pi syscall.ProcessInformation
+	err = syscall.CreateProcess(path, commandLine, nil, nil, false, extendedStartupInfoPresent|createUnicodeEnvironment,
+		environmentBlock(cmd.Env), dir, &si.StartupInfo, &pi)
+	if err != nil {
+		return 0, &os.PathError{Op: "CreateProcess", Path: cmd.Path, Err: err}
+	}
+	syscall.CloseHandle(pi.Thread)
+	// Held until os.FindProcess() has its own handle, so that the pid can't
+	// be reused in between.
+	defer syscall.CloseHandle(pi.Process)
+	return int(pi.ProcessId), nil
+}
+
+// conPTYCommandLine quotes args into a command line, like os/exec does.
+func conPTYCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = syscall.EscapeArg(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// environmentBlock returns env as CreateProcess takes it, or nil to inherit
+// ours.
+func environmentBlock(env []string) *uint16 {
+	if env == nil {
+		return nil
+	}
+	var block []uint16
+	for _, kv := range env {
+		block = append(block, utf16.Encode([]rune(kv))...)
+		block = append(block, 0)
+	}
+	if len(env) == 0 {
+		block = append(block, 0)
+	}
+	block = append(block, 0)
+	return &block[0]
+}
+
+type conPTYProcess struct {
+	process *os.Process
+	console syscall.Handle
+	input   *os.File
+	// copied is closed once the output of the console is captured.
+	copied    chan struct{}
+	closeOnce sync.Once
+}
+
+// closeConsole closes the pseudo console, which flushes its output and ends
+// it, and waits for the output to be captured.
+func (p *conPTYProcess) closeConsole() {
+	p.closeOnce.Do(func() {
+		closePseudoConsole.Call(uintptr(p.console))
+		p.input.Close()
+	})
+	<-p.copied
+}
+
+func (p *conPTYProcess) Pid() int {
+	return p.process.Pid
+}
+
+func (p *conPTYProcess) Signal(sig os.Signal) error {
+	return signalProcess(p.process, sig)
+}
+
+func (p *conPTYProcess) Kill() error {
+	return p.process.Kill()
+}
+
+func (p *conPTYProcess) Wait() error {
+	state, err := p.process.Wait()
+	p.closeConsole()
+	if err != nil {
+		return err
+	}
+	if !state.Success() {
+		return &exec.ExitError{ProcessState: state}
+	}
+	return nil
+}
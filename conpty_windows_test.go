@@ -0,0 +1,49 @@
+package testcli
+
+import (
+	"syscall"
+	"testing"
+)
+
+// TestWriteConsoleHelperProcess writes to the console directly, bypassing
+// the standard handles.
+func TestWriteConsoleHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	name, _ := syscall.UTF16PtrFromString("CONOUT$")
+	console, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, syscall.FILE_SHARE_WRITE,
+		nil, syscall.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.CloseHandle(console)
+	message, _ := syscall.UTF16FromString("written with WriteConsole\r\n")
+	var written uint32
+	if err := syscall.WriteConsole(console, &message[0], uint32(len(message)-1), &written, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConPTYExecutor(t *testing.T) {
+	c := ReexecCommand(t, "TestWriteConsoleHelperProcess")
+	c.SetExecutor(ConPTYExecutor())
+	c.Run()
+	if !c.Success() {
+		t.Fatalf("Expected to succeed, but failed with error: %s", c.Error())
+	}
+	if !c.StdoutContains("written with WriteConsole") {
+		t.Fatalf("Expected the console output to be captured, got %q", c.Stdout())
+	}
+	if c.StdoutContains("\x1b[") {
+		t.Fatalf("Expected the VT sequences to be stripped, got %q", c.Stdout())
+	}
+}
+
+func TestWriteConsoleMissedByPipes(t *testing.T) {
+	c := ReexecCommand(t, "TestWriteConsoleHelperProcess")
+	c.Run()
+	if c.StdoutContains("written with WriteConsole") {
+		t.Fatalf("Expected pipes not to capture the console output, got %q", c.Stdout())
+	}
+}
@@ -0,0 +1,71 @@
+package testcli
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// CommandContext is like Command(), but the command is stopped if ctx is done
+// before it exits, e.g. when the test cancels it. It's stopped like a command
+// timing out, see WithTimeout(): SIGTERM then SIGKILL 2 seconds later unless
+// another Termination is set, reaching its whole process group on Unix. Error() then matches the
+// error of ctx, context.Canceled or context.DeadlineExceeded, as well as the
+// error the command exited with, and the output captured until then stays
+// available. To stop a command started with Start() when the test ends:
+//
+//	ctx, cancel := context.WithCancel(context.Background())
+//	t.Cleanup(cancel)
+//	c := testcli.CommandContext(t, ctx, "server")
+//
+// or, to stop it before the test times out, derive ctx from t.Deadline().
+func CommandContext(t *testing.T, ctx context.Context, name string, arg ...string) *Cmd {
+	if ctx == nil {
+		panic("testcli: nil Context")
+	}
+	c := Command(t, name, arg...)
+	c.ctx = ctx
+	if c.timeout == 0 {
+		c.termination = defaultTermination()
+	}
+	return c
+}
+
+// watchContext stops the command once its context is done.
+func (c *Cmd) watchContext() {
+	select {
+	case <-c.done:
+	case <-c.ctx.Done():
+		c.terminationMu.Lock()
+		c.ctxErr = c.ctx.Err()
+		c.terminationMu.Unlock()
+		c.terminate(c.termination, TerminationReason{})
+	}
+}
+
+// contextError is the error of a command killed by the context of
+// CommandContext(), wrapping the error it exited with.
+type contextError struct {
+	ctxErr error
+	err    error
+}
+
+func (e *contextError) Error() string {
+	if e.err == nil {
+		return e.ctxErr.Error()
+	}
+	return fmt.Sprintf("%s: %s", e.ctxErr, e.err)
+}
+
+func (e *contextError) Unwrap() error {
+	return e.err
+}
+
+func (e *contextError) Is(target error) bool {
+	return target == e.ctxErr
+}
+
+// ExitCode is the exit status of the command, see exitCodeOf().
+func (e *contextError) ExitCode() int {
+	return exitCodeOf(e.err)
+}
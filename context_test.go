@@ -0,0 +1,75 @@
+package testcli
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCommandContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := CommandContext(t, ctx, "sh", "-c", "echo started; exec sleep 10")
+	c.Start()
+	if !c.StdoutContains("started") {
+		t.Fatalf("Expected %q to contain %q", c.Stdout(), "started")
+	}
+	cancel()
+	c.Wait()
+
+	if !errors.Is(c.Error(), context.Canceled) {
+		t.Fatalf("Expected the error to match context.Canceled, got %v", c.Error())
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(c.Error(), &exitErr) || c.ExitCode() != -1 {
+		t.Fatalf("Expected the error to wrap the exit error, got %v (%d)", c.Error(), c.ExitCode())
+	}
+	if c.Stdout() != "started\n" {
+		t.Fatalf("Expected the output until the cancellation, got %q", c.Stdout())
+	}
+}
+
+func TestCommandContextTerminates(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := CommandContext(t, ctx, "sh", "-c", `trap "echo terminated; exit 1" TERM; echo started; while :; do sleep 0.1; done`)
+	c.Start()
+	if !c.StdoutContains("started") {
+		t.Fatalf("Expected %q to contain %q", c.Stdout(), "started")
+	}
+	cancel()
+	c.Wait()
+
+	if !c.StdoutContains("terminated") {
+		t.Fatalf("Expected the command to be sent SIGTERM first, got %q", c.Stdout())
+	}
+	if reason := c.TerminationReason(); reason.Signal != syscall.SIGTERM || reason.Killed {
+		t.Fatalf("Expected the command to exit on SIGTERM, got %+v", reason)
+	}
+	if !errors.Is(c.Error(), context.Canceled) {
+		t.Fatalf("Expected the error to match context.Canceled, got %v", c.Error())
+	}
+}
+
+func TestCommandContextDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	c := CommandContext(t, ctx, "sleep", "10")
+	c.Run()
+	if !errors.Is(c.Error(), context.DeadlineExceeded) {
+		t.Fatalf("Expected the error to match context.DeadlineExceeded, got %v", c.Error())
+	}
+}
+
+func TestCommandContextNotDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := CommandContext(t, ctx, "true")
+	c.Run()
+	cancel()
+	if !c.Success() {
+		t.Fatalf("Expected to succeed, but failed with error: %s", c.Error())
+	}
+}
@@ -0,0 +1,136 @@
+package testcli
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Conventions describes the behavior every CLI is expected to follow. The zero
+// value checks the usual defaults.
+type Conventions struct {
+	// VersionFlag defaults to "--version". The command must exit 0 and print
+	// exactly one line, matching VersionPattern, to stdout.
+	VersionFlag string
+	// VersionPattern defaults to a semantic version anywhere in the line.
+	VersionPattern string
+
+	// HelpFlag defaults to "--help". The command must exit 0 and print its
+	// help to stdout, leaving stderr empty.
+	HelpFlag string
+
+	// UnknownFlag defaults to "--testcli-unknown-flag". The command must exit
+	// with UnknownFlagExitCode (2 if zero) and print something matching
+	// UsagePattern to stderr.
+	UnknownFlag         string
+	UnknownFlagExitCode int
+	// UsagePattern defaults to "(?i)usage".
+	UsagePattern string
+
+	// Skip lists the rules not to check: "version", "help" or "unknown-flag".
+	Skip []string
+}
+
+// semverPattern matches a semantic version, with an optional "v" prefix.
+const semverPattern = `\bv?\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?\b`
+
+type conventionRule struct {
+	name  string
+	flag  string
+	check func(c *Cmd) error
+}
+
+func (conv Conventions) rules() []conventionRule {
+	versionFlag := withDefault(conv.VersionFlag, "--version")
+	versionRe := regexp.MustCompile(withDefault(conv.VersionPattern, semverPattern))
+	helpFlag := withDefault(conv.HelpFlag, "--help")
+	unknownFlag := withDefault(conv.UnknownFlag, "--testcli-unknown-flag")
+	usageRe := regexp.MustCompile(withDefault(conv.UsagePattern, "(?i)usage"))
+	unknownCode := conv.UnknownFlagExitCode
+	if unknownCode == 0 {
+		unknownCode = 2
+	}
+
+	return []conventionRule{
+		{"version", versionFlag, func(c *Cmd) error {
+			if code := c.exitCode(); code != 0 {
+				return fmt.Errorf("Expected %s to exit 0, got %d", versionFlag, code)
+			}
+			var lines []string
+			if stdout := lockedText(c.stdout); stdout != "" {
+				lines = strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+			}
+			if len(lines) != 1 || lines[0] == "" {
+				return fmt.Errorf("Expected %s to print exactly one line, got %d", versionFlag, len(lines))
+			}
+			if !versionRe.MatchString(lines[0]) {
+				return fmt.Errorf("Expected %q to match %q", lines[0], versionRe)
+			}
+			return nil
+		}},
+		{"help", helpFlag, func(c *Cmd) error {
+			if code := c.exitCode(); code != 0 {
+				return fmt.Errorf("Expected %s to exit 0, got %d", helpFlag, code)
+			}
+			if lockedText(c.stdout) == "" {
+				return fmt.Errorf("Expected %s to print to stdout", helpFlag)
+			}
+			if lockedText(c.stderr) != "" {
+				return fmt.Errorf("Expected %s to leave stderr empty", helpFlag)
+			}
+			return nil
+		}},
+		{"unknown-flag", unknownFlag, func(c *Cmd) error {
+			if code := c.exitCode(); code != unknownCode {
+				return fmt.Errorf("Expected %s to exit %d, got %d", unknownFlag, unknownCode, code)
+			}
+			if !usageRe.MatchString(lockedText(c.stderr)) {
+				return fmt.Errorf("Expected stderr to match %q", usageRe)
+			}
+			return nil
+		}},
+	}
+}
+
+// lockedText returns the text of o as the assertions see it.
+func lockedText(o *output) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.text()
+}
+
+// CheckCLIConventions runs binary once per rule in conv, each as a subtest
+// named after the rule, failing the subtests whose rule doesn't hold.
+func CheckCLIConventions(t *testing.T, binary string, conv Conventions) {
+	t.Helper()
+	for _, rule := range conv.rules() {
+		if containsString(conv.Skip, rule.name) {
+			continue
+		}
+		rule := rule
+		t.Run(rule.name, func(t *testing.T) {
+			c := Command(t, binary, rule.flag)
+			c.Run()
+			if err := rule.check(c); err != nil {
+				c.fatalf("%s", err)
+			}
+		})
+	}
+}
+
+func withDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package testcli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeScript writes an executable shell script to a temporary directory and
+// returns its path.
+func writeScript(t *testing.T, body string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "testcli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "script")
+	if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheckCLIConventions(t *testing.T) {
+	binary := writeScript(t, `
+case "$1" in
+--version) echo "mycli version v1.2.3";;
+--help) echo "usage: mycli [flags]";;
+*) echo "usage: mycli [flags]" >&2; exit 2;;
+esac
+`)
+	CheckCLIConventions(t, binary, Conventions{})
+}
+
+func TestCLIConventionsViolations(t *testing.T) {
+	binary := writeScript(t, `
+case "$1" in
+--version) echo "mycli"; echo "1.2.3";;
+--help) echo "usage: mycli [flags]" >&2;;
+*) exit 1;;
+esac
+`)
+	for _, rule := range (Conventions{}).rules() {
+		c := Command(t, binary, rule.flag)
+		c.Run()
+		if err := rule.check(c); err == nil {
+			t.Fatalf("Expected rule %q to fail", rule.name)
+		}
+	}
+}
+
+func TestCLIConventionsCustomExpectations(t *testing.T) {
+	binary := writeScript(t, `
+case "$1" in
+-V) echo "2021.04";;
+*) echo "bad flag" >&2; exit 64;;
+esac
+`)
+	CheckCLIConventions(t, binary, Conventions{
+		VersionFlag:         "-V",
+		VersionPattern:      `^\d{4}\.\d{2}$`,
+		UnknownFlagExitCode: 64,
+		UsagePattern:        "bad flag",
+		Skip:                []string{"help"},
+	})
+}
+
+func TestCLIConventionsEmptyVersion(t *testing.T) {
+	binary := writeScript(t, "exit 0\n")
+	c := Command(t, binary, "--version")
+	c.Run()
+	err := (Conventions{}).rules()[0].check(c)
+	if expected := "Expected --version to print exactly one line, got 0"; err == nil || err.Error() != expected {
+		t.Fatalf("Expected %q, got %v", expected, err)
+	}
+}
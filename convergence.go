@@ -0,0 +1,84 @@
+package testcli
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+// ConvergenceOption configures CheckCleanConvergence().
+type ConvergenceOption func(*convergenceConfig)
+
+type convergenceConfig struct {
+	// flagAt is where the dry-run flag is inserted among the arguments, -1
+	// to append it.
+	flagAt   int
+	attempts int
+	interval time.Duration
+}
+
+// DryRunFlagAt inserts the dry-run flag before the argument at index i rather
+// than after the last one, e.g. 0 for a global flag preceding the
+// subcommand.
+func DryRunFlagAt(i int) ConvergenceOption {
+	return func(cfg *convergenceConfig) {
+		cfg.flagAt = i
+	}
+}
+
+// RetryDryRun runs the dry run up to attempts times, interval apart, until it
+// reports nothing pending, for commands whose changes settle asynchronously.
+// It runs once by default.
+func RetryDryRun(attempts int, interval time.Duration) ConvergenceOption {
+	return func(cfg *convergenceConfig) {
+		cfg.attempts = attempts
+		cfg.interval = interval
+	}
+}
+
+// CheckCleanConvergence runs the command, then runs it again with dryRunFlag,
+// and fails the test, showing both runs, unless the first one succeeds and
+// the dry run exits 0 with stdout or stderr matching nothingPendingPattern:
+// after a real run, there should be nothing left to do.
+func CheckCleanConvergence(t *testing.T, spec Spec, dryRunFlag, nothingPendingPattern string, opts ...ConvergenceOption) {
+	t.Helper()
+	cfg := &convergenceConfig{flagAt: -1, attempts: 1}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	nothingPending := regexp.MustCompile(nothingPendingPattern)
+
+	first := spec.Command(t)
+	first.Run()
+	if first.exitCode() != 0 {
+		first.fatalf("Expected the real run to succeed, got %v", first.exitError)
+	}
+
+	dry := spec
+	dry.Args = insertArg(spec.Args, cfg.flagAt, dryRunFlag)
+	var c *Cmd
+	for attempt := 1; ; attempt++ {
+		c = dry.Command(t)
+		c.Run()
+		if c.exitCode() == 0 && (nothingPending.MatchString(c.Stdout()) || nothingPending.MatchString(c.Stderr())) {
+			return
+		}
+		if attempt >= cfg.attempts {
+			break
+		}
+		time.Sleep(cfg.interval)
+	}
+	t.Fatalf("Expected the dry run to exit 0 and report nothing pending, matching %q, after %d attempts\nreal run:\n%s\ndry run:\n%s",
+		nothingPendingPattern, cfg.attempts, first.report(), c.report())
+}
+
+// insertArg returns a copy of args with arg inserted before index i, or
+// appended if i is out of range.
+func insertArg(args []string, i int, arg string) []string {
+	if i < 0 || i > len(args) {
+		i = len(args)
+	}
+	inserted := append([]string(nil), args[:i]...)
+	inserted = append(inserted, arg)
+	return append(inserted, args[i:]...)
+}
@@ -0,0 +1,63 @@
+package testcli
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// purgeScript deletes the *.tmp files of its directory, or lists them with
+// --dry-run, wherever the flag is. With leak set, it leaves one behind.
+const purgeScript = `
+dry=
+for arg; do [ "$arg" = --dry-run ] && dry=1; done
+if [ -n "$dry" ]; then
+	pending=$(ls *.tmp 2>/dev/null | wc -l)
+	echo "$pending pending changes"
+	exit 0
+fi
+rm -f *.tmp
+[ -n "$LEAK" ] && touch leaked.tmp
+exit 0`
+
+func TestCheckCleanConvergence(t *testing.T) {
+	ws := NewWorkspace(t)
+	ws.WriteFile("a.tmp", nil)
+	ws.WriteFile("b.tmp", nil)
+	spec := Spec{Name: "/bin/sh", Args: []string{"-c", purgeScript, "mycli", "purge"}, Dir: ws.Root()}
+	CheckCleanConvergence(t, spec, "--dry-run", `\b0 pending changes`)
+	CheckCleanConvergence(t, spec, "--dry-run", `\b0 pending changes`, DryRunFlagAt(3))
+}
+
+func TestInsertArg(t *testing.T) {
+	args := []string{"purge", "--all"}
+	for i, want := range map[int]string{0: "-n purge --all", 1: "purge -n --all", -1: "purge --all -n", 5: "purge --all -n"} {
+		if got := strings.Join(insertArg(args, i, "-n"), " "); got != want {
+			t.Errorf("Expected %q at %d, got %q", want, i, got)
+		}
+	}
+	if strings.Join(args, " ") != "purge --all" {
+		t.Fatalf("Expected the arguments to be left alone, got %q", args)
+	}
+}
+
+// TestCheckCleanConvergenceHelperProcess purges with a command leaving a
+// file behind.
+func TestCheckCleanConvergenceHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	ws := NewWorkspace(t)
+	spec := Spec{Name: "/bin/sh", Args: []string{"-c", purgeScript, "mycli"}, Dir: ws.Root(), EnvVars: []string{"LEAK=1"}}
+	CheckCleanConvergence(t, spec, "--dry-run", `\b0 pending changes`, RetryDryRun(2, 10*time.Millisecond))
+}
+
+func TestCheckCleanConvergenceFails(t *testing.T) {
+	c := ReexecCommand(t, "TestCheckCleanConvergenceHelperProcess")
+	c.Run()
+	for _, want := range []string{"report nothing pending", "after 2 attempts", "real run:", "dry run:", "1 pending changes"} {
+		if !strings.Contains(c.Stdout(), want) {
+			t.Fatalf("Expected %q to contain %q", c.Stdout(), want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package testcli
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Decoder names a decoding layer for the raw output of a command, see
+// StdoutDecoded() and RegisterDecoder().
+type Decoder string
+
+// The built-in decoders. No zstd decoder comes with the standard library, so
+// ZstdDecoder has to be registered before use, e.g. with
+// github.com/klauspost/compress/zstd:
+//
+//	testcli.RegisterDecoder(testcli.ZstdDecoder, func(b []byte) ([]byte, error) {
+//		d, _ := zstd.NewReader(nil)
+//		defer d.Close()
+//		return d.DecodeAll(b, nil)
+//	})
+const (
+	GzipDecoder Decoder = "gzip"
+	ZstdDecoder Decoder = "zstd"
+)
+
+// decodeDumpSize is how many bytes of a stream that fails to decode are shown.
+const decodeDumpSize = 64
+
+var (
+	decodersMu sync.Mutex
+	decoders   = map[Decoder]func([]byte) ([]byte, error){
+		GzipDecoder: decodeGzip,
+	}
+)
+
+// RegisterDecoder makes decode available as the decoder name, replacing the
+// one registered under that name if any.
+func RegisterDecoder(name Decoder, decode func([]byte) ([]byte, error)) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders[name] = decode
+}
+
+func decodeGzip(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// DecodedOutput is the output of a finished command after decoding, see
+// StdoutDecoded().
+type DecodedOutput struct {
+	c       *Cmd
+	stream  string
+	content string
+}
+
+// StdoutDecoded decodes the raw stdout of the finished command with the
+// decoder d. The test fails, showing the decoder error and the first bytes of
+// stdout, if it can't be decoded.
+func (c *Cmd) StdoutDecoded(d Decoder) *DecodedOutput {
+	c.t.Helper()
+	c.validateIsFinished()
+	c.validateNotCombined()
+	return c.decoded(c.stdout, "stdout", d)
+}
+
+// StdoutDecoded decodes the raw stdout of the finished command with the
+// decoder d. The test fails, showing the decoder error and the first bytes of
+// stdout, if it can't be decoded.
+func StdoutDecoded(d Decoder) *DecodedOutput {
+	c := pkgCmd()
+	c.t.Helper()
+	return c.StdoutDecoded(d)
+}
+
+// StderrDecoded is StdoutDecoded() for stderr.
+func (c *Cmd) StderrDecoded(d Decoder) *DecodedOutput {
+	c.t.Helper()
+	c.validateIsFinished()
+	c.validateNotCombined()
+	return c.decoded(c.stderr, "stderr", d)
+}
+
+// StderrDecoded is StdoutDecoded() for stderr.
+func StderrDecoded(d Decoder) *DecodedOutput {
+	c := pkgCmd()
+	c.t.Helper()
+	return c.StderrDecoded(d)
+}
+
+func (c *Cmd) decoded(o *output, stream string, d Decoder) *DecodedOutput {
+	c.t.Helper()
+	o.mu.Lock()
+	raw := []byte(o.content)
+	if o.spilled() {
+		r, done := o.reader(0)
+		raw, _ = ioutil.ReadAll(r)
+		done()
+	}
+	o.mu.Unlock()
+
+	content, err := decode(raw, d)
+	if err != nil {
+		c.fatalf("Can't decode %s as %s: %s", stream, d, err)
+	}
+	return &DecodedOutput{c: c, stream: stream, content: string(content)}
+}
+
+// decode decodes raw with the decoder d. Its error shows the first bytes of
+// raw.
+func decode(raw []byte, d Decoder) ([]byte, error) {
+	decodersMu.Lock()
+	fn := decoders[d]
+	decodersMu.Unlock()
+	if fn == nil {
+		return nil, fmt.Errorf("no decoder registered as %q, see RegisterDecoder()", d)
+	}
+	content, err := fn(raw)
+	if err != nil {
+		head := raw
+		if len(head) > decodeDumpSize {
+			head = head[:decodeDumpSize]
+		}
+		return nil, fmt.Errorf("%s\nfirst %d of %d bytes:\n%s", err, len(head), len(raw), hex.Dump(head))
+	}
+	return content, nil
+}
+
+// String returns the decoded output.
+func (d *DecodedOutput) String() string {
+	return d.content
+}
+
+// Contains determines if the decoded output contains `str`, this operation is
+// case insensitive unless CaseSensitive() is passed.
+func (d *DecodedOutput) Contains(str string, opts ...MatchOption) bool {
+	if matchOptions(opts).caseSensitive {
+		return strings.Contains(d.content, str)
+	}
+	return strings.Contains(strings.ToLower(d.content), strings.ToLower(str))
+}
+
+// Matches compares a regex to the decoded output.
+func (d *DecodedOutput) Matches(regex string, opts ...MatchOption) bool {
+	re := regexp.MustCompile(regex)
+	if matchOptions(opts).caseSensitive {
+		return re.MatchString(d.content)
+	}
+	return re.MatchString(strings.ToLower(d.content))
+}
+
+// AssertContains fails the test unless the decoded output contains `str`, see
+// Contains().
+func (d *DecodedOutput) AssertContains(str string, opts ...MatchOption) {
+	d.c.t.Helper()
+	if !d.Contains(str, opts...) {
+		d.c.fatalf("Expected the decoded %s to contain %q, got:\n%s", d.stream, str, d.content)
+	}
+}
+
+// AssertGzipStdoutContains fails the test unless the finished command's
+// stdout, decompressed with gzip, contains `str`, this operation is case
+// insensitive.
+func (c *Cmd) AssertGzipStdoutContains(str string) {
+	c.t.Helper()
+	c.StdoutDecoded(GzipDecoder).AssertContains(str)
+}
+
+// AssertGzipStdoutContains fails the test unless the finished command's
+// stdout, decompressed with gzip, contains `str`, this operation is case
+// insensitive.
+func AssertGzipStdoutContains(str string) {
+	c := pkgCmd()
+	c.t.Helper()
+	c.AssertGzipStdoutContains(str)
+}
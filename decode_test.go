@@ -0,0 +1,64 @@
+package testcli
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestStdoutDecodedGzip(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", "printf 'record-122\\nRecord-123\\n' | gzip -c")
+	c.Run()
+
+	if c.StdoutContains("record-123") {
+		t.Fatalf("Expected the raw stdout to be compressed")
+	}
+	decoded := c.StdoutDecoded(GzipDecoder)
+	if !decoded.Contains("record-123") || decoded.Contains("record-123", CaseSensitive()) {
+		t.Fatalf("Expected %q to contain %q, ignoring the case only", decoded, "record-123")
+	}
+	if !decoded.Matches(`(?m)^record-12[23]$`) {
+		t.Fatalf("Expected %q to match", decoded)
+	}
+	c.AssertGzipStdoutContains("record-122")
+}
+
+func TestRegisterDecoder(t *testing.T) {
+	RegisterDecoder("base64", func(b []byte) ([]byte, error) {
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+	})
+	c := Command(t, "echo", base64.StdEncoding.EncodeToString([]byte("hello")))
+	c.Run()
+	if got := c.StdoutDecoded("base64").String(); got != "hello" {
+		t.Fatalf("Expected %q, got %q", "hello", got)
+	}
+}
+
+// TestStdoutDecodedCorruptHelperProcess decodes stdout which isn't gzip.
+func TestStdoutDecodedCorruptHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	c := Command(t, "echo", "this is not gzip")
+	c.Run()
+	c.StdoutDecoded(GzipDecoder)
+}
+
+func TestStdoutDecodedCorrupt(t *testing.T) {
+	c := ReexecCommand(t, "TestStdoutDecodedCorruptHelperProcess")
+	c.Run()
+	if c.Success() {
+		t.Fatalf("Expected the test to fail")
+	}
+	for _, want := range []string{"Can't decode stdout as gzip", "gzip: invalid header", "74 68 69 73 20 69 73 20"} {
+		if !strings.Contains(c.Stdout(), want) {
+			t.Fatalf("Expected %q to contain %q", c.Stdout(), want)
+		}
+	}
+}
+
+func TestStdoutDecodedUnregistered(t *testing.T) {
+	if _, err := decode([]byte("x"), ZstdDecoder); err == nil || !strings.Contains(err.Error(), "RegisterDecoder") {
+		t.Fatalf("Expected zstd to require registering a decoder, got %v", err)
+	}
+}
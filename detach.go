@@ -0,0 +1,94 @@
+package testcli
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// detachGrace is how long after its stdout is closed the process must still
+// be running for stdout to be considered detached rather than done.
+const detachGrace = 100 * time.Millisecond
+
+// StdoutDetached reports whether the command closed its stdout while still
+// running, e.g. because it redirected its output to a log file. Nothing it
+// writes afterwards is captured, unless SetStdoutFallback() was used.
+func (c *Cmd) StdoutDetached() bool {
+	c.t.Helper()
+	c.validateHasStarted()
+	_, ok := c.stdoutDetachedAt()
+	return ok
+}
+
+// SetStdoutFallback makes the command capture what's written to the file at
+// path as its stdout, once the command detaches its stdout (see
+// StdoutDetached()). It must be called before Run() or Start().
+func (c *Cmd) SetStdoutFallback(path string) {
+	c.stdoutFallback = path
+}
+
+func (c *Cmd) stdoutDetachedAt() (time.Time, bool) {
+	c.detachMu.Lock()
+	defer c.detachMu.Unlock()
+	return c.detachedAt, !c.detachedAt.IsZero()
+}
+
+// checkStdoutDetached is called when stdout reached EOF, at eof.
+func (c *Cmd) checkStdoutDetached(eof time.Time) {
+	select {
+	case <-c.done:
+		return
+	case <-time.After(Scaled(detachGrace)):
+	}
+
+	c.detachMu.Lock()
+	c.detachedAt = eof
+	c.detachMu.Unlock()
+
+	if c.stdoutFallback != "" {
+		c.capture.Add(1)
+		go func() {
+			defer c.capture.Done()
+			follow(c.stdoutFallback, c.stdout, c.done)
+		}()
+	}
+}
+
+// follow copies what's appended to the file at path to o, until done is
+// closed.
+func follow(path string, o *output, done <-chan struct{}) {
+	var offset int64
+	read := func() {
+		f, err := os.Open(path)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return
+		}
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := f.Read(buf)
+			if n > 0 {
+				o.write(buf[:n])
+				offset += int64(n)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			read()
+		case <-done:
+			read()
+			return
+		}
+	}
+}
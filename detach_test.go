@@ -0,0 +1,46 @@
+package testcli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStdoutDetached(t *testing.T) {
+	ws := NewWorkspace(t)
+	c := ws.Command("/bin/bash", "-c", "echo started; exec > log.txt; echo moved; sleep 0.5")
+	c.Run()
+	if !c.StdoutDetached() {
+		t.Fatalf("Expected stdout to be detached")
+	}
+
+	if c.StdoutContains("moved") {
+		t.Fatalf("Expected %q not to contain %q", c.Stdout(), "moved")
+	}
+
+	if !strings.Contains(c.report(), "stdout was closed by the child") {
+		t.Fatalf("Expected the failure report to mention it, got %q", c.report())
+	}
+}
+
+func TestStdoutNotDetached(t *testing.T) {
+	c := Command(t, "/bin/bash", "-c", "echo started; sleep 0.2")
+	c.Run()
+	if c.StdoutDetached() {
+		t.Fatalf("Expected stdout not to be detached")
+	}
+}
+
+func TestStdoutFallback(t *testing.T) {
+	ws := NewWorkspace(t)
+	c := ws.Command("/bin/bash", "-c", "echo started; exec > log.txt; sleep 0.2; echo moved; sleep 0.2")
+	c.SetStdoutFallback(ws.Path("log.txt"))
+	c.Start()
+	if !c.StdoutContains("moved") {
+		t.Fatalf("Expected %q to contain %q", c.Stdout(), "moved")
+	}
+	c.Wait()
+
+	if c.Stdout() != "started\nmoved\n" {
+		t.Fatalf("Expected %q to be %q", c.Stdout(), "started\nmoved\n")
+	}
+}
@@ -0,0 +1,149 @@
+package testcli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContextLines is how many unchanged lines surround the changes in a
+// unified diff.
+const diffContextLines = 3
+
+// maxDiffCells bounds the table of the line diff, beyond which the whole
+// contents are shown as replaced rather than diffed.
+const maxDiffCells = 4 << 20
+
+// unifiedDiff returns the line diff of expected and actual in the unified
+// format, empty if they're equal.
+func unifiedDiff(expected, actual string) string {
+	if expected == actual {
+		return ""
+	}
+	a, b := strings.SplitAfter(expected, "\n"), strings.SplitAfter(actual, "\n")
+	ops := diffLines(a, b)
+
+	var out strings.Builder
+	out.WriteString("--- expected\n+++ actual\n")
+	for start := 0; start < len(ops); {
+		// Find the next change, and the extent of its hunk.
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		from := start - diffContextLines
+		if from < 0 {
+			from = 0
+		}
+		end := start
+		for unchanged := 0; end < len(ops) && unchanged <= 2*diffContextLines; end++ {
+			if ops[end].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+		// Leave at most diffContextLines of context after the last change.
+		for end > start && ops[end-1].kind == ' ' {
+			end--
+		}
+		if end += diffContextLines; end > len(ops) {
+			end = len(ops)
+		}
+
+		aStart, aLen, bStart, bLen := ops[from].a, 0, ops[from].b, 0
+		for _, op := range ops[from:end] {
+			if op.kind != '+' {
+				aLen++
+			}
+			if op.kind != '-' {
+				bLen++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aStart, aLen), hunkRange(bStart, bLen))
+		for _, op := range ops[from:end] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		start = end
+	}
+	return out.String()
+}
+
+// hunkRange formats the range of lines of a hunk, starting at 0-based start.
+func hunkRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if n == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+// diffOp is a line of a diff: kept (' '), removed ('-') or added ('+'), at
+// index a of the expected lines and b of the actual lines.
+type diffOp struct {
+	kind byte
+	line string
+	a, b int
+}
+
+// diffLines returns the edit script turning a into b, from their longest
+// common subsequence. The empty string left after a trailing newline by
+// strings.SplitAfter() is ignored.
+func diffLines(a, b []string) []diffOp {
+	if len(a) > 0 && a[len(a)-1] == "" {
+		a = a[:len(a)-1]
+	}
+	if len(b) > 0 && b[len(b)-1] == "" {
+		b = b[:len(b)-1]
+	}
+	var ops []diffOp
+	if (len(a)+1)*(len(b)+1) > maxDiffCells {
+		for i, line := range a {
+			ops = append(ops, diffOp{kind: '-', line: line, a: i})
+		}
+		for j, line := range b {
+			ops = append(ops, diffOp{kind: '+', line: line, a: len(a), b: j})
+		}
+		return ops
+	}
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{kind: ' ', line: a[i], a: i, b: j})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{kind: '-', line: a[i], a: i, b: j})
+			i++
+		default:
+			ops = append(ops, diffOp{kind: '+', line: b[j], a: i, b: j})
+			j++
+		}
+	}
+	return ops
+}
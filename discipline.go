@@ -0,0 +1,52 @@
+package testcli
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// CheckStreamDiscipline runs the command and fails the test if it writes
+// results to stderr or diagnostics to stdout: any line of stdout matching
+// diagnosticPattern, or any line of stderr matching resultPattern, is
+// reported with its stream and line number. An empty pattern isn't checked.
+func CheckStreamDiscipline(t *testing.T, spec Spec, resultPattern, diagnosticPattern string) {
+	t.Helper()
+	var result, diagnostic *regexp.Regexp
+	if resultPattern != "" {
+		result = regexp.MustCompile(resultPattern)
+	}
+	if diagnosticPattern != "" {
+		diagnostic = regexp.MustCompile(diagnosticPattern)
+	}
+
+	c := spec.Command(t)
+	c.Run()
+	if err := checkStreamDiscipline(c.Stdout(), c.Stderr(), result, diagnostic); err != nil {
+		c.fatalf("%s", err)
+	}
+}
+
+// checkStreamDiscipline returns the lines of stdout matching diagnostic and
+// the lines of stderr matching result, if any.
+func checkStreamDiscipline(stdout, stderr string, result, diagnostic *regexp.Regexp) error {
+	var misplaced []string
+	collect := func(stream, content string, re *regexp.Regexp) {
+		if re == nil {
+			return
+		}
+		for i, line := range splitLines(content) {
+			if re.MatchString(line) {
+				misplaced = append(misplaced, fmt.Sprintf("  %s:%d: %s", stream, i+1, line))
+			}
+		}
+	}
+	collect("stdout", stdout, diagnostic)
+	collect("stderr", stderr, result)
+	if len(misplaced) > 0 {
+		return fmt.Errorf("Expected results on stdout and diagnostics on stderr, got %d misplaced lines:\n%s",
+			len(misplaced), strings.Join(misplaced, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package testcli
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCheckStreamDiscipline(t *testing.T) {
+	CheckStreamDiscipline(t, Spec{Name: "sh", Args: []string{"-c", `echo '{"id": 1}'; echo 'warning: slow' >&2`}}, `^\{`, `^(warning|error):`)
+}
+
+func TestCheckStreamDisciplineMisplacedLines(t *testing.T) {
+	result, diagnostic := regexp.MustCompile(`^\{`), regexp.MustCompile(`^(warning|error):`)
+	err := checkStreamDiscipline("{\"id\": 1}\nwarning: slow\n", "error: oops\n{\"id\": 2}\n", result, diagnostic)
+	if err == nil {
+		t.Fatalf("Expected misplaced lines to be reported")
+	}
+	for _, line := range []string{"stdout:2: warning: slow", "stderr:2: {\"id\": 2}"} {
+		if !strings.Contains(err.Error(), line) {
+			t.Fatalf("Expected %q in %q", line, err)
+		}
+	}
+	if strings.Contains(err.Error(), "error: oops") || strings.Contains(err.Error(), "stdout:1") {
+		t.Fatalf("Expected well-placed lines not to be reported, got %q", err)
+	}
+
+	if err := checkStreamDiscipline("warning: slow\n", "", result, nil); err != nil {
+		t.Fatalf("Expected an empty pattern not to be checked, got %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package testcli
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+// ContainerOption configures WithContainer().
+type ContainerOption func(*dockerExecutor)
+
+// SkipIfDockerUnavailable skips the test, instead of failing it, when docker
+// isn't installed or the image can't be pulled, e.g. when offline.
+func SkipIfDockerUnavailable() ContainerOption {
+	return func(e *dockerExecutor) {
+		e.skipIfUnavailable = true
+	}
+}
+
+// DockerBinary sets the docker client to run, "docker" by default.
+func DockerBinary(path string) ContainerOption {
+	return func(e *dockerExecutor) {
+		e.binary = path
+	}
+}
+
+// WithContainer makes the command run inside a new container of image, with
+// the given volume mounts ("host:container[:ro]") and working directory. The
+// image is pulled once per test binary if it isn't present. The command gets
+// the image's environment plus the variables of its own environment that
+// differ from the local one.
+//
+// Signals and kills are delivered with docker kill, exit codes are those of
+// the containerized process, and the container is removed when the test
+// finishes, even if it panics.
+func WithContainer(image string, mounts []string, workdir string, opts ...ContainerOption) Option {
+	return func(c *Cmd) {
+		c.t.Helper()
+		e := &dockerExecutor{
+			t:       c.t,
+			binary:  "docker",
+			image:   image,
+			mounts:  mounts,
+			workdir: workdir,
+		}
+		for _, opt := range opts {
+			opt(e)
+		}
+		if err := e.pull(); err != nil {
+			if e.skipIfUnavailable {
+				c.t.Skip(err)
+			}
+			c.t.Fatal(err)
+		}
+		c.executor = e
+	}
+}
+
+// pulledImages remembers the images known to be present locally.
+var pulledImages sync.Map
+
+type dockerExecutor struct {
+	t                 *testing.T
+	binary            string
+	image             string
+	mounts            []string
+	workdir           string
+	skipIfUnavailable bool
+}
+
+func (e *dockerExecutor) pull() error {
+	if _, ok := pulledImages.Load(e.binary + " " + e.image); ok {
+		return nil
+	}
+	if _, err := exec.LookPath(e.binary); err != nil {
+		return fmt.Errorf("docker is not available: %w", err)
+	}
+	if exec.Command(e.binary, "image", "inspect", e.image).Run() != nil {
+		if out, err := exec.Command(e.binary, "pull", e.image).CombinedOutput(); err != nil {
+			return fmt.Errorf("docker pull %s: %w: %s", e.image, err, out)
+		}
+	}
+	pulledImages.Store(e.binary+" "+e.image, true)
+	return nil
+}
+
+func (e *dockerExecutor) Start(cmd *exec.Cmd) (Process, error) {
+	defer closeFile(cmd.Stdout)
+	defer closeFile(cmd.Stderr)
+
+	name := fmt.Sprintf("testcli-%d-%d", os.Getpid(), rand.Int63())
+	docker := exec.Command(e.binary, e.runArgs(name, cmd)...)
+	docker.Stdin = cmd.Stdin
+	docker.Stdout = cmd.Stdout
+	docker.Stderr = cmd.Stderr
+	if err := docker.Start(); err != nil {
+		return nil, fmt.Errorf("in container %s: %w", e.image, err)
+	}
+
+	binary := e.binary
+	e.t.Cleanup(func() {
+		exec.Command(binary, "rm", "-f", name).Run()
+	})
+	return &dockerProcess{executor: e, name: name, docker: docker}, nil
+}
+
+// runArgs returns the docker arguments running cmd in a container called name.
+func (e *dockerExecutor) runArgs(name string, cmd *exec.Cmd) []string {
+	args := []string{"run", "-i", "--name", name}
+	for _, mount := range e.mounts {
+		args = append(args, "-v", mount)
+	}
+	if workdir := withDefault(cmd.Dir, e.workdir); workdir != "" {
+		args = append(args, "-w", workdir)
+	}
+	for _, kv := range envDelta(cmd.Env, os.Environ()) {
+		args = append(args, "-e", kv)
+	}
+	args = append(args, e.image)
+	return append(args, cmd.Args...)
+}
+
+type dockerProcess struct {
+	executor *dockerExecutor
+	name     string
+	docker   *exec.Cmd
+
+	mu     sync.Mutex
+	killed bool
+}
+
+// Pid returns -1, the process lives in the container's pid namespace.
+func (p *dockerProcess) Pid() int {
+	return -1
+}
+
+func (p *dockerProcess) Signal(sig os.Signal) error {
+	name, ok := signalNames[sig]
+	if !ok {
+		return fmt.Errorf("signal %v can't be sent to a container", sig)
+	}
+	out, err := exec.Command(p.executor.binary, "kill", "--signal="+name, p.name).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("docker kill --signal=%s %s: %w: %s", name, p.name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (p *dockerProcess) Kill() error {
+	p.mu.Lock()
+	p.killed = true
+	p.mu.Unlock()
+	if err := p.Signal(syscall.SIGKILL); err != nil {
+		// The container may not exist yet, stop the client instead.
+		return p.docker.Process.Kill()
+	}
+	return nil
+}
+
+func (p *dockerProcess) Wait() error {
+	err := p.docker.Wait()
+	p.mu.Lock()
+	killed := p.killed
+	p.mu.Unlock()
+	if killed {
+		return fmt.Errorf("in container %s: %w", p.executor.image, errKilled)
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 125 {
+		return fmt.Errorf("docker run failed, the command didn't run: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("in container %s: %w", p.executor.image, err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package testcli
+
+import (
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+// fakeDocker is a docker client running containers as local processes. The
+// pid of each "container" is kept in a file named after it, next to the
+// script.
+const fakeDocker = `state=$(dirname "$0")
+cmd=$1; shift
+case "$cmd" in
+image|pull) exit 0;;
+rm) rm -f "$state/$2.pid";;
+kill) kill -s "${1#--signal=}" "$(cat "$state/$2.pid")";;
+run)
+	while [ $# -gt 0 ]; do
+		case "$1" in
+		-i) shift;;
+		--name) name=$2; shift 2;;
+		-v) shift 2;;
+		-w) cd "$2"; shift 2;;
+		-e) export "$2"; shift 2;;
+		*) break;;
+		esac
+	done
+	shift
+	echo $$ > "$state/$name.pid"
+	exec "$@";;
+esac
+`
+
+func TestContainerConformance(t *testing.T) {
+	binary := writeScript(t, fakeDocker)
+	TestExecutorConformance(t, func() Executor {
+		c := Command(t, "true")
+		c.Apply(WithContainer("ubuntu:22.04", nil, "", DockerBinary(binary)))
+		return c.executor
+	})
+}
+
+func TestContainerRunArgs(t *testing.T) {
+	e := &dockerExecutor{image: "ubuntu:22.04", mounts: []string{"/src:/src:ro"}, workdir: "/src"}
+	cmd := exec.Command("mycli", "build")
+	cmd.Env = []string{"FOO=bar"}
+
+	expected := []string{
+		"run", "-i", "--name", "box", "-v", "/src:/src:ro", "-w", "/src",
+		"-e", "FOO=bar", "ubuntu:22.04", "mycli", "build",
+	}
+	if got := e.runArgs("box", cmd); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestContainerSkipsWithoutDocker(t *testing.T) {
+	skipped := false
+	t.Run("unavailable", func(t *testing.T) {
+		defer func() { skipped = t.Skipped() }()
+		c := Command(t, "true")
+		c.Apply(WithContainer("ubuntu:22.04", nil, "", DockerBinary("testcli-no-such-docker"), SkipIfDockerUnavailable()))
+	})
+	if !skipped {
+		t.Fatalf("Expected the test to be skipped")
+	}
+}
+
+func TestContainer(t *testing.T) {
+	c := Command(t, "cat", "/etc/os-release")
+	c.Apply(WithContainer("alpine:3", nil, "", SkipIfDockerUnavailable()))
+	c.Run()
+	if !c.StdoutContains("alpine") {
+		t.Fatalf("Expected %q to contain %q", c.Stdout(), "alpine")
+	}
+}
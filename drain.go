@@ -0,0 +1,101 @@
+package testcli
+
+import (
+	"strings"
+	"time"
+)
+
+// defaultDrainTimeout bounds how long Wait() waits for the output of the
+// exited command to be captured, see WithDrainTimeout().
+const defaultDrainTimeout = 5 * time.Second
+
+// abandonPipesGrace is how long Wait() waits for the output of the exited
+// command to be captured with WaitForPipesClose(false): only what's already
+// in the pipes is left to read then.
+const abandonPipesGrace = 100 * time.Millisecond
+
+// pipesClose is what Wait() waits for once the command exited, see
+// WaitForPipesClose().
+type pipesClose int
+
+const (
+	// pipesDrainTimeout waits for the pipes to be closed up to the drain
+	// timeout, the default.
+	pipesDrainTimeout pipesClose = iota
+	// pipesWait waits for the pipes to be closed.
+	pipesWait
+	// pipesAbandon doesn't wait for the pipes to be closed.
+	pipesAbandon
+)
+
+// WithDrainTimeout bounds how long Wait() and Kill() wait, once the command
+// exited, for the rest of its output to be captured: 5 seconds by default,
+// scaled by TimeMultiplier(). The output is usually captured right away, but
+// children left running, e.g. in the background, may hold the pipes open.
+// See DrainIncomplete() and WaitForPipesClose().
+func WithDrainTimeout(d time.Duration) Option {
+	return func(c *Cmd) {
+		c.drainTimeout = d
+	}
+}
+
+// WaitForPipesClose sets what Wait() and Kill() do once the command exited
+// when children it left running, e.g. `sh -c "child & echo started"`, still
+// hold its stdout and stderr open. If wait is set, they wait for every one
+// of them to close the pipes, for the complete output, however long it
+// takes. Otherwise, they return as soon as what the command wrote is
+// captured, abandoning what its children write. By default, they wait up to
+// the drain timeout, see WithDrainTimeout().
+func WaitForPipesClose(wait bool) Option {
+	return func(c *Cmd) {
+		c.pipesClose = pipesAbandon
+		if wait {
+			c.pipesClose = pipesWait
+		}
+	}
+}
+
+// DrainIncomplete tells whether the output of the finished command was still
+// held open once the drain timeout elapsed, in which case what was written
+// afterwards is missing. See WithDrainTimeout().
+func (c *Cmd) DrainIncomplete() bool {
+	c.t.Helper()
+	c.validateIsFinished()
+	return c.drainIncomplete
+}
+
+// drain waits for the output of the exited command to be captured, as set by
+// WaitForPipesClose().
+func (c *Cmd) drain() {
+	c.t.Helper()
+	var deadline <-chan time.Time
+	timeout := c.drainTimeout
+	if timeout == 0 {
+		timeout = Scaled(defaultDrainTimeout)
+	}
+	switch c.pipesClose {
+	case pipesDrainTimeout:
+		deadline = time.After(timeout)
+	case pipesAbandon:
+		deadline = time.After(Scaled(abandonPipesGrace))
+	}
+	if c.waitCaptured(deadline) {
+		return
+	}
+	c.drainIncomplete = true
+	if c.stdoutPipe != nil {
+		c.pipeHolders = pipeHolders(c.stdoutPipe)
+	}
+	if c.pipesClose == pipesDrainTimeout {
+		c.logf("The output of %s was still held open%s %s after it exited, it may be incomplete",
+			c.Repro(), heldBy(c.pipeHolders), timeout)
+	}
+}
+
+// heldBy describes the processes holding the pipes, if known.
+func heldBy(holders []string) string {
+	if len(holders) == 0 {
+		return ""
+	}
+	return " by " + strings.Join(holders, ", ")
+}
@@ -0,0 +1,75 @@
+package testcli
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitDrainsFinalBurst(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		c := Command(t, "sh", "-c", "head -c 1048576 /dev/zero | tr '\\0' a; printf end; exit 3")
+		c.Run()
+		stdout := c.Stdout()
+		if len(stdout) != 1<<20+3 || !strings.HasSuffix(stdout, "aend") {
+			t.Fatalf("Expected the whole final burst, got %d bytes", len(stdout))
+		}
+		if c.DrainIncomplete() {
+			t.Fatalf("Expected the output to be drained")
+		}
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	c := Command(t, "sh", "-c", "(sleep 2; echo late) & echo early")
+	c.Apply(WithDrainTimeout(100 * time.Millisecond))
+	start := time.Now()
+	c.Run()
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected Wait() to give up on the background child, took %s", elapsed)
+	}
+	if !c.DrainIncomplete() {
+		t.Fatalf("Expected the drain to be incomplete")
+	}
+	if c.Stdout() != "early\n" {
+		t.Fatalf("Expected the output written before the command exited, got %q", c.Stdout())
+	}
+	if report := c.report(); !strings.Contains(report, "it may be incomplete") {
+		t.Fatalf("Expected the report to mention the incomplete drain, got %q", report)
+	}
+}
+
+// backgroundingScript leaves a child running in the background, holding its
+// stdout.
+const backgroundingScript = "(sleep 1; echo late) & echo started"
+
+func TestWaitForPipesClose(t *testing.T) {
+	c := Command(t, "sh", "-c", backgroundingScript)
+	c.Apply(WaitForPipesClose(true), WithDrainTimeout(100*time.Millisecond))
+	c.Run()
+	if c.Stdout() != "started\nlate\n" || c.DrainIncomplete() {
+		t.Fatalf("Expected the output of the background child too, got %q", c.Stdout())
+	}
+}
+
+func TestAbandonPipes(t *testing.T) {
+	c := Command(t, "sh", "-c", backgroundingScript)
+	c.Apply(WaitForPipesClose(false))
+	start := time.Now()
+	c.Run()
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Expected Wait() to return once the command exited, took %s", elapsed)
+	}
+	if c.Stdout() != "started\n" || !c.DrainIncomplete() {
+		t.Fatalf("Expected the output of the command only, got %q", c.Stdout())
+	}
+	report := c.report()
+	if !strings.Contains(report, "still held open") {
+		t.Fatalf("Expected the report to mention the pipes held open, got %q", report)
+	}
+	if runtime.GOOS == "linux" && !strings.Contains(report, "(sleep 1)") {
+		t.Fatalf("Expected the report to name the child holding the pipes, got %q", report)
+	}
+}
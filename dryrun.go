@@ -0,0 +1,125 @@
+package testcli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// DryRunEnvVar, when set to a non-empty value, makes commands validate
+// themselves instead of running, see Validate(). Assertions about their
+// results then skip the test rather than fail it, so that a cheap tier of
+// the tests can check every command is well-formed.
+const DryRunEnvVar = "TESTCLI_DRY_RUN"
+
+// stdinValidator is implemented by the stdin sources which can tell whether
+// they'll be able to feed the command.
+type stdinValidator interface {
+	validate() error
+}
+
+// Validate checks that the command is well-formed without running it: its
+// binary resolves, its directory exists, its environment is made of
+// key=value pairs without conflicting duplicates, and its input files and
+// stdin files exist. The binary isn't checked when the command runs through
+// another executor than the local one.
+func (c *Cmd) Validate() error {
+	var problems []string
+	if _, ok := c.executor.(localExecutor); ok {
+		if err := checkBinary(c.cmd.Path); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if c.cmd.Dir != "" {
+		if info, err := os.Stat(c.cmd.Dir); err != nil {
+			problems = append(problems, err.Error())
+		} else if !info.IsDir() {
+			problems = append(problems, fmt.Sprintf("%s isn't a directory", c.cmd.Dir))
+		}
+	}
+	problems = append(problems, checkEnv(c.environ())...)
+	for _, path := range c.inputFiles {
+		if _, err := os.Stat(path); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	for _, src := range c.stdinSequence {
+		if v, ok := src.(stdinValidator); ok {
+			if err := v.validate(); err != nil {
+				problems = append(problems, "stdin: "+err.Error())
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// checkBinary checks that path, as resolved by exec.Command(), is an
+// executable file.
+func checkBinary(path string) error {
+	if !strings.ContainsRune(path, filepath.Separator) && !strings.ContainsRune(path, '/') {
+		_, err := exec.LookPath(path)
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+	if runtime.GOOS != "windows" && info.Mode()&0111 == 0 {
+		return fmt.Errorf("%s isn't executable", path)
+	}
+	return nil
+}
+
+// checkEnv returns the problems of env.
+func checkEnv(env []string) []string {
+	var problems []string
+	values := make(map[string]string)
+	for _, kv := range env {
+		i := strings.IndexByte(kv, '=')
+		if i == 0 {
+			// Windows has variables like "=C:", holding the current
+			// directory of each drive.
+			i = strings.IndexByte(kv[1:], '=') + 1
+		}
+		if i <= 0 {
+			problems = append(problems, fmt.Sprintf("environment entry %q isn't key=value", kv))
+			continue
+		}
+		key, value := kv[:i], kv[i+1:]
+		if previous, ok := values[key]; ok && previous != value {
+			problems = append(problems, fmt.Sprintf("environment variable %s is set twice, to %q and %q", key, previous, value))
+		}
+		values[key] = value
+	}
+	return problems
+}
+
+// startDryRun validates the command instead of starting it.
+func (c *Cmd) startDryRun() {
+	c.t.Helper()
+	if err := c.Validate(); err != nil {
+		c.t.Fatalf("Invalid command %s: %s", c.Repro(), err)
+	}
+	c.dryRun = true
+	c.status = Finished
+	close(c.done)
+}
+
+// skipIfDryRun skips the test when asked about the results of a command
+// which only validated itself.
+func (c *Cmd) skipIfDryRun() {
+	c.t.Helper()
+	if c.dryRun {
+		c.t.Skipf("Dry run (%s is set): %s was validated, not run", DryRunEnvVar, c.Repro())
+	}
+}
@@ -0,0 +1,82 @@
+package testcli
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	c := Command(t, "cat")
+	c.SetEnv([]string{"A=1", "A=1", "B=2"})
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Expected a valid command, got %v", err)
+	}
+}
+
+func TestValidateProblems(t *testing.T) {
+	c := Command(t, "testcli-no-such-command")
+	c.cmd.Dir = "/testcli/no/such/dir"
+	c.SetEnv([]string{"A=1", "A=2", "oops"})
+	c.SetStdinSequence(FromFile("/testcli/no/such/file"))
+
+	err := c.Validate()
+	if err == nil {
+		t.Fatalf("Expected the command to be invalid")
+	}
+	for _, problem := range []string{"testcli-no-such-command", "/testcli/no/such/dir", `set twice, to "1" and "2"`, `"oops" isn't key=value`, "stdin: "} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Expected %q to mention %q", err, problem)
+		}
+	}
+}
+
+func TestValidateNotExecutable(t *testing.T) {
+	path := writeScript(t, "true")
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Command(t, path).Validate(); err == nil || !strings.Contains(err.Error(), "isn't executable") {
+		t.Fatalf("Expected the script not to be executable, got %v", err)
+	}
+}
+
+// TestDryRunHelperProcess runs a command and asserts on its results.
+func TestDryRunHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	name := "false"
+	if len(HelperArgs()) > 0 {
+		name = HelperArgs()[0]
+	}
+	c := Command(t, name)
+	c.Run()
+	if !c.Success() {
+		t.Fatalf("Expected not to run")
+	}
+}
+
+func dryRunHelper(t *testing.T, args ...string) *Cmd {
+	c := Command(t, os.Args[0], append([]string{"-test.run=^TestDryRunHelperProcess$", "-test.v", "--"}, args...)...)
+	c.SetEnv(append(os.Environ(), HelperEnvVar+"=TestDryRunHelperProcess", DryRunEnvVar+"=1"))
+	c.Run()
+	return c
+}
+
+func TestDryRun(t *testing.T) {
+	c := dryRunHelper(t)
+	if !c.Success() {
+		t.Fatalf("Expected the dry run to succeed, got %q", c.Stdout())
+	}
+	if !strings.Contains(c.Stdout(), "--- SKIP: TestDryRunHelperProcess") || !strings.Contains(c.Stdout(), "validated, not run") {
+		t.Fatalf("Expected the assertion to skip the test, got %q", c.Stdout())
+	}
+}
+
+func TestDryRunInvalid(t *testing.T) {
+	c := dryRunHelper(t, "testcli-no-such-command")
+	if !c.Failure() || !strings.Contains(c.Stdout(), "Invalid command") {
+		t.Fatalf("Expected the invalid command to fail the dry run, got %q", c.Stdout())
+	}
+}
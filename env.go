@@ -0,0 +1,137 @@
+package testcli
+
+import (
+	"os"
+	"strings"
+)
+
+// WithEnvPassthrough makes the command start from an empty environment,
+// rather than inheriting ours, except for the variables named keys, e.g. CI
+// or SSH_AUTH_SOCK. It can be used several times, adding to the allowed
+// variables. See SetEnv() for how it combines with the other ways of setting
+// the environment.
+func WithEnvPassthrough(keys ...string) Option {
+	return func(c *Cmd) {
+		c.envPassthrough = append(append([]string{}, c.envPassthrough...), keys...)
+	}
+}
+
+// SetEnvVar sets the variable key to value in the environment of the
+// command, replacing the inherited one, if any. See SetEnv() for how it
+// combines with the other ways of setting the environment.
+func (c *Cmd) SetEnvVar(key, value string) {
+	c.envEdits = append(c.envEdits, envEdit{name: key, value: value})
+}
+
+// AppendEnv sets vars, a list of "key=value", in the environment of the
+// command, as SetEnvVar() would.
+func (c *Cmd) AppendEnv(vars ...string) {
+	for _, kv := range vars {
+		c.SetEnvVar(envName(kv), strings.TrimPrefix(kv, envName(kv)+"="))
+	}
+}
+
+// UnsetEnvVar removes the variable key from the environment of the command,
+// e.g. to keep it from inheriting one of ours.
+func (c *Cmd) UnsetEnvVar(key string) {
+	c.envEdits = append(c.envEdits, envEdit{name: key, unset: true})
+}
+
+// envEdit is a change to a single variable, see SetEnvVar() and
+// UnsetEnvVar().
+type envEdit struct {
+	name  string
+	value string
+	unset bool
+}
+
+// environ returns the environment of the command, built in layers, each one
+// replacing the variables of the same names in the previous ones:
+//
+//  1. the inherited environment: ours, or only the variables allowed by
+//     WithEnvPassthrough() if used;
+//  2. the explicit environment of SetEnv() or Spec.Env, which replaces the
+//     inherited one, unless WithEnvPassthrough() is used;
+//  3. the variables set or unset by SetEnvVar(), AppendEnv() and
+//     UnsetEnvVar(), in the order they were called;
+//  4. the variables set by other options, e.g. StopFileEnv(), WithProxy() or
+//     WithCustomCA(), in the order they were set;
+//  5. the directories of RecordSubprocesses(), prepended to PATH.
+//
+// The layers don't depend on the order the options are applied in.
+func (c *Cmd) environ() []string {
+	var env []string
+	switch {
+	case c.envPassthrough != nil:
+		for _, kv := range os.Environ() {
+			if containsString(c.envPassthrough, envName(kv)) {
+				env = append(env, kv)
+			}
+		}
+		if c.env != nil {
+			env = setVars(env, c.env...)
+		}
+	case c.env != nil:
+		env = append(env, c.env...)
+	default:
+		env = os.Environ()
+	}
+	for _, edit := range c.envEdits {
+		if edit.unset {
+			env = unsetVar(env, edit.name)
+		} else {
+			env = setVars(env, edit.name+"="+edit.value)
+		}
+	}
+	env = setVars(env, c.envOverrides...)
+
+	if len(c.pathPrefix) > 0 {
+		path := strings.Join(c.pathPrefix, string(os.PathListSeparator))
+		for _, kv := range env {
+			if envName(kv) == "PATH" {
+				path += string(os.PathListSeparator) + strings.TrimPrefix(kv, "PATH=")
+			}
+		}
+		env = setVars(env, "PATH="+path)
+	}
+	return env
+}
+
+// overrideEnv adds env, a list of "name=value", to the variables set by
+// options, see environ().
+func (c *Cmd) overrideEnv(env ...string) {
+	c.envOverrides = setVars(c.envOverrides, env...)
+}
+
+// setVars returns env with vars, a list of "name=value", replacing the
+// variables of the same names. Variables repeated within vars are kept, so
+// that checkEnv() sees them.
+func setVars(env []string, vars ...string) []string {
+	names := map[string]bool{}
+	for _, kv := range vars {
+		names[envName(kv)] = true
+	}
+	var kept []string
+	for _, kv := range env {
+		if !names[envName(kv)] {
+			kept = append(kept, kv)
+		}
+	}
+	return append(kept, vars...)
+}
+
+// unsetVar returns env without the variable name.
+func unsetVar(env []string, name string) []string {
+	var kept []string
+	for _, kv := range env {
+		if envName(kv) != name {
+			kept = append(kept, kv)
+		}
+	}
+	return kept
+}
+
+// envName returns the name of the variable kv, "name=value".
+func envName(kv string) string {
+	return strings.SplitN(kv, "=", 2)[0]
+}
@@ -0,0 +1,186 @@
+package testcli
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEnvPrecedence(t *testing.T) {
+	for name, value := range map[string]string{"TESTCLI_INHERITED": "ours", "TESTCLI_ALLOWED": "ours"} {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Setenv(name, value)
+	}
+
+	const unset = "<unset>"
+	tests := []struct {
+		name        string
+		passthrough []string
+		setEnv      []string
+		edits       func(c *Cmd)
+		override    []string
+		expected    map[string]string
+	}{
+		{
+			name:     "inherited",
+			expected: map[string]string{"TESTCLI_INHERITED": "ours", "TESTCLI_ALLOWED": "ours"},
+		},
+		{
+			name:     "SetEnv replaces the inherited environment",
+			setEnv:   []string{"TESTCLI_EXPLICIT=set"},
+			expected: map[string]string{"TESTCLI_INHERITED": unset, "TESTCLI_EXPLICIT": "set"},
+		},
+		{
+			name:        "passthrough keeps only the allowed variables",
+			passthrough: []string{"TESTCLI_ALLOWED"},
+			expected:    map[string]string{"TESTCLI_INHERITED": unset, "TESTCLI_ALLOWED": "ours", "PATH": unset},
+		},
+		{
+			name:        "SetEnv is applied on top of the passthrough",
+			passthrough: []string{"TESTCLI_ALLOWED", "TESTCLI_INHERITED"},
+			setEnv:      []string{"TESTCLI_INHERITED=set", "TESTCLI_EXPLICIT=set"},
+			expected:    map[string]string{"TESTCLI_INHERITED": "set", "TESTCLI_ALLOWED": "ours", "TESTCLI_EXPLICIT": "set"},
+		},
+		{
+			name: "SetEnvVar layers on top of the inherited environment",
+			edits: func(c *Cmd) {
+				c.SetEnvVar("TESTCLI_INHERITED", "first")
+				c.AppendEnv("TESTCLI_INHERITED=edited", "TESTCLI_EXPLICIT=appended")
+			},
+			expected: map[string]string{"TESTCLI_INHERITED": "edited", "TESTCLI_ALLOWED": "ours", "TESTCLI_EXPLICIT": "appended"},
+		},
+		{
+			name:   "SetEnvVar and UnsetEnvVar layer on top of SetEnv",
+			setEnv: []string{"TESTCLI_EXPLICIT=set", "TESTCLI_ALLOWED=set"},
+			edits: func(c *Cmd) {
+				c.SetEnvVar("TESTCLI_EXPLICIT", "edited")
+				c.UnsetEnvVar("TESTCLI_ALLOWED")
+			},
+			expected: map[string]string{"TESTCLI_EXPLICIT": "edited", "TESTCLI_ALLOWED": unset, "TESTCLI_INHERITED": unset},
+		},
+		{
+			name:        "SetEnvVar layers on top of the passthrough",
+			passthrough: []string{"TESTCLI_ALLOWED"},
+			edits: func(c *Cmd) {
+				c.SetEnvVar("TESTCLI_ALLOWED", "edited")
+			},
+			expected: map[string]string{"TESTCLI_ALLOWED": "edited", "TESTCLI_INHERITED": unset},
+		},
+		{
+			name: "options win over SetEnvVar",
+			edits: func(c *Cmd) {
+				c.SetEnvVar("TESTCLI_INHERITED", "edited")
+			},
+			override: []string{"TESTCLI_INHERITED=option"},
+			expected: map[string]string{"TESTCLI_INHERITED": "option"},
+		},
+		{
+			name:     "options win over the inherited environment",
+			override: []string{"TESTCLI_INHERITED=option"},
+			expected: map[string]string{"TESTCLI_INHERITED": "option", "TESTCLI_ALLOWED": "ours"},
+		},
+		{
+			name:        "options win over SetEnv and the passthrough",
+			passthrough: []string{"TESTCLI_ALLOWED"},
+			setEnv:      []string{"TESTCLI_EXPLICIT=set"},
+			override:    []string{"TESTCLI_ALLOWED=option", "TESTCLI_EXPLICIT=option"},
+			expected:    map[string]string{"TESTCLI_ALLOWED": "option", "TESTCLI_EXPLICIT": "option", "TESTCLI_INHERITED": unset},
+		},
+	}
+
+	for _, test := range tests {
+		// The layers mustn't depend on the order they're set in.
+		for _, optionsFirst := range []bool{false, true} {
+			c := Command(t, "env")
+			apply := func() {
+				if test.passthrough != nil {
+					c.Apply(WithEnvPassthrough(test.passthrough...))
+				}
+				if test.setEnv != nil {
+					c.SetEnv(test.setEnv)
+				}
+				if test.edits != nil {
+					test.edits(c)
+				}
+			}
+			if !optionsFirst {
+				apply()
+			}
+			c.overrideEnv(test.override...)
+			if optionsFirst {
+				apply()
+			}
+
+			env := map[string]string{}
+			for _, kv := range c.environ() {
+				env[envName(kv)] = strings.TrimPrefix(kv, envName(kv)+"=")
+			}
+			for name, expected := range test.expected {
+				actual, ok := env[name]
+				if !ok {
+					actual = unset
+				}
+				if actual != expected {
+					t.Errorf("%s (options first: %t): expected %s to be %q, got %q", test.name, optionsFirst, name, expected, actual)
+				}
+			}
+		}
+	}
+}
+
+func TestEnvPassthrough(t *testing.T) {
+	defer os.Setenv("TESTCLI_ALLOWED", os.Getenv("TESTCLI_ALLOWED"))
+	os.Setenv("TESTCLI_ALLOWED", "ours")
+
+	c := Command(t, "/usr/bin/env")
+	c.Apply(WithEnvPassthrough("TESTCLI_ALLOWED"))
+	c.SetEnv([]string{"TESTCLI_EXPLICIT=set"})
+	stop := c.StopFileEnv("TESTCLI_STOP")
+	c.Run()
+
+	expected := "TESTCLI_ALLOWED=ours\nTESTCLI_EXPLICIT=set\nTESTCLI_STOP=" + stop.path + "\n"
+	if c.Stdout() != expected {
+		t.Fatalf("Expected the environment to be %q, got %q", expected, c.Stdout())
+	}
+	if repro := c.Repro(); !strings.HasPrefix(repro, "env -i TESTCLI_ALLOWED=ours TESTCLI_EXPLICIT=set TESTCLI_STOP=") {
+		t.Fatalf("Expected the repro to start from an empty environment, got %q", repro)
+	}
+}
+
+func TestRecordSubprocessesAfterSetEnv(t *testing.T) {
+	c := Command(t, "sh", "-c", "env >/dev/null")
+	c.RecordSubprocesses("env")
+	c.SetEnv([]string{"PATH=" + os.Getenv("PATH")})
+	c.Run()
+	if count := c.SubprocessCount("env"); count != 1 {
+		t.Fatalf("Expected 1 invocation of env, got %d", count)
+	}
+}
+
+func TestSetEnvVarPath(t *testing.T) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho shadowed\n"
+	if err := ioutil.WriteFile(dir+"/whoami", []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	c := Command(t, "sh", "-c", "whoami")
+	c.SetEnvVar("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	c.Run()
+	if c.Stdout() != "shadowed\n" {
+		t.Fatalf("Expected PATH to be overridden, got %q", c.Stdout())
+	}
+}
+
+func TestUnsetEnvVar(t *testing.T) {
+	defer os.Setenv("TESTCLI_INHERITED", os.Getenv("TESTCLI_INHERITED"))
+	os.Setenv("TESTCLI_INHERITED", "ours")
+
+	c := Command(t, "sh", "-c", `echo "${TESTCLI_INHERITED-unset}"`)
+	c.UnsetEnvVar("TESTCLI_INHERITED")
+	c.Run()
+	if c.Stdout() != "unset\n" {
+		t.Fatalf("Expected the variable to be unset, got %q", c.Stdout())
+	}
+}
@@ -0,0 +1,106 @@
+package testcli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TrimTrailingNewline makes StdoutEquals() and StderrEquals() ignore a single
+// trailing newline, which almost every command prints, on both sides.
+func TrimTrailingNewline() MatchOption {
+	return func(cfg *matchConfig) {
+		cfg.trimNewline = true
+	}
+}
+
+// StdoutEquals determines if command's STDOUT is exactly `str`, case
+// included. On a running command, it retries as StdoutContains() does.
+func (c *Cmd) StdoutEquals(str string, opts ...MatchOption) bool {
+	c.t.Helper()
+	return c.outputEquals(c.stdout, "stdout", str, opts) == nil
+}
+
+// StdoutEquals determines if command's STDOUT is exactly `str`.
+func StdoutEquals(str string, opts ...MatchOption) bool {
+	c := pkgCmd()
+	c.t.Helper()
+	return c.StdoutEquals(str, opts...)
+}
+
+// StderrEquals determines if command's STDERR is exactly `str`, see
+// StdoutEquals().
+func (c *Cmd) StderrEquals(str string, opts ...MatchOption) bool {
+	c.t.Helper()
+	return c.outputEquals(c.stderr, "stderr", str, opts) == nil
+}
+
+// StderrEquals determines if command's STDERR is exactly `str`.
+func StderrEquals(str string, opts ...MatchOption) bool {
+	c := pkgCmd()
+	c.t.Helper()
+	return c.StderrEquals(str, opts...)
+}
+
+// AssertStdoutEquals fails the test, showing the unified diff of str and
+// stdout, unless StdoutEquals(str, opts...).
+func (c *Cmd) AssertStdoutEquals(str string, opts ...MatchOption) *Cmd {
+	c.t.Helper()
+	c.assert(c.outputEquals(c.stdout, "stdout", str, opts))
+	return c
+}
+
+// AssertStdoutEquals fails the test, showing the unified diff of str and
+// stdout, unless StdoutEquals(str, opts...).
+func AssertStdoutEquals(str string, opts ...MatchOption) {
+	c := pkgCmd()
+	c.t.Helper()
+	c.AssertStdoutEquals(str, opts...)
+}
+
+// AssertStderrEquals fails the test, showing the unified diff of str and
+// stderr, unless StderrEquals(str, opts...).
+func (c *Cmd) AssertStderrEquals(str string, opts ...MatchOption) *Cmd {
+	c.t.Helper()
+	c.assert(c.outputEquals(c.stderr, "stderr", str, opts))
+	return c
+}
+
+// AssertStderrEquals fails the test, showing the unified diff of str and
+// stderr, unless StderrEquals(str, opts...).
+func AssertStderrEquals(str string, opts ...MatchOption) {
+	c := pkgCmd()
+	c.t.Helper()
+	c.AssertStderrEquals(str, opts...)
+}
+
+// outputEquals returns an error showing the diff of str and o, named stream
+// in messages, unless they're equal.
+func (c *Cmd) outputEquals(o *output, stream, str string, opts []MatchOption) error {
+	c.t.Helper()
+	c.validateHasStarted()
+	cfg := matchOptions(opts)
+	normalize := func(s string) string {
+		if cfg.trimNewline {
+			s = strings.TrimSuffix(s, "\n")
+		}
+		return s
+	}
+	want := normalize(str)
+	content := func() string {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		return normalize(o.text())
+	}
+	var got string
+	equal := func() bool {
+		got = content()
+		return got == want
+	}
+	if !c.awaitFinal(opts) {
+		got = content()
+	} else if c.waitFor(fmt.Sprintf("%s to equal %q", stream, str), equal, defaultPollInterval, Scaled(defaultPollTimeout)) == nil {
+		o.examineAll()
+		return nil
+	}
+	return fmt.Errorf("Expected %s to equal the expected output:\n%s", stream, unifiedDiff(want, got))
+}
@@ -0,0 +1,61 @@
+package testcli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStdoutEquals(t *testing.T) {
+	c := Command(t, "sh", "-c", "echo done; echo warning >&2")
+	c.Run()
+	if !c.StdoutEquals("done\n") || !c.StderrEquals("warning\n") {
+		t.Fatalf("Expected the exact output, got %q and %q", c.Stdout(), c.Stderr())
+	}
+	if c.StdoutEquals("done") || c.StdoutEquals("DONE\n") {
+		t.Fatalf("Expected the comparison to be exact")
+	}
+	if !c.StdoutEquals("done", TrimTrailingNewline()) || !c.StdoutEquals("done\n", TrimTrailingNewline()) {
+		t.Fatalf("Expected a trailing newline to be ignored")
+	}
+	c.AssertStdoutEquals("done\n").AssertStderrEquals("warning", TrimTrailingNewline())
+}
+
+func TestOutputEqualsShowsDiff(t *testing.T) {
+	c := Command(t, "printf", "a\\nB\\nc\\n")
+	c.Run()
+	err := c.outputEquals(c.stdout, "stdout", "a\nb\nc\n", nil)
+	want := "Expected stdout to equal the expected output:\n--- expected\n+++ actual\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"
+	if err == nil || err.Error() != want {
+		t.Fatalf("Expected the diff %q, got %v", want, err)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	expected := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	actual := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13"
+	want := strings.Join([]string{
+		"--- expected",
+		"+++ actual",
+		"@@ -1,6 +1,6 @@",
+		" 1",
+		" 2",
+		"-3",
+		"+three",
+		" 4",
+		" 5",
+		" 6",
+		"@@ -10,3 +10,4 @@",
+		" 10",
+		" 11",
+		" 12",
+		"+13",
+		`\ No newline at end of file`,
+		"",
+	}, "\n")
+	if got := unifiedDiff(expected, actual); got != want {
+		t.Fatalf("Expected the diff:\n%s\ngot:\n%s", want, got)
+	}
+	if got := unifiedDiff("same\n", "same\n"); got != "" {
+		t.Fatalf("Expected no diff of equal contents, got %q", got)
+	}
+}
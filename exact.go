@@ -0,0 +1,36 @@
+package testcli
+
+// CaseSensitive makes a single Contains or Matches assertion compare the
+// output as it is, rather than lowered, e.g. to tell an ID from the same word
+// in another case.
+func CaseSensitive() MatchOption {
+	return func(cfg *matchConfig) {
+		cfg.caseSensitive = true
+	}
+}
+
+// StdoutContainsExact is StdoutContains() with CaseSensitive().
+func (c *Cmd) StdoutContainsExact(str string, opts ...MatchOption) bool {
+	c.t.Helper()
+	return c.StdoutContains(str, append(opts, CaseSensitive())...)
+}
+
+// StdoutContainsExact is StdoutContains() with CaseSensitive().
+func StdoutContainsExact(str string, opts ...MatchOption) bool {
+	c := pkgCmd()
+	c.t.Helper()
+	return c.StdoutContainsExact(str, opts...)
+}
+
+// StderrContainsExact is StderrContains() with CaseSensitive().
+func (c *Cmd) StderrContainsExact(str string, opts ...MatchOption) bool {
+	c.t.Helper()
+	return c.StderrContains(str, append(opts, CaseSensitive())...)
+}
+
+// StderrContainsExact is StderrContains() with CaseSensitive().
+func StderrContainsExact(str string, opts ...MatchOption) bool {
+	c := pkgCmd()
+	c.t.Helper()
+	return c.StderrContainsExact(str, opts...)
+}
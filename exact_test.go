@@ -0,0 +1,47 @@
+package testcli
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestContainsExact(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", "echo 'id: MyID'; echo 'Warning: Disk' >&2")
+	c.Run()
+
+	if !c.StdoutContainsExact("MyID") || !c.StderrContainsExact("Warning") {
+		t.Fatalf("Expected the output to contain the exact strings")
+	}
+	if c.StdoutContainsExact("myid") || c.StderrContainsExact("WARNING") {
+		t.Fatalf("Expected the case to matter")
+	}
+	if !c.StdoutContains("myid") || !c.StderrContains("WARNING") {
+		t.Fatalf("Expected the default assertions to ignore the case")
+	}
+	if !c.StdoutMatches(`MyID`, CaseSensitive()) || c.StdoutMatches(`myid`, CaseSensitive()) {
+		t.Fatalf("Expected CaseSensitive() to apply to Matches too")
+	}
+}
+
+func TestContainsExactSpilled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testcli-spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := Command(t, "/bin/sh", "-c", "seq 10000; echo MyID")
+	c.SpillCaptureToDisk(dir, 100)
+	c.Run()
+
+	if !c.StdoutContainsExact("MyID") || c.StdoutContainsExact("myid") {
+		t.Fatalf("Expected the spilled stdout to be searched with the case")
+	}
+	if !c.StdoutMatches(`MyID`, CaseSensitive()) || c.StdoutMatches(`myid`, CaseSensitive()) {
+		t.Fatalf("Expected the spilled stdout to be matched with the case")
+	}
+	if !c.StdoutContains("myid") {
+		t.Fatalf("Expected the default assertion to ignore the case")
+	}
+}
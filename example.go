@@ -0,0 +1,102 @@
+package testcli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	exampleMu        sync.Mutex
+	exampleScrubbers = []string{"timestamps", "uuids"}
+	// exampleTimeout is scaled by TimeMultiplier() when used.
+	exampleTimeout = 30 * time.Second
+)
+
+// SetExampleScrubbers sets the scrubbers ExampleRun() applies, see
+// RegisterScrubber(). The default is "timestamps" and "uuids".
+func SetExampleScrubbers(names ...string) {
+	exampleMu.Lock()
+	exampleScrubbers = names
+	exampleMu.Unlock()
+}
+
+// SetExampleTimeout sets how long ExampleRun() lets a command run, 30 seconds
+// by default, scaled by TimeMultiplier().
+func SetExampleTimeout(d time.Duration) {
+	exampleMu.Lock()
+	exampleTimeout = d
+	exampleMu.Unlock()
+}
+
+// ExampleRun runs a command and prints its scrubbed stdout, and nothing else,
+// to os.Stdout, for Example functions to document a CLI with output verified
+// by go test:
+//
+//	func Example_greet() {
+//		testcli.ExampleRun("mycli", "greet", "world")
+//		// Output: Hello, world!
+//	}
+//
+// If the command fails, its exit status and scrubbed stderr are printed too,
+// which makes the example fail with them in the output. So does a command
+// still running after SetExampleTimeout(), which is killed along with its
+// process group.
+func ExampleRun(name string, arg ...string) {
+	exampleMu.Lock()
+	timeout := Scaled(exampleTimeout)
+	exampleMu.Unlock()
+
+	cmd := exec.Command(name, arg...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	setNewProcessGroup(cmd)
+	err := cmd.Start()
+	expired := make(chan struct{})
+	if err == nil {
+		timer := time.AfterFunc(timeout, func() {
+			close(expired)
+			killProcessGroup(cmd.Process)
+		})
+		err = cmd.Wait()
+		timer.Stop()
+	}
+
+	fmt.Fprint(os.Stdout, scrubExample(stdout.String()))
+	if err == nil {
+		return
+	}
+	if stdout.Len() > 0 && !strings.HasSuffix(stdout.String(), "\n") {
+		fmt.Fprintln(os.Stdout)
+	}
+	select {
+	case <-expired:
+		fmt.Fprintf(os.Stdout, "%s timed out after %s\n", name, timeout)
+	default:
+		fmt.Fprintf(os.Stdout, "%s failed: %s\n", name, err)
+	}
+	fmt.Fprintf(os.Stdout, "stderr:\n%s", scrubExample(stderr.String()))
+}
+
+// scrubExample applies the example scrubbers to s.
+func scrubExample(s string) string {
+	exampleMu.Lock()
+	names := exampleScrubbers
+	exampleMu.Unlock()
+
+	scrubbersMu.Lock()
+	defer scrubbersMu.Unlock()
+	for _, name := range names {
+		if scrub, ok := scrubbers[name]; ok {
+			s = scrub(s)
+		} else {
+			s += fmt.Sprintf("[unknown scrubber %q]\n", name)
+		}
+	}
+	return s
+}
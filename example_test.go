@@ -0,0 +1,54 @@
+package testcli
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ExampleExampleRun() {
+	ExampleRun("sh", "-c", "echo built at 2024-01-02T03:04:05Z")
+	// Output: built at <TIMESTAMP>
+}
+
+func ExampleExampleRun_failure() {
+	ExampleRun("sh", "-c", "printf partial; echo oops at 2024-01-02T03:04:05Z >&2; exit 2")
+	// Output:
+	// partial
+	// sh failed: exit status 2
+	// stderr:
+	// oops at <TIMESTAMP>
+}
+
+func TestScrubExampleUnknown(t *testing.T) {
+	SetExampleScrubbers("nope")
+	defer SetExampleScrubbers("timestamps", "uuids")
+	if got := scrubExample("x\n"); !strings.Contains(got, `unknown scrubber "nope"`) {
+		t.Fatalf("Expected the unknown scrubber to show up in the output, got %q", got)
+	}
+}
+
+func TestExampleRunTimeout(t *testing.T) {
+	SetExampleTimeout(100 * time.Millisecond)
+	defer SetExampleTimeout(30 * time.Second)
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	start := time.Now()
+	ExampleRun("sh", "-c", "echo started; sleep 10 & wait")
+	os.Stdout = stdout
+	w.Close()
+	output, _ := ioutil.ReadAll(r)
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Expected the command to be killed after the timeout, took %s", elapsed)
+	}
+	if !strings.Contains(string(output), "started\nsh timed out after ") {
+		t.Fatalf("Expected the output to report the timeout, got %q", output)
+	}
+}
@@ -0,0 +1,62 @@
+package testcli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ExecutionInfo describes the environment the command was started in, as
+// far as it isn't in its command line or variables, to tell apart the runs
+// that only fail on some machines, e.g. in CI.
+type ExecutionInfo struct {
+	// Dir is the working directory of the command, made absolute when it
+	// runs locally.
+	Dir string `json:"dir" xml:"dir"`
+	// Umask is the umask the command inherited, -1 if it's unknown.
+	Umask int `json:"umask" xml:"umask"`
+	// UID and GID are the user and group the command ran as, those of the
+	// tests unless the credential of cmd.SysProcAttr overrides them, -1 if
+	// there are none, e.g. on Windows.
+	UID int `json:"uid" xml:"uid"`
+	GID int `json:"gid" xml:"gid"`
+	// Path is the PATH the binary was resolved with, which is that of the
+	// tests rather than of the command, and Binary what it was resolved to.
+	Path   string `json:"path" xml:"path"`
+	Binary string `json:"binary" xml:"binary"`
+}
+
+// String describes the info on a line, as failure reports show it.
+func (i ExecutionInfo) String() string {
+	umask := "unknown"
+	if i.Umask >= 0 {
+		umask = fmt.Sprintf("%04o", i.Umask)
+	}
+	return fmt.Sprintf("ran %s in %s as uid %d gid %d with umask %s, PATH=%s", i.Binary, i.Dir, i.UID, i.GID, umask, i.Path)
+}
+
+// ExecutionInfo returns what the command was started with, recorded by Run()
+// or Start(). It's included in the failure reports and attachments.
+func (c *Cmd) ExecutionInfo() ExecutionInfo {
+	c.t.Helper()
+	c.validateHasStarted()
+	return c.execInfo
+}
+
+// recordExecutionInfo records the ExecutionInfo of the command about to
+// start.
+func (c *Cmd) recordExecutionInfo() {
+	info := ExecutionInfo{
+		Dir:    c.cmd.Dir,
+		Umask:  processUmask(),
+		Path:   os.Getenv("PATH"),
+		Binary: c.cmd.Path,
+	}
+	info.UID, info.GID = childCredential(c.cmd)
+	if _, ok := c.executor.(localExecutor); ok {
+		if abs, err := filepath.Abs(info.Dir); err == nil {
+			info.Dir = abs
+		}
+	}
+	c.execInfo = info
+}
@@ -0,0 +1,15 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package testcli
+
+import (
+	"os"
+	"os/exec"
+)
+
+// childCredential returns the user and group cmd runs as, -1 if there are
+// none.
+func childCredential(cmd *exec.Cmd) (int, int) {
+	return os.Getuid(), os.Getgid()
+}
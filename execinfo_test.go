@@ -0,0 +1,80 @@
+//go:build linux
+// +build linux
+
+package testcli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestExecutionInfo(t *testing.T) {
+	c := Command(t, "sh", "-c", "umask")
+	c.Run()
+	info := c.ExecutionInfo()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Dir != wd {
+		t.Errorf("Expected the working directory %s, got %s", wd, info.Dir)
+	}
+	if umask, err := strconv.ParseInt(strings.TrimSpace(c.Stdout()), 8, 32); err != nil || info.Umask != int(umask) {
+		t.Errorf("Expected the umask the child sees, %s, got %04o", c.Stdout(), info.Umask)
+	}
+	if info.UID != os.Getuid() || info.GID != os.Getgid() {
+		t.Errorf("Expected the user of the tests, got uid %d gid %d", info.UID, info.GID)
+	}
+	if info.Path != os.Getenv("PATH") || filepath.Base(info.Binary) != "sh" || !filepath.IsAbs(info.Binary) {
+		t.Errorf("Expected sh to be resolved with the PATH of the tests, got %+v", info)
+	}
+	if report := c.report(); !strings.Contains(report, "\nran "+info.Binary+" in "+wd+" as uid ") {
+		t.Errorf("Expected the report to include the execution info, got %q", report)
+	}
+}
+
+func TestExecutionInfoDir(t *testing.T) {
+	c := Command(t, "true")
+	c.SetDir("testdata")
+	c.Run()
+	if want, _ := filepath.Abs("testdata"); c.ExecutionInfo().Dir != want {
+		t.Fatalf("Expected the relative directory to be made absolute, %s, got %s", want, c.ExecutionInfo().Dir)
+	}
+}
+
+func TestExecutionInfoCredential(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("Running as another user requires root")
+	}
+	c := Command(t, "id", "-u")
+	c.cmd.SysProcAttr = &syscall.SysProcAttr{Credential: &syscall.Credential{Uid: 65534, Gid: 65534}}
+	c.Run()
+	if info := c.ExecutionInfo(); info.UID != 65534 || info.GID != 65534 {
+		t.Fatalf("Expected the credential override, got uid %d gid %d", info.UID, info.GID)
+	}
+	if got := strings.TrimSpace(c.Stdout()); got != "65534" {
+		t.Fatalf("Expected the child to run as 65534, got %q", got)
+	}
+}
+
+func TestExecutionInfoAttachment(t *testing.T) {
+	attachmentsDir(t, "json")
+	c := Command(t, "true")
+	c.Run()
+	path, err := c.writeAttachment("failed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `"execution": {`) || !strings.Contains(string(content), `"umask": `) {
+		t.Fatalf("Expected the attachment to include the execution info, got %s", content)
+	}
+}
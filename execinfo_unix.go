@@ -0,0 +1,17 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package testcli
+
+import (
+	"os"
+	"os/exec"
+)
+
+// childCredential returns the user and group cmd runs as.
+func childCredential(cmd *exec.Cmd) (int, int) {
+	if cmd.SysProcAttr != nil && cmd.SysProcAttr.Credential != nil {
+		return int(cmd.SysProcAttr.Credential.Uid), int(cmd.SysProcAttr.Credential.Gid)
+	}
+	return os.Getuid(), os.Getgid()
+}
@@ -0,0 +1,78 @@
+package testcli
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Executor starts the processes of a Cmd. The default one runs them locally
+// through os/exec; others can run them somewhere else (a container, a remote
+// host, a fake) as long as they behave the same way, which
+// TestExecutorConformance verifies.
+type Executor interface {
+	// Start starts the process described by cmd's Path, Args, Env, Dir and
+	// Stdin. cmd.Stdout and cmd.Stderr are *os.File pipes: the executor
+	// takes ownership of them and must close them once it's done writing,
+	// whether or not Start succeeds.
+	Start(cmd *exec.Cmd) (Process, error)
+}
+
+// Process is a process started by an Executor.
+type Process interface {
+	// Pid returns the process id, or -1 if it isn't meaningful.
+	Pid() int
+	// Signal sends a signal to the process.
+	Signal(sig os.Signal) error
+	// Kill kills the process right away.
+	Kill() error
+	// Wait waits for the process to exit. A non-zero exit status must be
+	// reported as an error implementing ExitCode() int, like
+	// *exec.ExitError, returning -1 if the process was killed.
+	Wait() error
+}
+
+// LocalExecutor returns the Executor used by default, which runs commands
+// on this machine.
+func LocalExecutor() Executor {
+	return localExecutor{}
+}
+
+type localExecutor struct{}
+
+func (localExecutor) Start(cmd *exec.Cmd) (Process, error) {
+	// The child gets its own copies of the pipes, ours must be closed so the
+	// readers see EOF when it exits.
+	defer closeFile(cmd.Stdout)
+	defer closeFile(cmd.Stderr)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return localProcess{cmd}, nil
+}
+
+type localProcess struct {
+	cmd *exec.Cmd
+}
+
+func (p localProcess) Pid() int {
+	return p.cmd.Process.Pid
+}
+
+func (p localProcess) Signal(sig os.Signal) error {
+	return signalProcess(p.cmd.Process, sig)
+}
+
+func (p localProcess) Kill() error {
+	return p.cmd.Process.Kill()
+}
+
+func (p localProcess) Wait() error {
+	return p.cmd.Wait()
+}
+
+// closeFile closes w if it's a file.
+func closeFile(w interface{}) {
+	if f, ok := w.(*os.File); ok {
+		f.Close()
+	}
+}
@@ -0,0 +1,7 @@
+package testcli
+
+import "testing"
+
+func TestLocalExecutorConformance(t *testing.T) {
+	TestExecutorConformance(t, LocalExecutor)
+}
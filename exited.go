@@ -0,0 +1,16 @@
+package testcli
+
+// Exited reports, without waiting, whether the process has exited and, if
+// so, its exit code and the error it exited with, nil on success. Unlike
+// Success() and Error(), which fail the test until the command is finished,
+// it may be called at any time, from any goroutine, which makes it what
+// helpers watching a running command should use. It returns false before
+// Start().
+func (c *Cmd) Exited() (bool, int, error) {
+	select {
+	case <-c.done:
+		return true, exitCodeOf(c.waitErr), c.waitErr
+	default:
+		return false, 0, nil
+	}
+}
@@ -0,0 +1,65 @@
+package testcli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExited(t *testing.T) {
+	c := Command(t, "sh", "-c", "sleep 0.2; exit 3")
+	if exited, _, _ := c.Exited(); exited {
+		t.Fatalf("Expected not to have exited before starting")
+	}
+
+	// Peeking from another goroutine while the command starts and runs.
+	peeked := make(chan bool)
+	go func() {
+		for {
+			if exited, _, _ := c.Exited(); exited {
+				peeked <- true
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	c.Start()
+	if exited, _, _ := c.Exited(); exited {
+		t.Fatalf("Expected not to have exited while running")
+	}
+	<-peeked
+	exited, code, err := c.Exited()
+	if !exited || code != 3 || err == nil {
+		t.Fatalf("Expected to have exited with code 3, got %t, %d, %v", exited, code, err)
+	}
+	c.Wait()
+}
+
+func TestExitedOnSuccess(t *testing.T) {
+	c := Command(t, "true")
+	c.Run()
+	if exited, code, err := c.Exited(); !exited || code != 0 || err != nil {
+		t.Fatalf("Expected to have exited with code 0, got %t, %d, %v", exited, code, err)
+	}
+}
+
+// TestRunTwiceHelperProcess runs the same command twice.
+func TestRunTwiceHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	c := Command(t, "true")
+	c.Run()
+	c.Run()
+}
+
+func TestRunTwice(t *testing.T) {
+	c := ReexecCommand(t, "TestRunTwiceHelperProcess")
+	c.Run()
+	if c.Success() {
+		t.Fatalf("Expected running a command twice to fail the test")
+	}
+	if !c.StdoutContains(ErrCmdAlreadyStarted.Error(), CaseSensitive()) || c.StdoutContains("panic") {
+		t.Fatalf("Expected a failure rather than a panic, got:\n%s", c.Stdout())
+	}
+}
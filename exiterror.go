@@ -0,0 +1,79 @@
+package testcli
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// stderrExcerptSize is how many bytes of stderr an ExitError shows.
+const stderrExcerptSize = 2048
+
+// ExitError is the error of a failed command, see Error(). Its message tells
+// the command line, the exit code and the start of stderr. It wraps the
+// underlying error, e.g. an *exec.ExitError, for errors.As() and errors.Is().
+type ExitError struct {
+	// Command is the command line, quoted for a POSIX shell.
+	Command string
+	// Code is the exit code, -1 if the command was killed by a signal or
+	// couldn't be started.
+	Code int
+	// Stderr is the start of stderr, of the combined output after
+	// CombineOutput(), up to 2KB.
+	Stderr string
+	// Truncated is how many bytes of stderr were left out.
+	Truncated int
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *ExitError) Error() string {
+	message := fmt.Sprintf("%s failed: %s", e.Command, e.Err)
+	if e.Code >= 0 {
+		message = fmt.Sprintf("%s failed with exit code %d: %s", e.Command, e.Code, e.Err)
+	}
+	if e.Stderr == "" {
+		return message
+	}
+	message += "\nstderr:\n" + e.Stderr
+	if e.Truncated > 0 {
+		message += fmt.Sprintf("\n... (%d more bytes)", e.Truncated)
+	}
+	return message
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode is the exit status of the command, see exitCodeOf().
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// newExitError wraps the error of the finished command.
+func (c *Cmd) newExitError() *ExitError {
+	o := c.stderr
+	if c.combined != nil {
+		o = c.combined
+	}
+	o.mu.Lock()
+	stderr := o.text()
+	o.mu.Unlock()
+
+	truncated := 0
+	if len(stderr) > stderrExcerptSize {
+		cut := stderrExcerptSize
+		for cut > 0 && !utf8.RuneStart(stderr[cut]) {
+			cut--
+		}
+		truncated = len(stderr) - cut
+		stderr = stderr[:cut]
+	}
+	return &ExitError{
+		Command:   quoteArgs(c.cmd.Args),
+		Code:      c.exitCode(),
+		Stderr:    stderr,
+		Truncated: truncated,
+		Err:       c.exitError,
+	}
+}
@@ -0,0 +1,65 @@
+package testcli
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestExitError(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", "echo disk full >&2; exit 3")
+	c.Run()
+
+	var exitErr *ExitError
+	if !errors.As(c.Error(), &exitErr) {
+		t.Fatalf("Expected an *ExitError, got %T", c.Error())
+	}
+	if want := "/bin/sh -c 'echo disk full >&2; exit 3' failed with exit code 3: exit status 3\nstderr:\ndisk full\n"; exitErr.Error() != want {
+		t.Fatalf("Expected %q, got %q", want, exitErr.Error())
+	}
+	var execErr *exec.ExitError
+	if !errors.As(c.Error(), &execErr) || execErr.ExitCode() != 3 {
+		t.Fatalf("Expected the *exec.ExitError to be wrapped, got %v", c.Error())
+	}
+}
+
+func TestExitErrorTruncatesStderr(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", "head -c 100000 /dev/zero | tr '\\0' x >&2; exit 1")
+	c.Run()
+
+	var exitErr *ExitError
+	if !errors.As(c.Error(), &exitErr) {
+		t.Fatalf("Expected an *ExitError, got %T", c.Error())
+	}
+	if len(exitErr.Stderr) != stderrExcerptSize || exitErr.Truncated != 100000-stderrExcerptSize {
+		t.Fatalf("Expected stderr to be truncated to %d bytes, got %d, %d left out", stderrExcerptSize, len(exitErr.Stderr), exitErr.Truncated)
+	}
+	if !strings.HasSuffix(exitErr.Error(), "\n... (97952 more bytes)") {
+		t.Fatalf("Expected the message to tell how much was left out, got %q", exitErr.Error()[len(exitErr.Error())-50:])
+	}
+}
+
+func TestExitErrorNotFound(t *testing.T) {
+	c := Command(t, "testcli-no-such-command")
+	c.Run()
+
+	var exitErr *ExitError
+	if !errors.As(c.Error(), &exitErr) || exitErr.Code != -1 || exitErr.Stderr != "" {
+		t.Fatalf("Expected an *ExitError without stderr, got %#v", c.Error())
+	}
+	if !errors.Is(c.Error(), exec.ErrNotFound) {
+		t.Fatalf("Expected the error to match exec.ErrNotFound, got %v", c.Error())
+	}
+	if want := "testcli-no-such-command failed: "; !strings.HasPrefix(exitErr.Error(), want) || strings.Contains(exitErr.Error(), "stderr") {
+		t.Fatalf("Expected %q to start with %q, without stderr", exitErr.Error(), want)
+	}
+}
+
+func TestExitErrorSuccess(t *testing.T) {
+	c := Command(t, "true")
+	c.Run()
+	if err := c.Error(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package testcli
+
+import (
+	"fmt"
+	"time"
+)
+
+// AssertExits fails the test unless the command exits with wantCode within
+// the given time, e.g. after being asked to quit. It doesn't kill the command
+// if it's still running.
+func (c *Cmd) AssertExits(within time.Duration, wantCode int) {
+	c.t.Helper()
+	c.AssertExitsOneOf(within, wantCode)
+}
+
+// AssertExitsOneOf is like AssertExits(), but accepts any of codes, for exit
+// codes which differ between platforms.
+func (c *Cmd) AssertExitsOneOf(within time.Duration, codes ...int) {
+	c.t.Helper()
+	c.validateHasStarted()
+	if err := c.checkExits(within, codes); err != nil {
+		c.fatalf("%s", err)
+	}
+}
+
+// WaitForExit waits up to timeout for the command to exit, and reports
+// whether it did, e.g. to assert it quits within 2 seconds of being asked
+// to. Unlike Wait(), it doesn't close the stdin of the command, and leaves
+// it running if it doesn't exit in time.
+func (c *Cmd) WaitForExit(timeout time.Duration) bool {
+	c.t.Helper()
+	c.validateHasStarted()
+	select {
+	case <-c.done:
+	case <-time.After(timeout):
+		return false
+	case <-c.testDone:
+		return false
+	}
+	c.finish()
+	return true
+}
+
+func (c *Cmd) checkExits(within time.Duration, codes []int) error {
+	c.t.Helper()
+	select {
+	case <-c.done:
+	case <-c.testDone:
+		return errTestOver
+	case <-time.After(within):
+		return fmt.Errorf("Expected to exit within %s, still running as pid %d after %s",
+			within, c.process.Pid(), time.Since(c.startedAt).Round(time.Millisecond))
+	}
+	// The background waiter already reaped the process, this only collects
+	// the output.
+	c.Wait()
+
+	code := c.exitCode()
+	for _, want := range codes {
+		if code == want {
+			return nil
+		}
+	}
+	if len(codes) == 1 {
+		return fmt.Errorf("Expected to exit with code %d, got %d (%v)", codes[0], code, c.exitError)
+	}
+	return fmt.Errorf("Expected to exit with one of the codes %v, got %d (%v)", codes, code, c.exitError)
+}
@@ -0,0 +1,81 @@
+package testcli
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAssertExits(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", `read cmd; [ "$cmd" = quit ] && exit 3`)
+	c.SetStdinSequence(FromString("quit\n"), KeepOpen())
+	c.Start()
+	c.AssertExits(2*time.Second, 3)
+	if !c.Failure() {
+		t.Fatalf("Expected the command to be finished and failed")
+	}
+}
+
+func TestAssertExitsOneOf(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", "exit 2")
+	c.Start()
+	c.AssertExitsOneOf(2*time.Second, 1, 2)
+}
+
+func TestCheckExitsTimeout(t *testing.T) {
+	c := Command(t, "sleep", "10")
+	c.Start()
+	defer c.Kill()
+
+	err := c.checkExits(100*time.Millisecond, []int{0})
+	if err == nil || !strings.Contains(err.Error(), "still running") {
+		t.Fatalf("Expected the command to be reported still running, got %v", err)
+	}
+}
+
+func TestCheckExitsWrongCode(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", "exit 1")
+	c.Start()
+
+	err := c.checkExits(2*time.Second, []int{0, 2})
+	if err == nil || !strings.Contains(err.Error(), "got 1") {
+		t.Fatalf("Expected the wrong exit code to be reported, got %v", err)
+	}
+}
+
+func TestWaitForExit(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", "sleep 0.1; exit 3")
+	c.Start()
+	if !c.WaitForExit(2 * time.Second) {
+		t.Fatalf("Expected the command to exit within 2s")
+	}
+	if code := c.ExitCode(); code != 3 {
+		t.Fatalf("Expected exit code 3, got %d", code)
+	}
+}
+
+func TestWaitForExitTimeout(t *testing.T) {
+	c := Command(t, "sleep", "10")
+	c.Start()
+	defer c.Kill()
+	if c.WaitForExit(50 * time.Millisecond) {
+		t.Fatalf("Expected the command to still be running")
+	}
+	if !c.IsRunning() {
+		t.Fatalf("Expected the command to be left running")
+	}
+}
+
+func TestExitedCommandNeedsNoWait(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", "echo crashed >&2; exit 2")
+	c.Start()
+	for c.IsRunning() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !c.Failure() || c.ExitCode() != 2 {
+		t.Fatalf("Expected the exited command to be failed with code 2, got %d", c.ExitCode())
+	}
+	if !strings.Contains(c.Stderr(), "crashed") {
+		t.Fatalf("Expected the output to be collected, got %q", c.Stderr())
+	}
+}
@@ -0,0 +1,47 @@
+package testcli
+
+import (
+	"regexp"
+	"time"
+)
+
+// ExpectStdout waits for the running command to print prompt on stdout,
+// considering only what it printed since the previous expectation, as
+// expect(1) does, e.g.
+//
+//	c.ExpectStdout("Password:", time.Second)
+//	c.SendLine("hunter2")
+//	c.ExpectStdout("Welcome", time.Second)
+//
+// The test fails, showing what was printed since the previous expectation,
+// if prompt doesn't show up within timeout or the command exits first. The
+// expectations share their offset with Interactive() and WaitForPrompt(), so
+// they shouldn't be mixed.
+func (c *Cmd) ExpectStdout(prompt string, timeout time.Duration) {
+	c.t.Helper()
+	c.validateHasStarted()
+	c.expect(regexp.MustCompile(regexp.QuoteMeta(prompt)), timeout)
+}
+
+// ExpectStdoutMatch is like ExpectStdout(), with a regex.
+func (c *Cmd) ExpectStdoutMatch(pattern string, timeout time.Duration) {
+	c.t.Helper()
+	c.validateHasStarted()
+	c.expect(regexp.MustCompile(pattern), timeout)
+}
+
+func (c *Cmd) expect(re *regexp.Regexp, timeout time.Duration) {
+	c.t.Helper()
+	if err := c.waitForPrompt(re, timeout); err != nil {
+		window, _ := c.promptWindow()
+		c.fatalf("%s, got since the previous expectation:\n%s", err, window)
+	}
+}
+
+// SendLine writes s followed by a newline to the stdin of the running command.
+// Like WriteStdin(), it requires StdinPipe() to be called before Run() or
+// Start().
+func (c *Cmd) SendLine(s string) {
+	c.t.Helper()
+	c.WriteStdin(s + "\n")
+}
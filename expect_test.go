@@ -0,0 +1,48 @@
+package testcli
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const wizardScript = `printf 'name: '; read name; printf 'color: '; read color; echo "hello $name, you like $color"`
+
+func TestExpectStdout(t *testing.T) {
+	c := Command(t, "sh", "-c", wizardScript)
+	c.StdinPipe()
+	c.Start()
+	c.ExpectStdout("name: ", time.Second)
+	c.SendLine("ana")
+	c.ExpectStdout("color: ", time.Second)
+	c.SendLine("blue")
+	c.ExpectStdoutMatch(`hello \w+, you like blue`, time.Second)
+	c.Wait()
+	if !c.Success() {
+		t.Fatalf("Expected the wizard to succeed, got %s", c.Error())
+	}
+}
+
+// TestExpectStdoutHelperProcess expects a prompt twice: the second
+// expectation mustn't match the output matched by the first.
+func TestExpectStdoutHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	c := Command(t, "sh", "-c", wizardScript)
+	c.StdinPipe()
+	c.Start()
+	c.ExpectStdout("name: ", time.Second)
+	c.ExpectStdout("name: ", 200*time.Millisecond)
+}
+
+func TestExpectStdoutMatchesOnlyNewOutput(t *testing.T) {
+	c := ReexecCommand(t, "TestExpectStdoutHelperProcess")
+	c.Run()
+	if c.Success() {
+		t.Fatalf("Expected the second expectation to fail")
+	}
+	if !strings.Contains(c.Stdout(), `Expected the command to prompt "name: " within 200ms`) {
+		t.Fatalf("Expected the failure to name the prompt, got %q", c.Stdout())
+	}
+}
@@ -0,0 +1,70 @@
+package testcli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AssertNoInheritedFDs fails the test if the running command has file
+// descriptors open beyond stdin, stdout, stderr, the extra files passed to it
+// and allowed, listing what they point to. It's meant to be called right
+// after Start(), to catch descriptors leaked from the test process because
+// they weren't close-on-exec; anything the command opened itself by then
+// is reported as well.
+//
+// It relies on /proc, and skips the test on platforms without it. It only
+// makes sense for commands running locally.
+func (c *Cmd) AssertNoInheritedFDs(allowed ...int) {
+	c.t.Helper()
+	c.validateHasStarted()
+	if _, ok := c.executor.(localExecutor); !ok {
+		c.t.Skip("AssertNoInheritedFDs only supports commands running locally")
+	}
+	if _, err := os.Stat("/proc/self/fd"); err != nil {
+		c.t.Skipf("AssertNoInheritedFDs needs /proc: %s", err)
+	}
+	if err := c.checkNoInheritedFDs(allowed); err != nil {
+		c.fatalf("%s", err)
+	}
+}
+
+func (c *Cmd) checkNoInheritedFDs(allowed []int) error {
+	dir := "/proc/" + strconv.Itoa(c.process.Pid()) + "/fd"
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("Can't list the file descriptors of the command: %s", err)
+	}
+
+	expected := map[int]bool{0: true, 1: true, 2: true}
+	for i := range c.cmd.ExtraFiles {
+		expected[3+i] = true
+	}
+	for _, fd := range allowed {
+		expected[fd] = true
+	}
+
+	var unexpected []int
+	for _, entry := range entries {
+		fd, err := strconv.Atoi(entry.Name())
+		if err == nil && !expected[fd] {
+			unexpected = append(unexpected, fd)
+		}
+	}
+	if len(unexpected) == 0 {
+		return nil
+	}
+
+	sort.Ints(unexpected)
+	var lines []string
+	for _, fd := range unexpected {
+		target, err := os.Readlink(dir + "/" + strconv.Itoa(fd))
+		if err != nil {
+			target = "?"
+		}
+		lines = append(lines, fmt.Sprintf("  %d -> %s", fd, target))
+	}
+	return fmt.Errorf("Expected no inherited file descriptors, the command has:\n%s", strings.Join(lines, "\n"))
+}
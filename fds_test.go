@@ -0,0 +1,59 @@
+//go:build linux
+// +build linux
+
+package testcli
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestAssertNoInheritedFDs(t *testing.T) {
+	c := Command(t, "sleep", "10")
+	c.Start()
+	defer c.Kill()
+	c.AssertNoInheritedFDs()
+}
+
+func TestAssertNoInheritedFDsExtraFiles(t *testing.T) {
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	c := Command(t, "sleep", "10")
+	c.cmd.ExtraFiles = []*os.File{f}
+	c.Start()
+	defer c.Kill()
+	c.AssertNoInheritedFDs()
+}
+
+func TestCheckNoInheritedFDsLeak(t *testing.T) {
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// Unlike the descriptors opened by the os package, dup(2)'s aren't
+	// close-on-exec.
+	leaked, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Command(t, "sleep", "10")
+	c.Start()
+	syscall.Close(leaked)
+	defer c.Kill()
+
+	err = c.checkNoInheritedFDs(nil)
+	if err == nil || !strings.Contains(err.Error(), strconv.Itoa(leaked)+" -> /dev/null") {
+		t.Fatalf("Expected the leaked descriptor to be reported, got %v", err)
+	}
+	if err := c.checkNoInheritedFDs([]int{leaked}); err != nil {
+		t.Fatalf("Expected the allowed descriptor to be ignored, got %v", err)
+	}
+}
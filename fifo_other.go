@@ -0,0 +1,14 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package testcli
+
+import "os"
+
+func mkfifo(path string) error {
+	return errFIFOUnsupported
+}
+
+func openFIFOWriter(path string) (*os.File, error) {
+	return nil, errFIFOUnsupported
+}
@@ -0,0 +1,19 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package testcli
+
+import (
+	"os"
+	"syscall"
+)
+
+func mkfifo(path string) error {
+	return syscall.Mkfifo(path, 0644)
+}
+
+// openFIFOWriter opens the FIFO at path for writing, without waiting for a
+// reader: it fails if there's none.
+func openFIFOWriter(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+}
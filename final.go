@@ -0,0 +1,52 @@
+package testcli
+
+import "time"
+
+// finalOutputTimeout is how long assertions on the final output wait for the
+// command to exit.
+const finalOutputTimeout = 10 * time.Second
+
+// FinalOutputOnly makes the Contains and Matches assertions of the command
+// wait for it to exit, then look once at its complete output, instead of
+// retrying while it runs. This catches an expected line followed by an error,
+// which a retrying assertion would accept as soon as the line shows up. If the
+// command doesn't exit within 10 seconds, the assertions return false.
+// Streaming() opts a single assertion out, e.g. to wait for readiness.
+func (c *Cmd) FinalOutputOnly() {
+	c.finalOutputOnly = true
+}
+
+// Final makes a single Contains or Matches assertion evaluate the final
+// output, as FinalOutputOnly() does for all of them.
+func Final() MatchOption {
+	return func(cfg *matchConfig) {
+		cfg.final = true
+	}
+}
+
+// Streaming makes a single Contains or Matches assertion retry while the
+// command runs, even after FinalOutputOnly().
+func Streaming() MatchOption {
+	return func(cfg *matchConfig) {
+		cfg.streaming = true
+	}
+}
+
+// awaitFinal waits for the command to exit and its output to be captured if
+// the assertion configured with opts evaluates the final output, and reports
+// whether it may go on.
+func (c *Cmd) awaitFinal(opts []MatchOption) bool {
+	cfg := matchOptions(opts)
+	if cfg.streaming || !(cfg.final || c.finalOutputOnly) {
+		return true
+	}
+	deadline := time.After(Scaled(finalOutputTimeout))
+	select {
+	case <-c.done:
+	case <-deadline:
+		return false
+	case <-c.testDone:
+		return false
+	}
+	return c.waitCaptured(deadline)
+}
@@ -0,0 +1,40 @@
+package testcli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFinalOutputOnly(t *testing.T) {
+	c := Command(t, "sh", "-c", "echo ok; sleep 0.3; echo error")
+	c.FinalOutputOnly()
+	c.Start()
+
+	if !c.StdoutContains("ok", Streaming()) {
+		t.Fatalf("Expected the streaming assertion to see %q", "ok")
+	}
+	if c.StdoutMatches(`ok\s*$`) {
+		t.Fatalf("Expected the final output %q not to end with %q", c.Stdout(), "ok")
+	}
+	if !c.StdoutContains("error") {
+		t.Fatalf("Expected the final output %q to contain %q", c.Stdout(), "error")
+	}
+	c.Wait()
+}
+
+func TestFinalOption(t *testing.T) {
+	c := Command(t, "sh", "-c", "echo ok; sleep 0.3; echo error")
+	started := time.Now()
+	c.Start()
+
+	if !c.StdoutMatches(`ok\s*$`) {
+		t.Fatalf("Expected the streaming assertion to match before %q is printed", "error")
+	}
+	if c.StdoutMatches(`ok\s*$`, Final()) {
+		t.Fatalf("Expected the final output %q not to end with %q", c.Stdout(), "ok")
+	}
+	if elapsed := time.Since(started); elapsed < 300*time.Millisecond {
+		t.Fatalf("Expected Final() to wait for the command to exit, took %s", elapsed)
+	}
+	c.Wait()
+}
@@ -0,0 +1,44 @@
+package testcli
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrFlockNotSupported is returned when checking file locks on a platform
+// without flock(2).
+var ErrFlockNotSupported = errors.New("flock is not supported on this platform")
+
+// AssertHoldsFlock fails the test unless the running command holds an
+// exclusive flock(2) on path, which is checked with a non-blocking lock
+// attempt from the test process.
+func (c *Cmd) AssertHoldsFlock(path string) {
+	c.t.Helper()
+	c.validateHasStarted()
+	locked, err := isFlocked(path)
+	if err != nil {
+		c.fatalf("Failed to check the lock on %s: %s", path, err)
+	}
+	if !locked {
+		c.fatalf("Expected the command to hold a lock on %s", path)
+	}
+}
+
+// AssertFlockReleasedWithin fails the test unless the lock on path can be
+// taken within d. It's meant to be used after Wait() or Kill().
+func (c *Cmd) AssertFlockReleasedWithin(path string, d time.Duration) {
+	c.t.Helper()
+	c.validateHasStarted()
+	var err error
+	released := poll(func() bool {
+		var locked bool
+		locked, err = isFlocked(path)
+		return err == nil && !locked
+	}, 10*time.Millisecond, d)
+	if err != nil {
+		c.fatalf("Failed to check the lock on %s: %s", path, err)
+	}
+	if !released {
+		c.fatalf("Expected the lock on %s to be released within %s", path, d)
+	}
+}
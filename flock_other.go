@@ -0,0 +1,18 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package testcli
+
+import "os"
+
+func isFlocked(path string) (bool, error) {
+	return false, ErrFlockNotSupported
+}
+
+func tryLockFile(f *os.File) (bool, error) {
+	return false, ErrFlockNotSupported
+}
+
+func unlockFile(f *os.File) error {
+	return ErrFlockNotSupported
+}
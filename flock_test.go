@@ -0,0 +1,47 @@
+//go:build linux
+// +build linux
+
+package testcli
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFlock(t *testing.T) {
+	path := filepath.Join(filepath.Dir(writeScript(t, "")), "state")
+	script := writeScript(t, `
+exec 9>"$1"
+flock -n 9 || { echo "already running" >&2; exit 1; }
+echo locked
+sleep 0.5
+`)
+
+	c := Command(t, script, path)
+	c.Start()
+	if !c.StdoutContains("locked") {
+		t.Fatalf("Expected %q to contain %q", c.Stdout(), "locked")
+	}
+	c.AssertHoldsFlock(path)
+
+	second := Command(t, script, path)
+	second.Run()
+	if !second.Failure() || !second.StderrContains("already running") {
+		t.Fatalf("Expected a second invocation to fail with %q, got %q", "already running", second.Stderr())
+	}
+
+	c.Wait()
+	c.AssertFlockReleasedWithin(path, time.Second)
+}
+
+func TestFlockNotHeld(t *testing.T) {
+	path := writeScript(t, "")
+	locked, err := isFlocked(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locked {
+		t.Fatalf("Expected %s not to be locked", path)
+	}
+}
@@ -0,0 +1,91 @@
+package testcli
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// FlushSpec describes what CheckStreamFlushing expects from a command.
+type FlushSpec struct {
+	Spec
+	// Stream is "stdout" or "stderr", the default.
+	Stream string
+	// Line is a line the command writes shortly after starting.
+	Line string
+	// Within is how soon after starting the line must have been written,
+	// 500ms by default.
+	Within time.Duration
+}
+
+// CheckStreamFlushing starts the command and fails the test unless
+// spec.Line shows up on spec.Stream within spec.Within, proving the stream
+// is flushed as the command goes rather than when it exits. The command is
+// then killed, and the line must still be in the captured output, which is
+// what matters for crash diagnostics.
+func CheckStreamFlushing(t *testing.T, spec FlushSpec) {
+	t.Helper()
+	c := spec.Command(t)
+	if err := c.checkStreamFlushing(spec); err != nil {
+		c.fatalf("%s", err)
+	}
+}
+
+func (c *Cmd) checkStreamFlushing(spec FlushSpec) error {
+	c.t.Helper()
+	within := spec.Within
+	if within == 0 {
+		within = Scaled(500 * time.Millisecond)
+	}
+	stream := withDefault(spec.Stream, "stderr")
+	o := c.stderr
+	if stream == "stdout" {
+		o = c.stdout
+	}
+
+	c.Start()
+	flushed := c.waitFor(fmt.Sprintf("%q on %s", spec.Line, stream), func() bool {
+		arrived, ok := o.arrival(spec.Line)
+		return ok && arrived.Sub(c.startedAt) <= within
+	}, 5*time.Millisecond, within+100*time.Millisecond) == nil
+	c.Kill()
+
+	arrived, ok := o.arrival(spec.Line)
+	if !ok {
+		return fmt.Errorf("Expected %q on %s, it was never written", spec.Line, stream)
+	}
+	if !flushed {
+		return fmt.Errorf("Expected %q on %s within %s, it arrived after %s",
+			spec.Line, stream, within, arrived.Sub(c.startedAt))
+	}
+	return nil
+}
+
+// arrival returns when the first occurrence of s was completely read, if the
+// assertions see it. It's located in the content as read, before filters, on
+// disk too once spilled by SpillCaptureToDisk().
+func (o *output) arrival(s string) (time.Time, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if !strings.Contains(o.text(), s) {
+		return time.Time{}, false
+	}
+	content := o.content
+	if o.spilled() {
+		content = o.spilledText()
+	}
+	i := strings.Index(content, s)
+	if i < 0 {
+		return time.Time{}, false
+	}
+	end, read := i+len(s), 0
+	for _, ch := range o.chunks {
+		read += ch.n
+		if read >= end {
+			return ch.at, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,64 @@
+package testcli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckStreamFlushing(t *testing.T) {
+	CheckStreamFlushing(t, FlushSpec{
+		Spec: Spec{Name: "/bin/bash", Args: []string{"-c", "echo starting >&2; exec sleep 10"}},
+		Line: "starting",
+	})
+}
+
+func TestStreamFlushingTooLate(t *testing.T) {
+	spec := FlushSpec{
+		Spec:   Spec{Name: "/bin/bash", Args: []string{"-c", "sleep 0.5; echo starting; exec sleep 10"}},
+		Stream: "stdout",
+		Line:   "starting",
+		Within: 100 * time.Millisecond,
+	}
+	if err := spec.Command(t).checkStreamFlushing(spec); err == nil {
+		t.Fatalf("Expected a line written after 500ms not to count as flushed within 100ms")
+	}
+}
+
+func TestOutputArrival(t *testing.T) {
+	c := Command(t, "/bin/bash", "-c", "printf 'first '; sleep 0.3; echo second")
+	c.Run()
+
+	first, ok := c.stdout.arrival("first")
+	if !ok {
+		t.Fatalf("Expected %q to have arrived", "first")
+	}
+	second, ok := c.stdout.arrival("second")
+	if !ok {
+		t.Fatalf("Expected %q to have arrived", "second")
+	}
+	if gap := second.Sub(first); gap < 200*time.Millisecond {
+		t.Fatalf("Expected %q to arrive about 300ms after %q, got %s", "second", "first", gap)
+	}
+
+	if _, ok := c.stdout.arrival("third"); ok {
+		t.Fatalf("Expected %q not to have arrived", "third")
+	}
+}
+
+func TestOutputArrivalSpilled(t *testing.T) {
+	c := Command(t, "/bin/bash", "-c", "printf 'first '; sleep 0.3; echo second")
+	c.SpillCaptureToDisk(t.TempDir(), 4)
+	c.Run()
+
+	first, ok := c.stdout.arrival("first")
+	if !ok {
+		t.Fatalf("Expected %q to have arrived", "first")
+	}
+	second, ok := c.stdout.arrival("second")
+	if !ok {
+		t.Fatalf("Expected %q to have arrived once spilled to disk", "second")
+	}
+	if gap := second.Sub(first); gap < 200*time.Millisecond {
+		t.Fatalf("Expected %q to arrive about 300ms after %q, got %s", "second", "first", gap)
+	}
+}
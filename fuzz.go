@@ -0,0 +1,63 @@
+package testcli
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// FuzzSpec describes the command run by FuzzCommand().
+type FuzzSpec struct {
+	Spec
+	// Package, if set, is a main package built once, see Build(), whose
+	// binary replaces Name.
+	Package string
+	// ArgsFromData, if set, maps the fuzz data to arguments appended to
+	// Spec.Args, instead of feeding it to stdin.
+	ArgsFromData func(data []byte) []string
+	// Timeout is how long an iteration may run before being considered a
+	// hang, 1 second by default.
+	Timeout time.Duration
+	// MaxOutput is how many bytes of each stream are captured, 64KiB by
+	// default. The rest is discarded, to keep iterations fast.
+	MaxOutput int
+}
+
+// command constructs the command of an iteration fed data.
+func (s FuzzSpec) command(t *testing.T, data []byte) *Cmd {
+	c := s.Spec.Command(t)
+	if s.ArgsFromData != nil {
+		c.cmd.Args = append(c.cmd.Args, s.ArgsFromData(data)...)
+	} else {
+		c.SetStdin(bytes.NewReader(data))
+	}
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = Scaled(time.Second)
+	}
+	limit := s.MaxOutput
+	if limit == 0 {
+		limit = 64 << 10
+	}
+	c.Apply(WithTimeout(timeout, Immediate()))
+	c.stdout.limit = limit
+	c.stderr.limit = limit
+	return c
+}
+
+// checkFuzzOutcome returns an error if the finished command hung or crashed:
+// it timed out, was killed by a signal or printed a failure report, see
+// DetectFailureReports(). Exiting normally, with any code, is fine.
+func checkFuzzOutcome(c *Cmd) error {
+	if reason := c.TerminationReason(); reason.TimedOut {
+		return fmt.Errorf("hang: still running after %s", c.timeout)
+	}
+	if c.exitCode() == -1 {
+		return fmt.Errorf("crash: %s", c.exitError)
+	}
+	if label, report, ok := findFailureReport(c.Stderr()); ok {
+		return fmt.Errorf("crash: %s: %s", label, report)
+	}
+	return nil
+}
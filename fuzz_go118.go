@@ -0,0 +1,44 @@
+//go:build go1.18
+// +build go1.18
+
+package testcli
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// FuzzCommand runs the command of spec for each input generated by the fuzz
+// target f, fed to its stdin, or mapped to arguments by spec.ArgsFromData.
+// An input fails if the command hangs or crashes, see FuzzSpec, and then is
+// saved and minimized by `go test -fuzz` as usual. check, if not nil, makes
+// further assertions on the finished command. The iterations share a working
+// directory, spec.Dir or a new one.
+func FuzzCommand(f *testing.F, spec FuzzSpec, check func(t *testing.T, c *Cmd, data []byte)) {
+	f.Helper()
+	if spec.Package != "" {
+		spec.Name = Build(f, spec.Package)
+	}
+	if spec.Dir == "" {
+		dir, err := ioutil.TempDir("", "testcli-fuzz")
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Cleanup(func() {
+			os.RemoveAll(dir)
+		})
+		spec.Dir = dir
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		c := spec.command(t, data)
+		c.Run()
+		if err := checkFuzzOutcome(c); err != nil {
+			c.fatalf("%s", err)
+		}
+		if check != nil {
+			check(t, c, data)
+		}
+	})
+}
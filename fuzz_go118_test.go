@@ -0,0 +1,22 @@
+//go:build go1.18
+// +build go1.18
+
+package testcli
+
+import (
+	"bytes"
+	"testing"
+)
+
+func FuzzCommandEcho(f *testing.F) {
+	f.Add([]byte("hello"))
+	f.Add([]byte{0, 1, 2})
+	FuzzCommand(f, FuzzSpec{Package: "./testdata/fuzzme"}, func(t *testing.T, c *Cmd, data []byte) {
+		if bytes.Contains(data, []byte("crash")) {
+			return
+		}
+		if c.Stdout() != string(data) {
+			t.Fatalf("Expected %q to be %q", c.Stdout(), data)
+		}
+	})
+}
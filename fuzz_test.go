@@ -0,0 +1,52 @@
+package testcli
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckFuzzOutcome(t *testing.T) {
+	fuzzme := Build(t, "./testdata/fuzzme")
+	tests := []struct {
+		name     string
+		spec     FuzzSpec
+		data     string
+		expected string
+	}{
+		{"normal", FuzzSpec{Spec: Spec{Name: fuzzme}}, "hello", ""},
+		{"non-zero exit", FuzzSpec{Spec: Spec{Name: "sh", Args: []string{"-c", "exit 1"}}}, "", ""},
+		{"panic", FuzzSpec{Spec: Spec{Name: fuzzme}}, "crash", "crash: Go panic: panic: crashed on crash"},
+		{"signal", FuzzSpec{Spec: Spec{Name: "sh", Args: []string{"-c", "kill -SEGV $$"}}}, "", "crash: signal: segmentation fault"},
+		{"sanitizer", FuzzSpec{Spec: Spec{Name: "sh", Args: []string{"-c", "echo '==42==ERROR: AddressSanitizer: heap-buffer-overflow' >&2; exit 1"}}}, "", "crash: AddressSanitizer: ==42==ERROR"},
+		{"hang", FuzzSpec{Spec: Spec{Name: "sleep", Args: []string{"5"}}, Timeout: 50 * time.Millisecond}, "", "hang: still running after 50ms"},
+		{"args", FuzzSpec{Spec: Spec{Name: "sh", Args: []string{"-c", `test "$0" = crash && kill -ABRT $$`}}, ArgsFromData: func(data []byte) []string {
+			return []string{string(data)}
+		}}, "crash", "crash: signal: aborted"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			c := test.spec.command(t, []byte(test.data))
+			c.Run()
+			err := checkFuzzOutcome(c)
+			if test.expected == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %q", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), test.expected) {
+				t.Fatalf("Expected an error starting with %q, got %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestFuzzSpecMaxOutput(t *testing.T) {
+	c := FuzzSpec{Spec: Spec{Name: "head", Args: []string{"-c", "100000", "/dev/zero"}}, MaxOutput: 10}.command(t, nil)
+	c.Run()
+	if len(c.Stdout()) != 10 {
+		t.Fatalf("Expected 10 bytes of stdout, got %d", len(c.Stdout()))
+	}
+}
@@ -0,0 +1,110 @@
+package testcli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// globalLockTimeout is how long WithGlobalLock() waits for the lock.
+const globalLockTimeout = 2 * time.Minute
+
+// globalLock is a lock shared by every process on the machine, see
+// WithGlobalLock().
+type globalLock struct {
+	name    string
+	path    string
+	file    *os.File
+	release sync.Once
+}
+
+var unsafeLockChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// WithGlobalLock makes the command hold the lock name, shared by every test
+// process on the machine, from before it starts until it exits: commands
+// with the same lock never run at the same time, even from the test binaries
+// of different packages run in parallel by `go test`. The test fails if the
+// lock isn't available within 2 minutes, naming the process holding it. The
+// lock is a file in os.TempDir(), locked with flock(2), or LockFileEx on
+// Windows.
+func WithGlobalLock(name string) Option {
+	return func(c *Cmd) {
+		c.globalLock = &globalLock{
+			name: name,
+			path: filepath.Join(os.TempDir(), "testcli-lock-"+unsafeLockChars.ReplaceAllString(name, "_")),
+		}
+	}
+}
+
+// acquire takes the lock, polling until timeout, then records who holds it
+// in the lock file.
+func (l *globalLock) acquire(owner string, timeout time.Duration) error {
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	var lockErr error
+	locked := poll(func() bool {
+		var ok bool
+		ok, lockErr = tryLockFile(f)
+		return ok || lockErr != nil
+	}, 50*time.Millisecond, timeout)
+	if lockErr != nil || !locked {
+		f.Close()
+		if lockErr != nil {
+			return fmt.Errorf("Failed to take the global lock %q: %s", l.name, lockErr)
+		}
+		return fmt.Errorf("Timed out after %s waiting for the global lock %q (%s), held by %s", timeout, l.name, l.path, l.holder())
+	}
+
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(owner), 0)
+	}
+	l.file = f
+	return nil
+}
+
+// holder describes the process holding the lock, as recorded in the lock
+// file.
+func (l *globalLock) holder() string {
+	owner, err := ioutil.ReadFile(l.path)
+	if err != nil || len(owner) == 0 {
+		return "an unknown process"
+	}
+	return strings.TrimSpace(string(owner))
+}
+
+// unlock releases the lock, once.
+func (l *globalLock) unlock() {
+	l.release.Do(func() {
+		if l.file != nil {
+			l.file.Truncate(0)
+			unlockFile(l.file)
+			l.file.Close()
+		}
+	})
+}
+
+// acquireGlobalLock takes the lock of WithGlobalLock(), if any, until the
+// command exits or the test ends.
+func (c *Cmd) acquireGlobalLock() {
+	c.t.Helper()
+	l := c.globalLock
+	if l == nil {
+		return
+	}
+	owner := fmt.Sprintf("pid %d (%s, running %s)", os.Getpid(), c.t.Name(), c.Repro())
+	if err := l.acquire(owner, Scaled(globalLockTimeout)); err != nil {
+		c.t.Fatal(err)
+	}
+	c.t.Cleanup(l.unlock)
+	go func() {
+		<-c.done
+		l.unlock()
+	}()
+}
@@ -0,0 +1,53 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package testcli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithGlobalLock(t *testing.T) {
+	name := fmt.Sprintf("test-%d", os.Getpid())
+	defer os.Remove(filepath.Join(os.TempDir(), "testcli-lock-"+name))
+	a := Command(t, "sleep", "0.3")
+	a.Apply(WithGlobalLock(name))
+	a.Start()
+
+	b := Command(t, "true")
+	b.Apply(WithGlobalLock(name))
+	b.Start()
+	if b.startedAt.Before(a.exitedAt) {
+		t.Fatalf("Expected the second command to start after the first exited, started %s before", a.exitedAt.Sub(b.startedAt))
+	}
+	a.Wait()
+	b.Wait()
+}
+
+func TestGlobalLockTimeout(t *testing.T) {
+	held := &globalLock{name: "held", path: filepath.Join(t.TempDir(), "lock")}
+	if err := held.acquire("pid 42 (TestSomething)", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	defer held.unlock()
+
+	waiting := &globalLock{name: "held", path: held.path}
+	err := waiting.acquire("pid 43", 100*time.Millisecond)
+	if err == nil {
+		t.Fatalf("Expected to time out while the lock is held")
+	}
+	if !strings.Contains(err.Error(), `global lock "held"`) || !strings.Contains(err.Error(), "held by pid 42 (TestSomething)") {
+		t.Fatalf("Expected %q to name the lock and its holder", err)
+	}
+
+	held.unlock()
+	if err := waiting.acquire("pid 43", time.Second); err != nil {
+		t.Fatalf("Expected the released lock to be available: %s", err)
+	}
+	waiting.unlock()
+}
@@ -0,0 +1,199 @@
+package testcli
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+var updateGolden = flag.Bool("testcli.update", false, "regenerate golden files instead of comparing against them")
+
+// goldenHeaderPrefix starts the optional first line of a golden file which
+// declares how to compare against it, e.g.
+//
+//	# testcli-golden: crlf,utf-8,scrub=timestamps
+const goldenHeaderPrefix = "# testcli-golden:"
+
+const byteOrderMark = "\ufeff"
+
+var (
+	scrubbersMu sync.Mutex
+	scrubbers   = map[string]func(string) string{
+		"timestamps": regexpScrubber(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?|\b\d{2}:\d{2}:\d{2}(\.\d+)?\b`, "<TIMESTAMP>"),
+		"uuids":      regexpScrubber(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`, "<UUID>"),
+	}
+)
+
+// RegisterScrubber makes scrub available to golden file headers as
+// "scrub=name". It's applied to both the output and the golden file before
+// comparing them. "timestamps" and "uuids" are built in.
+func RegisterScrubber(name string, scrub func(string) string) {
+	scrubbersMu.Lock()
+	defer scrubbersMu.Unlock()
+	scrubbers[name] = scrub
+}
+
+func regexpScrubber(regex, replacement string) func(string) string {
+	re := regexp.MustCompile(regex)
+	return func(s string) string {
+		return re.ReplaceAllString(s, replacement)
+	}
+}
+
+// AssertStdoutGolden fails the test unless stdout matches the content of the
+// golden file at path. When the tests run with -testcli.update, the file is
+// written with stdout instead.
+//
+// Without a header the comparison is exact. A first line like
+//
+//	# testcli-golden: crlf,utf-8,scrub=timestamps
+//
+// isn't part of the expected content, and relaxes the comparison:
+//   - "crlf" or "lf" ignores line endings, which editors and git autocrlf
+//     like to change, and regenerates the file with the declared one.
+//   - "utf-8" ignores a byte order mark, and requires stdout to be valid
+//     UTF-8.
+//   - "scrub=name" replaces the volatile parts of both sides, see
+//     RegisterScrubber(). It can be repeated.
+//
+// The header is kept when the file is regenerated.
+func (c *Cmd) AssertStdoutGolden(path string) {
+	c.t.Helper()
+	if c.dryRun && !*updateGolden {
+		if _, err := os.Stat(path); err != nil {
+			c.t.Fatalf("Golden file %s is missing: %s", path, err)
+		}
+	}
+	c.validateIsFinished()
+	if err := checkGolden(c.Stdout(), "stdout", path, *updateGolden); err != nil {
+		c.fatalf("%s", err)
+	}
+	c.stdout.examineAll()
+}
+
+// goldenHeader is the parsed header of a golden file.
+type goldenHeader struct {
+	line      string
+	eol       string
+	utf8      bool
+	scrubbers []func(string) string
+}
+
+// parseGolden splits the content of a golden file into its header, nil if
+// there's none, and the expected content.
+func parseGolden(content string) (*goldenHeader, string, error) {
+	if !strings.HasPrefix(strings.TrimPrefix(content, byteOrderMark), goldenHeaderPrefix) {
+		return nil, content, nil
+	}
+	content = strings.TrimPrefix(content, byteOrderMark)
+	line, body := content, ""
+	if i := strings.Index(content, "\n"); i >= 0 {
+		line, body = content[:i], content[i+1:]
+	}
+	line = strings.TrimSuffix(line, "\r")
+
+	h := &goldenHeader{line: line}
+	scrubbersMu.Lock()
+	defer scrubbersMu.Unlock()
+	for _, opt := range strings.Split(strings.TrimPrefix(line, goldenHeaderPrefix), ",") {
+		opt = strings.TrimSpace(opt)
+		switch {
+		case opt == "crlf":
+			h.eol = "\r\n"
+		case opt == "lf":
+			h.eol = "\n"
+		case opt == "utf-8":
+			h.utf8 = true
+		case strings.HasPrefix(opt, "scrub="):
+			scrub, ok := scrubbers[strings.TrimPrefix(opt, "scrub=")]
+			if !ok {
+				return nil, "", fmt.Errorf("Unknown scrubber in golden file header %q", line)
+			}
+			h.scrubbers = append(h.scrubbers, scrub)
+		case opt == "":
+		default:
+			return nil, "", fmt.Errorf("Unknown option %q in golden file header %q", opt, line)
+		}
+	}
+	return h, body, nil
+}
+
+// normalize returns s as it's compared, and written, under the header.
+func (h *goldenHeader) normalize(s string) string {
+	if h.utf8 {
+		s = strings.TrimPrefix(s, byteOrderMark)
+	}
+	if h.eol != "" {
+		s = strings.ReplaceAll(s, "\r\n", "\n")
+	}
+	for _, scrub := range h.scrubbers {
+		s = scrub(s)
+	}
+	return s
+}
+
+// encode returns the content of the golden file holding s.
+func (h *goldenHeader) encode(s string) string {
+	s = h.normalize(s)
+	eol := h.eol
+	if eol == "" {
+		eol = "\n"
+	}
+	return strings.ReplaceAll(h.line+"\n"+s, "\n", eol)
+}
+
+// checkGolden compares actual against the golden file at path, or writes it
+// there if update is set.
+func checkGolden(actual, what, path string, update bool) error {
+	return checkNormalizedGolden(actual, what, path, update, nil)
+}
+
+// checkNormalizedGolden is checkGolden(), applying normalize, if not nil, to
+// both sides before comparing them, and to actual before writing it.
+func checkNormalizedGolden(actual, what, path string, update bool, normalize func(string) string) error {
+	if normalize != nil {
+		actual = normalize(actual)
+	}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil && !(update && os.IsNotExist(err)) {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("Golden file %s doesn't exist, run the tests with -testcli.update to create it", path)
+		}
+		return err
+	}
+	header, expected, err := parseGolden(string(raw))
+	if err != nil {
+		return err
+	}
+	if normalize != nil {
+		expected = normalize(expected)
+	}
+
+	if update {
+		content := actual
+		if header != nil {
+			content = header.encode(actual)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(path, []byte(content), 0644)
+	}
+
+	if header != nil {
+		if header.utf8 && !utf8.ValidString(actual) {
+			return fmt.Errorf("Expected %s to be valid UTF-8, as declared by golden file %s", what, path)
+		}
+		actual, expected = header.normalize(actual), header.normalize(expected)
+	}
+	if actual != expected {
+		return fmt.Errorf("Expected %s to match golden file %s (run the tests with -testcli.update to regenerate it):\n%s", what, path, unifiedDiff(expected, actual))
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package testcli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func goldenFile(t *testing.T, content string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "testcli-golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	path := filepath.Join(dir, "out.golden")
+	if content != "" {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestAssertStdoutGolden(t *testing.T) {
+	c := Command(t, "printf", `a\nb\n`)
+	c.Run()
+	c.AssertStdoutGolden(goldenFile(t, "a\nb\n"))
+}
+
+func TestGoldenWithoutHeaderIsExact(t *testing.T) {
+	path := goldenFile(t, "a\r\nb\r\n")
+	if err := checkGolden("a\nb\n", "stdout", path, false); err == nil {
+		t.Fatalf("Expected line endings to matter without a header")
+	}
+}
+
+func TestGoldenHeader(t *testing.T) {
+	header := "# testcli-golden: crlf,utf-8,scrub=timestamps\r\n"
+	path := goldenFile(t, byteOrderMark+header+"started at <TIMESTAMP>\r\ndone\r\n")
+	if err := checkGolden("started at 2024-01-02T03:04:05Z\ndone\n", "stdout", path, false); err != nil {
+		t.Fatal(err)
+	}
+
+	err := checkGolden("started at 2024-01-02T03:04:05Z\nfailed\n", "stdout", path, false)
+	if err == nil || !strings.Contains(err.Error(), "-testcli.update") {
+		t.Fatalf("Expected a mismatch suggesting -testcli.update, got %v", err)
+	}
+	if err := checkGolden("bad \xff\ndone\n", "stdout", path, false); err == nil {
+		t.Fatalf("Expected invalid UTF-8 to be reported")
+	}
+}
+
+func TestGoldenHeaderUnknownOption(t *testing.T) {
+	path := goldenFile(t, "# testcli-golden: scrub=nope\n")
+	if err := checkGolden("", "stdout", path, false); err == nil {
+		t.Fatalf("Expected the unknown scrubber to be reported")
+	}
+}
+
+func TestGoldenUpdateKeepsHeader(t *testing.T) {
+	path := goldenFile(t, "# testcli-golden: crlf,scrub=uuids\nold\n")
+	if err := checkGolden("id 123e4567-e89b-12d3-a456-426614174000\nnew\n", "stdout", path, true); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "# testcli-golden: crlf,scrub=uuids\r\nid <UUID>\r\nnew\r\n"
+	if string(content) != expected {
+		t.Fatalf("Expected %q to be %q", content, expected)
+	}
+}
+
+func TestGoldenUpdateCreatesFile(t *testing.T) {
+	path := filepath.Join(filepath.Dir(goldenFile(t, "")), "testdata", "new.golden")
+	if err := checkGolden("fresh\n", "stdout", path, false); err == nil {
+		t.Fatalf("Expected the missing golden file to be reported")
+	}
+	if err := checkGolden("fresh\n", "stdout", path, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkGolden("fresh\n", "stdout", path, false); err != nil {
+		t.Fatal(err)
+	}
+}
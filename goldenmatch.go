@@ -0,0 +1,90 @@
+package testcli
+
+import (
+	"regexp"
+	"strings"
+)
+
+// GoldenOption configures StdoutMatchesGolden() and its variants.
+type GoldenOption func(*goldenConfig)
+
+type goldenConfig struct {
+	update       bool
+	replacements []goldenReplacement
+}
+
+type goldenReplacement struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// GoldenReplace replaces the matches of regex with replacement, which may
+// refer to its submatches as regexp.ReplaceAllString() does, in both the
+// output and the golden file before comparing them, e.g. for timestamps or
+// temporary paths. The golden file is written with the replacement too.
+func GoldenReplace(regex, replacement string) GoldenOption {
+	re := regexp.MustCompile(regex)
+	return func(cfg *goldenConfig) {
+		cfg.replacements = append(cfg.replacements, goldenReplacement{re: re, replacement: replacement})
+	}
+}
+
+// GoldenUpdate rewrites the golden file with the output instead of comparing
+// them if update is set, for tests with an update flag of their own. The
+// file is always rewritten when the tests run with -testcli.update.
+func GoldenUpdate(update bool) GoldenOption {
+	return func(cfg *goldenConfig) {
+		cfg.update = cfg.update || update
+	}
+}
+
+// StdoutMatchesGolden compares the stdout of the finished command with the
+// golden file at path, ignoring line endings, and reports whether they
+// match. If they don't, the test is marked as failed, showing their line
+// diff. With -testcli.update, or GoldenUpdate(), the file is written with
+// stdout instead. Unlike AssertStdoutGolden(), the comparison is configured
+// by opts rather than by a header in the file, though one is honored too.
+func (c *Cmd) StdoutMatchesGolden(path string, opts ...GoldenOption) bool {
+	c.t.Helper()
+	c.validateIsFinished()
+	c.validateNotCombined()
+	if !c.matchesGolden(c.Stdout(), "stdout", path, opts) {
+		return false
+	}
+	c.stdout.examineAll()
+	return true
+}
+
+// StderrMatchesGolden is StdoutMatchesGolden() for stderr.
+func (c *Cmd) StderrMatchesGolden(path string, opts ...GoldenOption) bool {
+	c.t.Helper()
+	c.validateIsFinished()
+	c.validateNotCombined()
+	return c.matchesGolden(c.Stderr(), "stderr", path, opts)
+}
+
+// CombinedMatchesGolden is StdoutMatchesGolden() for the combined output, see
+// CombineOutput().
+func (c *Cmd) CombinedMatchesGolden(path string, opts ...GoldenOption) bool {
+	c.t.Helper()
+	c.validateIsFinished()
+	return c.matchesGolden(c.CombinedOutput(), "output", path, opts)
+}
+
+// matchesGolden compares actual, named what in messages, with the golden
+// file at path, marking the test as failed if they differ.
+func (c *Cmd) matchesGolden(actual, what, path string, opts []GoldenOption) bool {
+	c.t.Helper()
+	cfg := &goldenConfig{update: *updateGolden}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	normalize := func(s string) string {
+		s = strings.ReplaceAll(s, "\r\n", "\n")
+		for _, r := range cfg.replacements {
+			s = r.re.ReplaceAllString(s, r.replacement)
+		}
+		return s
+	}
+	return c.check(checkNormalizedGolden(actual, what, path, cfg.update, normalize))
+}
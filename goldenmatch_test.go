@@ -0,0 +1,60 @@
+package testcli
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestStdoutMatchesGolden(t *testing.T) {
+	c := Command(t, "sh", "-c", `printf 'wrote /tmp/tmp.Xy12/out\r\ntook 35ms\r\n'; echo oops >&2`)
+	c.Run()
+	opts := []GoldenOption{
+		GoldenReplace(`/tmp/tmp\.\w+`, "<TMP>"),
+		GoldenReplace(`\d+ms`, "<DURATION>"),
+	}
+	if !c.StdoutMatchesGolden(goldenFile(t, "wrote <TMP>/out\ntook <DURATION>\n"), opts...) {
+		return
+	}
+	c.StderrMatchesGolden(goldenFile(t, "oops\n"))
+}
+
+func TestCombinedMatchesGolden(t *testing.T) {
+	c := Command(t, "sh", "-c", "echo out; echo err >&2")
+	c.CombineOutput()
+	c.Run()
+	c.CombinedMatchesGolden(goldenFile(t, "out\nerr\n"))
+}
+
+func TestMatchesGoldenUpdate(t *testing.T) {
+	c := Command(t, "sh", "-c", "echo took 12ms")
+	c.Run()
+	path := goldenFile(t, "stale\n")
+	c.StdoutMatchesGolden(path, GoldenUpdate(true), GoldenReplace(`\d+ms`, "<DURATION>"))
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "took <DURATION>\n" {
+		t.Fatalf("Expected the golden file to be written with the replacements, got %q", content)
+	}
+}
+
+// TestMatchesGoldenHelperProcess compares stdout with a golden file it
+// doesn't match.
+func TestMatchesGoldenHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	c := Command(t, "printf", `a\nB\nc\n`)
+	c.Run()
+	c.StdoutMatchesGolden(goldenFile(t, "a\nb\nc\n"))
+}
+
+func TestMatchesGoldenShowsDiff(t *testing.T) {
+	c := ReexecCommand(t, "TestMatchesGoldenHelperProcess")
+	c.Run()
+	if c.Success() || !strings.Contains(c.Stdout(), "@@ -1,3 +1,3 @@") || !strings.Contains(c.Stdout(), "-b\n        +B\n") {
+		t.Fatalf("Expected the mismatch to fail the test with a diff, got %q", c.Stdout())
+	}
+}
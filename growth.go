@@ -0,0 +1,96 @@
+package testcli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// growthTolerance is how much a file may grow on every run without being
+// considered to grow unboundedly, e.g. by a line appended to a log.
+const growthTolerance = 512
+
+// CheckBoundedGrowth runs the command runs times in a new workspace, and
+// samples the size of path, relative to it, after each run: the size of a
+// file, or the total size of the files in a directory. The test fails, with
+// the series of sizes, if a run makes it grow by more than maxGrowthPerRun
+// bytes, or if it keeps growing by more than 512 bytes on every run after the
+// first. The series is logged when tests run with -v.
+func CheckBoundedGrowth(t *testing.T, spec Spec, runs int, path string, maxGrowthPerRun int64) {
+	t.Helper()
+	ws := NewWorkspace(t)
+	if spec.Dir == "" {
+		spec.Dir = ws.Root()
+	}
+
+	sizes := make([]int64, runs)
+	for i := range sizes {
+		c := spec.Command(t)
+		c.Run()
+		if !c.Success() {
+			c.fatalf("Expected run %d to succeed, but failed with error: %s", i+1, c.Error())
+		}
+		size, err := diskUsage(ws.Path(path))
+		if err != nil {
+			t.Fatalf("Failed to measure %s after run %d: %s", path, i+1, err)
+		}
+		sizes[i] = size
+	}
+	if testing.Verbose() {
+		t.Logf("Size of %s after each run: %s", path, growthSeries(sizes))
+	}
+	if err := checkGrowth(sizes, maxGrowthPerRun); err != nil {
+		t.Fatalf("%s: %s\nsize after each run: %s", path, err, growthSeries(sizes))
+	}
+}
+
+// checkGrowth checks the sizes sampled after each run.
+func checkGrowth(sizes []int64, maxGrowthPerRun int64) error {
+	steadily := len(sizes) > 2
+	for i := 1; i < len(sizes); i++ {
+		growth := sizes[i] - sizes[i-1]
+		if growth > maxGrowthPerRun {
+			return fmt.Errorf("run %d grew it by %d bytes, more than %d", i+1, growth, maxGrowthPerRun)
+		}
+		if growth <= growthTolerance {
+			steadily = false
+		}
+	}
+	if steadily {
+		return fmt.Errorf("it grew by more than %d bytes on every run after the first", growthTolerance)
+	}
+	return nil
+}
+
+// growthSeries formats sizes along with the growth between them.
+func growthSeries(sizes []int64) string {
+	parts := make([]string, len(sizes))
+	for i, size := range sizes {
+		parts[i] = fmt.Sprint(size)
+		if i > 0 {
+			parts[i] += fmt.Sprintf(" (%+d)", size-sizes[i-1])
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// diskUsage returns the size of the file at path, or the total size of the
+// files under it if it's a directory, 0 if it doesn't exist.
+func diskUsage(path string) (int64, error) {
+	var total int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			total += info.Size()
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	return total, err
+}
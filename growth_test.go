@@ -0,0 +1,47 @@
+package testcli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckBoundedGrowth(t *testing.T) {
+	// A log line per run, and state rewritten rather than appended to.
+	spec := Spec{Name: "sh", Args: []string{"-c", "mkdir -p state; echo run >> state/log; echo 42 > state/counter"}}
+	CheckBoundedGrowth(t, spec, 5, "state", 100)
+}
+
+func TestCheckGrowth(t *testing.T) {
+	tests := []struct {
+		name     string
+		sizes    []int64
+		max      int64
+		expected string
+	}{
+		{"stable", []int64{100, 100, 100}, 10, ""},
+		{"first run only", []int64{5000, 5000, 5000}, 10, ""},
+		{"too much", []int64{0, 10, 2000, 2010}, 1000, "run 3 grew it by 1990 bytes, more than 1000"},
+		{"steadily", []int64{0, 1000, 2000, 3000}, 1000, "on every run after the first"},
+		{"settles", []int64{0, 1000, 2000, 2000}, 1000, ""},
+		{"small steps", []int64{0, 10, 20, 30}, 1000, ""},
+	}
+	for _, test := range tests {
+		err := checkGrowth(test.sizes, test.max)
+		if test.expected == "" {
+			if err != nil {
+				t.Errorf("%s: expected no error, got %q", test.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%s: expected an error containing %q, got %v", test.name, test.expected, err)
+		}
+	}
+}
+
+func TestGrowthSeries(t *testing.T) {
+	expected := "10, 30 (+20), 25 (-5)"
+	if got := growthSeries([]int64{10, 30, 25}); got != expected {
+		t.Fatalf("Expected %q to be %q", got, expected)
+	}
+}
@@ -0,0 +1,39 @@
+package testcli
+
+import (
+	"fmt"
+	"time"
+)
+
+// AssertIncrementalOutput fails the test unless the command wrote its stdout
+// as it went, rather than all at once on exit. A write event is a single
+// read from the stdout pipe; at least minChunks of them must have happened,
+// and the first byte must have arrived more than maxGap before the process
+// exited.
+func (c *Cmd) AssertIncrementalOutput(minChunks int, maxGap time.Duration) {
+	c.t.Helper()
+	c.validateIsFinished()
+	if err := c.checkIncrementalOutput(minChunks, maxGap); err != nil {
+		c.fatalf("%s", err)
+	}
+}
+
+func (c *Cmd) checkIncrementalOutput(minChunks int, maxGap time.Duration) error {
+	c.stdout.mu.Lock()
+	chunks := append([]chunk(nil), c.stdout.chunks...)
+	c.stdout.mu.Unlock()
+
+	if len(chunks) < minChunks {
+		return fmt.Errorf("Expected stdout to arrive in at least %d chunks, got %d", minChunks, len(chunks))
+	}
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	first := chunks[0].at
+	if gap := c.exitedAt.Sub(first); gap <= maxGap {
+		return fmt.Errorf("Expected stdout to start more than %s before exit, first byte arrived %s before exit (%s after start)",
+			maxGap, gap, first.Sub(c.startedAt))
+	}
+	return nil
+}
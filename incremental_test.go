@@ -0,0 +1,24 @@
+package testcli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAssertIncrementalOutput(t *testing.T) {
+	c := Command(t, "/bin/bash", "-c", "echo a; sleep 0.2; echo b; sleep 0.2; echo c")
+	c.Run()
+	c.AssertIncrementalOutput(3, 300*time.Millisecond)
+}
+
+func TestIncrementalOutputBuffered(t *testing.T) {
+	c := Command(t, "/bin/bash", "-c", "sleep 0.3; printf 'a\\nb\\nc\\n'")
+	c.Run()
+	if err := c.checkIncrementalOutput(2, 100*time.Millisecond); err == nil {
+		t.Fatalf("Expected buffered output to be rejected")
+	}
+
+	if err := c.checkIncrementalOutput(1, 200*time.Millisecond); err == nil {
+		t.Fatalf("Expected output written right before exit to be rejected")
+	}
+}
@@ -0,0 +1,65 @@
+package testcli
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strconv"
+)
+
+// WithInputFile writes content to a temporary file and passes its path to
+// the command with flag, for commands which can't read their input from
+// stdin. An argument made of the flag followed by "=", e.g. "--input=", is
+// completed with the path; otherwise "flag=path" is appended to the
+// arguments. The file is created in the workspace of the command, if it runs
+// in one, and is removed when the test finishes. See InputFiles().
+func WithInputFile(flag string, content []byte) Option {
+	return func(c *Cmd) {
+		c.t.Helper()
+		c.addInputFile(flag, "input-"+strconv.Itoa(len(c.inputFiles)+1), content)
+	}
+}
+
+// WithInputFileFS is like WithInputFile(), with the content of the file name
+// in fsys, e.g. an embedded fixture. The temporary file has the same base
+// name.
+func WithInputFileFS(flag string, fsys fs.FS, name string) Option {
+	return func(c *Cmd) {
+		c.t.Helper()
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		c.addInputFile(flag, path.Base(name), content)
+	}
+}
+
+// InputFiles returns the paths of the files created by WithInputFile() and
+// WithInputFileFS(), in order.
+func (c *Cmd) InputFiles() []string {
+	return append([]string(nil), c.inputFiles...)
+}
+
+func (c *Cmd) addInputFile(flag, name string, content []byte) {
+	c.t.Helper()
+	// Each file gets its own directory, so that names can't collide.
+	file := filepath.Join(c.tempDir(".testcli-input"), name)
+	if err := ioutil.WriteFile(file, content, 0644); err != nil {
+		c.t.Fatal(err)
+	}
+	c.inputFiles = append(c.inputFiles, file)
+	c.setFlag(flag, file)
+}
+
+// setFlag completes the argument made of flag followed by "=" with value, or
+// appends "flag=value" to the arguments if there's none.
+func (c *Cmd) setFlag(flag, value string) {
+	for i, arg := range c.cmd.Args {
+		if arg == flag+"=" {
+			c.cmd.Args[i] = flag + "=" + value
+			return
+		}
+	}
+	c.cmd.Args = append(c.cmd.Args, flag+"="+value)
+}
@@ -0,0 +1,57 @@
+package testcli
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestWithInputFile(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", `for arg; do cat "${arg#*=}"; echo; done`, "sh", "--first=")
+	c.Apply(
+		WithInputFile("--second", []byte("two")),
+		WithInputFile("--first", []byte("one")),
+		WithInputFile("--binary", []byte{0, 1, 0xff}),
+	)
+	c.Run()
+
+	if got, expected := c.Stdout(), "one\ntwo\n\x00\x01\xff\n"; got != expected {
+		t.Fatalf("Expected %q to be %q", got, expected)
+	}
+	if files := c.InputFiles(); len(files) != 3 {
+		t.Fatalf("Expected 3 input files, got %v", files)
+	}
+}
+
+func TestWithInputFileFS(t *testing.T) {
+	fsys := fstest.MapFS{"fixtures/data.json": {Data: []byte(`{"a":1}`)}}
+	c := Command(t, "cat")
+	c.Apply(WithInputFileFS("--input", fsys, "fixtures/data.json"))
+
+	path := c.InputFiles()[0]
+	if got := c.cmd.Args[len(c.cmd.Args)-1]; got != "--input="+path {
+		t.Fatalf("Expected the flag to be appended, got %q", got)
+	}
+	c.cmd.Args[len(c.cmd.Args)-1] = path
+	c.Run()
+	if c.Stdout() != `{"a":1}` {
+		t.Fatalf("Expected %q to be %q", c.Stdout(), `{"a":1}`)
+	}
+}
+
+func TestWithInputFileInWorkspace(t *testing.T) {
+	ws := NewWorkspace(t)
+	c := ws.Command("cat")
+	c.Apply(WithInputFile("--input", []byte("data")))
+
+	path := c.InputFiles()[0]
+	if rel, err := filepath.Rel(ws.Root(), path); err != nil || strings.HasPrefix(rel, "..") {
+		t.Fatalf("Expected %q to be in the workspace %q", path, ws.Root())
+	}
+	c.cmd.Args[len(c.cmd.Args)-1] = path
+	c.Run()
+	if c.Stdout() != "data" {
+		t.Fatalf("Expected %q to be %q", c.Stdout(), "data")
+	}
+}
@@ -0,0 +1,112 @@
+package testcli
+
+import (
+	"io"
+	"math/rand"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+// stdinJitter randomizes how the stdin sequence is written, see
+// WithStdinJitter().
+type stdinJitter struct {
+	min, max         time.Duration
+	seed             int64
+	rng              *rand.Rand
+	split            bool
+	splitInsideRunes bool
+}
+
+// JitterOption configures WithStdinJitter().
+type JitterOption func(*stdinJitter)
+
+// SplitWrites makes the jitter also split each write in up to three pieces,
+// at random, to simulate partial reads. Multi-byte UTF-8 characters are kept
+// whole, unless SplitInsideRunes() is given too.
+func SplitWrites() JitterOption {
+	return func(j *stdinJitter) {
+		j.split = true
+	}
+}
+
+// SplitInsideRunes allows SplitWrites() to split the encoding of a UTF-8
+// character.
+func SplitInsideRunes() JitterOption {
+	return func(j *stdinJitter) {
+		j.splitInsideRunes = true
+	}
+}
+
+// WithStdinJitter makes the stdin sequence (see SetStdinSequence()) wait a
+// random delay between min and max before each write, to shake out races in
+// the handling of input, e.g. an answer sent right after a prompt being lost.
+// The delays are drawn from seed, or a random seed if it's 0, which is logged
+// so that a failure can be reproduced by passing it. SetStdin() isn't
+// affected.
+func WithStdinJitter(min, max time.Duration, seed int64, opts ...JitterOption) Option {
+	return func(c *Cmd) {
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		j := &stdinJitter{min: min, max: max, seed: seed, rng: newJitterRand(seed)}
+		for _, opt := range opts {
+			opt(j)
+		}
+		c.jitter = j
+	}
+}
+
+func newJitterRand(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed))
+}
+
+// delay returns the delay before the next write.
+func (j *stdinJitter) delay() time.Duration {
+	if j.max <= j.min {
+		return j.min
+	}
+	return j.min + time.Duration(j.rng.Int63n(int64(j.max-j.min)+1))
+}
+
+// pieces returns data split as configured.
+func (j *stdinJitter) pieces(data []byte) [][]byte {
+	if !j.split || len(data) < 2 {
+		return [][]byte{data}
+	}
+	var cuts []int
+	for i := 1; i < len(data); i++ {
+		if j.splitInsideRunes || utf8.RuneStart(data[i]) {
+			cuts = append(cuts, i)
+		}
+	}
+	j.rng.Shuffle(len(cuts), func(a, b int) { cuts[a], cuts[b] = cuts[b], cuts[a] })
+	if n := j.rng.Intn(3); n < len(cuts) {
+		cuts = cuts[:n]
+	}
+	sort.Ints(cuts)
+
+	var pieces [][]byte
+	from := 0
+	for _, cut := range cuts {
+		pieces = append(pieces, data[from:cut])
+		from = cut
+	}
+	return append(pieces, data[from:])
+}
+
+// writeJittered writes data to w as configured by the jitter, giving up if
+// the command exits while waiting.
+func (c *Cmd) writeJittered(w io.Writer, data []byte) error {
+	for _, piece := range c.jitter.pieces(data) {
+		select {
+		case <-time.After(c.jitter.delay()):
+		case <-c.done:
+			return io.ErrClosedPipe
+		}
+		if err := c.recordWrite(w, piece); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package testcli
+
+import (
+	"bytes"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestWithStdinJitter(t *testing.T) {
+	c := Command(t, "cat")
+	c.Apply(WithStdinJitter(time.Millisecond, 5*time.Millisecond, 42, SplitWrites()))
+	c.RecordStdin()
+	c.SetStdinSequence(FromString("first answer\n"), FromString("second answer\n"))
+	c.Run()
+	if c.Stdout() != "first answer\nsecond answer\n" {
+		t.Fatalf("Expected %q to be %q", c.Stdout(), "first answer\nsecond answer\n")
+	}
+	if len(c.StdinTranscript()) <= 2 {
+		t.Fatalf("Expected the writes to be split, got %v", c.StdinTranscript())
+	}
+}
+
+func TestStdinJitterIsReproducible(t *testing.T) {
+	plan := func(seed int64) (pieces []string, delays []time.Duration) {
+		j := &stdinJitter{min: 0, max: time.Second, rng: newJitterRand(seed), split: true}
+		for i := 0; i < 5; i++ {
+			delays = append(delays, j.delay())
+			for _, p := range j.pieces([]byte("hello world")) {
+				pieces = append(pieces, string(p))
+			}
+		}
+		return pieces, delays
+	}
+	p1, d1 := plan(7)
+	p2, d2 := plan(7)
+	if len(p1) != len(p2) || len(d1) != len(d2) {
+		t.Fatalf("Expected the same seed to give the same plan, got %q %v and %q %v", p1, d1, p2, d2)
+	}
+	for i := range p1 {
+		if p1[i] != p2[i] {
+			t.Fatalf("Expected the same seed to give the same plan, got %q and %q", p1, p2)
+		}
+	}
+	for i := range d1 {
+		if d1[i] != d2[i] {
+			t.Fatalf("Expected the same seed to give the same plan, got %v and %v", d1, d2)
+		}
+	}
+}
+
+func TestStdinJitterKeepsRunesWhole(t *testing.T) {
+	data := []byte("日本語のテキスト")
+	for seed := int64(1); seed <= 200; seed++ {
+		j := &stdinJitter{rng: newJitterRand(seed), split: true}
+		pieces := j.pieces(data)
+		if joined := bytes.Join(pieces, nil); !bytes.Equal(joined, data) {
+			t.Fatalf("Expected the pieces %q to add up to %q", pieces, data)
+		}
+		for _, p := range pieces {
+			if !utf8.Valid(p) {
+				t.Fatalf("Expected seed %d not to split a character, got %q", seed, pieces)
+			}
+		}
+	}
+}
+
+func TestStdinJitterSplitInsideRunes(t *testing.T) {
+	data := []byte("日本語")
+	for seed := int64(1); seed <= 200; seed++ {
+		j := &stdinJitter{rng: newJitterRand(seed), split: true, splitInsideRunes: true}
+		for _, p := range j.pieces(data) {
+			if !utf8.Valid(p) {
+				return
+			}
+		}
+	}
+	t.Fatalf("Expected SplitInsideRunes() to split a character with some seed")
+}
@@ -0,0 +1,187 @@
+package testcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// JSONOption configures the JSON assertions, e.g. StdoutJSON().
+type JSONOption func(*jsonConfig)
+
+type jsonConfig struct {
+	ignorePrefix bool
+}
+
+// IgnoreNonJSONPrefix makes the JSON assertions skip the lines before the
+// first one starting with '{' or '[', e.g. a banner or logs.
+func IgnoreNonJSONPrefix() JSONOption {
+	return func(cfg *jsonConfig) {
+		cfg.ignorePrefix = true
+	}
+}
+
+// StdoutJSON unmarshals the stdout of the finished command into v, as
+// json.Unmarshal() does. If stdout isn't valid JSON, the test is marked as
+// failed, showing where, and the error is returned.
+func (c *Cmd) StdoutJSON(v interface{}, opts ...JSONOption) error {
+	c.t.Helper()
+	c.validateIsFinished()
+	err := c.unmarshalStdout(v, opts)
+	c.check(err)
+	return err
+}
+
+// StdoutJSONEquals determines if the stdout of the finished command holds
+// the same JSON value as want, regardless of formatting and of the order of
+// object keys. want is either JSON text, as a string or []byte, or a value
+// marshaled to JSON, e.g. a map or a struct with json tags.
+func (c *Cmd) StdoutJSONEquals(want interface{}, opts ...JSONOption) bool {
+	c.t.Helper()
+	c.validateIsFinished()
+	return c.checkStdoutJSONEquals(want, opts) == nil
+}
+
+// AssertStdoutJSONEquals fails the test, showing both values, unless
+// StdoutJSONEquals(want, opts...).
+func (c *Cmd) AssertStdoutJSONEquals(want interface{}, opts ...JSONOption) *Cmd {
+	c.t.Helper()
+	c.validateIsFinished()
+	c.assert(c.checkStdoutJSONEquals(want, opts))
+	return c
+}
+
+func (c *Cmd) checkStdoutJSONEquals(want interface{}, opts []JSONOption) error {
+	c.t.Helper()
+	expected, err := normalizeJSON(want)
+	if err != nil {
+		return err
+	}
+	var got interface{}
+	if err := c.unmarshalStdout(&got, opts); err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(got, expected) {
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(expected)
+		return fmt.Errorf("Expected stdout to be the JSON %s, got %s", wantJSON, gotJSON)
+	}
+	c.stdout.examineAll()
+	return nil
+}
+
+// StdoutJSONPath returns the value at path in the JSON stdout of the finished
+// command, and whether there's one. path is made of object keys and array
+// indexes separated by dots, e.g. "items.0.name". Values are as unmarshaled
+// into an interface{}: numbers are float64s, for instance.
+func (c *Cmd) StdoutJSONPath(path string, opts ...JSONOption) (interface{}, bool) {
+	c.t.Helper()
+	c.validateIsFinished()
+	var doc interface{}
+	if err := c.unmarshalStdout(&doc, opts); err != nil {
+		return nil, false
+	}
+	return jsonPath(doc, path)
+}
+
+// unmarshalStdout unmarshals stdout into v, returning an error showing where
+// stdout isn't valid JSON.
+func (c *Cmd) unmarshalStdout(v interface{}, opts []JSONOption) error {
+	cfg := &jsonConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	content := c.Stdout()
+	if cfg.ignorePrefix {
+		content = skipNonJSONPrefix(content)
+	}
+	if err := json.Unmarshal([]byte(content), v); err != nil {
+		if syntaxErr, ok := err.(*json.SyntaxError); ok {
+			return fmt.Errorf("stdout isn't valid JSON: %s, after byte %d: %s", err, syntaxErr.Offset, jsonContext(content, syntaxErr.Offset))
+		}
+		return fmt.Errorf("stdout isn't valid JSON: %s", err)
+	}
+	return nil
+}
+
+// skipNonJSONPrefix returns s from the first line starting with '{' or '['.
+func skipNonJSONPrefix(s string) string {
+	for i := 0; i < len(s); {
+		if line := strings.TrimLeft(s[i:], " \t"); strings.HasPrefix(line, "{") || strings.HasPrefix(line, "[") {
+			return s[i:]
+		}
+		next := strings.IndexByte(s[i:], '\n')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return s
+}
+
+// jsonContext quotes the content around offset, marking it with >>><<<.
+func jsonContext(content string, offset int64) string {
+	const around = 20
+	at := int(offset)
+	if at > len(content) {
+		at = len(content)
+	}
+	from, to := at-around, at+around
+	if from < 0 {
+		from = 0
+	}
+	if to > len(content) {
+		to = len(content)
+	}
+	return strconv.Quote(content[from:at] + ">>><<<" + content[at:to])
+}
+
+// normalizeJSON returns want, JSON text or a value to marshal, as unmarshaled
+// into an interface{}, for comparisons.
+func normalizeJSON(want interface{}) (interface{}, error) {
+	var raw []byte
+	switch w := want.(type) {
+	case string:
+		raw = []byte(w)
+	case []byte:
+		raw = w
+	default:
+		var err error
+		if raw, err = json.Marshal(want); err != nil {
+			return nil, fmt.Errorf("Can't marshal the expected JSON: %s", err)
+		}
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(raw, &normalized); err != nil {
+		return nil, fmt.Errorf("The expected JSON is invalid: %s", err)
+	}
+	return normalized, nil
+}
+
+// jsonPath returns the value at path in doc, see StdoutJSONPath().
+func jsonPath(doc interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return doc, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			doc = value
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			doc = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
@@ -0,0 +1,80 @@
+package testcli
+
+import (
+	"strings"
+	"testing"
+)
+
+const jsonOutput = `{"items": [{"name": "alpha", "size": 3}, {"name": "beta", "size": 5}], "total": 2}`
+
+func TestStdoutJSON(t *testing.T) {
+	c := Command(t, "echo", "  "+jsonOutput+"  ")
+	c.Run()
+	var got struct {
+		Items []struct {
+			Name string `json:"name"`
+		} `json:"items"`
+		Total int `json:"total"`
+	}
+	if err := c.StdoutJSON(&got); err != nil {
+		return
+	}
+	if got.Total != 2 || len(got.Items) != 2 || got.Items[1].Name != "beta" {
+		t.Fatalf("Expected stdout to be unmarshaled, got %+v", got)
+	}
+}
+
+func TestStdoutJSONEquals(t *testing.T) {
+	c := Command(t, "echo", jsonOutput)
+	c.Run()
+	reordered := `{"total": 2, "items": [{"size": 3, "name": "alpha"}, {"size": 5, "name": "beta"}]}`
+	if !c.StdoutJSONEquals(reordered) {
+		t.Fatalf("Expected the key order not to matter")
+	}
+	want := map[string]interface{}{
+		"total": 2,
+		"items": []map[string]interface{}{{"name": "alpha", "size": 3}, {"name": "beta", "size": 5}},
+	}
+	c.AssertStdoutJSONEquals(want)
+	if c.StdoutJSONEquals(`{"total": 3}`) {
+		t.Fatalf("Expected a different value not to be equal")
+	}
+	err := c.checkStdoutJSONEquals(`{"total": 3}`, nil)
+	if err == nil || !strings.Contains(err.Error(), `Expected stdout to be the JSON {"total":3}, got {"items":`) {
+		t.Fatalf("Expected both values to be shown, got %v", err)
+	}
+}
+
+func TestStdoutJSONPath(t *testing.T) {
+	c := Command(t, "echo", jsonOutput)
+	c.Run()
+	if name, ok := c.StdoutJSONPath("items.1.name"); !ok || name != "beta" {
+		t.Fatalf("Expected %q, got %v", "beta", name)
+	}
+	if size, ok := c.StdoutJSONPath("items.0.size"); !ok || size != 3.0 {
+		t.Fatalf("Expected 3, got %v", size)
+	}
+	for _, path := range []string{"items.2.name", "items.x", "total.value", "missing"} {
+		if value, ok := c.StdoutJSONPath(path); ok {
+			t.Errorf("Expected nothing at %q, got %v", path, value)
+		}
+	}
+}
+
+func TestIgnoreNonJSONPrefix(t *testing.T) {
+	c := Command(t, "sh", "-c", "echo 'mycli v1.2 {beta}'; echo 'loading...'; echo '"+jsonOutput+"'")
+	c.Run()
+	if total, ok := c.StdoutJSONPath("total", IgnoreNonJSONPrefix()); !ok || total != 2.0 {
+		t.Fatalf("Expected the banner to be skipped, got %v", total)
+	}
+}
+
+func TestInvalidJSON(t *testing.T) {
+	c := Command(t, "echo", `{"items": [1, 2,, 3]}`)
+	c.Run()
+	var v interface{}
+	err := c.unmarshalStdout(&v, nil)
+	if err == nil || !strings.Contains(err.Error(), `after byte 17: "{\"items\": [1, 2,,>>><<< 3]}\n"`) {
+		t.Fatalf("Expected the offending output to be shown, got %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package testcli
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// sentSignal is a signal sent to the command by the package.
+type sentSignal struct {
+	sig os.Signal
+	at  time.Time
+}
+
+// signal sends sig to the command, recording when.
+func (c *Cmd) signal(sig os.Signal) error {
+	at := time.Now()
+	if err := c.process.Signal(sig); err != nil {
+		return err
+	}
+	c.recordSignal(sig, at)
+	return nil
+}
+
+// kill kills the command, along with its process group if it leads one,
+// recording it as os.Kill.
+func (c *Cmd) kill() error {
+	at := time.Now()
+	if err := c.killProcess(); err != nil {
+		return err
+	}
+	c.recordSignal(os.Kill, at)
+	return nil
+}
+
+func (c *Cmd) killProcess() error {
+	if p, ok := c.process.(localProcess); ok && c.processGroup {
+		if killProcessGroup(p.cmd.Process) == nil {
+			return nil
+		}
+	}
+	return c.process.Kill()
+}
+
+func (c *Cmd) recordSignal(sig os.Signal, at time.Time) {
+	c.signalsMu.Lock()
+	c.signals = append(c.signals, sentSignal{sig: sig, at: at})
+	c.signalsMu.Unlock()
+}
+
+// SignalToExitLatencies returns, for each time the package sent sig to the
+// finished command, how long it took to exit afterwards, in order. Signals are
+// sent by Signal(), by Kill(), as os.Kill, and when a command times out, see
+// WithTimeout().
+func (c *Cmd) SignalToExitLatencies(sig os.Signal) []time.Duration {
+	c.t.Helper()
+	c.validateIsFinished()
+	c.signalsMu.Lock()
+	defer c.signalsMu.Unlock()
+	var latencies []time.Duration
+	for _, s := range c.signals {
+		if s.sig == sig {
+			latencies = append(latencies, c.exitedAt.Sub(s.at))
+		}
+	}
+	return latencies
+}
+
+// SignalToExitLatency returns how long the finished command took to exit
+// after the package first sent it sig, and whether it did, see
+// SignalToExitLatencies().
+func (c *Cmd) SignalToExitLatency(sig os.Signal) (time.Duration, bool) {
+	c.t.Helper()
+	latencies := c.SignalToExitLatencies(sig)
+	if len(latencies) == 0 {
+		return 0, false
+	}
+	return latencies[0], true
+}
+
+// AssertSignalToExitLatency fails the test if the package didn't send sig to
+// the finished command, or if the command took more than max to exit after
+// the first time it did.
+func (c *Cmd) AssertSignalToExitLatency(sig os.Signal, max time.Duration) {
+	c.t.Helper()
+	if err := c.checkSignalToExitLatency(sig, max); err != nil {
+		c.fatalf("%s", err)
+	}
+}
+
+func (c *Cmd) checkSignalToExitLatency(sig os.Signal, max time.Duration) error {
+	c.t.Helper()
+	latency, ok := c.SignalToExitLatency(sig)
+	if !ok {
+		return fmt.Errorf("Expected the command to be sent %s", sig)
+	}
+	if latency > max {
+		return fmt.Errorf("Expected the command to exit within %s of %s, took %s", max, sig, latency)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package testcli
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSignalToExitLatency(t *testing.T) {
+	c := Command(t, "sh", "-c", `trap 'sleep 0.2; exit 0' TERM; while :; do sleep 0.01; done`)
+	c.Apply(WithTimeout(50*time.Millisecond, Graceful(syscall.SIGTERM, 5*time.Second)))
+	c.Run()
+
+	latency, ok := c.SignalToExitLatency(syscall.SIGTERM)
+	if !ok || latency < 200*time.Millisecond || latency > 5*time.Second {
+		t.Fatalf("Expected the command to exit about 200ms after SIGTERM, got %s (sent: %t)", latency, ok)
+	}
+	if _, ok := c.SignalToExitLatency(os.Kill); ok {
+		t.Fatalf("Expected the command not to be killed")
+	}
+	c.AssertSignalToExitLatency(syscall.SIGTERM, 5*time.Second)
+	err := c.checkSignalToExitLatency(syscall.SIGTERM, 100*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "Expected the command to exit within 100ms of terminated") {
+		t.Fatalf("Expected the latency to exceed 100ms, got %v", err)
+	}
+}
+
+func TestSignalToExitLatencies(t *testing.T) {
+	c := Command(t, "sh", "-c", `trap 'echo hup' HUP; echo ready; while :; do sleep 0.01; done`)
+	c.Start()
+	if !c.StdoutContains("ready") {
+		t.Fatalf("Expected %q to contain %q", c.Stdout(), "ready")
+	}
+	for i := 0; i < 2; i++ {
+		if err := c.signal(syscall.SIGHUP); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	c.Kill()
+
+	latencies := c.SignalToExitLatencies(syscall.SIGHUP)
+	if len(latencies) != 2 || latencies[0] <= latencies[1] {
+		t.Fatalf("Expected two decreasing latencies, got %v", latencies)
+	}
+	if killed := c.SignalToExitLatencies(os.Kill); len(killed) != 1 || killed[0] >= latencies[1] {
+		t.Fatalf("Expected Kill() to be the last signal, got %v", killed)
+	}
+	if err := c.checkSignalToExitLatency(syscall.SIGTERM, time.Second); err == nil {
+		t.Fatalf("Expected SIGTERM not to be sent")
+	}
+}
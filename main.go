@@ -6,31 +6,169 @@
 // `testcli` is a wrapper around os/exec to test CLI apps in Go lang,
 // minimalistic, so you can do your tests with `testing` or any other testing
 // framework.
+//
+// The output of commands is captured through pipes. On Windows, output written
+// to the console directly with WriteConsole, rather than to the standard
+// handles, isn't captured that way: run such commands with ConPTYExecutor(),
+// which attaches them to a pseudo console.
 package testcli
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"regexp"
+	"runtime"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 )
 
+// chunk records a single read from one of the command's output streams.
+type chunk struct {
+	at time.Time
+	n  int
+	// seq orders the chunk among the other events of the command, see
+	// Cmd.seq.
+	seq int64
+}
+
 type output struct {
 	content string
+	chunks  []chunk
 	mu      *sync.Mutex
+	// filter, if set, is applied to content every time it is read.
+	filter func(string) string
+
+	// keep, if set, is how many lines content is trimmed to, see
+	// KeepTail(). lines counts the newlines in content, dropped the bytes
+	// trimmed from its start.
+	keep    int
+	lines   int
+	dropped int
+	// save, if set, gets everything written, see SaveOutputTo().
+	save io.Writer
+	// spill, if set, gets what doesn't fit in memory, see
+	// SpillCaptureToDisk().
+	spill *spill
+	// limit, if set, is how many bytes are captured, the rest is discarded.
+	limit int
+	// closeAfter, if set, is how many bytes are read before closing the read
+	// end, as a consumer like `head` would, see CheckSIGPIPEBehavior().
+	closeAfter int
+	// seq is the event counter of the command.
+	seq *int64
+	// notify, if set, is told about everything written, see waitFor().
+	notify *notifier
+	// examined holds the numbers of the lines looked at by assertions, see
+	// UnassertedOutput().
+	examined map[int]bool
+	// conn, if set, gets everything written too, see StdioConn().
+	conn *StdioConn
+	// readErr is the error which stopped the capture early, if any, see
+	// checkReadErrors().
+	readErr error
+	// collapse, if set, collapses progress bars every time content is read,
+	// after filter, see WithCollapsedProgress().
+	collapse bool
+}
+
+// text returns the captured content, filtered if a filter is set, with its
+// progress bars collapsed if collapse is set. Callers must hold o.mu.
+func (o *output) text() string {
+	content := o.content
+	if o.spilled() {
+		content = o.spilledText()
+	}
+	if o.filter != nil {
+		content = o.filter(content)
+	}
+	if o.collapse {
+		content = collapseProgress(content)
+	}
+	return content
+}
+
+// write appends p to the content as a new chunk.
+func (o *output) write(p []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.save != nil {
+		o.save.Write(p)
+	}
+	if o.conn != nil {
+		o.conn.feed(p)
+	}
+	o.chunks = append(o.chunks, chunk{at: time.Now(), n: len(p), seq: nextSeq(o.seq)})
+	defer o.notify.broadcast()
+	if o.limit > 0 {
+		if room := o.limit - len(o.content); room < len(p) {
+			if room > 0 {
+				o.content += string(p[:room])
+			}
+			return
+		}
+	}
+	if o.spill != nil {
+		if room := o.spill.threshold - len(o.content); room < len(p) {
+			if room < 0 {
+				room = 0
+			}
+			o.content += string(p[:room])
+			o.spill.write(p[room:])
+			return
+		}
+	}
+	o.content += string(p)
+	if o.keep > 0 {
+		o.lines += bytes.Count(p, []byte("\n"))
+		o.trim()
+	}
+}
+
+// capture reads r until EOF, appending everything to the content. Each read
+// is recorded as a separate chunk. Other errors are recorded in readErr,
+// except for the pipe being closed under it, e.g. by Kill(): the capture
+// runs in its own goroutine, which can't fail the test.
+func (o *output) capture(r io.ReadCloser) {
+	defer r.Close()
+	if o.conn != nil {
+		defer o.conn.feedEOF()
+	}
+	buf := make([]byte, 32*1024)
+	read := 0
+	for {
+		n, err := r.Read(buf)
+		if o.closeAfter > 0 && read+n >= o.closeAfter {
+			o.write(buf[:o.closeAfter-read])
+			return
+		}
+		read += n
+		if n > 0 {
+			o.write(buf[:n])
+		}
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, os.ErrClosed) {
+				o.mu.Lock()
+				o.readErr = err
+				o.mu.Unlock()
+			}
+			return
+		}
+	}
 }
 
 // Cmd is typically constructed through the Command() call and provides state
 // to the execution engine.
 type Cmd struct {
 	cmd       *exec.Cmd
+	executor  Executor
+	process   Process
 	env       []string
 	exitError error
 	status    string
@@ -38,6 +176,114 @@ type Cmd struct {
 	stderr    *output
 	stdin     io.Reader
 	t         *testing.T
+
+	recordStdin   bool
+	stdinSequence []StdinSource
+	stdinMu       sync.Mutex
+	transcript    []StdinWrite
+	stdinErr      error
+	stdinFed      chan struct{}
+	promptOffset  int
+	jitter        *stdinJitter
+	stdinPipe     *io.PipeWriter
+
+	// done is closed once the process has exited, after which waitErr and
+	// exitedAt are set.
+	done      chan struct{}
+	waitErr   error
+	startedAt time.Time
+	exitedAt  time.Time
+	// testDone is closed once the test of the command is over, see
+	// watchTest().
+	testDone chan struct{}
+	capture  sync.WaitGroup
+
+	failOnStderr  bool
+	stderrIgnored []*regexp.Regexp
+
+	detectFailureReports bool
+	globalLock           *globalLock
+	memoryLimit          int64
+	verbosity            Verbosity
+	memoryCgroup         *memoryCgroup
+
+	// seq counts the chunks of output and the writes to stdin, in the order
+	// they're captured or written, see AssertTranscriptGolden().
+	seq int64
+	// outputChanged is notified of the output of all the streams, see
+	// waitFor(). maxPollInterval, if set, caps the back-off of its checks.
+	outputChanged   *notifier
+	maxPollInterval time.Duration
+
+	detachMu       sync.Mutex
+	detachedAt     time.Time
+	stdoutFallback string
+	inputFiles     []string
+	replayed       bool
+	consumed       int
+	dryRun         bool
+
+	finalOutputOnly bool
+
+	// workspace, if set, is the workspace the command runs in, where the
+	// files created for it go, see tempDir().
+	workspace *Workspace
+
+	// combined, if set, captures stdout and stderr together, see
+	// CombineOutput().
+	combined *output
+	// streams are the named pipes captured, see CaptureNamedPipe().
+	streams []*namedStream
+
+	// guardPaths, allowedPaths and allowAllPaths configure the check of the
+	// paths passed to the command, see GuardPaths().
+	guardPaths    bool
+	allowedPaths  []string
+	allowAllPaths bool
+	// drainTimeout, if set, replaces defaultDrainTimeout. drainIncomplete
+	// is set when it elapsed, see drain(), and pipeHolders then describes
+	// the processes still holding stdoutPipe, the read end of stdout.
+	drainTimeout    time.Duration
+	drainIncomplete bool
+	pipesClose      pipesClose
+	stdoutPipe      *os.File
+	pipeHolders     []string
+
+	// waitProgress is how often the waits log their progress, see
+	// WithWaitProgress().
+	waitProgress time.Duration
+	// collapseProgress is set by WithCollapsedProgress().
+	collapseProgress bool
+	// execInfo is recorded when the command starts, see ExecutionInfo().
+	execInfo ExecutionInfo
+
+	subprocessLog   string
+	subprocessNames []string
+
+	timeout           time.Duration
+	termination       Termination
+	terminationMu     sync.Mutex
+	terminationReason TerminationReason
+
+	signalsMu sync.Mutex
+	signals   []sentSignal
+	// processGroup is set when the command leads its own process group,
+	// which kill() kills as a whole.
+	processGroup bool
+
+	// envPassthrough, if not nil, is the only variables inherited, see
+	// WithEnvPassthrough(). envEdits are the changes of SetEnvVar() and
+	// UnsetEnvVar(), envOverrides the variables set by options, and
+	// pathPrefix the directories prepended to PATH, see environ().
+	envPassthrough []string
+	envEdits       []envEdit
+	envOverrides   []string
+	pathPrefix     []string
+
+	// ctx, if set, stops the command once done, see CommandContext().
+	// ctxErr is its error, set under terminationMu once it did.
+	ctx    context.Context
+	ctxErr error
 }
 
 // ErrUninitializedCmd is returned when members are accessed before a run, that
@@ -48,49 +294,333 @@ var ErrUninitializedCmd = errors.New("You need to run this command first")
 // that can only be used after a command has finished executing.
 var ErrCmdNotFinished = errors.New("Command is still executing")
 
+// ErrCmdAlreadyStarted is returned when a command is run or started again. A
+// Cmd runs once, construct another one to run the same command line again.
+var ErrCmdAlreadyStarted = errors.New("Command was already started, a Cmd can only run once")
+
+// The states of a command, see Status().
 const (
-	// INITIALIZED represents the state of Command before it's started with Run() or Start()
-	initialized = "initialized"
-	// RUNNING represents the state of Command while it's running
-	running = "running"
-	// FINISHED represents the state of Command after it has exited successfully or not
-	finished = "finished"
+	// Initialized represents the state of Command before it's started with Run() or Start()
+	Initialized = "initialized"
+	// Running represents the state of Command while it's running
+	Running = "running"
+	// Finished represents the state of Command after it has exited successfully or not
+	Finished = "finished"
 )
 
-var pkgCmd *Cmd
+// pkgTest is a running test which constructed a command, and the last
+// command it Run(), for package-level functions.
+type pkgTest struct {
+	t *testing.T
+	// fn is the function the test runs, see testFunc().
+	fn uintptr
+	c  *Cmd
+}
+
+var (
+	pkgMu sync.Mutex
+	// pkgTests holds the tests still running which constructed a command.
+	pkgTests []*pkgTest
+)
+
+// ErrNoCommandRun is reported when a package-level function is called before
+// the calling test Run() a command.
+var ErrNoCommandRun = errors.New("No command was Run() by this test, package-level functions need Run(t, ...) first; " +
+	"use Command(t, ...) to share a command with subtests or goroutines")
+
+// ErrAmbiguousCommand is reported when a package-level function can't tell
+// which of the running tests called it, e.g. from parallel subtests sharing a
+// function, or from a goroutine while several tests Run() commands.
+var ErrAmbiguousCommand = errors.New("Several running tests Run() commands, package-level functions can't tell which one is calling; " +
+	"use Command(t, ...) instead")
+
+// pkgCmd returns the command package-level functions operate on, the last
+// one Run() by the calling test or subtest, so that its failures land on it.
+// Goroutines the test started, which the testing package doesn't run, get it
+// only while it's the one test with a command Run(). A test which didn't Run()
+// a command fails; when the calling test can't be told, see
+// ErrAmbiguousCommand, or didn't construct any command, it panics.
+func pkgCmd() *Cmd {
+	fn := testFunc()
+	pkgMu.Lock()
+	var matches []*pkgTest
+	for _, test := range pkgTests {
+		if (fn != 0 && test.fn == fn) || (fn == 0 && test.c != nil) {
+			matches = append(matches, test)
+		}
+	}
+	pkgMu.Unlock()
+
+	switch {
+	case len(matches) > 1:
+		panic(ErrAmbiguousCommand)
+	case len(matches) == 0:
+		panic(ErrNoCommandRun)
+	case matches[0].c == nil:
+		matches[0].t.Helper()
+		matches[0].t.Fatal(ErrNoCommandRun)
+	}
+	return matches[0].c
+}
+
+// trackTest records t as running until it completes, and returns it.
+func trackTest(t *testing.T) *pkgTest {
+	fn := testFunc()
+	pkgMu.Lock()
+	defer pkgMu.Unlock()
+	for _, test := range pkgTests {
+		if test.t == t {
+			if fn != 0 {
+				test.fn = fn
+			}
+			return test
+		}
+	}
+	test := &pkgTest{t: t, fn: fn}
+	pkgTests = append(pkgTests, test)
+	t.Cleanup(func() {
+		pkgMu.Lock()
+		defer pkgMu.Unlock()
+		for i, running := range pkgTests {
+			if running == test {
+				pkgTests = append(pkgTests[:i], pkgTests[i+1:]...)
+				break
+			}
+		}
+	})
+	return test
+}
+
+// testFunc returns the entry of the function the testing package runs on the
+// calling goroutine, which tells tests and subtests apart, or 0 on other
+// goroutines, e.g. one started by a test.
+func testFunc() uintptr {
+	pcs := make([]uintptr, 64)
+	for {
+		n := runtime.Callers(2, pcs)
+		if n < len(pcs) {
+			pcs = pcs[:n]
+			break
+		}
+		pcs = make([]uintptr, 2*len(pcs))
+	}
+	frames := runtime.CallersFrames(pcs)
+	var fn uintptr
+	for {
+		frame, more := frames.Next()
+		if frame.Function == "testing.tRunner" {
+			return fn
+		}
+		if !more {
+			return 0
+		}
+		fn = frame.Entry
+	}
+}
 
 // Command constructs a *Cmd. It is passed the command name and arguments.
 func Command(t *testing.T, name string, arg ...string) *Cmd {
-	return &Cmd{
-		cmd:    exec.Command(name, arg...),
-		t:      t,
-		status: initialized,
-		stdout: &output{mu: &sync.Mutex{}},
-		stderr: &output{mu: &sync.Mutex{}},
+	c := &Cmd{
+		cmd:      exec.Command(name, arg...),
+		executor: LocalExecutor(),
+		t:        t,
+		status:   Initialized,
+		stdout:   &output{mu: &sync.Mutex{}},
+		stderr:   &output{mu: &sync.Mutex{}},
+		done:     make(chan struct{}),
+		testDone: make(chan struct{}),
+
+		outputChanged: newNotifier(),
 	}
+	c.watchTest(t)
+	trackTest(t)
+	c.detectFailureReports = sanitizedBinary(c.cmd.Path)
+	c.stdout.seq, c.stderr.seq = &c.seq, &c.seq
+	c.stdout.notify, c.stderr.notify = c.outputChanged, c.outputChanged
+	defaultsMu.Lock()
+	defaults := defaultOptions
+	defaultsMu.Unlock()
+	c.Apply(defaults...)
+	return c
 }
 
 func (c *Cmd) validateIsFinished() {
 	c.t.Helper()
-	if c.status != finished {
+	c.skipIfDryRun()
+	// A command which exited on its own is finished, waited for or not.
+	if c.Status() == Finished {
+		c.finish()
+	}
+	if c.status != Finished {
 		c.t.Fatal(ErrCmdNotFinished)
 	}
 }
 
 func (c *Cmd) validateHasStarted() {
 	c.t.Helper()
+	c.skipIfDryRun()
 	// After calling Start() status can either be running or finished
-	if !(c.status == running || c.status == finished) {
+	if !(c.status == Running || c.status == Finished) {
 		c.t.Fatal(ErrUninitializedCmd)
 	}
 }
 
+// exitCode returns the exit status of a finished command, or -1 if it was
+// killed by a signal or could not be started.
+func (c *Cmd) exitCode() int {
+	return exitCodeOf(c.exitError)
+}
+
+// exitCodeOf returns the exit status reported by err, see exitCode().
+func exitCodeOf(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// fatalf fails the test with the given message, followed by the command line
+// and everything it has written so far.
+func (c *Cmd) fatalf(format string, args ...interface{}) {
+	c.t.Helper()
+	if !c.reportable() {
+		return
+	}
+	c.t.Fatal(c.failure(format, args...))
+}
+
+// errorf is like fatalf, but lets the test go on.
+func (c *Cmd) errorf(format string, args ...interface{}) {
+	c.t.Helper()
+	if !c.reportable() {
+		return
+	}
+	c.t.Error(c.failure(format, args...))
+}
+
+// failure returns the message followed by the report of the command.
+func (c *Cmd) failure(format string, args ...interface{}) string {
+	message := fmt.Sprintf(format, args...)
+	report := c.report()
+	if path, err := c.writeAttachment(message); err != nil {
+		report += fmt.Sprintf("\nfailed to write the attachment: %s", err)
+	} else if path != "" {
+		report += "\nattachment: [[ATTACHMENT|" + path + "]]"
+	}
+	return message + "\n" + report
+}
+
+// report describes the command and its captured output for failure messages.
+func (c *Cmd) report() string {
+	if c.effectiveVerbosity() == VerbosityQuiet {
+		return "command: " + c.Repro()
+	}
+	report := fmt.Sprintf("command: %s\nstdout:\n%s\nstderr:\n%s",
+		c.Repro(), c.reportedText(c.stdout), c.reportedText(c.stderr))
+	if c.combined != nil {
+		report = fmt.Sprintf("command: %s\noutput:\n%s", c.Repro(), c.reportedText(c.combined))
+	}
+	for _, s := range c.streams {
+		report += fmt.Sprintf("\n%s:\n%s", s.pipe.name, c.reportedText(s.o))
+	}
+	if at, ok := c.stdoutDetachedAt(); ok {
+		report += fmt.Sprintf("\nstdout was closed by the child at T+%s; it may be logging elsewhere",
+			at.Sub(c.startedAt).Round(time.Millisecond))
+	}
+	if c.drainIncomplete {
+		report += "\nthe output was still held open after the command exited" + heldBy(c.pipeHolders) + "; it may be incomplete"
+	}
+	if !c.startedAt.IsZero() {
+		report += "\n" + c.execInfo.String()
+	}
+	if exited, _, _ := c.Exited(); exited {
+		if reason, abnormal := c.endReason(); abnormal {
+			report += "\nno further output can arrive: " + reason
+		}
+	}
+	return report
+}
+
+// reportedText returns the content of o shown in failure messages, as much of
+// it as the verbosity allows.
+func (c *Cmd) reportedText(o *output) string {
+	if c.effectiveVerbosity() == VerbosityExcerpt {
+		return excerpt(o, excerptLines)
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.reportText()
+}
+
+// Option configures a Cmd, see Apply().
+type Option func(*Cmd)
+
+var (
+	defaultsMu     sync.Mutex
+	defaultOptions []Option
+)
+
+// SetDefaultOptions makes Command() apply opts to every command it constructs
+// from now on, replacing the previous defaults. It's usually called from
+// TestMain.
+func SetDefaultOptions(opts ...Option) {
+	defaultsMu.Lock()
+	defaultOptions = opts
+	defaultsMu.Unlock()
+}
+
+// Apply configures the command with opts. It must be called before Run() or
+// Start().
+func (c *Cmd) Apply(opts ...Option) {
+	c.t.Helper()
+	for _, opt := range opts {
+		opt(c)
+	}
+}
+
 // SetEnv overwrites the environment with the provided one. Otherwise, the
-// parent environment will be supplied.
+// parent environment will be supplied. With WithEnvPassthrough(), env is
+// added to the allowed variables instead. Either way, SetEnvVar() and the
+// variables set by other options, e.g. StopFileEnv(), apply on top of it,
+// see environ().
 func (c *Cmd) SetEnv(env []string) {
 	c.env = env
 }
 
+// SetExecutor makes the command run through e instead of locally. It must be
+// called before Run() or Start().
+func (c *Cmd) SetExecutor(e Executor) {
+	c.executor = e
+}
+
+// SetDir makes the command run in dir, which must exist when running
+// locally. It must be called before Run() or Start().
+func (c *Cmd) SetDir(dir string) {
+	c.t.Helper()
+	if _, ok := c.executor.(localExecutor); ok {
+		if info, err := os.Stat(dir); err != nil {
+			c.t.Fatalf("Can't run the command in %s: %s", dir, err)
+		} else if !info.IsDir() {
+			c.t.Fatalf("Can't run the command in %s: not a directory", dir)
+		}
+	}
+	c.cmd.Dir = dir
+}
+
+// SetTempDir makes the command run in a new empty directory, removed when the
+// test finishes, and returns its path. It must be called before Run() or
+// Start().
+func (c *Cmd) SetTempDir() string {
+	c.t.Helper()
+	dir := c.t.TempDir()
+	c.SetDir(dir)
+	return dir
+}
+
 // SetStdin sets the stdin stream. It makes no attempt to determine if the
 // command accepts anything over stdin.
 func (c *Cmd) SetStdin(stdin io.Reader) {
@@ -100,187 +630,400 @@ func (c *Cmd) SetStdin(stdin io.Reader) {
 // Run runs the command.
 func (c *Cmd) Run() {
 	c.t.Helper()
-	if c.stdin != nil {
-		c.cmd.Stdin = c.stdin
-	}
-
-	if c.env != nil {
-		c.cmd.Env = c.env
-	} else {
-		c.cmd.Env = os.Environ()
-	}
-
-	var outBuf bytes.Buffer
-	c.cmd.Stdout = &outBuf
-
-	var errBuf bytes.Buffer
-	c.cmd.Stderr = &errBuf
-
-	if err := c.cmd.Run(); err != nil {
-		c.exitError = err
-	}
-	c.stdout.content = string(outBuf.Bytes())
-	c.stderr.content = string(errBuf.Bytes())
-	c.status = finished
+	c.Start()
+	c.Wait()
 }
 
 // Start starts the command without waiting for it to complete
 func (c *Cmd) Start() {
 	c.t.Helper()
+	if c.status != Initialized {
+		c.t.Fatal(ErrCmdAlreadyStarted)
+	}
+	c.validateStderrPolicy()
+	if os.Getenv(DryRunEnvVar) != "" {
+		c.startDryRun()
+		return
+	}
+	c.acquireGlobalLock()
 	if c.stdin != nil {
 		c.cmd.Stdin = c.stdin
+		if c.recordStdin {
+			c.cmd.Stdin = &transcriptReader{r: c.stdin, c: c}
+		}
 	}
 
-	if c.env != nil {
-		c.cmd.Env = c.env
-	} else {
-		c.cmd.Env = os.Environ()
+	var stdinWriter *os.File
+	if c.stdinSequence != nil {
+		stdinReader, w, err := os.Pipe()
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		// The child has its own copy once started.
+		defer stdinReader.Close()
+		c.cmd.Stdin = stdinReader
+		stdinWriter = w
 	}
 
-	stdoutPipe, err := c.cmd.StdoutPipe()
+	c.cmd.Env = c.environ()
+	c.checkPaths()
+	c.recordExecutionInfo()
+
+	stdoutReader, stdoutWriter, err := os.Pipe()
 	if err != nil {
 		c.t.Fatal(err)
 	}
+	c.cmd.Stdout = stdoutWriter
+	c.stdoutPipe = stdoutReader
 
-	stderrPipe, err := c.cmd.StderrPipe()
-	if err != nil {
-		c.t.Fatal(err)
+	// Combined, both streams share a pipe, so that writes keep their order.
+	stderrReader, stderrWriter := stdoutReader, stdoutWriter
+	if c.combined == nil {
+		stderrReader, stderrWriter, err = os.Pipe()
+		if err != nil {
+			c.t.Fatal(err)
+		}
 	}
+	c.cmd.Stderr = stderrWriter
 
-	if err := c.cmd.Start(); err != nil {
-		c.exitError = err
+	if c.timeout > 0 || c.ctx != nil {
+		setNewProcessGroup(c.cmd)
+		c.processGroup = true
 	}
 
-	go func() {
-		scanner := bufio.NewScanner(stdoutPipe)
-		for scanner.Scan() {
-			c.stdout.mu.Lock()
-			c.stdout.content += scanner.Text() + "\n"
-			c.stdout.mu.Unlock()
+	c.startedAt = time.Now()
+	// The executor takes ownership of the write ends, the capture goroutines
+	// see EOF once every process holding them is done.
+	process, err := c.executor.Start(c.cmd)
+	c.status = Running
+	if err != nil {
+		stdoutReader.Close()
+		stderrReader.Close()
+		if stdinWriter != nil {
+			stdinWriter.Close()
 		}
-		if err := scanner.Err(); err != nil {
-			c.t.Fatal(err)
+		c.waitErr = err
+		c.exitedAt = time.Now()
+		close(c.done)
+		return
+	}
+	c.process = process
+	c.track()
+	c.applyMemoryLimit()
+	if c.timeout > 0 {
+		go c.watchTimeout()
+	}
+	if c.ctx != nil {
+		go c.watchContext()
+	}
+
+	if c.combined != nil {
+		c.capture.Add(1)
+		go func() {
+			defer c.capture.Done()
+			c.combined.capture(stdoutReader)
+			c.checkStdoutDetached(time.Now())
+		}()
+	} else {
+		c.capture.Add(2)
+		go func() {
+			defer c.capture.Done()
+			c.stdout.capture(stdoutReader)
+			c.checkStdoutDetached(time.Now())
+		}()
+		go func() {
+			defer c.capture.Done()
+			c.stderr.capture(stderrReader)
+		}()
+	}
+	c.captureStreams()
+	if stdinWriter != nil {
+		if c.jitter != nil {
+			c.t.Logf("Jittering stdin with seed %d", c.jitter.seed)
 		}
-	}()
+		c.stdinFed = make(chan struct{})
+		go c.feedStdin(stdinWriter)
+	}
 
+	// The background waiter reaps the process as soon as it exits, the
+	// command is then Finished, see Status(), and Wait() only joins it.
 	go func() {
-		scanner := bufio.NewScanner(stderrPipe)
-		for scanner.Scan() {
-			c.stderr.mu.Lock()
-			c.stderr.content += scanner.Text() + "\n"
-			c.stderr.mu.Unlock()
-		}
-		if err := scanner.Err(); err != nil {
-			c.t.Fatal(err)
-		}
+		err := process.Wait()
+		c.waitErr = err
+		c.exitedAt = time.Now()
+		close(c.done)
+		c.untrack()
 	}()
-	c.status = running
 }
 
-// Wait waits for a command started with Start() to exit.
+// Wait waits for a command started with Start() to exit and collects its
+// output. A command which already exited needn't be waited for: Success(),
+// ExitCode() and the like collect it too.
 // Will fail if called before Start() or Run()
 func (c *Cmd) Wait() {
 	c.t.Helper()
+	c.wait(nil)
+}
+
+// ErrWaitTimeout is returned by WaitWithTimeout() when the command doesn't
+// exit in time.
+var ErrWaitTimeout = errors.New("command didn't exit in time")
+
+// WaitWithTimeout is like Wait(), giving up after d, in which case the output
+// captured so far is logged and an error matching ErrWaitTimeout is returned.
+// The command is left running, to be killed or waited for again, unless term
+// is passed: it's then stopped as described, e.g. Immediate(), and finished.
+func (c *Cmd) WaitWithTimeout(d time.Duration, term ...Termination) error {
+	c.t.Helper()
+	if c.wait(time.After(d)) {
+		return nil
+	}
+	c.logf("%s didn't exit within %s, so far:\n%s", c.Repro(), d, c.report())
+	if len(term) > 0 {
+		c.terminate(term[0], TerminationReason{})
+		c.wait(nil)
+	}
+	return fmt.Errorf("%w: waited %s", ErrWaitTimeout, d)
+}
+
+// wait waits for the command to exit and finishes it, unless timeout fires
+// first, and reports whether it did.
+func (c *Cmd) wait(timeout <-chan time.Time) bool {
+	c.t.Helper()
+	if c.dryRun {
+		return true
+	}
 	c.validateHasStarted()
-	if err := c.cmd.Wait(); err != nil {
-		c.exitError = err
+	if c.stdinPipe != nil {
+		c.stdinPipe.Close()
+	}
+	select {
+	case <-c.done:
+	case <-timeout:
+		return false
+	case <-c.testDone:
+		return false
+	}
+	c.finish()
+	return true
+}
+
+// finish collects the output of the exited command, records how it exited
+// and runs the checks on it, once: the command is then Finished.
+func (c *Cmd) finish() {
+	c.t.Helper()
+	if c.status == Finished {
+		return
+	}
+	c.drain()
+	if c.stdinFed != nil {
+		select {
+		case <-c.stdinFed:
+		case <-time.After(Scaled(stdinAbortGrace)):
+		}
+	}
+	c.exitError = c.finalError()
+	c.status = Finished
+	c.checkReadErrors()
+
+	var promptErr *promptError
+	if errors.As(c.StdinError(), &promptErr) {
+		c.fatalf("%s", promptErr)
+	}
+	c.checkStderrPolicy()
+	c.checkFailureReports()
+}
+
+// checkReadErrors fails the test if the output of the command couldn't be
+// read to the end, since the assertions would see only part of it.
+func (c *Cmd) checkReadErrors() {
+	c.t.Helper()
+	for _, o := range []*output{c.stdout, c.stderr, c.combined} {
+		if o == nil {
+			continue
+		}
+		o.mu.Lock()
+		err := o.readErr
+		o.mu.Unlock()
+		if err != nil {
+			c.errorf("Failed to capture the output of the command, it may be incomplete: %s", err)
+		}
 	}
-	c.status = finished
 }
 
 // Kill kills the process of the current command
 func (c *Cmd) Kill() {
 	c.t.Helper()
+	if c.dryRun {
+		return
+	}
 	c.validateHasStarted()
-	err := c.cmd.Process.Kill()
+	if c.stdinPipe != nil {
+		c.stdinPipe.Close()
+	}
+	c.setTerminationReason(TerminationReason{Killed: true})
+	err := c.kill()
 	if err != nil {
 		c.t.Fatal(err)
 	}
-	c.status = finished
+	<-c.done
+	c.drain()
+	c.exitError = c.finalError()
+	c.status = Finished
 }
 
 // Run runs a command with name and arguments. After this, package-level
-// functions will return the data about the last command run.
+// functions called by the same test will return the data about the last
+// command run. Subtests and parallel tests each Run() their own, failures of
+// package-level functions land on the test calling them.
 func Run(t *testing.T, name string, arg ...string) {
 	t.Helper()
-	pkgCmd = Command(t, name, arg...)
-	pkgCmd.Run()
+	c := Command(t, name, arg...)
+	test := trackTest(t)
+	pkgMu.Lock()
+	test.c = c
+	pkgMu.Unlock()
+	c.Run()
 }
 
-// Error is the command's error, if any.
+// Error is the command's error, if any, an *ExitError.
 func (c *Cmd) Error() error {
 	c.t.Helper()
 	c.validateIsFinished()
-	return c.exitError
+	if c.exitError == nil {
+		return nil
+	}
+	return c.newExitError()
 }
 
-// Error is the command's error, if any.
+// Error is the command's error, if any, an *ExitError.
 func Error() error {
-	pkgCmd.t.Helper()
-	return pkgCmd.Error()
+	c := pkgCmd()
+	c.t.Helper()
+	return c.Error()
 }
 
 // Stdout stream for the command
 func (c *Cmd) Stdout() string {
 	c.t.Helper()
 	c.validateHasStarted()
+	c.validateNotCombined()
 	c.stdout.mu.Lock()
 	defer c.stdout.mu.Unlock()
-	return c.stdout.content
+	return c.stdout.text()
 }
 
 // Stdout stream for the command
 func Stdout() string {
-	pkgCmd.t.Helper()
-	return pkgCmd.Stdout()
+	c := pkgCmd()
+	c.t.Helper()
+	return c.Stdout()
 }
 
 // Stderr stream for the command
 func (c *Cmd) Stderr() string {
 	c.t.Helper()
 	c.validateHasStarted()
+	c.validateNotCombined()
 	c.stderr.mu.Lock()
 	defer c.stderr.mu.Unlock()
-	return c.stderr.content
+	return c.stderr.text()
 }
 
 // Stderr stream for the command
 func Stderr() string {
-	pkgCmd.t.Helper()
-	return pkgCmd.Stderr()
+	c := pkgCmd()
+	c.t.Helper()
+	return c.Stderr()
+}
+
+// StdoutLines returns the lines of stdout, without their line endings, "\n"
+// or "\r\n". A missing final newline doesn't make a difference.
+func (c *Cmd) StdoutLines() []string {
+	c.t.Helper()
+	return splitLines(c.Stdout())
+}
+
+// StdoutLines returns the lines of stdout, without their line endings, "\n"
+// or "\r\n". A missing final newline doesn't make a difference.
+func StdoutLines() []string {
+	c := pkgCmd()
+	c.t.Helper()
+	return c.StdoutLines()
+}
+
+// StderrLines returns the lines of stderr, like StdoutLines().
+func (c *Cmd) StderrLines() []string {
+	c.t.Helper()
+	return splitLines(c.Stderr())
+}
+
+// StderrLines returns the lines of stderr, like StdoutLines().
+func StderrLines() []string {
+	c := pkgCmd()
+	c.t.Helper()
+	return c.StderrLines()
+}
+
+// StdoutLine returns the line n of stdout, counting from 0, like
+// StdoutLines(). The test fails if stdout has fewer lines.
+func (c *Cmd) StdoutLine(n int) string {
+	c.t.Helper()
+	lines := c.StdoutLines()
+	if n < 0 || n >= len(lines) {
+		c.fatalf("Expected stdout to have a line %d, got %d lines", n, len(lines))
+	}
+	return lines[n]
+}
+
+// StdoutLine returns the line n of stdout, counting from 0, like
+// StdoutLines(). The test fails if stdout has fewer lines.
+func StdoutLine(n int) string {
+	c := pkgCmd()
+	c.t.Helper()
+	return c.StdoutLine(n)
+}
+
+// splitLines splits s into lines, without their line endings.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
 }
 
 // StdoutContains determines if command's STDOUT contains `str`, this operation
-// is case insensitive.
-func (c *Cmd) StdoutContains(str string) bool {
+// is case insensitive unless CaseSensitive() is passed.
+func (c *Cmd) StdoutContains(str string, opts ...MatchOption) bool {
 	c.t.Helper()
-	c.validateHasStarted()
-	str = strings.ToLower(str)
-	return retryStringTest(strings.Contains, c.stdout, str)
+	return c.outputContains(c.stdout, "stdout", str, opts)
 }
 
 // StdoutContains determines if command's STDOUT contains `str`, this operation
-// is case insensitive.
-func StdoutContains(str string) bool {
-	pkgCmd.t.Helper()
-	return pkgCmd.StdoutContains(str)
+// is case insensitive unless CaseSensitive() is passed.
+func StdoutContains(str string, opts ...MatchOption) bool {
+	c := pkgCmd()
+	c.t.Helper()
+	return c.StdoutContains(str, opts...)
 }
 
 // StderrContains determines if command's STDERR contains `str`, this operation
-// is case insensitive.
-func (c *Cmd) StderrContains(str string) bool {
+// is case insensitive unless CaseSensitive() is passed.
+func (c *Cmd) StderrContains(str string, opts ...MatchOption) bool {
 	c.t.Helper()
-	c.validateHasStarted()
-	str = strings.ToLower(str)
-	return retryStringTest(strings.Contains, c.stderr, str)
-	// return strings.Contains(strings.ToLower(c.stderr.content), str)
+	return c.outputContains(c.stderr, "stderr", str, opts)
 }
 
 // StderrContains determines if command's STDERR contains `str`, this operation
-// is case insensitive.
-func StderrContains(str string) bool {
-	pkgCmd.t.Helper()
-	return pkgCmd.StderrContains(str)
+// is case insensitive unless CaseSensitive() is passed.
+func StderrContains(str string, opts ...MatchOption) bool {
+	c := pkgCmd()
+	c.t.Helper()
+	return c.StderrContains(str, opts...)
 }
 
 // Success is a boolean status which indicates if the program exited non-zero
@@ -294,8 +1037,9 @@ func (c *Cmd) Success() bool {
 // Success is a boolean status which indicates if the program exited non-zero
 // or not.
 func Success() bool {
-	pkgCmd.t.Helper()
-	return pkgCmd.Success()
+	c := pkgCmd()
+	c.t.Helper()
+	return c.Success()
 }
 
 // Failure is the inverse of Success().
@@ -307,57 +1051,204 @@ func (c *Cmd) Failure() bool {
 
 // Failure is the inverse of Success().
 func Failure() bool {
-	pkgCmd.t.Helper()
-	return pkgCmd.Failure()
+	c := pkgCmd()
+	c.t.Helper()
+	return c.Failure()
+}
+
+// ExitCode is the exit status of the finished command, -1 if it was killed by
+// a signal or could not be started.
+func (c *Cmd) ExitCode() int {
+	c.t.Helper()
+	c.validateIsFinished()
+	return c.exitCode()
+}
+
+// ExitCode is the exit status of the finished command, -1 if it was killed by
+// a signal or could not be started.
+func ExitCode() int {
+	c := pkgCmd()
+	c.t.Helper()
+	return c.ExitCode()
+}
+
+// ExpectExitCode fails the test, showing the command's output, if the finished
+// command didn't exit with code.
+func (c *Cmd) ExpectExitCode(code int) {
+	c.t.Helper()
+	c.validateIsFinished()
+	if err := c.checkExitCode(code); err != nil {
+		c.fatalf("%s", err)
+	}
+}
+
+// ExpectExitCode fails the test, showing the command's output, if the finished
+// command didn't exit with code.
+func ExpectExitCode(code int) {
+	c := pkgCmd()
+	c.t.Helper()
+	c.ExpectExitCode(code)
+}
+
+func (c *Cmd) checkExitCode(code int) error {
+	if actual := c.exitCode(); actual != code {
+		return fmt.Errorf("Expected exit code %d, got %d: %v", code, actual, c.exitError)
+	}
+	return nil
 }
 
 // StdoutMatches compares a regex to the stdout produced by the command.
-func (c *Cmd) StdoutMatches(regex string) bool {
+func (c *Cmd) StdoutMatches(regex string, opts ...MatchOption) bool {
 	c.t.Helper()
-	c.validateHasStarted()
-	re := regexp.MustCompile(regex)
-	return retryStringTest(func(got, want string) bool {
-		return re.MatchString(got)
-	}, c.stdout, regex)
+	return c.outputMatches(c.stdout, "stdout", regex, opts)
 }
 
 // StdoutMatches compares a regex to the stdout produced by the command.
-func StdoutMatches(regex string) bool {
-	pkgCmd.t.Helper()
-	return pkgCmd.StdoutMatches(regex)
+func StdoutMatches(regex string, opts ...MatchOption) bool {
+	c := pkgCmd()
+	c.t.Helper()
+	return c.StdoutMatches(regex, opts...)
 }
 
 // StderrMatches compares a regex to the stderr produced by the command.
-func (c *Cmd) StderrMatches(regex string) bool {
+func (c *Cmd) StderrMatches(regex string, opts ...MatchOption) bool {
+	c.t.Helper()
+	return c.outputMatches(c.stderr, "stderr", regex, opts)
+}
+
+// StderrMatches compares a regex to the stderr produced by the command.
+func StderrMatches(regex string, opts ...MatchOption) bool {
+	c := pkgCmd()
+	c.t.Helper()
+	return c.StderrMatches(regex, opts...)
+}
+
+// outputContains is StdoutContains() for o, named stream in messages.
+func (c *Cmd) outputContains(o *output, stream, str string, opts []MatchOption) bool {
 	c.t.Helper()
 	c.validateHasStarted()
+	if !c.awaitFinal(opts) {
+		return false
+	}
+	fold := !matchOptions(opts).caseSensitive
+	if o.spill != nil {
+		return c.spilledContains(o, str, fold)
+	}
+	if fold {
+		str = strings.ToLower(str)
+	}
+	if !c.retryStringTest(fmt.Sprintf("%s to contain %q", stream, str), strings.Contains, c.view(o, opts), str) {
+		return false
+	}
+	o.examineContains(str, fold)
+	return true
+}
+
+// outputMatches is StdoutMatches() for o, named stream in messages.
+func (c *Cmd) outputMatches(o *output, stream, regex string, opts []MatchOption) bool {
+	c.t.Helper()
+	c.validateHasStarted()
+	if !c.awaitFinal(opts) {
+		return false
+	}
+	fold := !matchOptions(opts).caseSensitive
 	re := regexp.MustCompile(regex)
-	return retryStringTest(func(got, want string) bool {
+	if o.spill != nil {
+		return c.spilledMatches(o, re, fold)
+	}
+	if !c.retryStringTest(fmt.Sprintf("%s to match %q", stream, regex), func(got, want string) bool {
 		return re.MatchString(got)
-	}, c.stderr, regex)
+	}, c.view(o, opts), regex) {
+		return false
+	}
+	o.examineMatch(re, fold)
+	return true
 }
 
-// StderrMatches compares a regex to the stderr produced by the command.
-func StderrMatches(regex string) bool {
-	pkgCmd.t.Helper()
-	return pkgCmd.StderrMatches(regex)
+// MatchOption modifies how a single Contains or Matches assertion looks at the
+// captured output.
+type MatchOption func(*matchConfig)
+
+type matchConfig struct {
+	excludingEcho bool
+	final         bool
+	streaming     bool
+	caseSensitive bool
+	// window is how long the NotContains assertions watch a running
+	// command, see Window().
+	window time.Duration
+	// trimNewline makes the Equals assertions ignore a trailing newline,
+	// see TrimTrailingNewline().
+	trimNewline bool
+}
+
+// matchOptions returns the configuration of an assertion called with opts.
+func matchOptions(opts []MatchOption) *matchConfig {
+	cfg := &matchConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// view returns a function producing a snapshot of o, as seen by an assertion
+// configured with opts: lowered, unless CaseSensitive() is among them.
+func (c *Cmd) view(o *output, opts []MatchOption) func() string {
+	c.t.Helper()
+	cfg := matchOptions(opts)
+	if cfg.excludingEcho && !c.recordStdin {
+		c.t.Fatal("ExcludingEcho() requires RecordStdin() to be called before the command starts")
+	}
+	return func() string {
+		o.mu.Lock()
+		content := o.text()
+		o.mu.Unlock()
+		if cfg.excludingEcho {
+			content = c.removeEcho(content)
+		}
+		if !cfg.caseSensitive {
+			content = strings.ToLower(content)
+		}
+		return content
+	}
 }
 
 // retryStringTest takes in a testFunc and will test output for the expected string until either it
 // finds the expected string or times out (default 1 second)
-func retryStringTest(testFunc func(string, string) bool, output *output, expected string) bool {
-	ticker := time.NewTicker(100 * time.Millisecond)
-	timeout := time.After(1 * time.Second)
+func retryStringTest(testFunc func(string, string) bool, view func() string, expected string) bool {
+	return poll(func() bool {
+		return testFunc(strings.ToLower(view()), expected)
+	}, defaultPollInterval, Scaled(defaultPollTimeout))
+}
+
+// retryStringTest is like the function of the same name, giving up early if
+// the command exits. Unlike it, it leaves the case alone: view() lowers the
+// content unless the assertion is case sensitive.
+func (c *Cmd) retryStringTest(what string, testFunc func(string, string) bool, view func() string, expected string) bool {
+	return c.waitFor(what, func() bool {
+		return testFunc(view(), expected)
+	}, defaultPollInterval, Scaled(defaultPollTimeout)) == nil
+}
+
+// defaultPollInterval and defaultPollTimeout pace the retries of assertions.
+const (
+	defaultPollInterval = 100 * time.Millisecond
+	defaultPollTimeout  = 1 * time.Second
+)
+
+// poll calls cond every interval until it returns true, or returns false once
+// timeout elapses.
+func poll(cond func() bool, interval, timeout time.Duration) bool {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	deadline := time.After(timeout)
 	for {
 		select {
 		case <-ticker.C:
-			output.mu.Lock()
-			found := testFunc(strings.ToLower(output.content), expected)
-			output.mu.Unlock()
-			if found == true {
+			if cond() {
 				return true
 			}
-		case <-timeout:
+		case <-deadline:
 			return false
 		}
 	}
@@ -2,9 +2,14 @@ package testcli
 
 import (
 	"bytes"
+	"errors"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
 	"time"
 )
 
@@ -66,6 +71,82 @@ func TestPackageSuccessfulRun(t *testing.T) {
 	}
 }
 
+func TestExitCode(t *testing.T) {
+	c := Command(t, "sh", "-c", "exit 3")
+	c.Run()
+	if c.ExitCode() != 3 {
+		t.Fatalf("Expected exit code 3, got %d", c.ExitCode())
+	}
+	c.ExpectExitCode(3)
+	if err := c.checkExitCode(1); err == nil || !strings.Contains(err.Error(), "Expected exit code 1, got 3") {
+		t.Fatalf("Expected exit code 3 not to be 1, got %v", err)
+	}
+
+	c = Command(t, "myunknowncommand")
+	c.Run()
+	if c.ExitCode() != -1 {
+		t.Fatalf("Expected exit code -1 when failing to start, got %d", c.ExitCode())
+	}
+
+	c = Command(t, "sleep", "10")
+	c.Start()
+	c.Kill()
+	if c.ExitCode() != -1 {
+		t.Fatalf("Expected exit code -1 when killed, got %d", c.ExitCode())
+	}
+}
+
+func TestPackageExitCode(t *testing.T) {
+	Run(t, "sh", "-c", "exit 2")
+	if ExitCode() != 2 {
+		t.Fatalf("Expected exit code 2, got %d", ExitCode())
+	}
+	ExpectExitCode(2)
+}
+
+func TestSetDir(t *testing.T) {
+	// The temporary directory may be behind a symlink, like on macOS.
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Command(t, "pwd")
+	c.SetDir(dir)
+	c.Run()
+	if c.Stdout() != dir+"\n" {
+		t.Fatalf("Expected the command to run in %s, got %q", dir, c.Stdout())
+	}
+
+	c = Command(t, "sh", "-c", "pwd; ls -A")
+	tmp, err := filepath.EvalSymlinks(c.SetTempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Start()
+	c.Wait()
+	if c.Stdout() != tmp+"\n" {
+		t.Fatalf("Expected the command to run in the empty %s, got %q", tmp, c.Stdout())
+	}
+}
+
+// TestSetDirHelperProcess sets a missing directory.
+func TestSetDirHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	c := Command(t, "pwd")
+	c.SetDir("/nonexistent/testcli")
+	t.Log("Not reached")
+}
+
+func TestSetDirMissing(t *testing.T) {
+	c := ReexecCommand(t, "TestSetDirHelperProcess")
+	c.Run()
+	if c.Success() || !strings.Contains(c.Stdout(), "Can't run the command in /nonexistent/testcli") || strings.Contains(c.Stdout(), "Not reached") {
+		t.Fatalf("Expected SetDir() to fail the test right away, got %q", c.Stdout())
+	}
+}
+
 func TestStdout(t *testing.T) {
 	user := os.Getenv("USER")
 	c := Command(t, "whoami")
@@ -95,6 +176,55 @@ func TestPackageStdout(t *testing.T) {
 	}
 }
 
+func TestStdoutLines(t *testing.T) {
+	c := Command(t, "printf", "first\r\nsecond\n\nsummary")
+	c.Run()
+	lines := c.StdoutLines()
+	if len(lines) != 4 || lines[0] != "first" || lines[2] != "" || lines[3] != "summary" {
+		t.Fatalf("Expected 4 lines without line endings, got %q", lines)
+	}
+	if line := c.StdoutLine(1); line != "second" {
+		t.Fatalf("Expected the line 1 to be %q, got %q", "second", line)
+	}
+
+	c = Command(t, "sh", "-c", "echo a >&2; echo b >&2")
+	c.Run()
+	if lines := c.StderrLines(); len(lines) != 2 || lines[1] != "b" {
+		t.Fatalf("Expected 2 lines, got %q", lines)
+	}
+	if lines := c.StdoutLines(); len(lines) != 0 {
+		t.Fatalf("Expected no lines, got %q", lines)
+	}
+}
+
+func TestPackageStdoutLines(t *testing.T) {
+	Run(t, "sh", "-c", "echo one; echo two; echo oops >&2")
+	if lines := StdoutLines(); len(lines) != 2 || StdoutLine(1) != "two" {
+		t.Fatalf("Expected 2 lines, got %q", lines)
+	}
+	if lines := StderrLines(); len(lines) != 1 || lines[0] != "oops" {
+		t.Fatalf("Expected 1 line, got %q", lines)
+	}
+}
+
+// TestStdoutLineHelperProcess reads a line past the end of stdout.
+func TestStdoutLineHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	c := Command(t, "echo", "only")
+	c.Run()
+	c.StdoutLine(1)
+}
+
+func TestStdoutLineOutOfRange(t *testing.T) {
+	c := ReexecCommand(t, "TestStdoutLineHelperProcess")
+	c.Run()
+	if c.Success() || !strings.Contains(c.Stdout(), "Expected stdout to have a line 1, got 1 lines") {
+		t.Fatalf("Expected StdoutLine() to fail the test, got %q", c.Stdout())
+	}
+}
+
 func TestStderr(t *testing.T) {
 	c := Command(t, "cp")
 	c.Run()
@@ -202,7 +332,7 @@ func TestReadStdoutAfterKillingLongRunningProcess(t *testing.T) {
 
 	time.Sleep(1 * time.Second)
 
-	if c.status != "running" {
+	if !c.IsRunning() {
 		t.Fatal("command should still be running")
 
 	}
@@ -214,6 +344,83 @@ func TestReadStdoutAfterKillingLongRunningProcess(t *testing.T) {
 	}
 }
 
+// TestKillMidStreamHelperProcess kills a command while it's printing.
+func TestKillMidStreamHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	c := Command(t, "sh", "-c", "while :; do echo line; done")
+	c.Start()
+	c.WaitForStdout("line", 5*time.Second)
+	c.Kill()
+	c.Wait()
+	if !c.StdoutContains("line") {
+		t.Fatalf("Expected %q to contain %q", c.Stdout(), "line")
+	}
+}
+
+func TestKillMidStream(t *testing.T) {
+	c := ReexecCommand(t, "TestKillMidStreamHelperProcess")
+	c.Run()
+	if !c.Success() {
+		t.Fatalf("Expected killing a command mid-stream not to fail the test, got:\n%s", c.Stdout())
+	}
+}
+
+func TestStdoutIsRawBytes(t *testing.T) {
+	c := Command(t, "sh", "-c", `printf 'a\r\nb\000c\n\nPassword: '`)
+	c.Run()
+	if got, want := c.Stdout(), "a\r\nb\x00c\n\nPassword: "; got != want {
+		t.Fatalf("Expected stdout byte for byte %q, got %q", want, got)
+	}
+}
+
+func TestPartialLineWhileRunning(t *testing.T) {
+	c := Command(t, "sh", "-c", "printf 'Password: '; exec sleep 5")
+	c.Start()
+	defer c.Kill()
+	c.WaitForStdout("Password: ", 5*time.Second)
+	if got := c.Stdout(); got != "Password: " {
+		t.Fatalf("Expected the prompt without a newline, got %q", got)
+	}
+}
+
+func TestVeryLongLine(t *testing.T) {
+	// Longer than the 64KB token limit of a bufio.Scanner.
+	c := Command(t, "sh", "-c", "head -c 200000 /dev/zero | tr '\\0' a; printf end")
+	c.Run()
+	if got := len(c.Stdout()); got != 200003 {
+		t.Fatalf("Expected the whole 200003 bytes line, got %d bytes", got)
+	}
+	if !strings.HasSuffix(c.Stdout(), "aend") {
+		t.Fatalf("Expected the line to end with %q, got %q", "aend", c.Stdout()[len(c.Stdout())-10:])
+	}
+}
+
+func TestMegabyteLineWhileRunning(t *testing.T) {
+	c := Command(t, "sh", "-c", `printf '{"data": "'; head -c 1048576 /dev/zero | tr '\\0' a; printf '", "status": "done"}\n'; exec sleep 5`)
+	c.Start()
+	defer c.Kill()
+	if !c.StdoutContains(`"status": "done"}`) {
+		t.Fatalf("Expected the end of the 1MB line to be found, got %d bytes", len(c.Stdout()))
+	}
+}
+
+func TestCaptureReadError(t *testing.T) {
+	failure := errors.New("device on fire")
+	o := &output{mu: &sync.Mutex{}}
+	o.capture(ioutil.NopCloser(iotest.ErrReader(failure)))
+	if o.readErr != failure {
+		t.Fatalf("Expected the read error to be recorded, got %v", o.readErr)
+	}
+
+	o = &output{mu: &sync.Mutex{}}
+	o.capture(ioutil.NopCloser(iotest.ErrReader(os.ErrClosed)))
+	if o.readErr != nil {
+		t.Fatalf("Expected the pipe being closed not to be an error, got %v", o.readErr)
+	}
+}
+
 func TestTail(t *testing.T) {
 	_, err := os.Create("log.txt")
 	if err != nil {
@@ -263,4 +470,44 @@ func TestMultiline(t *testing.T) {
 	}
 
 	c.cmd.Process.Kill()
-}
\ No newline at end of file
+}
+
+func TestWaitWithTimeout(t *testing.T) {
+	c := Command(t, "sh", "-c", "echo started; exec sleep 30")
+	c.Start()
+	start := time.Now()
+	err := c.WaitWithTimeout(200 * time.Millisecond)
+	if !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("Expected ErrWaitTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Expected to give up after 200ms, took %s", elapsed)
+	}
+	if !c.StdoutContains("started") {
+		t.Fatalf("Expected the partial output to be available, got %q", c.Stdout())
+	}
+	c.Kill()
+	if !c.Failure() {
+		t.Fatalf("Expected the killed command to fail")
+	}
+}
+
+func TestWaitWithTimeoutTerminates(t *testing.T) {
+	c := Command(t, "sh", "-c", "echo started; exec sleep 30")
+	c.Start()
+	if err := c.WaitWithTimeout(200*time.Millisecond, Immediate()); !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("Expected ErrWaitTimeout, got %v", err)
+	}
+	if c.ExitCode() != -1 || c.Stdout() != "started\n" {
+		t.Fatalf("Expected the command to be killed after printing, got %d, %q", c.ExitCode(), c.Stdout())
+	}
+}
+
+func TestWaitWithTimeoutExits(t *testing.T) {
+	c := Command(t, "echo", "done")
+	c.Start()
+	if err := c.WaitWithTimeout(10 * time.Second); err != nil {
+		t.Fatalf("Expected the command to exit in time, got %v", err)
+	}
+	c.AssertSuccess()
+}
@@ -0,0 +1,208 @@
+package testcli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Outcome is what matchers evaluate: the output and exit code of a finished
+// command. It's plain data, so that matchers can be evaluated far from any
+// test, see Cmd.Outcome().
+type Outcome struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// Matcher checks an Outcome. Matchers are plain values, independent of any
+// test: helper packages can build and combine them with And(), Or() and Not(),
+// and tests evaluate them with Cmd.Assert() or Cmd.Check().
+type Matcher interface {
+	// Evaluate reports whether o matches, along with an explanation of what
+	// was found either way.
+	Evaluate(o Outcome) (bool, string)
+}
+
+// MatcherFunc makes a function a Matcher.
+type MatcherFunc func(o Outcome) (bool, string)
+
+// Evaluate calls f.
+func (f MatcherFunc) Evaluate(o Outcome) (bool, string) {
+	return f(o)
+}
+
+// ContentMatcher matches the content of stdout, or of stderr after
+// OnStderr().
+type ContentMatcher struct {
+	stream string
+	match  func(content, stream string) (bool, string)
+}
+
+func newContentMatcher(match func(content, stream string) (bool, string)) *ContentMatcher {
+	return &ContentMatcher{stream: "stdout", match: match}
+}
+
+// OnStderr returns a copy of m matching stderr instead.
+func (m *ContentMatcher) OnStderr() *ContentMatcher {
+	return &ContentMatcher{stream: "stderr", match: m.match}
+}
+
+// Evaluate matches the content of the stream of m.
+func (m *ContentMatcher) Evaluate(o Outcome) (bool, string) {
+	content := o.Stdout
+	if m.stream == "stderr" {
+		content = o.Stderr
+	}
+	return m.match(content, m.stream)
+}
+
+// Contains matches stdout containing str, case insensitively like
+// StdoutContains().
+func Contains(str string) *ContentMatcher {
+	return newContentMatcher(func(content, stream string) (bool, string) {
+		if strings.Contains(strings.ToLower(content), strings.ToLower(str)) {
+			return true, fmt.Sprintf("%s contains %q", stream, str)
+		}
+		return false, fmt.Sprintf("%s doesn't contain %q", stream, str)
+	})
+}
+
+// Regexp matches stdout matching regex. It panics if regex doesn't compile,
+// when the matcher is built.
+func Regexp(regex string) *ContentMatcher {
+	re := regexp.MustCompile(regex)
+	return newContentMatcher(func(content, stream string) (bool, string) {
+		if re.MatchString(content) {
+			return true, fmt.Sprintf("%s matches %q", stream, regex)
+		}
+		return false, fmt.Sprintf("%s doesn't match %q", stream, regex)
+	})
+}
+
+// JSONEq matches stdout holding the same JSON value as expected, regardless
+// of formatting and of the order of object keys. It panics if expected isn't
+// valid JSON, when the matcher is built.
+func JSONEq(expected string) *ContentMatcher {
+	var want interface{}
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		panic(fmt.Sprintf("testcli: JSONEq: invalid JSON %q: %s", expected, err))
+	}
+	return newContentMatcher(func(content, stream string) (bool, string) {
+		var got interface{}
+		if err := json.Unmarshal([]byte(content), &got); err != nil {
+			return false, fmt.Sprintf("%s isn't valid JSON: %s", stream, err)
+		}
+		if reflect.DeepEqual(got, want) {
+			return true, fmt.Sprintf("%s is JSON equal to %s", stream, compactJSON(expected))
+		}
+		return false, fmt.Sprintf("%s is JSON %s, not %s", stream, compactJSON(content), compactJSON(expected))
+	})
+}
+
+// compactJSON returns s, valid JSON, without insignificant spaces.
+func compactJSON(s string) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(s)); err != nil {
+		return s
+	}
+	return buf.String()
+}
+
+// ExitCodeIs matches the exit code being code.
+func ExitCodeIs(code int) Matcher {
+	return MatcherFunc(func(o Outcome) (bool, string) {
+		if o.ExitCode == code {
+			return true, fmt.Sprintf("exit code is %d", code)
+		}
+		return false, fmt.Sprintf("exit code is %d, not %d", o.ExitCode, code)
+	})
+}
+
+// And matches when all of ms match. Its explanation lists each of them.
+func And(ms ...Matcher) Matcher {
+	return MatcherFunc(func(o Outcome) (bool, string) {
+		failed, explanation := evaluateAll(o, ms)
+		return failed == 0, fmt.Sprintf("all of (%d of %d failed):%s", failed, len(ms), explanation)
+	})
+}
+
+// Or matches when any of ms matches. Its explanation lists each of them.
+func Or(ms ...Matcher) Matcher {
+	return MatcherFunc(func(o Outcome) (bool, string) {
+		failed, explanation := evaluateAll(o, ms)
+		return failed < len(ms), fmt.Sprintf("any of (%d of %d failed):%s", failed, len(ms), explanation)
+	})
+}
+
+// Not matches when m doesn't.
+func Not(m Matcher) Matcher {
+	return MatcherFunc(func(o Outcome) (bool, string) {
+		ok, explanation := m.Evaluate(o)
+		return !ok, "not:" + renderChild(ok, explanation)
+	})
+}
+
+// evaluateAll evaluates each of ms, and returns how many failed and their
+// rendered explanations.
+func evaluateAll(o Outcome, ms []Matcher) (int, string) {
+	failed := 0
+	var explanation strings.Builder
+	for _, m := range ms {
+		ok, child := m.Evaluate(o)
+		if !ok {
+			failed++
+		}
+		explanation.WriteString(renderChild(ok, child))
+	}
+	return failed, explanation.String()
+}
+
+// renderChild renders the explanation of a combined matcher on its own
+// lines, marked with whether it matched, and indented under its parent.
+func renderChild(ok bool, explanation string) string {
+	mark := "[ok]   "
+	if !ok {
+		mark = "[FAIL] "
+	}
+	lines := strings.Split(explanation, "\n")
+	for i := range lines[1:] {
+		lines[i+1] = strings.Repeat(" ", len(mark)) + lines[i+1]
+	}
+	return "\n  " + mark + strings.Join(lines, "\n  ")
+}
+
+// Outcome returns the output and exit code of the finished command, for
+// matchers to evaluate.
+func (c *Cmd) Outcome() Outcome {
+	c.t.Helper()
+	c.validateIsFinished()
+	c.validateNotCombined()
+	return Outcome{Stdout: c.Stdout(), Stderr: c.Stderr(), ExitCode: c.exitCode()}
+}
+
+// Assert fails the test, with the explanation of m, unless the finished
+// command matches m.
+func (c *Cmd) Assert(m Matcher) *Cmd {
+	c.t.Helper()
+	c.assert(c.checkMatcher(m))
+	return c
+}
+
+// Check marks the test as failed, with the explanation of m, unless the
+// finished command matches m, and reports whether it did.
+func (c *Cmd) Check(m Matcher) bool {
+	c.t.Helper()
+	return c.check(c.checkMatcher(m))
+}
+
+func (c *Cmd) checkMatcher(m Matcher) error {
+	c.t.Helper()
+	if ok, explanation := m.Evaluate(c.Outcome()); !ok {
+		return fmt.Errorf("Expected the command to match: %s", explanation)
+	}
+	return nil
+}
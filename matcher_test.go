@@ -0,0 +1,61 @@
+package testcli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchers(t *testing.T) {
+	o := Outcome{Stdout: `{"id": 1, "tags": ["a"]}` + "\n", Stderr: "Warning: slow\n", ExitCode: 2}
+	for _, test := range []struct {
+		m           Matcher
+		ok          bool
+		explanation string
+	}{
+		{Contains(`"ID"`), true, `stdout contains "\"ID\""`},
+		{Contains("slow"), false, `stdout doesn't contain "slow"`},
+		{Contains("slow").OnStderr(), true, `stderr contains "slow"`},
+		{Regexp(`^W\w+:`).OnStderr(), true, `stderr matches "^W\\w+:"`},
+		{JSONEq(`{"tags":["a"],"id":1}`), true, `stdout is JSON equal to {"tags":["a"],"id":1}`},
+		{JSONEq(`{"id":2}`), false, `stdout is JSON {"id":1,"tags":["a"]}, not {"id":2}`},
+		{JSONEq(`{}`).OnStderr(), false, "stderr isn't valid JSON: invalid character 'W' looking for beginning of value"},
+		{ExitCodeIs(0), false, "exit code is 2, not 0"},
+		{Not(ExitCodeIs(0)), true, "not:\n  [FAIL] exit code is 2, not 0"},
+		{
+			And(Contains("id"), Or(ExitCodeIs(0), ExitCodeIs(1)), Not(Contains("warning").OnStderr())),
+			false,
+			`all of (2 of 3 failed):
+  [ok]   stdout contains "id"
+  [FAIL] any of (2 of 2 failed):
+           [FAIL] exit code is 2, not 0
+           [FAIL] exit code is 2, not 1
+  [FAIL] not:
+           [ok]   stderr contains "warning"`,
+		},
+		{Or(ExitCodeIs(2), Contains("missing")), true, "any of (1 of 2 failed):\n  [ok]   exit code is 2\n  [FAIL] stdout doesn't contain \"missing\""},
+	} {
+		ok, explanation := test.m.Evaluate(o)
+		if ok != test.ok || explanation != test.explanation {
+			t.Errorf("Expected (%t, %q), got (%t, %q)", test.ok, test.explanation, ok, explanation)
+		}
+	}
+}
+
+// TestAssertMatcherHelperProcess asserts a matcher the command doesn't match.
+func TestAssertMatcherHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	c := Command(t, "echo", "hello")
+	c.Run()
+	c.Assert(And(Contains("hello"), ExitCodeIs(0)))
+	c.Assert(Contains("goodbye"))
+}
+
+func TestAssertMatcher(t *testing.T) {
+	c := ReexecCommand(t, "TestAssertMatcherHelperProcess")
+	c.Run()
+	if c.Success() || !strings.Contains(c.Stdout(), `Expected the command to match: stdout doesn't contain "goodbye"`) {
+		t.Fatalf("Expected the explanation of the matcher, got %q", c.Stdout())
+	}
+}
@@ -0,0 +1,206 @@
+package testcli
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// namedPipePollInterval paces the reads of named pipes which fell back to a
+// regular file, see NamedPipe().
+const namedPipePollInterval = 10 * time.Millisecond
+
+// errFIFOUnsupported is returned by mkfifo() where FIFOs aren't supported.
+var errFIFOUnsupported = errors.New("FIFOs are not supported on this platform")
+
+// NamedPipe is a path in a workspace a command writes to as if it were a
+// file, e.g. a log file, captured as a named stream, see CaptureNamedPipe().
+type NamedPipe struct {
+	name string
+	path string
+	// polled is set where FIFOs aren't supported: the path is a regular
+	// file, whose growth is polled instead.
+	polled bool
+}
+
+// NamedPipe creates a FIFO named rel in the workspace, for commands insisting
+// on writing to a path, e.g. --log=path, to be captured like stdout. Where
+// FIFOs aren't supported, e.g. on Windows, it's a regular file read as it
+// grows instead. Its stream is named after rel, see Cmd.Stream().
+func (ws *Workspace) NamedPipe(rel string) *NamedPipe {
+	ws.t.Helper()
+	path := ws.Path(rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		ws.t.Fatal(err)
+	}
+	p := &NamedPipe{name: rel, path: path}
+	if err := mkfifo(path); err != nil {
+		if err != errFIFOUnsupported {
+			ws.t.Fatalf("Can't create the named pipe %s: %s", rel, err)
+		}
+		p.polled = true
+		ws.WriteFile(rel, nil)
+	}
+	return p
+}
+
+// Path returns the absolute path of the pipe, to be passed to the command.
+func (p *NamedPipe) Path() string {
+	return p.path
+}
+
+// namedStream is the capture of a named pipe.
+type namedStream struct {
+	pipe *NamedPipe
+	o    *output
+}
+
+// CaptureNamedPipe captures what the command writes to p as the stream named
+// after it, see Cmd.Stream(), until the command exits and the writers close
+// it. A command can capture several pipes. Named streams are part of failure
+// reports, attachments and transcripts, prefixed with "[name] ".
+func CaptureNamedPipe(p *NamedPipe) Option {
+	return func(c *Cmd) {
+		c.streams = append(c.streams, &namedStream{
+			pipe: p,
+			o:    &output{mu: &sync.Mutex{}, seq: &c.seq, notify: c.outputChanged},
+		})
+	}
+}
+
+// captureStreams starts capturing the named pipes of the started command.
+func (c *Cmd) captureStreams() {
+	for _, s := range c.streams {
+		s := s
+		c.capture.Add(1)
+		go func() {
+			defer c.capture.Done()
+			if s.pipe.polled {
+				s.poll(c.done)
+			} else {
+				s.drain(c.done)
+			}
+		}()
+	}
+}
+
+// drain captures the FIFO until the command exits, reopening it each time
+// its writers close it.
+func (s *namedStream) drain(done <-chan struct{}) {
+	drained := make(chan struct{})
+	defer close(drained)
+	go s.unblock(done, drained)
+	for {
+		// Blocks until a writer opens the pipe.
+		f, err := os.Open(s.pipe.path)
+		if err != nil {
+			return
+		}
+		s.o.capture(f)
+		select {
+		case <-done:
+			return
+		default:
+		}
+	}
+}
+
+// unblock opens the FIFO for writing, once the command exited, until drain()
+// returns, for it not to wait for a writer which won't come.
+func (s *namedStream) unblock(done, drained <-chan struct{}) {
+	select {
+	case <-done:
+	case <-drained:
+		return
+	}
+	ticker := time.NewTicker(namedPipePollInterval)
+	defer ticker.Stop()
+	for {
+		if w, err := openFIFOWriter(s.pipe.path); err == nil {
+			w.Close()
+		}
+		select {
+		case <-drained:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll captures what's appended to the regular file of the pipe until the
+// command exits.
+func (s *namedStream) poll(done <-chan struct{}) {
+	var offset int64
+	read := func() {
+		f, err := os.Open(s.pipe.path)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := f.ReadAt(buf, offset)
+			if n > 0 {
+				s.o.write(buf[:n])
+				offset += int64(n)
+			}
+			if err == io.EOF || n == 0 {
+				return
+			}
+		}
+	}
+	ticker := time.NewTicker(namedPipePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			read()
+		case <-done:
+			read()
+			return
+		}
+	}
+}
+
+// Stream is a named stream of a command, see CaptureNamedPipe().
+type Stream struct {
+	c *Cmd
+	s *namedStream
+}
+
+// Stream returns the stream captured from the named pipe name. The test fails
+// if the command doesn't capture it.
+func (c *Cmd) Stream(name string) *Stream {
+	c.t.Helper()
+	for _, s := range c.streams {
+		if s.pipe.name == name {
+			return &Stream{c: c, s: s}
+		}
+	}
+	c.t.Fatalf("No named pipe %q is captured, see CaptureNamedPipe()", name)
+	return nil
+}
+
+// String returns what was captured from the stream so far.
+func (s *Stream) String() string {
+	s.c.t.Helper()
+	s.c.validateHasStarted()
+	s.s.o.mu.Lock()
+	defer s.s.o.mu.Unlock()
+	return s.s.o.text()
+}
+
+// Contains determines if the stream contains `str`, like StdoutContains().
+func (s *Stream) Contains(str string, opts ...MatchOption) bool {
+	s.c.t.Helper()
+	return s.c.outputContains(s.s.o, s.s.pipe.name, str, opts)
+}
+
+// Matches compares a regex to the stream, like StdoutMatches().
+func (s *Stream) Matches(regex string, opts ...MatchOption) bool {
+	s.c.t.Helper()
+	return s.c.outputMatches(s.s.o, s.s.pipe.name, regex, opts)
+}
@@ -0,0 +1,79 @@
+package testcli
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestNamedPipe(t *testing.T) {
+	ws := NewWorkspace(t)
+	log := ws.NamedPipe("log")
+	audit := ws.NamedPipe("audit/events")
+	c := ws.Command("/bin/sh", "-c", `echo started; echo "opened db" > "$1"; echo "user=ann" > "$2"; echo "closed db" >> "$1"`, "sh", log.Path(), audit.Path())
+	c.Apply(CaptureNamedPipe(log), CaptureNamedPipe(audit))
+	c.RecordStdin()
+	c.Run()
+
+	if got := c.Stream("log").String(); got != "opened db\nclosed db\n" {
+		t.Fatalf("Expected the log to be captured across reopenings, got %q", got)
+	}
+	if !c.Stream("log").Contains("CLOSED DB") || !c.Stream("audit/events").Matches(`^user=\w+\n$`) {
+		t.Fatalf("Expected the named streams to be matched")
+	}
+	if c.Stream("log").Contains("user=") {
+		t.Fatalf("Expected the streams to be captured separately")
+	}
+
+	transcript, err := c.transcriptText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The writes to different pipes may be captured in any order.
+	for _, line := range []string{"started\n", "[log] opened db\n", "[audit/events] user=ann\n", "[log] closed db\n"} {
+		if !strings.Contains(transcript, line) {
+			t.Fatalf("Expected the transcript %q to contain %q", transcript, line)
+		}
+	}
+	if report := c.report(); !strings.Contains(report, "\nlog:\nopened db\nclosed db\n") {
+		t.Fatalf("Expected the report to show the named streams, got %q", report)
+	}
+}
+
+func TestNamedPipeNeverOpened(t *testing.T) {
+	ws := NewWorkspace(t)
+	log := ws.NamedPipe("log")
+	c := ws.Command("true")
+	c.Apply(CaptureNamedPipe(log))
+	c.Run()
+	if c.DrainIncomplete() || c.Stream("log").String() != "" {
+		t.Fatalf("Expected an empty stream, captured at once")
+	}
+}
+
+func TestNamedPipeStreaming(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("No FIFOs on Windows")
+	}
+	ws := NewWorkspace(t)
+	log := ws.NamedPipe("log")
+	c := ws.Command("/bin/sh", "-c", `exec 3> "$1"; echo ready >&3; exec sleep 10`, "sh", log.Path())
+	c.Apply(CaptureNamedPipe(log))
+	c.Start()
+	defer c.Kill()
+	if !c.Stream("log").Contains("ready") {
+		t.Fatalf("Expected the stream to be matched while the command runs")
+	}
+}
+
+func TestNamedPipePolled(t *testing.T) {
+	ws := NewWorkspace(t)
+	log := &NamedPipe{name: "log", path: ws.Path("log"), polled: true}
+	ws.WriteFile("log", nil)
+	c := ws.Command("/bin/sh", "-c", `echo one >> "$1"; sleep 0.05; echo two >> "$1"`, "sh", log.Path())
+	c.Apply(CaptureNamedPipe(log))
+	c.Run()
+	if got := c.Stream("log").String(); got != "one\ntwo\n" {
+		t.Fatalf("Expected the polled file to be captured, got %q", got)
+	}
+}
@@ -0,0 +1,91 @@
+package testcli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window sets how long StdoutNotContains() and StderrNotContains() watch a
+// running command for the string to show up, 1 second by default.
+func Window(d time.Duration) MatchOption {
+	return func(cfg *matchConfig) {
+		cfg.window = d
+	}
+}
+
+// StdoutNotContains determines if command's STDOUT doesn't contain `str`,
+// this operation is case insensitive unless CaseSensitive() is passed. The
+// output of a finished command is checked once. A running command is watched
+// until it exits, or for the Window(), and it's false as soon as str shows
+// up: unlike !StdoutContains(), which gives up on the first of these, it
+// doesn't hope for the string to appear.
+func (c *Cmd) StdoutNotContains(str string, opts ...MatchOption) bool {
+	c.t.Helper()
+	return c.outputNotContains(c.stdout, "stdout", str, opts)
+}
+
+// StdoutNotContains determines if command's STDOUT doesn't contain `str`,
+// this operation is case insensitive unless CaseSensitive() is passed.
+func StdoutNotContains(str string, opts ...MatchOption) bool {
+	c := pkgCmd()
+	c.t.Helper()
+	return c.StdoutNotContains(str, opts...)
+}
+
+// StderrNotContains determines if command's STDERR doesn't contain `str`,
+// see StdoutNotContains().
+func (c *Cmd) StderrNotContains(str string, opts ...MatchOption) bool {
+	c.t.Helper()
+	return c.outputNotContains(c.stderr, "stderr", str, opts)
+}
+
+// StderrNotContains determines if command's STDERR doesn't contain `str`,
+// this operation is case insensitive unless CaseSensitive() is passed.
+func StderrNotContains(str string, opts ...MatchOption) bool {
+	c := pkgCmd()
+	c.t.Helper()
+	return c.StderrNotContains(str, opts...)
+}
+
+// outputNotContains is StdoutNotContains() for o, named stream in messages.
+// It waits for str to show up: the assertion holds if the wait gives up.
+func (c *Cmd) outputNotContains(o *output, stream, str string, opts []MatchOption) bool {
+	c.t.Helper()
+	c.validateHasStarted()
+	if !c.awaitFinal(opts) {
+		return false
+	}
+	cfg := matchOptions(opts)
+	window := cfg.window
+	if window == 0 {
+		window = Scaled(defaultPollTimeout)
+	}
+	fold := !cfg.caseSensitive
+	var contains func() bool
+	if o.spill != nil {
+		needle := []byte(str)
+		if fold {
+			asciiLower(needle)
+		}
+		var offset int64
+		contains = func() bool {
+			var found bool
+			found, offset = o.streamContains(needle, offset, fold)
+			return found
+		}
+	} else {
+		if fold {
+			str = strings.ToLower(str)
+		}
+		view := c.view(o, opts)
+		contains = func() bool {
+			return strings.Contains(view(), str)
+		}
+	}
+	if c.waitFor(fmt.Sprintf("%s to contain %q", stream, str), contains, defaultPollInterval, window) == nil {
+		return false
+	}
+	o.examineAll()
+	return true
+}
@@ -0,0 +1,64 @@
+package testcli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNotContainsOnFinishedCommand(t *testing.T) {
+	c := Command(t, "sh", "-c", "echo all good; echo warning >&2")
+	c.Run()
+	started := time.Now()
+	if !c.StdoutNotContains("error") || !c.StderrNotContains("error") {
+		t.Fatalf("Expected no error in %q", c.Stdout())
+	}
+	if c.StdoutNotContains("GOOD") || c.StderrNotContains("warning") {
+		t.Fatalf("Expected what was printed to be found")
+	}
+	if !c.StdoutNotContains("GOOD", CaseSensitive()) {
+		t.Fatalf("Expected the case sensitive search not to find %q", "GOOD")
+	}
+	if elapsed := time.Since(started); elapsed > 100*time.Millisecond {
+		t.Fatalf("Expected a single check of the finished command, took %s", elapsed)
+	}
+}
+
+func TestNotContainsWatchesRunningCommand(t *testing.T) {
+	c := Command(t, "sh", "-c", "echo starting; sleep 0.3; echo error; exec sleep 5")
+	c.Start()
+	defer c.Kill()
+	if c.StdoutNotContains("error") {
+		t.Fatalf("Expected the error printed later to be seen, got %q", c.Stdout())
+	}
+}
+
+func TestNotContainsUntilExit(t *testing.T) {
+	c := Command(t, "sh", "-c", "sleep 0.2; echo done")
+	c.Start()
+	started := time.Now()
+	if !c.StdoutNotContains("error", Window(time.Minute)) {
+		t.Fatalf("Expected no error in %q", c.Stdout())
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Fatalf("Expected to stop watching when the command exited, took %s", elapsed)
+	}
+	c.Wait()
+}
+
+func TestNotContainsWindow(t *testing.T) {
+	c := Command(t, "sh", "-c", "sleep 1; echo error; exec sleep 5")
+	c.Start()
+	defer c.Kill()
+	if !c.StdoutNotContains("error", Window(100*time.Millisecond)) {
+		t.Fatalf("Expected the error not to be printed within the window")
+	}
+}
+
+func TestNotContainsSpilled(t *testing.T) {
+	c := Command(t, "sh", "-c", "echo all good, nothing to see here")
+	c.SpillCaptureToDisk(t.TempDir(), 4)
+	c.Run()
+	if !c.StdoutNotContains("error") || c.StdoutNotContains("NOTHING") {
+		t.Fatalf("Expected the spilled output to be searched, got %q", c.Stdout())
+	}
+}
@@ -0,0 +1,110 @@
+package testcli
+
+import (
+	"os"
+	"regexp"
+	"runtime"
+	"syscall"
+)
+
+// outOfMemoryMessages are what programs print when an allocation fails, e.g.
+// after hitting RLIMIT_AS.
+var outOfMemoryMessages = regexp.MustCompile(`(?m)^.*(fatal error: runtime: out of memory|Cannot allocate memory|std::bad_alloc|MemoryError|JavaScript heap out of memory|java\.lang\.OutOfMemoryError).*$`)
+
+// OOMEvidence is what LikelyOOMKilled() decides from.
+type OOMEvidence struct {
+	// Signal is the signal which killed the process, nil if it exited.
+	Signal os.Signal
+	// KilledByTest is set when the test itself killed the process, e.g. with
+	// Kill() or WithTimeout().
+	KilledByTest bool
+	// MaxRSS is the peak resident set size of the process in bytes, 0 if
+	// unknown.
+	MaxRSS int64
+	// MemoryLimit is the limit set by WithMemoryLimit(), 0 if none.
+	MemoryLimit int64
+	// Cgroup is set when MemoryLimit is enforced by a cgroup, rather than by
+	// RLIMIT_AS.
+	Cgroup bool
+	// OOMKills is how many processes of the cgroup the kernel killed for
+	// running out of memory, -1 if unknown.
+	OOMKills int
+	// OutOfMemoryMessage is the line of stderr reporting a failed
+	// allocation, as printed after hitting RLIMIT_AS, empty if none.
+	OutOfMemoryMessage string
+}
+
+// WithMemoryLimit limits the memory of the command to limit bytes. On Linux,
+// it's enforced with a memory cgroup when the test can create one, e.g. as
+// root, so that exceeding it gets the command killed by the OOM killer, and
+// with RLIMIT_AS otherwise, so that allocations fail. Either way, the limit
+// is set right after the command starts. The test is skipped on other
+// platforms.
+func WithMemoryLimit(limit int64) Option {
+	return func(c *Cmd) {
+		c.t.Helper()
+		if runtime.GOOS != "linux" {
+			c.t.Skip("Memory limits are only supported on Linux")
+		}
+		c.memoryLimit = limit
+	}
+}
+
+// OOMEvidence returns what's known of how the finished command died, see
+// LikelyOOMKilled().
+func (c *Cmd) OOMEvidence() OOMEvidence {
+	c.t.Helper()
+	c.validateIsFinished()
+	e := OOMEvidence{
+		KilledByTest: c.killedByTest(),
+		MemoryLimit:  c.memoryLimit,
+		OOMKills:     -1,
+	}
+	if state := c.cmd.ProcessState; state != nil {
+		if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			e.Signal = status.Signal()
+		}
+		e.MaxRSS = maxRSS(state)
+	}
+	if c.memoryCgroup != nil {
+		e.Cgroup = true
+		e.OOMKills = c.memoryCgroup.oomKills()
+	}
+	c.stderr.mu.Lock()
+	e.OutOfMemoryMessage = outOfMemoryMessages.FindString(c.stderr.text())
+	c.stderr.mu.Unlock()
+	return e
+}
+
+// LikelyOOMKilled reports whether the finished command was likely killed by
+// the kernel for running out of memory, as opposed to exiting on its own or
+// being killed by the test. It's a best guess from OOMEvidence(): the command
+// must have been killed by SIGKILL, not by the test, and its cgroup must
+// have recorded an OOM kill, or, when that's unknown, it must have come
+// within 10% of its memory limit, if any.
+func (c *Cmd) LikelyOOMKilled() bool {
+	c.t.Helper()
+	return c.OOMEvidence().likelyOOMKilled()
+}
+
+func (e OOMEvidence) likelyOOMKilled() bool {
+	if e.Signal != syscall.SIGKILL || e.KilledByTest {
+		return false
+	}
+	if e.OOMKills >= 0 {
+		return e.OOMKills > 0
+	}
+	if e.MemoryLimit > 0 && e.MaxRSS > 0 {
+		return e.MaxRSS >= e.MemoryLimit*9/10
+	}
+	// The OOM killer is the usual sender of an unexpected SIGKILL.
+	return true
+}
+
+// killedByTest reports whether the process was killed by Kill() or a
+// timeout.
+func (c *Cmd) killedByTest() bool {
+	c.terminationMu.Lock()
+	defer c.terminationMu.Unlock()
+	return c.terminationReason.Killed || c.terminationReason.Signal != nil
+}
@@ -0,0 +1,142 @@
+package testcli
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// memoryCgroup is a cgroup limiting the memory of a command.
+type memoryCgroup struct {
+	dir string
+	v2  bool
+}
+
+// newMemoryCgroup creates a cgroup limited to limit bytes, without swap,
+// under the cgroup of the test process.
+func newMemoryCgroup(limit int64) (*memoryCgroup, error) {
+	self, err := ioutil.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("testcli-%d-%d", os.Getpid(), nextSeq(&memoryCgroups))
+	for _, line := range strings.Split(string(self), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		var g *memoryCgroup
+		switch {
+		case parts[0] == "0" && parts[1] == "":
+			g = &memoryCgroup{dir: filepath.Join("/sys/fs/cgroup", parts[2], name), v2: true}
+		case strings.Contains(","+parts[1]+",", ",memory,"):
+			g = &memoryCgroup{dir: filepath.Join("/sys/fs/cgroup/memory", parts[2], name)}
+		default:
+			continue
+		}
+		if err := g.create(limit); err == nil {
+			return g, nil
+		}
+	}
+	return nil, fmt.Errorf("can't create a memory cgroup")
+}
+
+var memoryCgroups int64
+
+func (g *memoryCgroup) create(limit int64) error {
+	if err := os.Mkdir(g.dir, 0755); err != nil {
+		return err
+	}
+	files := []string{"memory.limit_in_bytes", "memory.memsw.limit_in_bytes"}
+	if g.v2 {
+		files = []string{"memory.max", "memory.swap.max"}
+	}
+	if err := g.write(files[0], limit); err != nil {
+		g.remove()
+		return err
+	}
+	// Without swap, which isn't always accounted for.
+	swap := limit
+	if g.v2 {
+		swap = 0
+	}
+	g.write(files[1], swap)
+	return nil
+}
+
+func (g *memoryCgroup) write(file string, value int64) error {
+	return ioutil.WriteFile(filepath.Join(g.dir, file), []byte(strconv.FormatInt(value, 10)), 0644)
+}
+
+// add moves the process pid to the cgroup.
+func (g *memoryCgroup) add(pid int) error {
+	return g.write("cgroup.procs", int64(pid))
+}
+
+// oomKills returns how many processes of the cgroup the OOM killer killed, -1
+// if unknown.
+func (g *memoryCgroup) oomKills() int {
+	file := "memory.oom_control"
+	if g.v2 {
+		file = "memory.events"
+	}
+	f, err := os.Open(filepath.Join(g.dir, file))
+	if err != nil {
+		return -1
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			if n, err := strconv.Atoi(fields[1]); err == nil {
+				return n
+			}
+		}
+	}
+	return -1
+}
+
+// remove removes the cgroup, once its processes exited.
+func (g *memoryCgroup) remove() {
+	os.Remove(g.dir)
+}
+
+// applyMemoryLimit enforces WithMemoryLimit() on the started command.
+func (c *Cmd) applyMemoryLimit() {
+	c.t.Helper()
+	if c.memoryLimit <= 0 {
+		return
+	}
+	pid := c.process.Pid()
+	if pid <= 0 {
+		c.t.Skip("Memory limits need a local process")
+	}
+	if g, err := newMemoryCgroup(c.memoryLimit); err == nil {
+		if err := g.add(pid); err == nil {
+			c.memoryCgroup = g
+			c.t.Cleanup(g.remove)
+			return
+		}
+		g.remove()
+	}
+	limit := syscall.Rlimit{Cur: uint64(c.memoryLimit), Max: uint64(c.memoryLimit)}
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), syscall.RLIMIT_AS,
+		uintptr(unsafe.Pointer(&limit)), 0, 0, 0); errno != 0 {
+		c.t.Fatalf("Failed to limit the memory of %d: %s", pid, errno)
+	}
+}
+
+// maxRSS returns the peak resident set size of the process, in bytes.
+func maxRSS(state *os.ProcessState) int64 {
+	if usage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		return usage.Maxrss * 1024
+	}
+	return 0
+}
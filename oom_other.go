@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package testcli
+
+import "os"
+
+type memoryCgroup struct{}
+
+func (g *memoryCgroup) oomKills() int {
+	return -1
+}
+
+func (c *Cmd) applyMemoryLimit() {}
+
+func maxRSS(state *os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,87 @@
+//go:build linux
+// +build linux
+
+package testcli
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestLikelyOOMKilled(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Building memhog is slow")
+	}
+	memhog := Build(t, "./testdata/memhog")
+	c := Command(t, memhog)
+	c.Apply(WithMemoryLimit(50 << 20))
+	c.Run()
+
+	evidence := c.OOMEvidence()
+	t.Logf("Evidence: %+v", evidence)
+	if !c.Failure() {
+		t.Fatalf("Expected the memory hog to fail")
+	}
+	if evidence.Cgroup {
+		if !c.LikelyOOMKilled() {
+			t.Fatalf("Expected the memory hog to be OOM killed, evidence: %+v", evidence)
+		}
+		return
+	}
+	// RLIMIT_AS makes allocations fail instead.
+	if c.LikelyOOMKilled() || evidence.OutOfMemoryMessage == "" {
+		t.Fatalf("Expected the memory hog to run out of memory on its own, evidence: %+v\n%s", evidence, c.Stderr())
+	}
+}
+
+func TestLikelyOOMKilledWithinLimit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Building memhog is slow")
+	}
+	memhog := Build(t, "./testdata/memhog")
+	c := Command(t, memhog, "10")
+	c.Apply(WithMemoryLimit(200 << 20))
+	c.Run()
+	if !c.Success() || c.LikelyOOMKilled() {
+		t.Fatalf("Expected to succeed within the limit, got %v: %+v", c.Error(), c.OOMEvidence())
+	}
+}
+
+func TestLikelyOOMKilledExitCode(t *testing.T) {
+	c := Command(t, "sh", "-c", "exit 1")
+	c.Run()
+	if c.LikelyOOMKilled() {
+		t.Fatalf("Expected exiting with 1 not to be an OOM kill")
+	}
+}
+
+func TestLikelyOOMKilledByTest(t *testing.T) {
+	c := Command(t, "sleep", "5")
+	c.Start()
+	c.Kill()
+	evidence := c.OOMEvidence()
+	if evidence.Signal != syscall.SIGKILL || !evidence.KilledByTest || c.LikelyOOMKilled() {
+		t.Fatalf("Expected a SIGKILL from the test not to be an OOM kill, evidence: %+v", evidence)
+	}
+}
+
+func TestOOMEvidenceLikelyOOMKilled(t *testing.T) {
+	tests := []struct {
+		name     string
+		evidence OOMEvidence
+		expected bool
+	}{
+		{"exited", OOMEvidence{OOMKills: -1}, false},
+		{"SIGTERM", OOMEvidence{Signal: syscall.SIGTERM, OOMKills: -1}, false},
+		{"cgroup OOM kill", OOMEvidence{Signal: syscall.SIGKILL, OOMKills: 1}, true},
+		{"cgroup without OOM kill", OOMEvidence{Signal: syscall.SIGKILL, OOMKills: 0}, false},
+		{"near the limit", OOMEvidence{Signal: syscall.SIGKILL, OOMKills: -1, MemoryLimit: 100, MaxRSS: 95}, true},
+		{"far from the limit", OOMEvidence{Signal: syscall.SIGKILL, OOMKills: -1, MemoryLimit: 100, MaxRSS: 10}, false},
+		{"unexpected SIGKILL", OOMEvidence{Signal: syscall.SIGKILL, OOMKills: -1}, true},
+	}
+	for _, test := range tests {
+		if got := test.evidence.likelyOOMKilled(); got != test.expected {
+			t.Errorf("%s: expected %t, got %t", test.name, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package testcli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// StrictPathsEnvVar turns GuardPaths() on for every command when set to a
+// non-empty value, e.g. in CI. AllowAllPaths() opts single commands out.
+const StrictPathsEnvVar = "TESTCLI_STRICT_PATHS"
+
+// GuardPaths makes the command fail the test before it runs if one of its
+// arguments, or of the environment variables set by the test, is an absolute
+// path outside of its directory and of the paths allowed by AllowPath(). It's
+// a safety net for destructive commands whose paths come from variables,
+// e.g. `mycli purge --dir /`. Both bare arguments and the values of flags,
+// as in --dir=/x and --dir /x, are checked. The files of WithInputFile() are
+// always allowed.
+func GuardPaths() Option {
+	return func(c *Cmd) {
+		c.guardPaths = true
+	}
+}
+
+// AllowPath allows the command to be passed paths, and anything under them,
+// despite GuardPaths().
+func AllowPath(paths ...string) Option {
+	return func(c *Cmd) {
+		c.allowedPaths = append(c.allowedPaths, paths...)
+	}
+}
+
+// AllowAllPaths turns GuardPaths() off for the command, even with
+// StrictPathsEnvVar set.
+func AllowAllPaths() Option {
+	return func(c *Cmd) {
+		c.allowAllPaths = true
+	}
+}
+
+// checkPaths fails the test if the command is guarded and passed a path
+// outside of its allowed roots, see GuardPaths().
+func (c *Cmd) checkPaths() {
+	c.t.Helper()
+	if err := c.unsafePath(); err != nil {
+		c.t.Fatalf("Refusing to run the command: %s", err)
+	}
+}
+
+func (c *Cmd) unsafePath() error {
+	guarded := c.guardPaths || os.Getenv(StrictPathsEnvVar) != ""
+	if !guarded || c.allowAllPaths {
+		return nil
+	}
+	roots := append(append([]string(nil), c.allowedPaths...), c.inputFiles...)
+	if c.cmd.Dir != "" {
+		roots = append(roots, c.cmd.Dir)
+	} else if wd, err := os.Getwd(); err == nil {
+		roots = append(roots, wd)
+	}
+
+	args := c.cmd.Args
+	for i, arg := range args[1:] {
+		if path, ok := pathOf(arg); ok && !within(path, roots) {
+			highlighted := append([]string(nil), args...)
+			highlighted[i+1] = ">>>" + arg + "<<<"
+			return fmt.Errorf("argument %q is outside of %s, see AllowPath()\n  %s",
+				path, strings.Join(roots, ", "), strings.Join(highlighted, " "))
+		}
+	}
+
+	// Only the variables set by the test: those set by the package point to
+	// its temporary files.
+	set := map[string]bool{}
+	for _, kv := range c.envOverrides {
+		set[envName(kv)] = true
+	}
+	for _, kv := range envDelta(c.environ(), os.Environ()) {
+		parts := strings.SplitN(kv, "=", 2)
+		if set[parts[0]] || len(parts) < 2 {
+			continue
+		}
+		if value := parts[1]; filepath.IsAbs(value) && !within(value, roots) {
+			return fmt.Errorf("environment variable %s is outside of %s, see AllowPath()", kv, strings.Join(roots, ", "))
+		}
+	}
+	return nil
+}
+
+// pathOf returns the absolute path arg is, or holds as the value of a flag
+// like --dir=/x.
+func pathOf(arg string) (string, bool) {
+	if strings.HasPrefix(arg, "-") {
+		i := strings.IndexByte(arg, '=')
+		if i < 0 {
+			return "", false
+		}
+		arg = arg[i+1:]
+	}
+	return arg, filepath.IsAbs(arg)
+}
+
+// within reports whether path is one of roots or under one of them.
+func within(path string, roots []string) bool {
+	path = filepath.Clean(path)
+	for _, root := range roots {
+		rel, err := filepath.Rel(filepath.Clean(root), path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
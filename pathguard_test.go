@@ -0,0 +1,78 @@
+package testcli
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestUnsafePath(t *testing.T) {
+	ws := NewWorkspace(t)
+	for _, test := range []struct {
+		args  []string
+		opts  []Option
+		env   [2]string
+		error string
+	}{
+		{args: []string{"purge", "--dir", ws.Path("data")}},
+		{args: []string{"purge", "--dir=" + ws.Path("data"), "-v"}},
+		{args: []string{"purge", "relative/dir"}},
+		{args: []string{"purge", "--dir", "/"}, error: `argument "/" is outside of ` + ws.Root()},
+		{args: []string{"purge", "--dir=/etc"}, error: "purge >>>--dir=/etc<<<"},
+		{args: []string{"purge", ws.Root() + "/../elsewhere"}, error: "outside of"},
+		{args: []string{"purge", "/tmp/testcli-x"}, opts: []Option{AllowPath("/tmp")}},
+		{args: []string{"purge", "/"}, opts: []Option{AllowAllPaths()}},
+		{args: []string{"purge"}, env: [2]string{"DATA_DIR", "/var/lib"}, error: "environment variable DATA_DIR=/var/lib is outside of"},
+		{args: []string{"purge"}, env: [2]string{"DATA_DIR", ws.Path("data")}},
+	} {
+		c := ws.Command("mycli", test.args...)
+		c.Apply(GuardPaths())
+		c.Apply(test.opts...)
+		if test.env[0] != "" {
+			c.SetEnvVar(test.env[0], test.env[1])
+		}
+		err := c.unsafePath()
+		if test.error == "" && err != nil {
+			t.Errorf("Expected %q to be allowed, got %s", test.args, err)
+		}
+		if test.error != "" && (err == nil || !strings.Contains(err.Error(), test.error)) {
+			t.Errorf("Expected %q to be refused with %q, got %v", test.args, test.error, err)
+		}
+	}
+}
+
+func TestUnsafePathStrict(t *testing.T) {
+	c := Command(t, "rm", "-rf", "/")
+	if c.unsafePath() != nil {
+		t.Fatalf("Expected commands not to be guarded by default")
+	}
+	os.Setenv(StrictPathsEnvVar, "1")
+	defer os.Unsetenv(StrictPathsEnvVar)
+	if c.unsafePath() == nil {
+		t.Fatalf("Expected commands to be guarded with %s", StrictPathsEnvVar)
+	}
+}
+
+// TestGuardPathsHelperProcess runs a command passed a path outside of the
+// workspace.
+func TestGuardPathsHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	ws := NewWorkspace(t)
+	c := ws.Command("echo", "purge", "--dir", "/")
+	c.Apply(GuardPaths())
+	c.Run()
+	t.Log("the command ran")
+}
+
+func TestGuardPaths(t *testing.T) {
+	c := ReexecCommand(t, "TestGuardPathsHelperProcess")
+	c.Run()
+	if c.Success() || !strings.Contains(c.Stdout(), `Refusing to run the command: argument "/" is outside of`) || !strings.Contains(c.Stdout(), "echo purge --dir >>>/<<<") {
+		t.Fatalf("Expected the command to be refused, got %q", c.Stdout())
+	}
+	if strings.Contains(c.Stdout(), "the command ran") {
+		t.Fatalf("Expected the test to stop before running the command")
+	}
+}
@@ -0,0 +1,45 @@
+package testcli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// pipeHolders describes the other processes holding the pipe f, e.g.
+// "pid 42 (sleep 10)", as seen in /proc. Processes we can't inspect are
+// left out.
+func pipeHolders(f *os.File) []string {
+	pipe, err := os.Readlink("/proc/self/fd/" + strconv.Itoa(int(f.Fd())))
+	if err != nil || !strings.HasPrefix(pipe, "pipe:") {
+		return nil
+	}
+	fds, _ := filepath.Glob("/proc/[0-9]*/fd/*")
+	self := os.Getpid()
+	seen := map[int]bool{}
+	var holders []string
+	for _, fd := range fds {
+		pid, _ := strconv.Atoi(strings.Split(fd, "/")[2])
+		if pid == self || seen[pid] {
+			continue
+		}
+		if target, err := os.Readlink(fd); err != nil || target != pipe {
+			continue
+		}
+		seen[pid] = true
+		holders = append(holders, fmt.Sprintf("pid %d (%s)", pid, processCmdline(pid)))
+	}
+	return holders
+}
+
+// processCmdline returns the command line of the process pid.
+func processCmdline(pid int) string {
+	raw, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return "?"
+	}
+	return strings.Join(strings.Split(strings.TrimSuffix(string(raw), "\x00"), "\x00"), " ")
+}
@@ -0,0 +1,65 @@
+package testcli
+
+import (
+	"regexp"
+	"strings"
+)
+
+// FailOnStderr makes a successful run fail the test if the command wrote
+// anything to stderr, except for lines matching one of ignorePatterns. It's
+// checked once the command has finished, by Wait() or Run(), and can be
+// enforced for every command with SetDefaultOptions(). Stderr can't be told
+// apart from stdout once combined, so starting a command with both
+// FailOnStderr() and CombineOutput() fails the test.
+func FailOnStderr(ignorePatterns ...string) Option {
+	return func(c *Cmd) {
+		c.failOnStderr = true
+		for _, pattern := range ignorePatterns {
+			c.stderrIgnored = append(c.stderrIgnored, regexp.MustCompile(pattern))
+		}
+	}
+}
+
+// validateStderrPolicy fails the test if FailOnStderr() can't be enforced.
+func (c *Cmd) validateStderrPolicy() {
+	c.t.Helper()
+	if c.failOnStderr && c.combined != nil {
+		c.t.Fatal("FailOnStderr() can't check stderr combined with stdout by CombineOutput()")
+	}
+}
+
+// checkStderrPolicy enforces FailOnStderr() on a finished command.
+func (c *Cmd) checkStderrPolicy() {
+	c.t.Helper()
+	if !c.failOnStderr || c.exitError != nil {
+		return
+	}
+	if offending := c.unexpectedStderr(); len(offending) > 0 {
+		c.errorf("Expected a successful run to leave stderr empty, got:\n%s", strings.Join(offending, "\n"))
+	}
+}
+
+// unexpectedStderr returns the lines of stderr not ignored by FailOnStderr().
+func (c *Cmd) unexpectedStderr() []string {
+	c.stderr.mu.Lock()
+	stderr := c.stderr.text()
+	c.stderr.mu.Unlock()
+
+	var offending []string
+	for _, line := range strings.Split(strings.TrimSuffix(stderr, "\n"), "\n") {
+		if line == "" || c.ignoredOnStderr(line) {
+			continue
+		}
+		offending = append(offending, line)
+	}
+	return offending
+}
+
+func (c *Cmd) ignoredOnStderr(line string) bool {
+	for _, re := range c.stderrIgnored {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package testcli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFailOnStderr(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", "echo 'warning: deprecated' >&2; echo 'debug: x' >&2; echo 'oops' >&2")
+	c.Apply(FailOnStderr("^debug:", "deprecated"))
+	c.Start()
+	<-c.done
+	c.capture.Wait()
+
+	expected := []string{"oops"}
+	if got := c.unexpectedStderr(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestFailOnStderrIgnoresFailures(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", "echo 'oops' >&2; exit 1")
+	c.Apply(FailOnStderr())
+	c.Run()
+	if !c.Failure() {
+		t.Fatalf("Expected to fail, but succeeded")
+	}
+}
+
+func TestFailOnStderrAllowsIgnoredLines(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", "echo 'debug: x' >&2")
+	c.Apply(FailOnStderr("^debug:"))
+	c.Run()
+	if !c.Success() {
+		t.Fatalf("Expected to succeed, but failed with error: %s", c.Error())
+	}
+}
+
+// TestFailOnStderrCombinedHelperProcess fails stderr checks on combined output.
+func TestFailOnStderrCombinedHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	c := Command(t, "/bin/sh", "-c", "echo oops >&2")
+	c.Apply(FailOnStderr())
+	c.CombineOutput()
+	c.Run()
+}
+
+func TestFailOnStderrCombined(t *testing.T) {
+	c := ReexecCommand(t, "TestFailOnStderrCombinedHelperProcess")
+	c.Run()
+	if !c.Failure() {
+		t.Fatalf("Expected to fail, but succeeded")
+	}
+
+	c.stdout.filter = nil
+	expected := "FailOnStderr() can't check stderr combined with stdout"
+	if !c.StdoutContains(expected, CaseSensitive()) {
+		t.Fatalf("Expected %q to contain %q", c.Stdout(), expected)
+	}
+}
+
+func TestSetDefaultOptions(t *testing.T) {
+	SetDefaultOptions(FailOnStderr("ignored"))
+	defer SetDefaultOptions()
+
+	c := Command(t, "true")
+	if !c.failOnStderr || len(c.stderrIgnored) != 1 {
+		t.Fatalf("Expected the default options to be applied")
+	}
+
+	SetDefaultOptions()
+	if c := Command(t, "true"); c.failOnStderr {
+		t.Fatalf("Expected the default options to be reset")
+	}
+}
+
+// TestFailOnStderrHelperProcess runs a command writing to stderr under the
+// FailOnStderr policy.
+func TestFailOnStderrHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	c := Command(t, "/bin/sh", "-c", "echo 'something odd' >&2")
+	c.Apply(FailOnStderr())
+	c.Run()
+}
+
+func TestFailOnStderrFailsTest(t *testing.T) {
+	c := ReexecCommand(t, "TestFailOnStderrHelperProcess")
+	c.Run()
+	if !c.Failure() {
+		t.Fatalf("Expected the helper test to fail")
+	}
+
+	if !c.StdoutContains("something odd") {
+		t.Fatalf("Expected %q to contain the offending line", c.Stdout())
+	}
+}
@@ -0,0 +1,172 @@
+package testcli
+
+import (
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Impl is an implementation of a standard tool.
+type Impl string
+
+// The implementations ProbeTool() tells apart.
+const (
+	ImplGNU     Impl = "GNU"
+	ImplBSD     Impl = "BSD"
+	ImplBusyBox Impl = "BusyBox"
+	ImplUnknown Impl = "unknown"
+)
+
+// ToolProbe describes the implementation of a tool, see ProbeTool().
+type ToolProbe struct {
+	t *testing.T
+	// Tool is the name of the tool, e.g. "sed".
+	Tool string
+	// Path is where it was found in PATH.
+	Path string
+	Impl Impl
+	// Version is the version it reports, empty if unknown.
+	Version string
+}
+
+// probeDef tells implementations of a tool apart by their behavior, when
+// what they print for --version isn't conclusive. It returns ImplUnknown if
+// the behavior isn't either.
+type probeDef func(path string) Impl
+
+// probeDefs are the behavioral probes of the tools known to differ. Others,
+// like grep and cp, are told apart by their version: GNU ones name
+// themselves, BSD ones either name themselves too or reject --version.
+var probeDefs = map[string]probeDef{
+	// GNU sed understands \t in replacements, BSD sed writes a "t".
+	"sed": func(path string) Impl {
+		out, err := probeOutput(path, "a\n", "s/a/\\t/")
+		switch {
+		case err != nil:
+			return ImplUnknown
+		case out == "\t\n":
+			return ImplGNU
+		case out == "t\n":
+			return ImplBSD
+		}
+		return ImplUnknown
+	},
+	// BSD date has no -d, which sets the date to show in GNU date.
+	"date": func(path string) Impl {
+		out, err := probeOutput(path, "", "-u", "-d", "@0", "+%Y")
+		if err == nil && out == "1970\n" {
+			return ImplGNU
+		}
+		if _, err := probeOutput(path, "", "-u", "-r", "0", "+%Y"); err == nil {
+			return ImplBSD
+		}
+		return ImplUnknown
+	},
+}
+
+var (
+	probesMu sync.Mutex
+	probes   = map[string]ToolProbe{}
+)
+
+var versionNumber = regexp.MustCompile(`\d+(\.\d+)+`)
+
+// ProbeTool finds out which implementation of tool, like sed, grep, date or
+// cp, is first in PATH, so that tests can expect its actual behavior rather
+// than guess it from runtime.GOOS: GNU coreutils are common on macOS, BSD
+// tools on Linux are rare but BusyBox is the norm in small containers. It runs
+// a few cheap invocations, once per process. The test fails if tool isn't
+// found.
+func ProbeTool(t *testing.T, tool string) *ToolProbe {
+	t.Helper()
+	path, err := exec.LookPath(tool)
+	if err != nil {
+		t.Fatalf("Can't probe %s: %s", tool, err)
+	}
+
+	probesMu.Lock()
+	p, ok := probes[path]
+	probesMu.Unlock()
+	if !ok {
+		p = probe(tool, path)
+		probesMu.Lock()
+		probes[path] = p
+		probesMu.Unlock()
+	}
+	p.t = t
+	return &p
+}
+
+// probe runs the invocations telling the implementations of tool apart.
+func probe(tool, path string) ToolProbe {
+	p := ToolProbe{Tool: tool, Path: path, Impl: ImplUnknown}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil && strings.HasPrefix(filepath.Base(resolved), "busybox") {
+		p.Impl = ImplBusyBox
+	}
+	version, _ := probeOutput(path, "", "--version")
+	help, _ := probeOutput(path, "", "--help")
+	p.Impl, p.Version = classifyProbe(p.Impl, version, help)
+	if p.Impl == ImplUnknown && probeDefs[tool] != nil {
+		p.Impl = probeDefs[tool](path)
+	}
+	return p
+}
+
+// classifyProbe tells the implementation of a tool from what it prints for
+// --version and --help, and returns its version, starting from impl if it's
+// already known.
+func classifyProbe(impl Impl, version, help string) (Impl, string) {
+	// BusyBox sed claims "This is not GNU sed version 4.0", the banner is
+	// what counts.
+	text := version + help
+	if i := strings.Index(text, "BusyBox"); i >= 0 {
+		return ImplBusyBox, versionNumber.FindString(firstLine(text[i:]))
+	}
+	if impl != ImplUnknown {
+		return impl, ""
+	}
+
+	first := firstLine(version)
+	switch {
+	case strings.Contains(first, "BSD"):
+		// e.g. "grep (BSD grep, GNU compatible) 2.6.0-FreeBSD".
+		return ImplBSD, versionNumber.FindString(first)
+	case strings.Contains(first, "GNU"):
+		return ImplGNU, versionNumber.FindString(first)
+	case strings.Contains(version, "illegal option") || strings.Contains(version, "unrecognized option"):
+		// BSD tools don't know --version.
+		return ImplBSD, ""
+	}
+	return ImplUnknown, ""
+}
+
+func firstLine(s string) string {
+	return strings.SplitN(s, "\n", 2)[0]
+}
+
+// probeOutput runs path with args, stdin as input, and returns what it
+// printed to stdout and stderr.
+func probeOutput(path, stdin string, args ...string) (string, error) {
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+// ExpectByImpl returns the expectation for the implementation of the tool,
+// e.g. the message a test expects it to print, or the one for ImplUnknown if
+// it has none. The test fails if there's neither.
+func (p *ToolProbe) ExpectByImpl(expected map[Impl]string) string {
+	p.t.Helper()
+	if s, ok := expected[p.Impl]; ok {
+		return s
+	}
+	if s, ok := expected[ImplUnknown]; ok {
+		return s
+	}
+	p.t.Fatalf("No expectation for the %s implementation of %s (%s)", p.Impl, p.Tool, p.Path)
+	return ""
+}
@@ -0,0 +1,91 @@
+package testcli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClassifyProbe(t *testing.T) {
+	tests := []struct {
+		name, version, help string
+		impl                Impl
+		number              string
+	}{
+		{"GNU sed", "sed (GNU sed) 4.9\nCopyright", "", ImplGNU, "4.9"},
+		{"GNU coreutils", "cp (GNU coreutils) 9.1\n", "", ImplGNU, "9.1"},
+		{"BSD grep", "grep (BSD grep, GNU compatible) 2.6.0-FreeBSD\n", "", ImplBSD, "2.6.0"},
+		{"BSD sed", "sed: illegal option -- -\nusage: sed script [-Ealnru]", "", ImplBSD, ""},
+		{"BusyBox sed", "This is not GNU sed version 4.0\n", "BusyBox v1.36.1 (2023-07-27) multi-call binary.\n", ImplBusyBox, "1.36.1"},
+		{"unknown", "mysed 1.0\n", "", ImplUnknown, ""},
+	}
+	for _, test := range tests {
+		impl, number := classifyProbe(ImplUnknown, test.version, test.help)
+		if impl != test.impl || number != test.number {
+			t.Errorf("%s: expected %s %q, got %s %q", test.name, test.impl, test.number, impl, number)
+		}
+	}
+}
+
+func TestProbeToolBehavior(t *testing.T) {
+	for _, tool := range []string{"sed", "date"} {
+		p := ProbeTool(t, tool)
+		if p.Impl != ImplGNU && p.Impl != ImplBSD {
+			continue
+		}
+		if impl := probeDefs[tool](p.Path); impl != p.Impl {
+			t.Errorf("Expected the behavior of %s to be %s like its version says, got %s", tool, p.Impl, impl)
+		}
+	}
+}
+
+func TestProbeToolIsCached(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	script := "#!/bin/sh\necho run >> " + log + "\necho 'mytool (GNU mytool) 1.2.3'\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "mytool"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	p := ProbeTool(t, "mytool")
+	if p.Impl != ImplGNU || p.Version != "1.2.3" {
+		t.Fatalf("Expected GNU 1.2.3, got %s %q", p.Impl, p.Version)
+	}
+	ran, _ := ioutil.ReadFile(log)
+	ProbeTool(t, "mytool")
+	again, _ := ioutil.ReadFile(log)
+	if len(again) != len(ran) {
+		t.Fatalf("Expected the probe to run once, ran %d times", strings.Count(string(again), "run"))
+	}
+}
+
+func TestExpectByImpl(t *testing.T) {
+	p := &ToolProbe{t: t, Tool: "sed", Impl: ImplBSD}
+	expected := map[Impl]string{ImplGNU: "unknown option", ImplBSD: "illegal option"}
+	if got := p.ExpectByImpl(expected); got != "illegal option" {
+		t.Fatalf("Expected %q, got %q", "illegal option", got)
+	}
+	p.Impl = ImplBusyBox
+	expected[ImplUnknown] = "option"
+	if got := p.ExpectByImpl(expected); got != "option" {
+		t.Fatalf("Expected the fallback %q, got %q", "option", got)
+	}
+}
+
+func TestExpectByImplWithSed(t *testing.T) {
+	sed := ProbeTool(t, "sed")
+	c := Command(t, "sed", `s/a/x\ty/`)
+	c.SetStdin(strings.NewReader("a\n"))
+	c.Run()
+	expected := sed.ExpectByImpl(map[Impl]string{
+		ImplBSD:     "xty\n",
+		ImplUnknown: "x\ty\n",
+	})
+	if c.Stdout() != expected {
+		t.Fatalf("Expected %q to be %q", c.Stdout(), expected)
+	}
+}
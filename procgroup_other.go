@@ -0,0 +1,15 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package testcli
+
+import (
+	"os"
+	"os/exec"
+)
+
+func setNewProcessGroup(cmd *exec.Cmd) {}
+
+func killProcessGroup(p *os.Process) error {
+	return p.Kill()
+}
@@ -0,0 +1,66 @@
+package testcli
+
+import (
+	"fmt"
+	"time"
+)
+
+// WithWaitProgress makes the waits on the running command, e.g.
+// WaitForStdout() or the Contains assertions, log what they're still waiting
+// for every interval, so that a long wait doesn't look like a hang:
+//
+//	still waiting for stdout to contain "ready" (23s elapsed, 1.2KB captured, process running pid 4242)
+//
+// It's off by default, see SetDefaultOptions() to turn it on for every
+// command.
+func WithWaitProgress(every time.Duration) Option {
+	return func(c *Cmd) {
+		c.waitProgress = every
+	}
+}
+
+// progressTicker returns the channel ticking when a wait should log its
+// progress, nil if it shouldn't, and the function stopping it.
+func (c *Cmd) progressTicker() (<-chan time.Time, func()) {
+	if c.waitProgress <= 0 {
+		return nil, func() {}
+	}
+	ticker := time.NewTicker(c.waitProgress)
+	return ticker.C, ticker.Stop
+}
+
+// logWaitProgress logs that a wait for what, started at started, is still
+// going on.
+func (c *Cmd) logWaitProgress(what string, started time.Time) {
+	captured := 0
+	for _, o := range []*output{c.stdout, c.stderr, c.combined} {
+		if o != nil {
+			captured += o.captured()
+		}
+	}
+	c.logf("still waiting for %s (%s elapsed, %s captured, process running pid %d)",
+		what, time.Since(started).Round(time.Second), formatSize(captured), c.process.Pid())
+}
+
+// captured returns how many bytes were written to o.
+func (o *output) captured() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := 0
+	for _, chunk := range o.chunks {
+		n += chunk.n
+	}
+	return n
+}
+
+// formatSize formats n bytes for humans, e.g. 1.2KB.
+func formatSize(n int) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%dB", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1fKB", float64(n)/1024)
+	default:
+		return fmt.Sprintf("%.1fMB", float64(n)/(1024*1024))
+	}
+}
@@ -0,0 +1,44 @@
+package testcli
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWaitProgressHelperProcess waits with progress logging, and fails for
+// its logs to be shown.
+func TestWaitProgressHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	c := Command(t, "sh", "-c", "printf starting; sleep 0.35; echo ready; exec sleep 5")
+	c.Apply(WithWaitProgress(100 * time.Millisecond))
+	c.Start()
+	defer c.Kill()
+	c.WaitForStdout("ready", 5*time.Second)
+	time.Sleep(300 * time.Millisecond)
+	t.Fatal("resolved")
+}
+
+func TestWaitProgress(t *testing.T) {
+	c := ReexecCommand(t, "TestWaitProgressHelperProcess")
+	c.Run()
+	stdout := c.Stdout()
+	progress := regexp.MustCompile(`still waiting for stdout to contain "ready" \(0s elapsed, 8B captured, process running pid \d+\)`)
+	if n := len(progress.FindAllString(stdout, -1)); n < 2 {
+		t.Fatalf("Expected the progress of the wait to be logged, got %q", stdout)
+	}
+	if i := strings.Index(stdout, "resolved"); i < 0 || strings.Contains(stdout[i:], "still waiting") {
+		t.Fatalf("Expected the logs to stop once the wait resolved, got %q", stdout)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for n, want := range map[int]string{12: "12B", 1229: "1.2KB", 3 << 20: "3.0MB"} {
+		if got := formatSize(n); got != want {
+			t.Errorf("Expected %d bytes as %q, got %q", n, want, got)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package testcli
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ProxyRequest is a request that went through a Proxy.
+type ProxyRequest struct {
+	Method string
+	// Host is the host:port the request was for.
+	Host string
+	// URL is the requested URL. HTTPS requests are tunneled through CONNECT,
+	// only their Host is known.
+	URL string
+}
+
+// Proxy is an HTTP proxy recording the requests going through it, see
+// ProxyEnv().
+type Proxy struct {
+	t         *testing.T
+	server    *httptest.Server
+	noProxy   []string
+	transport *http.Transport
+
+	mu       sync.Mutex
+	requests []ProxyRequest
+}
+
+// ProxyEnv starts a proxy, forwarding plain HTTP requests and tunneling
+// HTTPS ones, which is stopped when the test ends. WithProxy() points
+// commands to it, except for the hosts in noProxy.
+func ProxyEnv(t *testing.T, noProxy ...string) *Proxy {
+	t.Helper()
+	p := &Proxy{
+		t:       t,
+		noProxy: noProxy,
+		// Not http.DefaultTransport, which would honor the proxy settings
+		// of the test itself.
+		transport: &http.Transport{},
+	}
+	p.server = httptest.NewServer(http.HandlerFunc(p.serve))
+	t.Cleanup(func() {
+		p.server.Close()
+		p.transport.CloseIdleConnections()
+	})
+	return p
+}
+
+// URL returns the URL of the proxy, e.g. http://127.0.0.1:38919.
+func (p *Proxy) URL() string {
+	return p.server.URL
+}
+
+// Env returns the variables pointing to the proxy, in both their upper and
+// lower case forms since programs disagree on which one to read.
+func (p *Proxy) Env() []string {
+	var env []string
+	for _, kv := range []string{
+		"HTTP_PROXY=" + p.URL(),
+		"HTTPS_PROXY=" + p.URL(),
+		"NO_PROXY=" + strings.Join(p.noProxy, ","),
+	} {
+		name := strings.SplitN(kv, "=", 2)[0]
+		env = append(env, kv, strings.ToLower(name)+strings.TrimPrefix(kv, name))
+	}
+	return env
+}
+
+// WithProxy makes the command use p, replacing the proxy settings it would
+// otherwise inherit. It must be applied after SetEnv(), if used.
+func WithProxy(p *Proxy) Option {
+	return func(c *Cmd) {
+		c.overrideEnv(p.Env()...)
+	}
+}
+
+// Requests returns the requests that went through the proxy so far, in
+// order.
+func (p *Proxy) Requests() []ProxyRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]ProxyRequest(nil), p.requests...)
+}
+
+// AssertProxied fails the test unless a request for host, with or without
+// its port, went through the proxy.
+func (p *Proxy) AssertProxied(host string) {
+	p.t.Helper()
+	if !p.proxied(host) {
+		p.t.Fatalf("Expected a request for %s to go through the proxy, got %v", host, p.Requests())
+	}
+}
+
+// AssertNotProxied fails the test if a request for host, with or without its
+// port, went through the proxy.
+func (p *Proxy) AssertNotProxied(host string) {
+	p.t.Helper()
+	if p.proxied(host) {
+		p.t.Fatalf("Expected no request for %s to go through the proxy, got %v", host, p.Requests())
+	}
+}
+
+func (p *Proxy) proxied(host string) bool {
+	for _, r := range p.Requests() {
+		hostname, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			hostname = r.Host
+		}
+		if r.Host == host || hostname == host {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Proxy) serve(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	p.requests = append(p.requests, ProxyRequest{Method: r.Method, Host: r.Host, URL: r.URL.String()})
+	p.mu.Unlock()
+
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "not a proxy request", http.StatusBadRequest)
+		return
+	}
+
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	out.Header.Del("Proxy-Connection")
+	out.Header.Del("Proxy-Authorization")
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// tunnel connects the client to the host it asked for, and copies bytes
+// both ways until either side is done.
+func (p *Proxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	upstream, err := net.DialTimeout("tcp", r.Host, 10*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	client, buf, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer client.Close()
+	if _, err := client.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+		return
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, buf)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// defaultCAEnvVars are the variables WithCustomCA() sets by default, read by
+// OpenSSL, Go, Node.js, Python requests and curl.
+var defaultCAEnvVars = []string{"SSL_CERT_FILE", "NODE_EXTRA_CA_CERTS", "REQUESTS_CA_BUNDLE", "CURL_CA_BUNDLE"}
+
+// WithCustomCA writes certPEM to a file, removed when the test ends, and
+// points the command's TLS clients to it through the variables vars, or
+// SSL_CERT_FILE, NODE_EXTRA_CA_CERTS, REQUESTS_CA_BUNDLE and CURL_CA_BUNDLE if
+// none are given. It must be applied after SetEnv(), if used.
+func WithCustomCA(certPEM []byte, vars ...string) Option {
+	if len(vars) == 0 {
+		vars = defaultCAEnvVars
+	}
+	return func(c *Cmd) {
+		c.t.Helper()
+		dir, err := ioutil.TempDir("", "testcli-ca")
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		c.t.Cleanup(func() {
+			os.RemoveAll(dir)
+		})
+		path := filepath.Join(dir, "ca.pem")
+		if err := ioutil.WriteFile(path, certPEM, 0644); err != nil {
+			c.t.Fatal(err)
+		}
+		var env []string
+		for _, name := range vars {
+			env = append(env, name+"="+path)
+		}
+		c.overrideEnv(env...)
+	}
+}
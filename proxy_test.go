@@ -0,0 +1,126 @@
+package testcli
+
+import (
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func requireCurl(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("curl"); err != nil {
+		t.Skip("curl is not installed")
+	}
+}
+
+func TestProxyEnv(t *testing.T) {
+	requireCurl(t)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	}))
+	defer backend.Close()
+
+	proxy := ProxyEnv(t)
+	c := Command(t, "curl", "-sS", backend.URL+"/greeting")
+	c.Apply(WithProxy(proxy))
+	c.Run()
+	if !c.Success() {
+		t.Fatalf("Expected to succeed, but failed with error: %s\n%s", c.Error(), c.Stderr())
+	}
+	if c.Stdout() != "hello" {
+		t.Fatalf("Expected %q to be %q", c.Stdout(), "hello")
+	}
+
+	u, _ := url.Parse(backend.URL)
+	proxy.AssertProxied("127.0.0.1")
+	requests := proxy.Requests()
+	if len(requests) != 1 || requests[0].Method != "GET" || requests[0].Host != u.Host || requests[0].URL != backend.URL+"/greeting" {
+		t.Fatalf("Expected a single GET of %s/greeting, got %v", backend.URL, requests)
+	}
+}
+
+func TestProxyEnvNoProxy(t *testing.T) {
+	requireCurl(t)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	proxy := ProxyEnv(t, "127.0.0.1")
+	c := Command(t, "curl", "-sS", backend.URL)
+	c.Apply(WithProxy(proxy))
+	c.Run()
+	if !c.Success() {
+		t.Fatalf("Expected to succeed, but failed with error: %s\n%s", c.Error(), c.Stderr())
+	}
+	proxy.AssertNotProxied("127.0.0.1")
+}
+
+func TestProxyEnvTunnelsWithCustomCA(t *testing.T) {
+	requireCurl(t)
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "secure")
+	}))
+	defer backend.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: backend.Certificate().Raw})
+
+	proxy := ProxyEnv(t)
+	c := Command(t, "curl", "-sS", backend.URL)
+	c.Apply(WithProxy(proxy), WithCustomCA(ca))
+	c.Run()
+	if !c.Success() {
+		t.Fatalf("Expected to succeed, but failed with error: %s\n%s", c.Error(), c.Stderr())
+	}
+	if c.Stdout() != "secure" {
+		t.Fatalf("Expected %q to be %q", c.Stdout(), "secure")
+	}
+	requests := proxy.Requests()
+	if len(requests) != 1 || requests[0].Method != "CONNECT" {
+		t.Fatalf("Expected a single CONNECT, got %v", requests)
+	}
+}
+
+func TestProxyEnvOverridesInheritedSettings(t *testing.T) {
+	proxy := ProxyEnv(t, "internal")
+	c := Command(t, "sh", "-c", `echo "$HTTP_PROXY $http_proxy $https_proxy $NO_PROXY $no_proxy $OTHER"`)
+	c.SetEnv([]string{"PATH=/usr/bin:/bin", "http_proxy=http://old:1", "NO_PROXY=old", "OTHER=kept"})
+	c.Apply(WithProxy(proxy))
+	c.Run()
+
+	u := proxy.URL()
+	expected := strings.Join([]string{u, u, u, "internal", "internal", "kept"}, " ") + "\n"
+	if c.Stdout() != expected {
+		t.Fatalf("Expected %q to be %q", c.Stdout(), expected)
+	}
+	for _, kv := range c.cmd.Env {
+		if kv == "http_proxy=http://old:1" || kv == "NO_PROXY=old" {
+			t.Fatalf("Expected %q to be replaced, got %q", kv, c.cmd.Env)
+		}
+	}
+}
+
+func TestWithCustomCAVars(t *testing.T) {
+	c := Command(t, "sh", "-c", `cat "$MY_CA"; echo " ${SSL_CERT_FILE:-unset}"`)
+	c.SetEnv([]string{"PATH=/usr/bin:/bin"})
+	c.Apply(WithCustomCA([]byte("pem"), "MY_CA"))
+	c.Run()
+	if c.Stdout() != "pem unset\n" {
+		t.Fatalf("Expected %q to be %q", c.Stdout(), "pem unset\n")
+	}
+}
+
+func TestProxyEnvStopsWhenTheTestEnds(t *testing.T) {
+	var addr string
+	t.Run("sub", func(t *testing.T) {
+		addr = strings.TrimPrefix(ProxyEnv(t).URL(), "http://")
+	})
+	if conn, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+		conn.Close()
+		t.Fatalf("Expected the proxy at %s to be stopped", addr)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+// +build race
+
+package testcli
+
+// raceEnabled tells whether the race detector is on, see TimeMultiplier().
+const raceEnabled = true
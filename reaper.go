@@ -0,0 +1,84 @@
+package testcli
+
+import (
+	"sync"
+	"time"
+)
+
+// live holds the commands started by the package that haven't exited yet.
+var (
+	liveMu sync.Mutex
+	live   = map[*Cmd]bool{}
+
+	watchdogGrace time.Duration
+)
+
+// track registers a started command, and arranges for the watchdog to look
+// at it when its test finishes.
+func (c *Cmd) track() {
+	liveMu.Lock()
+	live[c] = true
+	liveMu.Unlock()
+
+	c.t.Cleanup(func() {
+		liveMu.Lock()
+		grace := watchdogGrace
+		liveMu.Unlock()
+		if grace > 0 {
+			go c.reapAfter(grace)
+		}
+	})
+}
+
+func (c *Cmd) untrack() {
+	liveMu.Lock()
+	delete(live, c)
+	liveMu.Unlock()
+}
+
+// reapAfter kills the command if it's still running after grace.
+func (c *Cmd) reapAfter(grace time.Duration) {
+	select {
+	case <-c.done:
+	case <-time.After(grace):
+		c.kill()
+	}
+}
+
+// ReapAll kills every command started by the package that is still running,
+// and returns how many there were. Tests that panic or forget to stop their
+// commands would otherwise leave them behind, possibly interfering with
+// later tests. It's meant for TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		code := m.Run()
+//		testcli.ReapAll()
+//		os.Exit(code)
+//	}
+//
+// or as a deferred call in tests.
+func ReapAll() int {
+	liveMu.Lock()
+	cmds := make([]*Cmd, 0, len(live))
+	for c := range live {
+		cmds = append(cmds, c)
+	}
+	liveMu.Unlock()
+
+	for _, c := range cmds {
+		c.kill()
+	}
+	for _, c := range cmds {
+		<-c.done
+	}
+	return len(cmds)
+}
+
+// EnableWatchdog makes the package kill the commands still running grace
+// after the test that started them finished, whether it passed, failed or
+// panicked. A zero grace disables it, which is the default.
+func EnableWatchdog(grace time.Duration) {
+	liveMu.Lock()
+	watchdogGrace = grace
+	liveMu.Unlock()
+}
@@ -0,0 +1,84 @@
+//go:build linux
+// +build linux
+
+package testcli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// alive reports whether pid is a running process, zombies excluded.
+func alive(pid int) bool {
+	stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	// The state follows the command name, which is in parentheses.
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+// TestReaperHelperProcess starts a long running command, then panics.
+func TestReaperHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	defer ReapAll()
+	c := Command(t, "sleep", "30")
+	c.Start()
+	fmt.Printf("pid=%d\n", c.process.Pid())
+	panic("boom")
+}
+
+func TestReapAllOnPanic(t *testing.T) {
+	c := ReexecCommand(t, "TestReaperHelperProcess")
+	c.Run()
+	if !c.Failure() || !c.StderrContains("boom") {
+		t.Fatalf("Expected the helper to panic, got %q", c.Stderr())
+	}
+
+	m := regexpMustFind(t, `pid=(\d+)`, c.Stdout())
+	pid, _ := strconv.Atoi(m)
+	if !poll(func() bool { return !alive(pid) }, 10*time.Millisecond, 2*time.Second) {
+		t.Fatalf("Expected process %d to have been reaped", pid)
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	EnableWatchdog(100 * time.Millisecond)
+	defer EnableWatchdog(0)
+
+	var c *Cmd
+	t.Run("leaky", func(t *testing.T) {
+		c = Command(t, "sleep", "30")
+		c.Start()
+	})
+
+	pid := c.process.Pid()
+	if !alive(pid) {
+		t.Fatalf("Expected process %d to be alive during the grace period", pid)
+	}
+	select {
+	case <-c.done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected process %d to be killed after its test finished", pid)
+	}
+}
+
+func TestReapAll(t *testing.T) {
+	c := Command(t, "sleep", "30")
+	c.Start()
+	if n := ReapAll(); n < 1 {
+		t.Fatalf("Expected at least one command to be reaped, got %d", n)
+	}
+
+	c.Wait()
+	if !c.Failure() {
+		t.Fatalf("Expected the reaped command to fail")
+	}
+}
@@ -0,0 +1,95 @@
+package testcli
+
+import (
+	"os"
+	"regexp"
+	"testing"
+)
+
+// HelperEnvVar is set in the environment of processes started by
+// ReexecCommand. Its value is the name of the test function being run as a
+// helper.
+const HelperEnvVar = "TESTCLI_HELPER_PROCESS"
+
+// testingOutput matches the lines the testing package writes to stdout on its
+// own behalf, as opposed to what the helper process printed.
+var testingOutput = regexp.MustCompile(`(?m)^(PASS|FAIL|ok .*|--- (PASS|FAIL|SKIP): .*|=== (RUN|PAUSE|CONT|NAME) .*|testing: warning: no tests to run|coverage: .*)\n?`)
+
+type reexecConfig struct {
+	env  []string
+	args []string
+}
+
+// ReexecOption configures a command constructed by ReexecCommand.
+type ReexecOption func(*reexecConfig)
+
+// WithHelperEnv adds variables, in the form "key=value", to the environment
+// of the helper process.
+func WithHelperEnv(env ...string) ReexecOption {
+	return func(rc *reexecConfig) {
+		rc.env = append(rc.env, env...)
+	}
+}
+
+// WithHelperArgs passes args to the helper process. They can be retrieved
+// from the helper with HelperArgs().
+func WithHelperArgs(args ...string) ReexecOption {
+	return func(rc *reexecConfig) {
+		rc.args = append(rc.args, args...)
+	}
+}
+
+// ReexecCommand constructs a *Cmd that runs the current test binary again,
+// restricted to the test function named test. This is the usual way of
+// testing code that must run in its own process, like signal handlers or
+// os.Exit paths. The helper test should return immediately unless
+// IsHelperProcess() is true:
+//
+//	func TestHelperProcess(t *testing.T) {
+//		if !testcli.IsHelperProcess() {
+//			return
+//		}
+//		fmt.Println("hello from the helper")
+//		os.Exit(3)
+//	}
+//
+// None of the parent's -test.* flags are passed down, and the lines the
+// testing package prints about the helper test (PASS, --- FAIL, ...) are
+// filtered out of the captured stdout.
+func ReexecCommand(t *testing.T, test string, opts ...ReexecOption) *Cmd {
+	rc := &reexecConfig{}
+	for _, opt := range opts {
+		opt(rc)
+	}
+
+	args := []string{"-test.run=^" + regexp.QuoteMeta(test) + "$"}
+	if len(rc.args) > 0 {
+		args = append(args, "--")
+		args = append(args, rc.args...)
+	}
+
+	c := Command(t, os.Args[0], args...)
+	env := append(os.Environ(), HelperEnvVar+"="+test)
+	c.SetEnv(append(env, rc.env...))
+	c.stdout.filter = func(s string) string {
+		return testingOutput.ReplaceAllString(s, "")
+	}
+	return c
+}
+
+// IsHelperProcess reports whether the running test binary was started by
+// ReexecCommand.
+func IsHelperProcess() bool {
+	return os.Getenv(HelperEnvVar) != ""
+}
+
+// HelperArgs returns the arguments passed to the helper process with
+// WithHelperArgs().
+func HelperArgs() []string {
+	for i, arg := range os.Args {
+		if arg == "--" {
+			return os.Args[i+1:]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package testcli
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHelperProcess is not a real test: it is the body of the helper process
+// started by the tests below, and does nothing when run normally.
+func TestHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	fmt.Printf("greeting=%s args=%s\n", os.Getenv("GREETING"), strings.Join(HelperArgs(), ","))
+	fmt.Fprintln(os.Stderr, "bye")
+	os.Exit(3)
+}
+
+// TestHelperProcessReturns is a helper that returns normally, which makes the
+// testing package print its own summary to stdout.
+func TestHelperProcessReturns(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	fmt.Println("done")
+}
+
+func TestReexecCommand(t *testing.T) {
+	c := ReexecCommand(t, "TestHelperProcess", WithHelperEnv("GREETING=hi"), WithHelperArgs("a", "b"))
+	c.Run()
+	if !c.Failure() {
+		t.Fatalf("Expected to fail, but succeeded")
+	}
+
+	expected := "greeting=hi args=a,b\n"
+	if c.Stdout() != expected {
+		t.Fatalf("Expected %q to be %q", c.Stdout(), expected)
+	}
+
+	if !c.StderrContains("bye") {
+		t.Fatalf("Expected %q to contain %q", c.Stderr(), "bye")
+	}
+}
+
+func TestReexecCommandFiltersTestingOutput(t *testing.T) {
+	c := ReexecCommand(t, "TestHelperProcessReturns")
+	c.Run()
+	if !c.Success() {
+		t.Fatalf("Expected to succeed, but failed with error: %s", c.Error())
+	}
+
+	if c.Stdout() != "done\n" {
+		t.Fatalf("Expected %q to be %q", c.Stdout(), "done\n")
+	}
+}
+
+// TestPackageLevelHelperProcess uses package-level functions from subtests.
+func TestPackageLevelHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	Run(t, "echo", "parent")
+	seen := make(chan bool)
+	go func() { seen <- StdoutContains("parent") }()
+	if !<-seen {
+		t.Fatalf("Expected a goroutine to see the test's command")
+	}
+	t.Run("own", func(t *testing.T) {
+		Run(t, "echo", "child")
+		if !StdoutContains("child") {
+			t.Fatalf("Expected the subtest to see its own command, got %q", Stdout())
+		}
+	})
+	t.Run("fails", func(t *testing.T) {
+		Run(t, "echo", "child")
+		StdoutLine(5)
+	})
+	if !StdoutContains("parent") {
+		t.Fatalf("Expected the test to see its own command after its subtests, got %q", Stdout())
+	}
+}
+
+func TestPackageLevelFunctionsInSubtests(t *testing.T) {
+	c := ReexecCommand(t, "TestPackageLevelHelperProcess")
+	c.Run()
+	if !c.Failure() {
+		t.Fatalf("Expected to fail, but succeeded")
+	}
+
+	// The testing package's own output is what this test is about.
+	c.stdout.filter = nil
+	expected := "--- FAIL: TestPackageLevelHelperProcess/fails"
+	if !c.StdoutContains(expected) {
+		t.Fatalf("Expected %q to contain %q", c.Stdout(), expected)
+	}
+	for _, unexpected := range []string{"/own", "FailNow on a parent test", "Expected the test to see"} {
+		if strings.Contains(c.Stdout(), unexpected) {
+			t.Fatalf("Expected only the failing subtest to fail, got %q", c.Stdout())
+		}
+	}
+}
+
+// TestPackageLevelNoRunHelperProcess uses package-level functions without
+// Run().
+func TestPackageLevelNoRunHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	Run(t, "echo", "parent")
+	t.Run("sub", func(t *testing.T) {
+		Command(t, "echo", "unused")
+		Stdout()
+	})
+}
+
+func TestPackageLevelFunctionsWithoutRun(t *testing.T) {
+	c := ReexecCommand(t, "TestPackageLevelNoRunHelperProcess")
+	c.Run()
+	if !c.Failure() {
+		t.Fatalf("Expected to fail, but succeeded")
+	}
+
+	c.stdout.filter = nil
+	if !c.StdoutContains("--- FAIL: TestPackageLevelNoRunHelperProcess/sub") {
+		t.Fatalf("Expected the subtest to fail, got %q", c.Stdout())
+	}
+
+	if !c.StdoutContains(ErrNoCommandRun.Error(), CaseSensitive()) {
+		t.Fatalf("Expected %q to explain the failure", c.Stdout())
+	}
+
+	if strings.Contains(c.Stdout()+c.Stderr(), "panic") {
+		t.Fatalf("Expected no panic, got %q", c.Stdout()+c.Stderr())
+	}
+}
+
+func TestPackageLevelFunctionsInParallelTests(t *testing.T) {
+	t.Run("a", func(t *testing.T) {
+		t.Parallel()
+		Run(t, "sh", "-c", "sleep 0.1; echo a")
+		time.Sleep(100 * time.Millisecond)
+		if Stdout() != "a\n" {
+			t.Fatalf("Expected the subtest to see its own command, got %q", Stdout())
+		}
+	})
+	t.Run("b", func(t *testing.T) {
+		t.Parallel()
+		Run(t, "sh", "-c", "sleep 0.1; echo b")
+		time.Sleep(100 * time.Millisecond)
+		if Stdout() != "b\n" {
+			t.Fatalf("Expected the subtest to see its own command, got %q", Stdout())
+		}
+	})
+}
+
+// regexpMustFind returns the first submatch of regex in s.
+func regexpMustFind(t *testing.T, regex, s string) string {
+	t.Helper()
+	m := regexp.MustCompile(regex).FindStringSubmatch(s)
+	if m == nil {
+		t.Fatalf("Expected %q to match %q", s, regex)
+	}
+	return m[1]
+}
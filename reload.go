@@ -0,0 +1,73 @@
+package testcli
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// CheckReload checks the daemon contract of reloading the configuration on a
+// signal, e.g. SIGHUP, without restarting: it calls mutateConfig, e.g. to
+// edit the configuration file, sends sig to the running command, then waits
+// up to within for stdout, past what was printed before the signal, to match
+// expectAfter. It marks the test as failed, showing the output, unless it
+// does and the command is still running as the same process, and reports
+// whether it passed.
+func CheckReload(t *testing.T, c *Cmd, mutateConfig func(), sig os.Signal, expectAfter *regexp.Regexp, within time.Duration) bool {
+	t.Helper()
+	c.validateHasStarted()
+	if err := c.checkReload(mutateConfig, sig, expectAfter, within); err != nil {
+		t.Error(c.failure("%s", err))
+		return false
+	}
+	return true
+}
+
+func (c *Cmd) checkReload(mutateConfig func(), sig os.Signal, expectAfter *regexp.Regexp, within time.Duration) error {
+	c.t.Helper()
+	if exited, _, _ := c.Exited(); exited {
+		reason, _ := c.endReason()
+		return fmt.Errorf("Expected the command to be running to reload it, %s", reason)
+	}
+	pid := c.PID()
+	mutateConfig()
+	mark := c.stdoutMark()
+	if err := c.Signal(sig); err != nil {
+		return fmt.Errorf("Failed to ask pid %d to reload: %s", pid, err)
+	}
+	what := fmt.Sprintf("stdout to match %q after %s", expectAfter, sig)
+	err := c.waitFor(what, func() bool {
+		return expectAfter.MatchString(c.stdoutSince(mark))
+	}, defaultPollInterval, within)
+	if err != nil {
+		return fmt.Errorf("Expected pid %d to reload: %s", pid, err)
+	}
+	// The process is the one which was signaled as long as it didn't exit:
+	// a restart would show up as an exit, even under the same command.
+	if exited, _, _ := c.Exited(); exited {
+		reason, _ := c.endReason()
+		return fmt.Errorf("Expected pid %d to keep running after reloading, %s", pid, reason)
+	}
+	return nil
+}
+
+// stdoutMark returns the position of the end of stdout captured so far, see
+// stdoutSince().
+func (c *Cmd) stdoutMark() int {
+	c.stdout.mu.Lock()
+	defer c.stdout.mu.Unlock()
+	return len(c.stdout.text())
+}
+
+// stdoutSince returns stdout captured past mark.
+func (c *Cmd) stdoutSince(mark int) string {
+	c.stdout.mu.Lock()
+	defer c.stdout.mu.Unlock()
+	text := c.stdout.text()
+	if mark > len(text) {
+		return ""
+	}
+	return text[mark:]
+}
@@ -0,0 +1,67 @@
+package testcli
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// reloadScript prints its configuration when started and on SIGHUP.
+const reloadScript = `trap 'echo "loaded $(cat "$CONF")"' HUP; echo "loaded $(cat "$CONF")"; while :; do sleep 0.05; done`
+
+// startReloading starts reloadScript with v1 for configuration, and returns
+// a function changing it.
+func startReloading(t *testing.T, script string) (*Cmd, func(string)) {
+	conf := filepath.Join(t.TempDir(), "conf")
+	write := func(s string) {
+		if err := ioutil.WriteFile(conf, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("v1")
+	c := Command(t, "sh", "-c", script)
+	c.SetEnvVar("CONF", conf)
+	c.Start()
+	c.WaitForStdout("loaded v1", 5*time.Second)
+	return c, write
+}
+
+func TestCheckReload(t *testing.T) {
+	c, write := startReloading(t, reloadScript)
+	defer c.Kill()
+	if !CheckReload(t, c, func() { write("v2") }, syscall.SIGHUP, regexp.MustCompile(`loaded v2`), 5*time.Second) {
+		return
+	}
+	CheckReload(t, c, func() { write("v3") }, syscall.SIGHUP, regexp.MustCompile(`loaded v3`), 5*time.Second)
+}
+
+func TestCheckReloadLooksPastTheSignal(t *testing.T) {
+	c, write := startReloading(t, reloadScript)
+	defer c.Kill()
+	// Printed before the signal only.
+	err := c.checkReload(func() { write("v2") }, syscall.SIGHUP, regexp.MustCompile(`loaded v1`), 300*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timed out after 300ms") {
+		t.Fatalf("Expected the output from before the signal to be ignored, got %v", err)
+	}
+}
+
+// TestCheckReloadHelperProcess reloads a command which exits on SIGHUP.
+func TestCheckReloadHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	c, write := startReloading(t, `echo "loaded $(cat "$CONF")"; while :; do sleep 0.05; done`)
+	CheckReload(t, c, func() { write("v2") }, syscall.SIGHUP, regexp.MustCompile(`loaded v2`), 5*time.Second)
+}
+
+func TestCheckReloadExits(t *testing.T) {
+	c := ReexecCommand(t, "TestCheckReloadHelperProcess")
+	c.Run()
+	if c.Success() || !strings.Contains(c.Stdout(), "to reload: process was killed: signal: hangup while waiting for stdout to match") {
+		t.Fatalf("Expected the exit to fail the reload, got %q", c.Stdout())
+	}
+}
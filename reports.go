@@ -0,0 +1,118 @@
+package testcli
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// failurePattern is a message meaning that a run went wrong, whatever its
+// exit code, see AddFailurePattern().
+type failurePattern struct {
+	re    *regexp.Regexp
+	label string
+}
+
+var (
+	failurePatternsMu sync.Mutex
+	failurePatterns   = []failurePattern{
+		{regexp.MustCompile(`(?m)^WARNING: DATA RACE$`), "data race"},
+		{regexp.MustCompile(`(?m)^==\d+==ERROR: AddressSanitizer`), "AddressSanitizer"},
+		{regexp.MustCompile(`(?m)^==\d+==WARNING: MemorySanitizer`), "MemorySanitizer"},
+		{regexp.MustCompile(`(?m)^WARNING: ThreadSanitizer`), "ThreadSanitizer"},
+		{regexp.MustCompile(`(?m)^\S+:\d+:\d+: runtime error: `), "UndefinedBehaviorSanitizer"},
+		{regexp.MustCompile(`(?m)^(panic|fatal error): .*\n+goroutine \d+ \[`), "Go panic"},
+	}
+)
+
+// maxReportLines bounds the length of the failure reports quoted in errors.
+const maxReportLines = 50
+
+// AddFailurePattern registers regex, with the (?m) flag set so that ^ and $
+// match at line boundaries, as a message meaning that a run went wrong,
+// named label in errors. It's used by DetectFailureReports() and
+// FuzzCommand(), along with the messages of the race detector, sanitizers and
+// Go panics.
+func AddFailurePattern(regex, label string) {
+	re := regexp.MustCompile("(?m)" + regex)
+	failurePatternsMu.Lock()
+	failurePatterns = append(failurePatterns, failurePattern{re: re, label: label})
+	failurePatternsMu.Unlock()
+}
+
+// DetectFailureReports makes the test fail if the command printed a race
+// detector, sanitizer or Go panic report, or a message registered with
+// AddFailurePattern(), to stderr, even if it exited with 0. It's checked once
+// the command has finished, by Wait() or Run(). It's enabled by default for
+// binaries built by Build() with -race, -asan or -msan.
+func DetectFailureReports() Option {
+	return func(c *Cmd) {
+		c.detectFailureReports = true
+	}
+}
+
+// checkFailureReports enforces DetectFailureReports() on a finished command.
+func (c *Cmd) checkFailureReports() {
+	c.t.Helper()
+	if !c.detectFailureReports {
+		return
+	}
+	o := c.stderr
+	if c.combined != nil {
+		o = c.combined
+	}
+	o.mu.Lock()
+	stderr := o.text()
+	o.mu.Unlock()
+	if label, report, ok := findFailureReport(stderr); ok {
+		c.t.Errorf("%s reported by %s:\n%s", label, c.Repro(), report)
+	}
+}
+
+// findFailureReport returns the first failure report in s, along with the
+// label of its pattern.
+func findFailureReport(s string) (string, string, bool) {
+	failurePatternsMu.Lock()
+	patterns := failurePatterns
+	failurePatternsMu.Unlock()
+
+	first, label := -1, ""
+	for _, p := range patterns {
+		if loc := p.re.FindStringIndex(s); loc != nil && (first < 0 || loc[0] < first) {
+			first, label = loc[0], p.label
+		}
+	}
+	if first < 0 {
+		return "", "", false
+	}
+	return label, extractReport(s[first:]), true
+}
+
+// extractReport returns the report s starts with: up to a separator line made
+// of "=", like the ones around race reports, or maxReportLines lines.
+func extractReport(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, line := range lines {
+		if i > 0 && strings.Trim(line, "=") == "" && line != "" {
+			lines = lines[:i]
+			break
+		}
+	}
+	if len(lines) > maxReportLines {
+		lines = append(lines[:maxReportLines], "...")
+	}
+	return strings.Join(lines, "\n")
+}
+
+var (
+	sanitizedBinariesMu sync.Mutex
+	sanitizedBinaries   = map[string]bool{}
+)
+
+// sanitizedBinary reports whether path was built by Build() with the race
+// detector or a sanitizer.
+func sanitizedBinary(path string) bool {
+	sanitizedBinariesMu.Lock()
+	defer sanitizedBinariesMu.Unlock()
+	return sanitizedBinaries[path]
+}
@@ -0,0 +1,77 @@
+package testcli
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestFindFailureReport(t *testing.T) {
+	stderr := "starting\n==================\nWARNING: DATA RACE\nWrite at 0x00c by goroutine 7:\n  main.main.func1()\n==================\nFound 1 data race(s)\n"
+	label, report, ok := findFailureReport(stderr)
+	if !ok || label != "data race" {
+		t.Fatalf("Expected a data race, got %q %t", label, ok)
+	}
+	expected := "WARNING: DATA RACE\nWrite at 0x00c by goroutine 7:\n  main.main.func1()"
+	if report != expected {
+		t.Fatalf("Expected %q to be %q", report, expected)
+	}
+
+	if _, _, ok := findFailureReport("panic: in the title of a log line\n"); ok {
+		t.Fatalf("Expected a line starting with panic: not to be a Go panic without a goroutine trace")
+	}
+}
+
+func TestAddFailurePattern(t *testing.T) {
+	saved := failurePatterns
+	defer func() { failurePatterns = saved }()
+
+	AddFailurePattern(`^FATAL: .*corrupt`, "corruption")
+	label, report, ok := findFailureReport("ok\nFATAL: index corrupt\n")
+	if !ok || label != "corruption" || report != "FATAL: index corrupt" {
+		t.Fatalf("Expected the corruption to be found, got %q %q %t", label, report, ok)
+	}
+}
+
+// TestDetectFailureReportsHelperProcess runs a command printing a failure
+// report and exiting with 0.
+func TestDetectFailureReportsHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	AddFailurePattern(`^FATAL: `, "fatal message")
+	c := Command(t, "sh", "-c", "echo 'FATAL: lost' >&2")
+	c.Apply(DetectFailureReports())
+	c.Run()
+}
+
+func TestDetectFailureReports(t *testing.T) {
+	c := ReexecCommand(t, "TestDetectFailureReportsHelperProcess")
+	c.Run()
+	if !c.Failure() {
+		t.Fatalf("Expected the report to fail the helper test despite exiting with 0")
+	}
+	if !c.StdoutContains("fatal message reported by sh -c") {
+		t.Fatalf("Expected %q to quote the report", c.Stdout())
+	}
+}
+
+func TestBuildWithRaceDetectsRaces(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Building with -race is slow")
+	}
+	if out, err := exec.Command("go", "build", "-race", "-o", t.TempDir()+"/probe", "./testdata/racy").CombinedOutput(); err != nil {
+		t.Skipf("The race detector isn't available: %s", out)
+	}
+	racy := Build(t, "./testdata/racy", "-race")
+
+	c := Command(t, racy)
+	if !c.detectFailureReports {
+		t.Fatalf("Expected failure reports to be detected for %s, built with -race", racy)
+	}
+	c.Start()
+	<-c.done
+	c.capture.Wait()
+	if label, _, ok := findFailureReport(c.stderr.content); !ok || label != "data race" {
+		t.Fatalf("Expected %q to report a data race", c.stderr.content)
+	}
+}
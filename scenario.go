@@ -0,0 +1,99 @@
+package testcli
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ScenarioStep is a command run by a Scenario.
+type ScenarioStep struct {
+	// Name names the step in reports, the command line by default.
+	Name string
+	Spec
+	// Check, if not nil, makes assertions on the finished command.
+	Check func(c *Cmd)
+	// Services, if not nil, is called instead of running a command, to
+	// start, restart or stop the long-running services of the scenario.
+	Services func(s *ScenarioServices)
+}
+
+// name returns the name of the step.
+func (step ScenarioStep) name() string {
+	if step.Name != "" {
+		return step.Name
+	}
+	if step.Services != nil && step.Spec.Name == "" {
+		return "services"
+	}
+	return strings.Join(append([]string{filepath.Base(step.Spec.Name)}, step.Args...), " ")
+}
+
+// Scenario runs commands one after the other in a shared workspace, stopping
+// at the first failing step. Steps run from the root of the workspace unless
+// their Dir is set. Services started by a step keep running across the
+// following ones, until stopped or the end of the scenario.
+type Scenario struct {
+	Steps []ScenarioStep
+	// Workspace is where the steps run, a new one by default.
+	Workspace *Workspace
+	// Subtests runs each step in its own subtest, named after it, so that
+	// reports and CI dashboards show which step failed. The steps after it
+	// are skipped rather than run.
+	Subtests bool
+}
+
+// Run runs the steps of the scenario.
+func (s Scenario) Run(t *testing.T) {
+	t.Helper()
+	ws := s.Workspace
+	if ws == nil {
+		ws = NewWorkspace(t)
+	}
+	services := newScenarioServices(t, ws)
+
+	if !s.Subtests {
+		for _, step := range s.Steps {
+			step.run(t, ws, services)
+			// Failures which let the test go on, e.g. from Check, stop the
+			// scenario too.
+			if t.Failed() {
+				return
+			}
+		}
+		return
+	}
+
+	failed := ""
+	for _, step := range s.Steps {
+		step := step
+		if failed != "" {
+			t.Run(step.name(), func(t *testing.T) {
+				t.Skipf("Skipped, step %q failed", failed)
+			})
+			continue
+		}
+		if !t.Run(step.name(), func(t *testing.T) { step.run(t, ws, services) }) {
+			failed = step.name()
+		}
+	}
+}
+
+// run runs the step in ws.
+func (step ScenarioStep) run(t *testing.T, ws *Workspace, services *ScenarioServices) {
+	t.Helper()
+	if step.Services != nil {
+		services.t = t
+		step.Services(services)
+		return
+	}
+	c := step.Spec.Command(t)
+	if c.cmd.Dir == "" {
+		c.cmd.Dir = ws.Root()
+		c.workspace = ws
+	}
+	c.Run()
+	if step.Check != nil {
+		step.Check(c)
+	}
+}
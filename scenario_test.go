@@ -0,0 +1,90 @@
+package testcli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestScenario(t *testing.T) {
+	Scenario{Steps: []ScenarioStep{
+		{Spec: Spec{Name: "sh", Args: []string{"-c", "echo state > file"}}},
+		{Spec: Spec{Name: "cat", Args: []string{"file"}}, Check: func(c *Cmd) {
+			if c.Stdout() != "state\n" {
+				c.fatalf("Expected the steps to share their workspace")
+			}
+		}},
+	}}.Run(t)
+}
+
+// TestScenarioHelperProcess runs a scenario whose second step fails.
+func TestScenarioHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	Scenario{Subtests: true, Steps: []ScenarioStep{
+		{Spec: Spec{Name: "true"}},
+		{Name: "fails", Spec: Spec{Name: "false"}, Check: func(c *Cmd) {
+			if !c.Success() {
+				c.fatalf("Expected to succeed")
+			}
+		}},
+		{Spec: Spec{Name: "echo", Args: []string{"unreachable"}}, Check: func(c *Cmd) {
+			fmt.Println("third step ran")
+		}},
+	}}.Run(t)
+}
+
+func TestScenarioSubtests(t *testing.T) {
+	c := Command(t, os.Args[0], "-test.run=^TestScenarioHelperProcess$", "-test.v")
+	c.SetEnv(append(os.Environ(), HelperEnvVar+"=TestScenarioHelperProcess"))
+	c.Run()
+	if !c.Failure() {
+		t.Fatalf("Expected to fail, but succeeded")
+	}
+
+	for _, line := range []string{
+		"--- PASS: TestScenarioHelperProcess/true",
+		"--- FAIL: TestScenarioHelperProcess/fails",
+		"--- SKIP: TestScenarioHelperProcess/echo_unreachable",
+		`Skipped, step "fails" failed`,
+	} {
+		if !c.StdoutContains(line) {
+			t.Fatalf("Expected %q to contain %q", c.Stdout(), line)
+		}
+	}
+	if strings.Contains(c.Stdout(), "third step ran") {
+		t.Fatalf("Expected the steps after the failure to be skipped")
+	}
+}
+
+// TestScenarioErrorHelperProcess runs a scenario whose first step fails
+// without stopping the test.
+func TestScenarioErrorHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	Scenario{Steps: []ScenarioStep{
+		{Spec: Spec{Name: "false"}, Check: func(c *Cmd) {
+			if !c.Success() {
+				c.errorf("Expected to succeed")
+			}
+		}},
+		{Spec: Spec{Name: "echo", Args: []string{"unreachable"}}, Check: func(c *Cmd) {
+			fmt.Println("second step ran")
+		}},
+	}}.Run(t)
+}
+
+func TestScenarioStopsAtNonFatalFailure(t *testing.T) {
+	c := Command(t, os.Args[0], "-test.run=^TestScenarioErrorHelperProcess$", "-test.v")
+	c.SetEnv(append(os.Environ(), HelperEnvVar+"=TestScenarioErrorHelperProcess"))
+	c.Run()
+	if !c.Failure() {
+		t.Fatalf("Expected to fail, but succeeded")
+	}
+	if strings.Contains(c.Stdout(), "second step ran") {
+		t.Fatalf("Expected the steps after the failure not to run")
+	}
+}
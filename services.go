@@ -0,0 +1,172 @@
+package testcli
+
+import (
+	"testing"
+	"time"
+)
+
+// serviceReadyTimeout is how long a service has to get ready once started.
+const serviceReadyTimeout = 10 * time.Second
+
+// Readiness tells when a started service is ready, see
+// ScenarioServices.StartService(). It returns an error if the service isn't
+// ready within timeout.
+type Readiness func(c *Cmd, timeout time.Duration) error
+
+// ReadyWhenStdout is ready once stdout contains str, case insensitively.
+func ReadyWhenStdout(str string) Readiness {
+	return func(c *Cmd, timeout time.Duration) error {
+		return c.waitForStdout(str, timeout)
+	}
+}
+
+// ReadyWhenPort is ready once addr, a host:port, accepts TCP connections.
+func ReadyWhenPort(addr string) Readiness {
+	return func(c *Cmd, timeout time.Duration) error {
+		return c.waitForPort(addr, timeout)
+	}
+}
+
+// RestartOption configures ScenarioServices.RestartService().
+type RestartOption func(*restartConfig)
+
+type restartConfig struct {
+	maxDowntime time.Duration
+}
+
+// MaxDowntime fails the test if the service was down for longer than d: from
+// the exit of the old command to the new one being ready.
+func MaxDowntime(d time.Duration) RestartOption {
+	return func(cfg *restartConfig) {
+		cfg.maxDowntime = d
+	}
+}
+
+// ScenarioServices keeps track of the long-running commands of a Scenario,
+// by name. They run in its workspace, from its root unless their Dir is set,
+// and are killed at the end of the scenario if still running.
+type ScenarioServices struct {
+	// root is the test of the scenario, which the services belong to, and t
+	// the test of the current step, which their failures are reported to.
+	root     *testing.T
+	t        *testing.T
+	ws       *Workspace
+	services map[string]*service
+}
+
+type service struct {
+	cmd      *Cmd
+	ready    Readiness
+	downtime time.Duration
+}
+
+func newScenarioServices(t *testing.T, ws *Workspace) *ScenarioServices {
+	s := &ScenarioServices{root: t, t: t, ws: ws, services: map[string]*service{}}
+	t.Cleanup(func() {
+		for _, svc := range s.services {
+			svc.cmd.t = t
+			svc.cmd.terminate(Immediate(), TerminationReason{})
+			svc.cmd.Wait()
+		}
+	})
+	return s
+}
+
+// StartService starts spec as the service name, and waits for it to be ready
+// as told by ready, ready once started if nil. The test fails if it doesn't
+// get ready within 10 seconds, scaled by TimeMultiplier().
+func (s *ScenarioServices) StartService(name string, spec Spec, ready Readiness) *Cmd {
+	s.t.Helper()
+	if _, ok := s.services[name]; ok {
+		s.t.Fatalf("Service %q is already running", name)
+	}
+	c := s.start(name, spec, ready)
+	s.services[name] = &service{cmd: c, ready: ready}
+	return c
+}
+
+func (s *ScenarioServices) start(name string, spec Spec, ready Readiness) *Cmd {
+	s.t.Helper()
+	// Started on behalf of the scenario, so that it outlives the step, but
+	// failing the step.
+	c := spec.Command(s.root)
+	if c.cmd.Dir == "" {
+		c.cmd.Dir = s.ws.Root()
+	}
+	c.Start()
+	c.t = s.t
+	if ready == nil {
+		return c
+	}
+	if err := ready(c, Scaled(serviceReadyTimeout)); err != nil {
+		c.terminate(Immediate(), TerminationReason{})
+		c.Wait()
+		s.t.Fatal(c.failure("Service %q didn't get ready: %s", name, err))
+	}
+	return c
+}
+
+// StopService stops the service name gracefully, with SIGTERM, then kills it
+// if it's still running 2 seconds later, scaled by TimeMultiplier(). It
+// returns the finished command.
+func (s *ScenarioServices) StopService(name string) *Cmd {
+	s.t.Helper()
+	svc := s.service(name)
+	s.stop(svc.cmd)
+	delete(s.services, name)
+	return svc.cmd
+}
+
+func (s *ScenarioServices) stop(c *Cmd) {
+	s.t.Helper()
+	c.t = s.t
+	c.terminate(defaultTermination(), TerminationReason{})
+	c.Wait()
+}
+
+// RestartService stops the service name as StopService() does, then starts
+// newSpec in its place, waiting for it to be ready as the old one was. See
+// Downtime() and MaxDowntime().
+func (s *ScenarioServices) RestartService(name string, newSpec Spec, opts ...RestartOption) *Cmd {
+	s.t.Helper()
+	cfg := &restartConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	svc := s.service(name)
+	old := svc.cmd
+	s.stop(old)
+	c := s.start(name, newSpec, svc.ready)
+	svc.cmd = c
+	svc.downtime = time.Since(old.exitedAt)
+	if cfg.maxDowntime > 0 && svc.downtime > cfg.maxDowntime {
+		s.t.Fatal(c.failure("Expected service %q to be down for at most %s while restarting, was down for %s",
+			name, cfg.maxDowntime, svc.downtime.Round(time.Millisecond)))
+	}
+	return c
+}
+
+// Service returns the running command of the service name.
+func (s *ScenarioServices) Service(name string) *Cmd {
+	s.t.Helper()
+	c := s.service(name).cmd
+	c.t = s.t
+	return c
+}
+
+// Downtime returns how long the service name was down during its last
+// restart, from the exit of the old command to the new one being ready, 0 if
+// it was never restarted.
+func (s *ScenarioServices) Downtime(name string) time.Duration {
+	s.t.Helper()
+	return s.service(name).downtime
+}
+
+func (s *ScenarioServices) service(name string) *service {
+	s.t.Helper()
+	svc, ok := s.services[name]
+	if !ok {
+		s.t.Fatalf("No service %q is running", name)
+	}
+	return svc
+}
@@ -0,0 +1,91 @@
+package testcli
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// daemon returns the spec of a service running script, then printing
+// "ready" and running until SIGTERM.
+func daemon(script string) Spec {
+	return Spec{Name: "/bin/sh", Args: []string{"-c", script + `; trap 'exit 0' TERM; echo ready; while :; do sleep 0.05; done`}}
+}
+
+func TestScenarioServicesRestart(t *testing.T) {
+	var downtime time.Duration
+	Scenario{Subtests: true, Steps: []ScenarioStep{
+		{Name: "start v1", Services: func(s *ScenarioServices) {
+			s.StartService("daemon", daemon("echo v1-state > state"), ReadyWhenStdout("ready"))
+		}},
+		{Spec: Spec{Name: "cat", Args: []string{"state"}}, Check: func(c *Cmd) {
+			c.AssertStdoutContains("v1-state")
+		}},
+		{Name: "upgrade to v2", Services: func(s *ScenarioServices) {
+			old := s.Service("daemon")
+			c := s.RestartService("daemon", daemon("cat state > migrated"), MaxDowntime(Scaled(5*time.Second)))
+			if !old.Success() {
+				t.Errorf("Expected v1 to stop gracefully, got %v", old.Error())
+			}
+			if c == old || !c.StdoutContains("ready") {
+				t.Errorf("Expected v2 to be running and ready")
+			}
+			downtime = s.Downtime("daemon")
+		}},
+		{Spec: Spec{Name: "cat", Args: []string{"migrated"}}, Check: func(c *Cmd) {
+			c.AssertStdoutContains("v1-state")
+		}},
+		{Name: "stop", Services: func(s *ScenarioServices) {
+			if c := s.StopService("daemon"); !c.Success() {
+				t.Errorf("Expected v2 to stop gracefully, got %v", c.Error())
+			}
+		}},
+	}}.Run(t)
+
+	if downtime <= 0 {
+		t.Fatalf("Expected the downtime to be measured, got %s", downtime)
+	}
+}
+
+// TestScenarioServicesMaxDowntimeHelperProcess restarts a service whose new
+// version takes too long to get ready.
+func TestScenarioServicesMaxDowntimeHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	Scenario{Steps: []ScenarioStep{
+		{Services: func(s *ScenarioServices) {
+			s.StartService("daemon", daemon("true"), ReadyWhenStdout("ready"))
+			s.RestartService("daemon", daemon("sleep 0.5"), MaxDowntime(100*time.Millisecond))
+		}},
+	}}.Run(t)
+}
+
+func TestScenarioServicesMaxDowntime(t *testing.T) {
+	c := ReexecCommand(t, "TestScenarioServicesMaxDowntimeHelperProcess")
+	c.Run()
+	if c.Success() || !strings.Contains(c.Stdout(), `Expected service "daemon" to be down for at most 100ms while restarting`) {
+		t.Fatalf("Expected the restart to exceed the downtime bound, got %q", c.Stdout())
+	}
+}
+
+// TestScenarioServicesNotReadyHelperProcess starts a service which exits
+// before getting ready.
+func TestScenarioServicesNotReadyHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	Scenario{Subtests: true, Steps: []ScenarioStep{
+		{Services: func(s *ScenarioServices) {
+			s.StartService("daemon", Spec{Name: "false"}, ReadyWhenStdout("ready"))
+		}},
+	}}.Run(t)
+}
+
+func TestScenarioServicesNotReady(t *testing.T) {
+	c := ReexecCommand(t, "TestScenarioServicesNotReadyHelperProcess")
+	c.Run()
+	if c.Success() || !strings.Contains(c.Stdout(), `Service "daemon" didn't get ready: process exited (code 1)`) {
+		t.Fatalf("Expected the service not to get ready, got %q", c.Stdout())
+	}
+}
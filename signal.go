@@ -0,0 +1,45 @@
+package testcli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// ErrSignalNotSupported is returned by Signal() when the platform can't
+// deliver the signal. On Windows, only os.Kill is always delivered, and
+// SIGTERM and os.Interrupt are delivered as a CTRL_BREAK event to commands
+// leading their own process group, e.g. when WithTimeout() is used.
+var ErrSignalNotSupported = errors.New("signal not supported on this platform")
+
+// Signal sends sig to the started command, which must still be running. It
+// can still be waited for and asserted on afterwards. See
+// ErrSignalNotSupported.
+func (c *Cmd) Signal(sig os.Signal) error {
+	c.t.Helper()
+	c.validateHasStarted()
+	if exited, _, _ := c.Exited(); exited {
+		return fmt.Errorf("can't send %s: %w", sig, os.ErrProcessDone)
+	}
+	if sig == os.Kill {
+		c.setTerminationReason(TerminationReason{Killed: true})
+		return c.kill()
+	}
+	if err := c.signal(sig); err != nil {
+		return signalError(sig, err)
+	}
+	return nil
+}
+
+// Interrupt sends os.Interrupt to the started command, see Signal().
+func (c *Cmd) Interrupt() error {
+	c.t.Helper()
+	return c.Signal(os.Interrupt)
+}
+
+// Terminate sends SIGTERM to the started command, see Signal().
+func (c *Cmd) Terminate() error {
+	c.t.Helper()
+	return c.Signal(syscall.SIGTERM)
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+// +build !windows
+
+package testcli
+
+import "os"
+
+func signalProcess(p *os.Process, sig os.Signal) error {
+	return p.Signal(sig)
+}
+
+func signalError(sig os.Signal, err error) error {
+	return err
+}
@@ -0,0 +1,61 @@
+package testcli
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+)
+
+// shutdownDaemon prints "ready", then "shutting down" and exits 0 on sig.
+func shutdownDaemon(t *testing.T, sig string) *Cmd {
+	return Command(t, "/bin/sh", "-c", `trap 'echo shutting down; exit 0' `+sig+`; echo ready; while :; do sleep 0.01; done`)
+}
+
+func TestTerminate(t *testing.T) {
+	c := shutdownDaemon(t, "TERM")
+	c.Start()
+	c.AssertStdoutContains("ready")
+	if err := c.Terminate(); err != nil {
+		t.Fatal(err)
+	}
+	c.Wait()
+	c.AssertSuccess()
+	c.AssertStdoutContains("shutting down")
+	if _, ok := c.SignalToExitLatency(syscall.SIGTERM); !ok {
+		t.Fatalf("Expected SIGTERM to be recorded")
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	c := shutdownDaemon(t, "INT")
+	c.Start()
+	c.AssertStdoutContains("ready")
+	if err := c.Interrupt(); err != nil {
+		t.Fatal(err)
+	}
+	c.Wait()
+	c.AssertSuccess()
+	c.AssertStdoutContains("shutting down")
+}
+
+func TestSignalKill(t *testing.T) {
+	c := Command(t, "sleep", "10")
+	c.Start()
+	if err := c.Signal(os.Kill); err != nil {
+		t.Fatal(err)
+	}
+	c.Wait()
+	c.AssertExitCode(-1)
+	if !c.TerminationReason().Killed {
+		t.Fatalf("Expected the command to be recorded as killed, got %+v", c.TerminationReason())
+	}
+}
+
+func TestSignalExited(t *testing.T) {
+	c := Command(t, "true")
+	c.Run()
+	if err := c.Terminate(); !errors.Is(err, os.ErrProcessDone) {
+		t.Fatalf("Expected os.ErrProcessDone, got %v", err)
+	}
+}
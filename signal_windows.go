@@ -0,0 +1,44 @@
+package testcli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+var generateConsoleCtrlEvent = syscall.NewLazyDLL("kernel32.dll").NewProc("GenerateConsoleCtrlEvent")
+
+const ctrlBreakEvent = 1
+
+// signalProcess delivers SIGTERM and os.Interrupt as a CTRL_BREAK event,
+// which needs the process to lead its own process group, see
+// setNewProcessGroup().
+func signalProcess(p *os.Process, sig os.Signal) error {
+	if sig != syscall.SIGTERM && sig != os.Interrupt {
+		return p.Signal(sig)
+	}
+	if r, _, err := generateConsoleCtrlEvent.Call(ctrlBreakEvent, uintptr(p.Pid)); r == 0 {
+		return err
+	}
+	return nil
+}
+
+// signalError marks the failure to deliver sig as ErrSignalNotSupported.
+func signalError(sig os.Signal, err error) error {
+	return fmt.Errorf("%w: %s: %v", ErrSignalNotSupported, sig, err)
+}
+
+// setNewProcessGroup makes the command lead a new process group, so that
+// console control events can be sent to it alone.
+func setNewProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
+
+// killProcessGroup kills p. Unlike on Unix, its children are left alone.
+func killProcessGroup(p *os.Process) error {
+	return p.Kill()
+}
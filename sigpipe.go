@@ -0,0 +1,91 @@
+package testcli
+
+import (
+	"fmt"
+	"regexp"
+	"syscall"
+	"testing"
+)
+
+// sigpipeExitCode is how shells report a process killed by SIGPIPE.
+const sigpipeExitCode = 128 + 13
+
+// tracebackPattern matches the stack traces of interpreters, which aren't
+// failure reports of their own but shouldn't show up on a closed stdout.
+var tracebackPattern = regexp.MustCompile(`(?m)^Traceback \(most recent call last\):$`)
+
+// SIGPIPEOption configures CheckSIGPIPEBehavior().
+type SIGPIPEOption func(*sigpipeConfig)
+
+type sigpipeConfig struct {
+	closeAfter int
+	accepted   []int
+}
+
+// CloseStdoutAfter closes stdout once n bytes were read, 1 by default.
+func CloseStdoutAfter(n int) SIGPIPEOption {
+	return func(cfg *sigpipeConfig) {
+		cfg.closeAfter = n
+	}
+}
+
+// AcceptExitCodes replaces the exit codes accepted once stdout is closed, 0
+// and 141 by default.
+func AcceptExitCodes(codes ...int) SIGPIPEOption {
+	return func(cfg *sigpipeConfig) {
+		cfg.accepted = codes
+	}
+}
+
+// CheckSIGPIPEBehavior runs the command with its stdout closed early by the
+// reader, as in `mycli list | head -1`, while stderr is still read until the
+// end. The test fails if the command then exits with a code other than 0 or
+// 141, the code a shell reports for a process killed by SIGPIPE, or if its
+// stderr holds a panic, a traceback or another failure report. The command
+// must print more than a pipe can buffer, usually 64 KiB, to be sure to write
+// to the closed pipe.
+func CheckSIGPIPEBehavior(t *testing.T, spec Spec, opts ...SIGPIPEOption) {
+	t.Helper()
+	cfg := &sigpipeConfig{closeAfter: 1, accepted: []int{0, sigpipeExitCode}}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	c := spec.Command(t)
+	c.stdout.closeAfter = cfg.closeAfter
+	c.Run()
+	if err := checkSIGPIPEOutcome(c, cfg.accepted); err != nil {
+		c.fatalf("%s", err)
+	}
+}
+
+// checkSIGPIPEOutcome tells whether the finished command handled its stdout
+// being closed.
+func checkSIGPIPEOutcome(c *Cmd, accepted []int) error {
+	code := c.exitCode()
+	if state := c.cmd.ProcessState; state == nil {
+		return fmt.Errorf("Expected the command to run, got %s", c.Error())
+	} else if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() && status.Signal() == syscall.SIGPIPE {
+		code = sigpipeExitCode
+	}
+	if !containsInt(accepted, code) {
+		return fmt.Errorf("Expected the command to exit with one of %v once its stdout was closed, got %d", accepted, code)
+	}
+	stderr := c.Stderr()
+	if label, report, ok := findFailureReport(stderr); ok {
+		return fmt.Errorf("Expected no %s once stdout was closed, got:\n%s", label, report)
+	}
+	if tracebackPattern.MatchString(stderr) {
+		return fmt.Errorf("Expected no traceback once stdout was closed, got:\n%s", stderr)
+	}
+	return nil
+}
+
+func containsInt(list []int, n int) bool {
+	for _, item := range list {
+		if item == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package testcli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckSIGPIPEBehavior(t *testing.T) {
+	CheckSIGPIPEBehavior(t, Spec{Name: "yes"})
+	CheckSIGPIPEBehavior(t, Spec{Name: Build(t, "./testdata/brokenpipe")}, CloseStdoutAfter(100))
+}
+
+func TestCheckSIGPIPEOutcome(t *testing.T) {
+	brokenpipe := Build(t, "./testdata/brokenpipe")
+	c := Command(t, brokenpipe, "-panic")
+	c.stdout.closeAfter = 100
+	c.Run()
+	if !strings.HasPrefix(c.Stdout(), "line 0\n") || len(c.Stdout()) != 100 {
+		t.Fatalf("Expected the first 100 bytes of stdout, got %q", c.Stdout())
+	}
+	err := checkSIGPIPEOutcome(c, []int{0, sigpipeExitCode})
+	if err == nil || !strings.Contains(err.Error(), "Expected the command to exit with one of [0 141] once its stdout was closed, got 2") {
+		t.Fatalf("Expected the panic's exit code to be rejected, got %v", err)
+	}
+	err = checkSIGPIPEOutcome(c, []int{2})
+	if err == nil || !strings.Contains(err.Error(), "Expected no Go panic once stdout was closed") {
+		t.Fatalf("Expected the panic to be reported, got %v", err)
+	}
+
+	c = Command(t, "sh", "-c", "yes; exit 3")
+	c.stdout.closeAfter = 1
+	c.Run()
+	err = checkSIGPIPEOutcome(c, []int{0, sigpipeExitCode})
+	if err == nil || !strings.Contains(err.Error(), "got 3") {
+		t.Fatalf("Expected exit code 3 to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package testcli
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotHashLimit is the size of the largest files a Snapshot hashes. Larger
+// ones are deemed modified when their size or modification time changes.
+const snapshotHashLimit = 1 << 20
+
+// Snapshot records the files of a workspace, see Workspace.Snapshot().
+type Snapshot struct {
+	files map[string]snapshotFile
+}
+
+type snapshotFile struct {
+	size    int64
+	modTime time.Time
+	// hash is the hash of the content, or the target of a symlink, and empty
+	// for files too large to be hashed.
+	hash string
+}
+
+// Snapshot records the relative path, size and content hash of every file in
+// the workspace, for AssertDiff() to compare with the files after running a
+// command. Directories themselves aren't recorded.
+func (ws *Workspace) Snapshot() Snapshot {
+	ws.t.Helper()
+	s, err := takeSnapshot(ws.root)
+	if err != nil {
+		ws.t.Fatal(err)
+	}
+	return s
+}
+
+func takeSnapshot(root string) (Snapshot, error) {
+	s := Snapshot{files: map[string]snapshotFile{}}
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		f := snapshotFile{size: info.Size(), modTime: info.ModTime()}
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			f.hash = "-> " + target
+		case info.Mode().IsRegular() && info.Size() <= snapshotHashLimit:
+			if f.hash, err = hashContent(p); err != nil {
+				return err
+			}
+		}
+		s.files[filepath.ToSlash(rel)] = f
+		return nil
+	})
+	return s, err
+}
+
+func hashContent(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// ChangeClass is how a file changed between two snapshots.
+type ChangeClass string
+
+// The classes of changes.
+const (
+	ClassAdded     ChangeClass = "added"
+	ClassRemoved   ChangeClass = "removed"
+	ClassModified  ChangeClass = "modified"
+	ClassUnchanged ChangeClass = "unchanged"
+)
+
+// DiffRule allows the files matching its patterns to change in a given way,
+// see AssertDiff().
+type DiffRule struct {
+	class    ChangeClass
+	patterns []string
+}
+
+// Added expects the files matching patterns to be created.
+func Added(patterns ...string) DiffRule {
+	return DiffRule{class: ClassAdded, patterns: patterns}
+}
+
+// Removed expects the files matching patterns to be deleted.
+func Removed(patterns ...string) DiffRule {
+	return DiffRule{class: ClassRemoved, patterns: patterns}
+}
+
+// Modified expects the files matching patterns to be modified.
+func Modified(patterns ...string) DiffRule {
+	return DiffRule{class: ClassModified, patterns: patterns}
+}
+
+// Unchanged expects the files matching patterns to be left alone.
+func Unchanged(patterns ...string) DiffRule {
+	return DiffRule{class: ClassUnchanged, patterns: patterns}
+}
+
+// matches reports whether the rule applies to the slash-separated path p.
+func (r DiffRule) matches(p string) bool {
+	for _, pattern := range r.patterns {
+		if matchGlob(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// AssertDiff fails the test unless the files of the workspace changed since
+// before as rules expect. A file matching the patterns of some rules must
+// have changed the way one of them says, e.g. Removed("build/**") fails if a
+// file under build is left; files matching none must be unchanged. Patterns
+// are slash-separated, like path.Match() ones, and "**" matches any number of
+// directories. The failure lists the unexpected changes.
+func (ws *Workspace) AssertDiff(before Snapshot, rules ...DiffRule) {
+	ws.t.Helper()
+	after := ws.Snapshot()
+	if err := checkDiff(before, after, rules); err != nil {
+		ws.t.Fatal(err)
+	}
+}
+
+func checkDiff(before, after Snapshot, rules []DiffRule) error {
+	var paths []string
+	for p := range before.files {
+		paths = append(paths, p)
+	}
+	for p := range after.files {
+		if _, ok := before.files[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var unexpected []string
+	for _, p := range paths {
+		class := classify(before, after, p)
+		var expected []string
+		matched, ok := false, false
+		for _, r := range rules {
+			if !r.matches(p) {
+				continue
+			}
+			matched = true
+			if r.class == class {
+				ok = true
+				break
+			}
+			expected = append(expected, string(r.class))
+		}
+		if !matched {
+			ok, expected = class == ClassUnchanged, []string{string(ClassUnchanged)}
+		}
+		if !ok {
+			unexpected = append(unexpected, fmt.Sprintf("  %-9s %s (expected %s)", class, p, strings.Join(expected, " or ")))
+		}
+	}
+	if len(unexpected) > 0 {
+		return fmt.Errorf("Unexpected changes to the workspace:\n%s", strings.Join(unexpected, "\n"))
+	}
+	return nil
+}
+
+// classify returns how the file at p changed.
+func classify(before, after Snapshot, p string) ChangeClass {
+	b, existed := before.files[p]
+	a, exists := after.files[p]
+	switch {
+	case !existed:
+		return ClassAdded
+	case !exists:
+		return ClassRemoved
+	case a.size != b.size || a.hash != b.hash || (a.hash == "" && !a.modTime.Equal(b.modTime)):
+		return ClassModified
+	}
+	return ClassUnchanged
+}
+
+// matchGlob reports whether the slash-separated name matches pattern, where
+// "**" matches any number of path elements, and other elements are matched
+// with path.Match().
+func matchGlob(pattern, name string) bool {
+	return matchElems(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchElems(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchElems(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], name[0]); !ok {
+		return false
+	}
+	return matchElems(pattern[1:], name[1:])
+}
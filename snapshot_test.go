@@ -0,0 +1,66 @@
+package testcli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWorkspaceAssertDiff(t *testing.T) {
+	ws := NewWorkspace(t)
+	ws.WriteFile("src/main.c", []byte("int main() {}\n"))
+	ws.WriteFile("build/main.o", []byte("obj"))
+	ws.WriteFile("build/sub/lib.o", []byte("obj"))
+	ws.WriteFile("Makefile", []byte("all:\n"))
+	before := ws.Snapshot()
+
+	c := ws.Command("sh", "-c", "rm -r build && echo '# cleaned' >> Makefile && touch clean.log")
+	c.Run()
+	ws.AssertDiff(before, Removed("build/**"), Unchanged("src/**"), Modified("Makefile"), Added("*.log"))
+}
+
+func TestCheckDiff(t *testing.T) {
+	ws := NewWorkspace(t)
+	ws.WriteFile("src/main.c", []byte("int main() {}\n"))
+	ws.WriteFile("build/main.o", []byte("obj"))
+	ws.WriteFile("build/keep.o", []byte("obj"))
+	before := ws.Snapshot()
+
+	c := ws.Command("sh", "-c", "rm build/main.o && echo '// oops' >> src/main.c && touch stray")
+	c.Run()
+	err := checkDiff(before, ws.Snapshot(), []DiffRule{Removed("build/**")})
+	if err == nil {
+		t.Fatalf("Expected unexpected changes")
+	}
+	for _, expected := range []string{
+		"unchanged build/keep.o (expected removed)",
+		"modified  src/main.c (expected unchanged)",
+		"added     stray (expected unchanged)",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected %q to contain %q", err, expected)
+		}
+	}
+	if strings.Contains(err.Error(), "main.o") {
+		t.Errorf("Expected %q not to mention the expected removal", err)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		expected      bool
+	}{
+		{"build/**", "build/a.o", true},
+		{"build/**", "build/x/y/a.o", true},
+		{"build/**", "src/a.o", false},
+		{"**/*.o", "a.o", true},
+		{"**/*.o", "x/y/a.o", true},
+		{"src/*.c", "src/x/a.c", false},
+		{"src/**/a.c", "src/a.c", true},
+	}
+	for _, test := range tests {
+		if got := matchGlob(test.pattern, test.name); got != test.expected {
+			t.Errorf("Expected matchGlob(%q, %q) to be %t", test.pattern, test.name, test.expected)
+		}
+	}
+}
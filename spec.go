@@ -0,0 +1,56 @@
+package testcli
+
+import (
+	"os"
+	"testing"
+)
+
+// Spec describes a command independently of any test, for the helpers that
+// construct and run commands on their own.
+type Spec struct {
+	Name string
+	Args []string
+	// Env, if not nil, replaces the environment, see SetEnv().
+	Env []string
+	// EnvVars, a list of "key=value", are set on top of the environment, see
+	// AppendEnv().
+	EnvVars []string
+	// Dir is the working directory, the current one by default.
+	Dir string
+	// StdinFile, if set, is the file fed to stdin, see FromFile().
+	StdinFile string
+}
+
+// Command constructs a *Cmd from the spec.
+func (s Spec) Command(t *testing.T) *Cmd {
+	c := Command(t, s.Name, s.Args...)
+	if s.Env != nil {
+		c.SetEnv(s.Env)
+	}
+	c.AppendEnv(s.EnvVars...)
+	c.cmd.Dir = s.Dir
+	if s.StdinFile != "" {
+		c.SetStdinSequence(FromFile(s.StdinFile))
+	}
+	return c
+}
+
+// Spec returns the spec of the command, e.g. to save it with Save(): its
+// arguments, working directory and environment, as the variables which differ
+// from ours if it only adds to ours, in full otherwise. Stdin is only part of
+// it when fed from a single file with FromFile(), and options aren't.
+func (c *Cmd) Spec() Spec {
+	s := Spec{Name: c.cmd.Args[0], Args: c.cmd.Args[1:], Dir: c.cmd.Dir}
+	env := c.environ()
+	if removed := envDelta(os.Environ(), env); len(removed) > 0 {
+		s.Env = env
+	} else {
+		s.EnvVars = envDelta(env, os.Environ())
+	}
+	if len(c.stdinSequence) == 1 {
+		if path, ok := c.stdinSequence[0].(fileSource); ok {
+			s.StdinFile = string(path)
+		}
+	}
+	return s
+}
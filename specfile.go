@@ -0,0 +1,164 @@
+package testcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// specFileVersion is the version of the format written by Save(). Files of
+// earlier versions keep loading.
+const specFileVersion = 1
+
+// secretEnvPattern matches the names of the variables saved by reference
+// rather than by value, see Save().
+var secretEnvPattern = regexp.MustCompile(`(?i)(TOKEN|SECRET|PASSWORD|PASSWD|CREDENTIAL|API_?KEY|PRIVATE_KEY|AUTH)`)
+
+// specFile is the content of a file written by Save().
+type specFile struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+	// RootRelative is set when Name is relative to the root of
+	// SpecBinaryRoot().
+	RootRelative bool      `json:"root_relative,omitempty"`
+	Args         []string  `json:"args,omitempty"`
+	ReplaceEnv   bool      `json:"replace_env,omitempty"`
+	Env          []specVar `json:"env,omitempty"`
+	Dir          string    `json:"dir,omitempty"`
+	StdinFile    string    `json:"stdin_file,omitempty"`
+}
+
+// specVar is an environment variable of a spec file, either its value or a
+// reference to the variable of the same name of the environment loading it.
+type specVar struct {
+	Name    string `json:"name"`
+	Value   string `json:"value,omitempty"`
+	FromEnv bool   `json:"from_env,omitempty"`
+}
+
+// SpecFileOption configures Save() and LoadSpec().
+type SpecFileOption func(*specFileConfig)
+
+type specFileConfig struct {
+	root   string
+	redact []string
+}
+
+// SpecBinaryRoot makes Save() store the binary relative to root when it's
+// under it, e.g. the directory binaries are built to, and LoadSpec() resolve
+// it relative to root.
+func SpecBinaryRoot(root string) SpecFileOption {
+	return func(cfg *specFileConfig) {
+		cfg.root = root
+	}
+}
+
+// RedactEnv makes Save() store the variables names by reference, in addition
+// to the ones whose names look like secrets.
+func RedactEnv(names ...string) SpecFileOption {
+	return func(cfg *specFileConfig) {
+		cfg.redact = append(cfg.redact, names...)
+	}
+}
+
+func newSpecFileConfig(opts []SpecFileOption) *specFileConfig {
+	cfg := &specFileConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// Save writes the spec to path as JSON, to be loaded by LoadSpec(), e.g. to
+// attach the command of a failing test to an issue. The variables whose names
+// look like secrets, like GITHUB_TOKEN, are saved by name only, and taken
+// from the environment loading the spec.
+func (s Spec) Save(path string, opts ...SpecFileOption) error {
+	cfg := newSpecFileConfig(opts)
+	f := specFile{Version: specFileVersion, Name: s.Name, Args: s.Args, Dir: s.Dir, StdinFile: s.StdinFile}
+	if cfg.root != "" && filepath.IsAbs(s.Name) {
+		if rel, err := filepath.Rel(cfg.root, s.Name); err == nil && !strings.HasPrefix(rel, "..") {
+			f.Name, f.RootRelative = filepath.ToSlash(rel), true
+		}
+	}
+
+	env := s.EnvVars
+	if s.Env != nil {
+		f.ReplaceEnv = true
+		env = append(append([]string{}, s.Env...), s.EnvVars...)
+	}
+	for _, kv := range env {
+		name := envName(kv)
+		if secretEnvPattern.MatchString(name) || containsString(cfg.redact, name) {
+			f.Env = append(f.Env, specVar{Name: name, FromEnv: true})
+			continue
+		}
+		f.Env = append(f.Env, specVar{Name: name, Value: strings.TrimPrefix(kv, name+"=")})
+	}
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// LoadSpec constructs the command saved to path by Save(). The test fails if
+// the file can't be read, or if it references a variable our environment
+// doesn't have.
+func LoadSpec(t *testing.T, path string, opts ...SpecFileOption) *Cmd {
+	t.Helper()
+	s, err := readSpec(path, newSpecFileConfig(opts))
+	if err != nil {
+		t.Fatalf("Failed to load the spec %s: %s", path, err)
+	}
+	return s.Command(t)
+}
+
+func readSpec(path string, cfg *specFileConfig) (Spec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Spec{}, err
+	}
+	var f specFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return Spec{}, err
+	}
+	if f.Version < 1 || f.Version > specFileVersion {
+		return Spec{}, fmt.Errorf("unsupported version %d, expected at most %d", f.Version, specFileVersion)
+	}
+
+	s := Spec{Name: f.Name, Args: f.Args, Dir: f.Dir, StdinFile: f.StdinFile}
+	if f.RootRelative {
+		if cfg.root == "" {
+			return Spec{}, fmt.Errorf("%s is relative to a root, see SpecBinaryRoot()", f.Name)
+		}
+		s.Name = filepath.Join(cfg.root, filepath.FromSlash(f.Name))
+	}
+	var env []string
+	for _, v := range f.Env {
+		if !v.FromEnv {
+			env = append(env, v.Name+"="+v.Value)
+			continue
+		}
+		value, ok := os.LookupEnv(v.Name)
+		if !ok {
+			return Spec{}, fmt.Errorf("%s must be set in the environment", v.Name)
+		}
+		env = append(env, v.Name+"="+value)
+	}
+	if f.ReplaceEnv {
+		s.Env = env
+		if s.Env == nil {
+			s.Env = []string{}
+		}
+	} else {
+		s.EnvVars = env
+	}
+	return s, nil
+}
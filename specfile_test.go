@@ -0,0 +1,103 @@
+package testcli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSpecSaveLoad(t *testing.T) {
+	defer os.Setenv("TESTCLI_API_TOKEN", os.Getenv("TESTCLI_API_TOKEN"))
+	os.Setenv("TESTCLI_API_TOKEN", "hunter2")
+
+	dir := t.TempDir()
+	root := filepath.Join(dir, "bin")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\necho \"$1 $TESTCLI_GREETING $TESTCLI_API_TOKEN $(pwd)\"\ncat\n"
+	if err := ioutil.WriteFile(filepath.Join(root, "greet"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	stdin := filepath.Join(dir, "stdin")
+	if err := ioutil.WriteFile(stdin, []byte("from stdin\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := Command(t, filepath.Join(root, "greet"), "hello")
+	c.SetEnvVar("TESTCLI_GREETING", "world")
+	c.SetEnvVar("TESTCLI_API_TOKEN", "hunter2")
+	c.cmd.Dir = dir
+	c.SetStdinSequence(FromFile(stdin))
+	path := filepath.Join(dir, "spec.json")
+	if err := c.Spec().Save(path, SpecBinaryRoot(root)); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "hunter2") || !strings.Contains(string(content), `"name": "greet"`) {
+		t.Fatalf("Expected the secret to be redacted and the binary to be relative, got:\n%s", content)
+	}
+
+	loaded := LoadSpec(t, path, SpecBinaryRoot(root))
+	loaded.Run()
+	expected := "hello world hunter2 " + dir + "\nfrom stdin\n"
+	if loaded.Stdout() != expected {
+		t.Fatalf("Expected the loaded spec to print %q, got %q", expected, loaded.Stdout())
+	}
+}
+
+func TestSpecReplacingEnv(t *testing.T) {
+	c := Command(t, "env")
+	c.SetEnv([]string{"TESTCLI_ONLY=1"})
+	s := c.Spec()
+	if len(s.Env) != 1 || s.Env[0] != "TESTCLI_ONLY=1" || s.EnvVars != nil {
+		t.Fatalf("Expected the environment to be saved in full, got %+v", s)
+	}
+
+	path := filepath.Join(t.TempDir(), "spec.json")
+	if err := s.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded := LoadSpec(t, path)
+	loaded.Run()
+	if loaded.Stdout() != "TESTCLI_ONLY=1\n" {
+		t.Fatalf("Expected the environment to be replaced, got %q", loaded.Stdout())
+	}
+}
+
+func TestReadSpec(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "spec.json")
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	s, err := readSpec(write(`{"version": 1, "name": "echo", "args": ["hi"], "env": [{"name": "A", "value": "b"}]}`), &specFileConfig{})
+	if err != nil || s.Name != "echo" || len(s.EnvVars) != 1 || s.EnvVars[0] != "A=b" {
+		t.Fatalf("Expected a version 1 spec to load, got %+v (%v)", s, err)
+	}
+
+	if _, err := readSpec(write(`{"version": 99, "name": "echo"}`), &specFileConfig{}); err == nil || !strings.Contains(err.Error(), "unsupported version 99") {
+		t.Fatalf("Expected a future version to be rejected, got %v", err)
+	}
+
+	os.Unsetenv("TESTCLI_MISSING_SECRET")
+	path := write(`{"version": 1, "name": "echo", "env": [{"name": "TESTCLI_MISSING_SECRET", "from_env": true}]}`)
+	if _, err := readSpec(path, &specFileConfig{}); err == nil || !strings.Contains(err.Error(), "TESTCLI_MISSING_SECRET must be set") {
+		t.Fatalf("Expected a missing reference to be reported, got %v", err)
+	}
+
+	path = write(`{"version": 1, "name": "greet", "root_relative": true}`)
+	if _, err := readSpec(path, &specFileConfig{}); err == nil {
+		t.Fatalf("Expected a relative binary to require a root")
+	}
+}
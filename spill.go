@@ -0,0 +1,209 @@
+package testcli
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// spillTailSize is how much of the end of a spill file failure reports show.
+const spillTailSize = 4096
+
+// spill holds the part of an output stream beyond what's kept in memory.
+type spill struct {
+	t         *testing.T
+	dir       string
+	name      string
+	threshold int
+	file      *os.File
+	size      int64
+	err       error
+}
+
+// SpillCaptureToDisk keeps at most memThreshold bytes of each output stream
+// in memory, and writes the rest to a temporary file in dir, for commands
+// printing more than fits in memory. Contains and Matches assertions search
+// both transparently, streaming the file, and only compare ASCII letters
+// case insensitively; Stdout() and Stderr() read the whole file back. The
+// files are removed when the test finishes, unless it failed. It must be
+// called before Run() or Start(), and isn't compatible with KeepTail().
+func (c *Cmd) SpillCaptureToDisk(dir string, memThreshold int) {
+	c.stdout.spill = &spill{t: c.t, dir: dir, name: "stdout", threshold: memThreshold}
+	c.stderr.spill = &spill{t: c.t, dir: dir, name: "stderr", threshold: memThreshold}
+}
+
+// write appends p, which doesn't fit in memory, to the spill file.
+func (s *spill) write(p []byte) {
+	if s.err != nil {
+		return
+	}
+	if s.file == nil {
+		if s.file, s.err = ioutil.TempFile(s.dir, "testcli-"+s.name+"-"); s.err != nil {
+			return
+		}
+		s.t.Cleanup(func() {
+			s.file.Close()
+			if s.t.Failed() {
+				s.t.Logf("The spilled %s was kept at %s", s.name, s.file.Name())
+				return
+			}
+			os.Remove(s.file.Name())
+		})
+	}
+	n, err := s.file.Write(p)
+	s.size += int64(n)
+	s.err = err
+}
+
+// spilled reports whether part of the content is on disk. Callers must hold
+// o.mu.
+func (o *output) spilled() bool {
+	return o.spill != nil && o.spill.file != nil
+}
+
+// reader returns a reader over the whole content, from offset, as of now.
+// Callers must hold o.mu, but not while reading.
+func (o *output) reader(offset int64) (io.Reader, func()) {
+	mem := o.content
+	if offset < int64(len(mem)) {
+		mem = mem[offset:]
+		offset = 0
+	} else {
+		offset -= int64(len(mem))
+		mem = ""
+	}
+	if !o.spilled() {
+		return strings.NewReader(mem), func() {}
+	}
+	f, err := os.Open(o.spill.file.Name())
+	if err != nil {
+		return strings.NewReader(mem), func() {}
+	}
+	disk := io.NewSectionReader(f, offset, o.spill.size-offset)
+	return io.MultiReader(strings.NewReader(mem), disk), func() { f.Close() }
+}
+
+// spilledText returns the whole content, reading the spill file back.
+// Callers must hold o.mu.
+func (o *output) spilledText() string {
+	r, done := o.reader(0)
+	defer done()
+	content, _ := ioutil.ReadAll(r)
+	return string(content)
+}
+
+// reportText returns the content for failure reports: all of it, or what's
+// in memory and the end of the spill file. Callers must hold o.mu.
+func (o *output) reportText() string {
+	if !o.spilled() {
+		return o.text()
+	}
+	skipped := o.spill.size - spillTailSize
+	if skipped < 0 {
+		skipped = 0
+	}
+	tail := make([]byte, o.spill.size-skipped)
+	if f, err := os.Open(o.spill.file.Name()); err == nil {
+		n, _ := f.ReadAt(tail, skipped)
+		tail = tail[:n]
+		f.Close()
+	}
+	note := fmt.Sprintf("\n[... %d bytes spilled to %s ...]\n", skipped, o.spill.file.Name())
+	if o.spill.err != nil {
+		note += fmt.Sprintf("[spilling failed: %s]\n", o.spill.err)
+	}
+	return o.content + note + string(tail)
+}
+
+// asciiLower lowers the ASCII letters of p in place.
+func asciiLower(p []byte) {
+	for i, b := range p {
+		if 'A' <= b && b <= 'Z' {
+			p[i] = b + 'a' - 'A'
+		}
+	}
+}
+
+type lowerReader struct {
+	r io.Reader
+}
+
+func (lr lowerReader) Read(p []byte) (int, error) {
+	n, err := lr.r.Read(p)
+	asciiLower(p[:n])
+	return n, err
+}
+
+// streamContains reports whether the content past offset contains needle,
+// which must be lowered already if fold is set, in which case the content is
+// lowered too, and returns where the next search can start from.
+func (o *output) streamContains(needle []byte, offset int64, fold bool) (bool, int64) {
+	if len(needle) == 0 {
+		return true, offset
+	}
+	o.mu.Lock()
+	r, done := o.reader(offset)
+	o.mu.Unlock()
+	defer done()
+
+	var window []byte
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if fold {
+				asciiLower(buf[:n])
+			}
+			window = append(window, buf[:n]...)
+			if bytes.Contains(window, needle) {
+				return true, offset
+			}
+			// Keep what could be the start of a match straddling reads.
+			if keep := len(needle) - 1; len(window) > keep {
+				offset += int64(len(window) - keep)
+				window = append(window[:0], window[len(window)-keep:]...)
+			}
+		}
+		if err != nil {
+			return false, offset
+		}
+	}
+}
+
+// spilledContains is Contains for spilled outputs: each retry resumes the
+// search where the previous one stopped. The search ignores the case unless
+// fold is false.
+func (c *Cmd) spilledContains(o *output, str string, fold bool) bool {
+	needle := []byte(str)
+	if fold {
+		asciiLower(needle)
+	}
+	var offset int64
+	return c.waitFor(fmt.Sprintf("output to contain %q", str), func() bool {
+		var found bool
+		found, offset = o.streamContains(needle, offset, fold)
+		return found
+	}, defaultPollInterval, Scaled(defaultPollTimeout)) == nil
+}
+
+// spilledMatches is Matches for spilled outputs. Each retry streams the
+// whole content through the regex, which can't resume a search. The content
+// is lowered unless fold is false.
+func (c *Cmd) spilledMatches(o *output, re *regexp.Regexp, fold bool) bool {
+	return c.waitFor(fmt.Sprintf("output to match %q", re), func() bool {
+		o.mu.Lock()
+		r, done := o.reader(0)
+		o.mu.Unlock()
+		defer done()
+		if !fold {
+			return re.MatchReader(bufio.NewReader(r))
+		}
+		return re.MatchReader(bufio.NewReader(lowerReader{r}))
+	}, defaultPollInterval, Scaled(defaultPollTimeout)) == nil
+}
@@ -0,0 +1,80 @@
+package testcli
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSpillCaptureToDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testcli-spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	t.Run("run", func(t *testing.T) {
+		c := Command(t, "seq", "200000")
+		c.SpillCaptureToDisk(dir, 1000)
+		c.Run()
+
+		if len(c.stdout.content) != 1000 {
+			t.Fatalf("Expected 1000 bytes in memory, got %d", len(c.stdout.content))
+		}
+		if !c.StdoutContains("199999\n200000") {
+			t.Fatalf("Expected the spilled stdout to be searched")
+		}
+		if !c.StdoutMatches(`(?m)^150000$`) {
+			t.Fatalf("Expected the spilled stdout to be matched")
+		}
+		if got := strings.Count(c.Stdout(), "\n"); got != 200000 {
+			t.Fatalf("Expected Stdout() to return all 200000 lines, got %d", got)
+		}
+		if report := c.report(); !strings.Contains(report, "bytes spilled to") || len(report) > 10000 {
+			t.Fatalf("Expected the report to show the head and the tail only, got %d bytes", len(report))
+		}
+	})
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Fatalf("Expected the spill files to be removed, found %d", len(files))
+	}
+}
+
+func TestStreamContains(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testcli-spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	o := &output{mu: &sync.Mutex{}, spill: &spill{t: t, dir: dir, name: "stdout", threshold: 4}}
+	o.write([]byte("abcdEF"))
+	o.write([]byte(strings.Repeat("x", 64*1024-3) + "NEEDLE" + "tail"))
+
+	for needle, expected := range map[string]bool{
+		"cdef":   true, // straddles memory and disk
+		"xneedl": true, // straddles two reads
+		"tail":   true,
+		"nope":   false,
+	} {
+		if found, _ := o.streamContains([]byte(needle), 0, true); found != expected {
+			t.Errorf("Expected searching %q to return %t", needle, expected)
+		}
+	}
+
+	// Resuming doesn't miss a match straddling the previous end.
+	found, next := o.streamContains([]byte("tailabc"), 0, true)
+	if found {
+		t.Fatalf("Expected no match yet")
+	}
+	o.write([]byte("ABC"))
+	if found, _ := o.streamContains([]byte("tailabc"), next, true); !found {
+		t.Fatalf("Expected the resumed search to find the match")
+	}
+}
@@ -0,0 +1,303 @@
+package testcli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// SSHConfig describes how an SSHExecutor reaches the remote host.
+type SSHConfig struct {
+	// Host is the name or address of the remote host.
+	Host string
+	// User and Port default to whatever the ssh client configuration says.
+	User string
+	Port int
+	// IdentityFile is the private key to authenticate with, if any.
+	IdentityFile string
+	// ConnectTimeout bounds establishing the connection, independently of
+	// how long the command runs. It defaults to 10 seconds.
+	ConnectTimeout time.Duration
+	// PTY allocates a pseudo-terminal for the command. Its stdout and stderr
+	// are then both captured as stdout, like on a real terminal. It's
+	// allocated on the remote host by ssh, see ConPTYExecutor() for a local
+	// pseudo console on Windows.
+	PTY bool
+	// Options are passed to ssh as -o options, e.g. "StrictHostKeyChecking=no".
+	Options []string
+	// Binary is the ssh client to run, "ssh" by default.
+	Binary string
+}
+
+// SSHExecutor returns an Executor running commands on a remote host through
+// the ssh client, which must be able to log in without prompting (BatchMode
+// is always on). The command gets the remote login environment plus the
+// variables of its own environment that differ from the local one, and runs
+// from its Dir if one is set.
+//
+// The ssh client can't send the protocol's signal message, so signals are
+// delivered by running kill(1) on the remote host over a second connection.
+// Errors mention the host the command ran on.
+func SSHExecutor(config SSHConfig) Executor {
+	return &sshExecutor{config: config}
+}
+
+type sshExecutor struct {
+	config SSHConfig
+}
+
+// sshPidMarker precedes the remote process id on the stream the remote
+// shell reports it on.
+const sshPidMarker = "testcli-pid "
+
+func (e *sshExecutor) Start(cmd *exec.Cmd) (Process, error) {
+	stdout, _ := cmd.Stdout.(*os.File)
+	stderr, _ := cmd.Stderr.(*os.File)
+	// The remote shell reports its pid on stderr, or stdout when there's a
+	// PTY since both end up there; it is filtered out before the capture.
+	marked := stderr
+	if e.config.PTY {
+		marked = stdout
+	}
+
+	markReader, markWriter, err := os.Pipe()
+	if err != nil {
+		closeFile(stdout)
+		closeFile(stderr)
+		return nil, e.errorf("%w", err)
+	}
+
+	ssh := exec.Command(withDefault(e.config.Binary, "ssh"), e.args(e.remoteCommand(cmd))...)
+	ssh.Stdin = cmd.Stdin
+	ssh.Stdout = stdout
+	ssh.Stderr = stderr
+	if e.config.PTY {
+		ssh.Stdout = markWriter
+	} else {
+		ssh.Stderr = markWriter
+	}
+
+	err = ssh.Start()
+	markWriter.Close()
+	if marked == stdout {
+		closeFile(stderr)
+	} else {
+		closeFile(stdout)
+	}
+	if err != nil {
+		markReader.Close()
+		closeFile(marked)
+		return nil, e.errorf("%w", err)
+	}
+
+	p := &sshProcess{executor: e, ssh: ssh, pid: -1, pidKnown: make(chan struct{})}
+	go p.readPid(markReader, marked)
+	return p, nil
+}
+
+// args returns the arguments of the ssh client running remote.
+func (e *sshExecutor) args(remote string) []string {
+	timeout := e.config.ConnectTimeout
+	if timeout == 0 {
+		timeout = Scaled(10 * time.Second)
+	}
+	seconds := int((timeout + time.Second - 1) / time.Second)
+
+	args := []string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=" + strconv.Itoa(seconds)}
+	if e.config.PTY {
+		args = append(args, "-tt")
+	} else {
+		args = append(args, "-T")
+	}
+	for _, opt := range e.config.Options {
+		args = append(args, "-o", opt)
+	}
+	if e.config.User != "" {
+		args = append(args, "-l", e.config.User)
+	}
+	if e.config.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(e.config.Port))
+	}
+	if e.config.IdentityFile != "" {
+		args = append(args, "-i", e.config.IdentityFile)
+	}
+	return append(args, e.config.Host, remote)
+}
+
+// remoteCommand returns the shell command running cmd on the remote host.
+func (e *sshExecutor) remoteCommand(cmd *exec.Cmd) string {
+	stream := "2"
+	if e.config.PTY {
+		stream = "1"
+	}
+	script := "echo " + sshPidMarker + "$$ >&" + stream + "; "
+	if cmd.Dir != "" {
+		script += "cd " + shellQuote(cmd.Dir) + " && "
+	}
+	script += "exec"
+	if env := envDelta(cmd.Env, os.Environ()); len(env) > 0 {
+		script += " env"
+		for _, kv := range env {
+			script += " " + shellQuote(kv)
+		}
+	}
+	for _, arg := range cmd.Args {
+		script += " " + shellQuote(arg)
+	}
+	return script
+}
+
+func (e *sshExecutor) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("on host %s: "+format, append([]interface{}{e.config.Host}, args...)...)
+}
+
+type sshProcess struct {
+	executor *sshExecutor
+	ssh      *exec.Cmd
+
+	mu       sync.Mutex
+	pid      int
+	pidKnown chan struct{}
+	killed   bool
+}
+
+// readPid reads the remote pid from r, then copies the rest of it to w.
+func (p *sshProcess) readPid(r *os.File, w *os.File) {
+	defer r.Close()
+	defer w.Close()
+	br := bufio.NewReader(r)
+	line, err := br.ReadString('\n')
+	if strings.HasPrefix(line, sshPidMarker) {
+		pid, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, sshPidMarker)))
+		p.mu.Lock()
+		p.pid = pid
+		p.mu.Unlock()
+	} else {
+		w.WriteString(line)
+	}
+	close(p.pidKnown)
+	if err == nil {
+		io.Copy(w, br)
+	}
+}
+
+// Pid returns the id of the process on the remote host, or -1 if it isn't
+// known yet.
+func (p *sshProcess) Pid() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pid
+}
+
+func (p *sshProcess) Signal(sig os.Signal) error {
+	name, ok := signalNames[sig]
+	if !ok {
+		return p.executor.errorf("signal %v can't be sent over ssh", sig)
+	}
+
+	timeout := p.executor.config.ConnectTimeout
+	if timeout == 0 {
+		timeout = Scaled(10 * time.Second)
+	}
+	select {
+	case <-p.pidKnown:
+	case <-time.After(timeout):
+	}
+	pid := p.Pid()
+	if pid <= 0 {
+		return p.executor.errorf("the remote process id is unknown")
+	}
+
+	kill := exec.Command(withDefault(p.executor.config.Binary, "ssh"),
+		p.executor.args("kill -"+name+" "+strconv.Itoa(pid))...)
+	if out, err := kill.CombinedOutput(); err != nil {
+		return p.executor.errorf("kill -%s %d: %w: %s", name, pid, err, out)
+	}
+	return nil
+}
+
+func (p *sshProcess) Kill() error {
+	p.mu.Lock()
+	p.killed = true
+	p.mu.Unlock()
+	err := p.Signal(syscall.SIGKILL)
+	if err != nil {
+		// Better to lose the remote process than to hang the test.
+		p.ssh.Process.Kill()
+	}
+	return err
+}
+
+func (p *sshProcess) Wait() error {
+	err := p.ssh.Wait()
+	p.mu.Lock()
+	killed := p.killed
+	p.mu.Unlock()
+	if killed {
+		return p.executor.errorf("%w", errKilled)
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 255 {
+		return p.executor.errorf("ssh failed, the command may not have run: %w", err)
+	}
+	if err != nil {
+		return p.executor.errorf("%w", err)
+	}
+	return nil
+}
+
+// errKilled is reported by executors which can't get the exit status of a
+// killed process from the process itself.
+var errKilled = killedError{}
+
+type killedError struct{}
+
+func (killedError) Error() string {
+	return "signal: killed"
+}
+
+func (killedError) ExitCode() int {
+	return -1
+}
+
+var signalNames = map[os.Signal]string{
+	syscall.SIGHUP:  "HUP",
+	syscall.SIGINT:  "INT",
+	syscall.SIGQUIT: "QUIT",
+	syscall.SIGKILL: "KILL",
+	syscall.SIGTERM: "TERM",
+}
+
+// envDelta returns the entries of env which aren't in base, sorted.
+func envDelta(env, base []string) []string {
+	inBase := make(map[string]bool, len(base))
+	for _, kv := range base {
+		inBase[kv] = true
+	}
+	var delta []string
+	for _, kv := range env {
+		if !inBase[kv] {
+			delta = append(delta, kv)
+		}
+	}
+	sort.Strings(delta)
+	return delta
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:,+@%") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,68 @@
+package testcli
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSSH is an ssh client running the remote command on this machine.
+const fakeSSH = `for last; do :; done
+exec sh -c "$last"
+`
+
+func TestSSHExecutorConformance(t *testing.T) {
+	binary := writeScript(t, fakeSSH)
+	TestExecutorConformance(t, func() Executor {
+		return SSHExecutor(SSHConfig{Host: "example.com", Binary: binary})
+	})
+}
+
+func TestSSHExecutorArgs(t *testing.T) {
+	e := &sshExecutor{config: SSHConfig{
+		Host:           "arm-box",
+		User:           "ci",
+		Port:           2222,
+		IdentityFile:   "/keys/ci",
+		ConnectTimeout: 1500 * time.Millisecond,
+		PTY:            true,
+		Options:        []string{"StrictHostKeyChecking=no"},
+	}}
+
+	expected := []string{
+		"-o", "BatchMode=yes", "-o", "ConnectTimeout=2", "-tt",
+		"-o", "StrictHostKeyChecking=no", "-l", "ci", "-p", "2222", "-i", "/keys/ci",
+		"arm-box", "true",
+	}
+	if got := e.args("true"); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestSSHExecutorRemoteCommand(t *testing.T) {
+	e := &sshExecutor{config: SSHConfig{Host: "arm-box"}}
+	cmd := exec.Command("mycli", "--name", "it's me")
+	cmd.Dir = "/srv/my app"
+	cmd.Env = []string{"FOO=bar baz"}
+
+	expected := `echo testcli-pid $$ >&2; cd '/srv/my app' && exec env 'FOO=bar baz' mycli --name 'it'\''s me'`
+	if got := e.remoteCommand(cmd); got != expected {
+		t.Fatalf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestSSHExecutorReportsHost(t *testing.T) {
+	binary := writeScript(t, "echo 'ssh: connect to host arm-box port 22: Connection refused' >&2; exit 255\n")
+	c := Command(t, "mycli")
+	c.SetExecutor(SSHExecutor(SSHConfig{Host: "arm-box", Binary: binary}))
+	c.Run()
+	if !c.Failure() || !strings.Contains(c.Error().Error(), "arm-box") {
+		t.Fatalf("Expected an error mentioning the host, got %v", c.Error())
+	}
+
+	if !c.StderrContains("connection refused") {
+		t.Fatalf("Expected %q to contain %q", c.Stderr(), "connection refused")
+	}
+}
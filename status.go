@@ -0,0 +1,33 @@
+package testcli
+
+// PID returns the process id of the started command, e.g. to signal it with
+// another tool or to inspect /proc. It fails the test before Start(), or if
+// the command couldn't be started.
+func (c *Cmd) PID() int {
+	c.t.Helper()
+	c.validateHasStarted()
+	if c.process == nil {
+		c.t.Fatalf("The command has no process: %s", c.waitErr)
+	}
+	return c.process.Pid()
+}
+
+// Status returns the state of the command: Initialized, Running or
+// Finished. A command is Finished as soon as its process exits, even before
+// Wait() collects it.
+func (c *Cmd) Status() string {
+	if c.status == Running {
+		select {
+		case <-c.done:
+			return Finished
+		default:
+		}
+	}
+	return c.status
+}
+
+// IsRunning reports whether the command was started and its process hasn't
+// exited yet.
+func (c *Cmd) IsRunning() bool {
+	return c.Status() == Running
+}
@@ -0,0 +1,49 @@
+package testcli
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestStatus(t *testing.T) {
+	c := Command(t, "sh", "-c", "read line")
+	c.StdinPipe()
+	if got := c.Status(); got != Initialized || c.IsRunning() {
+		t.Fatalf("Expected status %q before Start(), got %q", Initialized, got)
+	}
+	c.Start()
+	if got := c.Status(); got != Running || !c.IsRunning() {
+		t.Fatalf("Expected status %q after Start(), got %q", Running, got)
+	}
+	c.WriteStdin("done\n")
+	// Not waited for, the command is finished as soon as it exits.
+	deadline := time.Now().Add(5 * time.Second)
+	for c.IsRunning() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := c.Status(); got != Finished {
+		t.Fatalf("Expected status %q once exited, got %q", Finished, got)
+	}
+	c.Wait()
+	if got := c.Status(); got != Finished {
+		t.Fatalf("Expected status %q after Wait(), got %q", Finished, got)
+	}
+}
+
+func TestPID(t *testing.T) {
+	c := Command(t, "sleep", "5")
+	c.Start()
+	p, err := os.FindProcess(c.PID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	c.Wait()
+	if c.Success() {
+		t.Fatalf("Expected the command to be killed through its PID")
+	}
+}
@@ -0,0 +1,432 @@
+package testcli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// StdinWrite is a piece of input the harness passed to the command's stdin.
+type StdinWrite struct {
+	At   time.Time
+	Data string
+	// seq orders the write among the other events of the command, see
+	// Cmd.seq.
+	seq int64
+}
+
+// transcriptReader records everything read from r, which is what ends up
+// written to the command's stdin.
+type transcriptReader struct {
+	r io.Reader
+	c *Cmd
+}
+
+func (tr *transcriptReader) Read(p []byte) (int, error) {
+	n, err := tr.r.Read(p)
+	if n > 0 {
+		// Numbered before being written, so that it comes before the
+		// output it causes.
+		seq := nextSeq(&tr.c.seq)
+		tr.c.stdinMu.Lock()
+		tr.c.transcript = append(tr.c.transcript, StdinWrite{At: time.Now(), Data: string(p[:n]), seq: seq})
+		tr.c.stdinMu.Unlock()
+	}
+	return n, err
+}
+
+// RecordStdin makes the command keep a transcript of what is written to its
+// stdin. It must be called before Run() or Start().
+func (c *Cmd) RecordStdin() {
+	c.recordStdin = true
+}
+
+// StdinTranscript returns what has been written to the command's stdin so far,
+// in order. It is empty unless RecordStdin() was called.
+func (c *Cmd) StdinTranscript() []StdinWrite {
+	c.t.Helper()
+	c.validateHasStarted()
+	c.stdinMu.Lock()
+	defer c.stdinMu.Unlock()
+	return append([]StdinWrite(nil), c.transcript...)
+}
+
+// ExcludingEcho makes an assertion ignore the command echoing back its input,
+// as `cat` or a terminal would: each write in the stdin transcript is removed
+// from the output the first time it appears verbatim, in order. It requires
+// RecordStdin().
+func ExcludingEcho() MatchOption {
+	return func(cfg *matchConfig) {
+		cfg.excludingEcho = true
+	}
+}
+
+// removeEcho removes from content the exact echoes of the stdin transcript.
+func (c *Cmd) removeEcho(content string) string {
+	c.stdinMu.Lock()
+	defer c.stdinMu.Unlock()
+
+	pos := 0
+	for _, w := range c.transcript {
+		i := strings.Index(content[pos:], w.Data)
+		if i < 0 {
+			continue
+		}
+		pos += i
+		content = content[:pos] + content[pos+len(w.Data):]
+	}
+	return content
+}
+
+// StdinSource is a part of the input fed to a command by SetStdinSequence.
+type StdinSource interface {
+	feed(c *Cmd, w io.Writer) error
+}
+
+// SetStdinSequence feeds the sources to the command's stdin one after the
+// other, and closes it after the last one, unless it's KeepOpen(). It replaces
+// SetStdin() and must be called before Run() or Start(). If the command stops
+// reading its input, the rest of the sequence is dropped; if it exits, the
+// sequence is aborted with ErrStdinAborted, even when something else, like a
+// background child, still holds its stdin open.
+func (c *Cmd) SetStdinSequence(sources ...StdinSource) {
+	c.stdinSequence = sources
+}
+
+// ErrStdinAborted is reported by StdinError() when the command exited before
+// the stdin sequence was done.
+var ErrStdinAborted = errors.New("stdin feed aborted: process exited")
+
+// stdinAbortGrace bounds how long Wait() waits for the stdin sequence to stop
+// once the command exited, in case a source is blocked outside of our control,
+// e.g. reading from a FromReader() reader.
+const stdinAbortGrace = time.Second
+
+func (c *Cmd) feedStdin(w io.WriteCloser) {
+	defer close(c.stdinFed)
+	fed := make(chan struct{})
+	defer close(fed)
+	go func() {
+		select {
+		case <-fed:
+		case <-c.done:
+			// Unblocks a pending write, which would otherwise wait for
+			// whoever else holds the pipe to read it.
+			w.Close()
+		}
+	}()
+
+	defer w.Close()
+	for _, src := range c.stdinSequence {
+		if err := src.feed(c, w); err != nil {
+			if exited, _, _ := c.Exited(); exited {
+				var promptErr *promptError
+				if !errors.As(err, &promptErr) {
+					err = ErrStdinAborted
+				}
+			}
+			c.stdinMu.Lock()
+			c.stdinErr = err
+			c.stdinMu.Unlock()
+			return
+		}
+	}
+}
+
+// StdinError returns the error that stopped the stdin sequence, if any, e.g.
+// ErrStdinAborted.
+func (c *Cmd) StdinError() error {
+	c.stdinMu.Lock()
+	defer c.stdinMu.Unlock()
+	return c.stdinErr
+}
+
+// writeStdin writes data to w, recording it in the transcript if enabled,
+// with the jitter if any.
+func (c *Cmd) writeStdin(w io.Writer, data []byte) error {
+	if c.jitter != nil {
+		return c.writeJittered(w, data)
+	}
+	return c.recordWrite(w, data)
+}
+
+// recordWrite writes data to w, recording it in the transcript if enabled.
+func (c *Cmd) recordWrite(w io.Writer, data []byte) error {
+	// Numbered before being written, so that it comes before the output it
+	// causes.
+	seq := nextSeq(&c.seq)
+	n, err := w.Write(data)
+	if n > 0 && c.recordStdin {
+		c.stdinMu.Lock()
+		c.transcript = append(c.transcript, StdinWrite{At: time.Now(), Data: string(data[:n]), seq: seq})
+		c.stdinMu.Unlock()
+	}
+	return err
+}
+
+type sourceFunc func(c *Cmd, w io.Writer) error
+
+func (f sourceFunc) feed(c *Cmd, w io.Writer) error {
+	return f(c, w)
+}
+
+// FromReader streams r until EOF.
+func FromReader(r io.Reader) StdinSource {
+	return sourceFunc(func(c *Cmd, w io.Writer) error {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				if err := c.writeStdin(w, buf[:n]); err != nil {
+					return err
+				}
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// FromString writes s.
+func FromString(s string) StdinSource {
+	return FromReader(strings.NewReader(s))
+}
+
+// FromFile streams the content of the file at path.
+func FromFile(path string) StdinSource {
+	return fileSource(path)
+}
+
+type fileSource string
+
+func (path fileSource) feed(c *Cmd, w io.Writer) error {
+	f, err := os.Open(string(path))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return FromReader(f).feed(c, w)
+}
+
+func (path fileSource) validate() error {
+	_, err := os.Stat(string(path))
+	return err
+}
+
+// FirstBytes hands control to the next source once src has written n bytes.
+func FirstBytes(n int64, src StdinSource) StdinSource {
+	return sourceFunc(func(c *Cmd, w io.Writer) error {
+		lw := &limitedWriter{w: w, n: n}
+		err := src.feed(c, lw)
+		if err == errLimitReached {
+			return nil
+		}
+		return err
+	})
+}
+
+var errLimitReached = errors.New("limit reached")
+
+// limitedWriter writes at most n bytes to w, then fails with errLimitReached.
+type limitedWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	if lw.n <= 0 {
+		return 0, errLimitReached
+	}
+	truncated := false
+	if int64(len(p)) > lw.n {
+		p = p[:lw.n]
+		truncated = true
+	}
+	n, err := lw.w.Write(p)
+	lw.n -= int64(n)
+	if err == nil && truncated {
+		err = errLimitReached
+	}
+	return n, err
+}
+
+// defaultPromptTimeout is how long prompts are waited for.
+const defaultPromptTimeout = 5 * time.Second
+
+// WaitForPrompt hands control to the next source once the command's stdout
+// matches prompt, looking only at what it printed since the previous prompt.
+func WaitForPrompt(prompt string) StdinSource {
+	re := regexp.MustCompile(prompt)
+	return sourceFunc(func(c *Cmd, w io.Writer) error {
+		return c.waitForPrompt(re, Scaled(defaultPromptTimeout))
+	})
+}
+
+// Prompt is a step of an Interactive() session.
+type Prompt struct {
+	// Expect is a regex the command's stdout must match.
+	Expect string
+	// Send is written to stdin once Expect matched.
+	Send string
+}
+
+// Respond returns a Prompt writing answer once expect matches.
+func Respond(expect, answer string) Prompt {
+	return Prompt{Expect: expect, Send: answer}
+}
+
+// Interactive answers the command's prompts in order: for each one, it waits
+// for the command's stdout to match Expect, considering only what it printed
+// since the previous prompt, then writes Send. The test fails if a prompt
+// doesn't show up within 5 seconds.
+func Interactive(prompts ...Prompt) StdinSource {
+	return sourceFunc(func(c *Cmd, w io.Writer) error {
+		for _, p := range prompts {
+			if err := c.waitForPrompt(regexp.MustCompile(p.Expect), Scaled(defaultPromptTimeout)); err != nil {
+				return err
+			}
+			if err := c.writeStdin(w, []byte(p.Send)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// KeepOpen keeps stdin open until the command exits, instead of closing it
+// after the last source.
+func KeepOpen() StdinSource {
+	return sourceFunc(func(c *Cmd, w io.Writer) error {
+		<-c.done
+		return nil
+	})
+}
+
+// promptError is reported when a prompt didn't show up.
+type promptError struct {
+	prompt  string
+	timeout time.Duration
+	exited  bool
+	// reason tells how the command ended, see endReason().
+	reason string
+}
+
+func (e *promptError) Error() string {
+	if e.exited && e.reason != "" {
+		return fmt.Sprintf("The command exited before prompting %q: %s", e.prompt, e.reason)
+	}
+	if e.exited {
+		return fmt.Sprintf("The command exited before prompting %q", e.prompt)
+	}
+	return fmt.Sprintf("Expected the command to prompt %q within %s", e.prompt, e.timeout)
+}
+
+// waitForPrompt waits for stdout, past the end of the previous prompt, to
+// match re within timeout, and moves the prompt offset past the match.
+func (c *Cmd) waitForPrompt(re *regexp.Regexp, timeout time.Duration) error {
+	interval := 10 * time.Millisecond
+	if c.maxPollInterval > 0 {
+		interval = c.maxPollInterval
+	}
+	deadline := time.After(timeout)
+	backoff := newBackoff(interval)
+	defer backoff.stop()
+	started := time.Now()
+	progress, stopProgress := c.progressTicker()
+	defer stopProgress()
+	for {
+		changed := c.outputChanged.changed()
+		content, base := c.promptWindow()
+		if loc := re.FindStringIndex(content); loc != nil {
+			c.promptOffset = base + loc[1]
+			return nil
+		}
+
+		tick := backoff.next()
+		for tick != nil {
+			select {
+			case <-tick:
+				tick = nil
+			case <-changed:
+				tick, changed = backoff.reset(), nil
+			case <-c.done:
+				// Give the capture a last chance to catch up.
+				c.capture.Wait()
+				content, base := c.promptWindow()
+				if loc := re.FindStringIndex(content); loc != nil {
+					c.promptOffset = base + loc[1]
+					return nil
+				}
+				reason, _ := c.endReason()
+				return &promptError{prompt: re.String(), exited: true, reason: reason}
+			case <-deadline:
+				return &promptError{prompt: re.String(), timeout: timeout}
+			case <-c.testDone:
+				return errTestOver
+			case <-progress:
+				c.logWaitProgress(fmt.Sprintf("the prompt %q", re), started)
+			}
+		}
+	}
+}
+
+// promptWindow returns stdout past the end of the previous prompt, or what's
+// left of it after KeepTail(), and the offset it starts at.
+func (c *Cmd) promptWindow() (string, int) {
+	c.stdout.mu.Lock()
+	content, dropped := c.stdout.text(), c.stdout.dropped
+	c.stdout.mu.Unlock()
+	from := c.promptOffset - dropped
+	if from < 0 {
+		from = 0
+	}
+	if from > len(content) {
+		return "", c.promptOffset
+	}
+	return content[from:], dropped + from
+}
+
+// StdinPipe returns a pipe to the command's stdin, to write to while it runs,
+// e.g. to answer a prompt once it shows up. See WriteStdin(). It's closed by
+// Wait() and Kill(), so that the command sees EOF rather than waiting for
+// more input, and writing to it fails once the command exited. It must be
+// called before Run() or Start(), and after SetStdinSequence(), if used: the
+// pipe is fed after its sources.
+func (c *Cmd) StdinPipe() io.WriteCloser {
+	if c.stdinPipe == nil {
+		r, w := io.Pipe()
+		c.stdinPipe = w
+		c.stdinSequence = append(c.stdinSequence, sourceFunc(func(c *Cmd, stdin io.Writer) error {
+			go func() {
+				<-c.done
+				r.CloseWithError(ErrStdinAborted)
+			}()
+			return FromReader(r).feed(c, stdin)
+		}))
+	}
+	return c.stdinPipe
+}
+
+// WriteStdin writes s to the stdin of the running command. It requires
+// StdinPipe() to be called before Run() or Start().
+func (c *Cmd) WriteStdin(s string) {
+	c.t.Helper()
+	c.validateHasStarted()
+	if c.stdinPipe == nil {
+		c.t.Fatal("WriteStdin() requires StdinPipe() to be called before the command starts")
+	}
+	if exited, code, _ := c.Exited(); exited {
+		c.fatalf("Failed to write %q to stdin: the command exited with %d", s, code)
+	}
+	if _, err := io.WriteString(c.stdinPipe, s); err != nil {
+		c.fatalf("Failed to write %q to stdin: %s", s, err)
+	}
+}
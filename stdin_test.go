@@ -0,0 +1,210 @@
+package testcli
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStdinTranscript(t *testing.T) {
+	c := Command(t, "cat")
+	c.SetStdin(strings.NewReader("hello\n"))
+	c.RecordStdin()
+	c.Run()
+
+	transcript := c.StdinTranscript()
+	if len(transcript) != 1 || transcript[0].Data != "hello\n" {
+		t.Fatalf("Expected transcript to hold %q, got %v", "hello\n", transcript)
+	}
+
+	if !c.StdoutContains("hello") {
+		t.Fatalf("Expected %q to contain %q", c.Stdout(), "hello")
+	}
+
+	if c.StdoutContains("hello", ExcludingEcho()) {
+		t.Fatalf("Expected echoed %q to be excluded", "hello")
+	}
+}
+
+func TestExcludingEcho(t *testing.T) {
+	c := Command(t, "/bin/bash", "-c", `read x; echo "$x"; echo "answer: $x"`)
+	c.SetStdin(strings.NewReader("hello\n"))
+	c.RecordStdin()
+	c.Start()
+	c.Wait()
+
+	if !c.StdoutContains("answer: hello", ExcludingEcho()) {
+		t.Fatalf("Expected %q to contain %q", c.Stdout(), "answer: hello")
+	}
+
+	if c.StdoutMatches("^hello", ExcludingEcho()) {
+		t.Fatalf("Expected echo to be excluded from %q", c.Stdout())
+	}
+
+	if !c.StdoutMatches("^hello") {
+		t.Fatalf("Expected %q to match %q", c.Stdout(), "^hello")
+	}
+}
+
+func TestStdinNotRecordedByDefault(t *testing.T) {
+	c := Command(t, "cat")
+	c.SetStdin(strings.NewReader("hello\n"))
+	c.Run()
+
+	if transcript := c.StdinTranscript(); len(transcript) != 0 {
+		t.Fatalf("Expected empty transcript, got %v", transcript)
+	}
+}
+
+func TestSetStdinSequence(t *testing.T) {
+	ws := NewWorkspace(t)
+	ws.WriteFile("dump.sql", []byte("INSERT 1;\nINSERT 2;\nCOMMIT;\n"))
+
+	c := ws.Command("/bin/bash", "-c", `
+while IFS= read -r line; do
+	echo "loaded: $line"
+	[ "$line" = "COMMIT;" ] && break
+done
+printf "Apply? [y/N] "
+read answer
+echo "answer=$answer"
+printf "Sure? [y/N] "
+read answer
+echo "answer=$answer"
+`)
+	c.SetStdinSequence(
+		FromFile(ws.Path("dump.sql")),
+		Interactive(Respond(`Apply\? \[y/N\] $`, "y\n"), Respond(`Sure\?`, "n\n")),
+	)
+	c.Run()
+	if !c.Success() {
+		t.Fatalf("Expected to succeed, but failed with error: %s", c.Error())
+	}
+
+	expected := "loaded: COMMIT;\nApply? [y/N] answer=y\nSure? [y/N] answer=n\n"
+	if !c.StdoutContains(expected) {
+		t.Fatalf("Expected %q to contain %q", c.Stdout(), expected)
+	}
+}
+
+func TestStdinSequenceHandoff(t *testing.T) {
+	c := Command(t, "/bin/bash", "-c", `
+head -c 4; echo
+echo ready
+read line; echo "line=$line"
+cat`)
+	c.RecordStdin()
+	c.SetStdinSequence(
+		FirstBytes(4, FromString("abcdefgh")),
+		WaitForPrompt("(?m)^ready$"),
+		FromString("after\n"),
+		FromString("rest"),
+	)
+	c.Run()
+
+	expected := "abcd\nready\nline=after\nrest"
+	if c.Stdout() != expected {
+		t.Fatalf("Expected %q to be %q", c.Stdout(), expected)
+	}
+
+	transcript := c.StdinTranscript()
+	if len(transcript) != 3 || transcript[0].Data != "abcd" {
+		t.Fatalf("Expected 3 writes, starting with %q, got %v", "abcd", transcript)
+	}
+}
+
+func TestStdinSequenceKeepOpen(t *testing.T) {
+	c := Command(t, "cat")
+	c.SetStdinSequence(FromString("hello\n"), KeepOpen())
+	c.Start()
+	if !c.StdoutContains("hello") {
+		t.Fatalf("Expected %q to contain %q", c.Stdout(), "hello")
+	}
+
+	// cat would have exited on EOF.
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case <-c.done:
+		t.Fatalf("Expected stdin to be kept open")
+	default:
+	}
+	c.Kill()
+}
+
+func TestStdinSequenceMissingPrompt(t *testing.T) {
+	c := Command(t, "/bin/bash", "-c", "echo no prompt here")
+	c.SetStdinSequence(Interactive(Respond("password:", "hunter2\n")))
+	c.Start()
+	<-c.done
+	c.capture.Wait()
+
+	if !poll(func() bool { return c.StdinError() != nil }, 10*time.Millisecond, time.Second) {
+		t.Fatalf("Expected the missing prompt to be reported")
+	}
+	if !strings.Contains(c.StdinError().Error(), "exited before prompting") {
+		t.Fatalf("Expected an error about the missing prompt, got %q", c.StdinError())
+	}
+}
+
+func TestStdinSequenceAbortedOnExit(t *testing.T) {
+	// Reads half of the input and exits, leaving a background child holding
+	// stdin open without reading it, so that writes would block forever.
+	c := Command(t, "/bin/sh", "-c", `exec 3<&0; head -c 65536 >/dev/null; (exec sleep 2 <&3 >/dev/null 2>&1) & exit 1`)
+	c.SetStdinSequence(FromString(strings.Repeat("x", 1<<20)))
+	c.Start()
+	<-c.done
+
+	select {
+	case <-c.stdinFed:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("Expected the stdin feeder to stop once the command exited")
+	}
+	c.Wait()
+	if err := c.StdinError(); err != ErrStdinAborted {
+		t.Fatalf("Expected %v, got %v", ErrStdinAborted, err)
+	}
+}
+
+func TestWriteStdin(t *testing.T) {
+	script := writeScript(t, `
+printf 'Continue? [y/N] '
+read answer
+echo "continuing: $answer"
+printf 'Really? [y/N] '
+read answer
+echo "really: $answer"
+`)
+	c := Command(t, "/bin/sh", script)
+	c.StdinPipe()
+	c.Start()
+	c.WaitForStdout("Continue? [y/N]", time.Second)
+	c.WriteStdin("y\n")
+	c.WaitForStdout("Really? [y/N]", time.Second)
+	c.WriteStdin("n\n")
+	c.Wait()
+
+	expected := "Continue? [y/N] continuing: y\nReally? [y/N] really: n\n"
+	if c.Stdout() != expected {
+		t.Fatalf("Expected %q to be %q", c.Stdout(), expected)
+	}
+}
+
+func TestStdinPipeClosedByWait(t *testing.T) {
+	c := Command(t, "cat")
+	c.StdinPipe()
+	c.Start()
+	c.WriteStdin("partial")
+	c.Wait()
+	if c.Stdout() != "partial" {
+		t.Fatalf("Expected %q to be %q", c.Stdout(), "partial")
+	}
+}
+
+func TestStdinPipeAfterExit(t *testing.T) {
+	c := Command(t, "true")
+	w := c.StdinPipe()
+	c.Run()
+	if _, err := w.Write([]byte("too late\n")); err == nil {
+		t.Fatalf("Expected writing after the command exited to fail")
+	}
+}
@@ -0,0 +1,227 @@
+package testcli
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// StdioConn is a net.Conn over the stdin and stdout of a running command, to
+// drive it with a client speaking its protocol, e.g. a language server. See
+// Cmd.StdioConn().
+type StdioConn struct {
+	c *Cmd
+
+	mu     sync.Mutex
+	buf    []byte
+	eof    bool
+	closed bool
+	// arrived is closed, and replaced, whenever pending reads must check
+	// again, e.g. when something is fed.
+	arrived chan struct{}
+
+	readDeadline  time.Time
+	writeDeadline time.Time
+
+	terminateOnClose bool
+}
+
+// StdioConnOption configures a StdioConn.
+type StdioConnOption func(*StdioConn)
+
+// TerminateOnClose makes Close() also stop the command, as WithTimeout()
+// would once it times out: SIGTERM then SIGKILL 2 seconds later.
+func TerminateOnClose() StdioConnOption {
+	return func(conn *StdioConn) {
+		conn.terminateOnClose = true
+	}
+}
+
+// StdioConn returns a connection writing to the command's stdin and reading
+// from its stdout, honoring deadlines. Stdout is still captured as usual:
+// the connection reads its own copy of it, so that matchers and the
+// connection see the same output. Closing the connection closes stdin. It
+// must be called before Run() or Start(), and replaces StdinPipe().
+func (c *Cmd) StdioConn(opts ...StdioConnOption) *StdioConn {
+	if c.stdout.conn == nil {
+		conn := &StdioConn{c: c, arrived: make(chan struct{})}
+		c.StdinPipe()
+		c.stdout.conn = conn
+	}
+	for _, opt := range opts {
+		opt(c.stdout.conn)
+	}
+	return c.stdout.conn
+}
+
+// feed makes p available to Read().
+func (conn *StdioConn) feed(p []byte) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if !conn.closed {
+		conn.buf = append(conn.buf, p...)
+	}
+	conn.broadcast()
+}
+
+// feedEOF makes Read() report io.EOF once what was fed is read.
+func (conn *StdioConn) feedEOF() {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	conn.eof = true
+	conn.broadcast()
+}
+
+// Read reads from the command's stdout. It returns io.EOF once the command
+// closed its stdout, and os.ErrDeadlineExceeded past the read deadline.
+func (conn *StdioConn) Read(p []byte) (int, error) {
+	for {
+		conn.mu.Lock()
+		switch {
+		case conn.closed:
+			conn.mu.Unlock()
+			return 0, net.ErrClosed
+		case len(conn.buf) > 0:
+			n := copy(p, conn.buf)
+			conn.buf = conn.buf[n:]
+			conn.mu.Unlock()
+			return n, nil
+		case conn.eof:
+			conn.mu.Unlock()
+			return 0, io.EOF
+		}
+		arrived, deadline := conn.arrived, conn.readDeadline
+		conn.mu.Unlock()
+
+		if err := waitUntil(arrived, deadline); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Write writes to the command's stdin, failing with os.ErrDeadlineExceeded
+// past the write deadline. A write that timed out may still complete, like
+// on a network connection.
+func (conn *StdioConn) Write(p []byte) (int, error) {
+	conn.mu.Lock()
+	closed, deadline := conn.closed, conn.writeDeadline
+	conn.mu.Unlock()
+	if closed {
+		return 0, net.ErrClosed
+	}
+
+	type result struct {
+		n   int
+		err error
+	}
+	written := make(chan result, 1)
+	go func() {
+		n, err := conn.c.stdinPipe.Write(p)
+		written <- result{n, err}
+	}()
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case r := <-written:
+		return r.n, r.err
+	case <-timeout:
+		return 0, os.ErrDeadlineExceeded
+	}
+}
+
+// waitUntil waits for ch to be closed, failing with os.ErrDeadlineExceeded
+// past deadline, unless it's zero.
+func waitUntil(ch <-chan struct{}, deadline time.Time) error {
+	if deadline.IsZero() {
+		<-ch
+		return nil
+	}
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-ch:
+		return nil
+	case <-timer.C:
+		return os.ErrDeadlineExceeded
+	}
+}
+
+// Close closes the command's stdin, and stops the command if
+// TerminateOnClose() was set. Pending reads fail with net.ErrClosed.
+func (conn *StdioConn) Close() error {
+	conn.mu.Lock()
+	if conn.closed {
+		conn.mu.Unlock()
+		return net.ErrClosed
+	}
+	conn.closed = true
+	conn.buf = nil
+	conn.broadcast()
+	conn.mu.Unlock()
+
+	err := conn.c.stdinPipe.Close()
+	if conn.terminateOnClose {
+		if exited, _, _ := conn.c.Exited(); !exited {
+			conn.c.terminate(defaultTermination(), TerminationReason{})
+		}
+	}
+	return err
+}
+
+// LocalAddr returns the address of the harness end of the connection.
+func (conn *StdioConn) LocalAddr() net.Addr {
+	return stdioAddr("testcli")
+}
+
+// RemoteAddr returns the address of the command end of the connection, its
+// command line.
+func (conn *StdioConn) RemoteAddr() net.Addr {
+	return stdioAddr(conn.c.Repro())
+}
+
+// SetDeadline sets the read and write deadlines.
+func (conn *StdioConn) SetDeadline(t time.Time) error {
+	conn.mu.Lock()
+	conn.readDeadline, conn.writeDeadline = t, t
+	// Pending reads must notice the new deadline.
+	conn.broadcast()
+	conn.mu.Unlock()
+	return nil
+}
+
+// SetReadDeadline sets the deadline of Read(), none if zero.
+func (conn *StdioConn) SetReadDeadline(t time.Time) error {
+	conn.mu.Lock()
+	conn.readDeadline = t
+	conn.broadcast()
+	conn.mu.Unlock()
+	return nil
+}
+
+// SetWriteDeadline sets the deadline of Write(), none if zero.
+func (conn *StdioConn) SetWriteDeadline(t time.Time) error {
+	conn.mu.Lock()
+	conn.writeDeadline = t
+	conn.mu.Unlock()
+	return nil
+}
+
+// broadcast wakes up pending reads. Callers must hold conn.mu.
+func (conn *StdioConn) broadcast() {
+	close(conn.arrived)
+	conn.arrived = make(chan struct{})
+}
+
+// stdioAddr is the address of an end of a StdioConn.
+type stdioAddr string
+
+func (a stdioAddr) Network() string { return "stdio" }
+func (a stdioAddr) String() string  { return string(a) }
+
+var _ net.Conn = (*StdioConn)(nil)
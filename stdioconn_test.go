@@ -0,0 +1,67 @@
+package testcli
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestStdioConn(t *testing.T) {
+	c := Command(t, "cat")
+	conn := c.StdioConn()
+	c.Start()
+
+	if _, err := conn.Write([]byte("ping\n")); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Fatalf("Expected to read %q, got %q (%v)", "ping\n", line, err)
+	}
+	if !c.StdoutContains("ping") {
+		t.Fatalf("Expected the capture to see %q too, got %q", "ping", c.Stdout())
+	}
+
+	conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Expected the read to time out, got %v", err)
+	}
+
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("Expected reads to fail once closed, got %v", err)
+	}
+	c.Wait()
+	if !c.Success() {
+		t.Fatalf("Expected cat to exit on EOF, got %s", c.Error())
+	}
+}
+
+func TestStdioConnEOF(t *testing.T) {
+	c := Command(t, "echo", "hello")
+	conn := c.StdioConn()
+	c.Start()
+	content, err := ioutil.ReadAll(conn)
+	if err != nil || string(content) != "hello\n" {
+		t.Fatalf("Expected to read %q until EOF, got %q (%v)", "hello\n", content, err)
+	}
+	c.Wait()
+}
+
+func TestStdioConnTerminateOnClose(t *testing.T) {
+	c := Command(t, "sleep", "10")
+	conn := c.StdioConn(TerminateOnClose())
+	c.Start()
+	conn.Close()
+	c.Wait()
+	if reason := c.TerminationReason(); reason.Signal != syscall.SIGTERM || reason.Killed {
+		t.Fatalf("Expected the command to be stopped by SIGTERM, got %+v", reason)
+	}
+}
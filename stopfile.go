@@ -0,0 +1,72 @@
+package testcli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// StopFile is a file whose creation asks a command to shut down, for
+// commands polling for it where signals aren't available.
+type StopFile struct {
+	t    *testing.T
+	path string
+}
+
+// StopFile allocates the path of a stop file, which doesn't exist yet, and
+// passes it to the command with flag, completing an argument made of the flag
+// followed by "=", or appending "flag=path". The path is in the workspace of
+// the command, if it runs in one. It must be called before Run() or Start().
+func (c *Cmd) StopFile(flag string) *StopFile {
+	c.t.Helper()
+	s := c.newStopFile()
+	c.setFlag(flag, s.path)
+	return s
+}
+
+// StopFileEnv is like StopFile(), passing the path in the environment
+// variable name instead.
+func (c *Cmd) StopFileEnv(name string) *StopFile {
+	c.t.Helper()
+	s := c.newStopFile()
+	c.overrideEnv(name + "=" + s.path)
+	return s
+}
+
+func (c *Cmd) newStopFile() *StopFile {
+	c.t.Helper()
+	return &StopFile{t: c.t, path: filepath.Join(c.tempDir(".testcli-stop"), "stop")}
+}
+
+// Path returns the path of the stop file.
+func (s *StopFile) Path() string {
+	return s.path
+}
+
+// Trigger creates the stop file. It's created under another name then
+// renamed, so that the command never sees it partially written.
+func (s *StopFile) Trigger() {
+	s.t.Helper()
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte("stop\n"), 0644); err != nil {
+		s.t.Fatal(err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+// AssertExitsAfter calls trigger, e.g. a StopFile's Trigger, then fails the
+// test unless the command exits within the given time with one of codes, 0
+// if none.
+func (c *Cmd) AssertExitsAfter(trigger func(), within time.Duration, codes ...int) {
+	c.t.Helper()
+	c.validateHasStarted()
+	if len(codes) == 0 {
+		codes = []int{0}
+	}
+	trigger()
+	c.AssertExitsOneOf(within, codes...)
+}
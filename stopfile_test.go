@@ -0,0 +1,43 @@
+package testcli
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const stopFileLoop = `echo started; while [ ! -e "$STOP" ]; do sleep 0.02; done; echo stopping`
+
+func TestStopFile(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", `STOP=${1#--stop-file=}; `+stopFileLoop, "sh", "--stop-file=")
+	stop := c.StopFile("--stop-file")
+	c.Start()
+	if !c.StdoutContains("started") {
+		c.Kill()
+		t.Fatalf("Expected %q to contain %q", c.Stdout(), "started")
+	}
+
+	c.AssertExitsAfter(stop.Trigger, 2*time.Second)
+	if c.Stdout() != "started\nstopping\n" {
+		t.Fatalf("Expected a graceful shutdown, got %q", c.Stdout())
+	}
+}
+
+func TestStopFileEnv(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", stopFileLoop+"; exit 4")
+	stop := c.StopFileEnv("STOP")
+	c.Start()
+	c.AssertExitsAfter(stop.Trigger, 2*time.Second, 4)
+}
+
+func TestStopFileInWorkspace(t *testing.T) {
+	ws := NewWorkspace(t)
+	c := ws.Command("/bin/sh", "-c", stopFileLoop)
+	stop := c.StopFileEnv("STOP")
+	if rel, err := filepath.Rel(ws.Root(), stop.Path()); err != nil || strings.HasPrefix(rel, "..") {
+		t.Fatalf("Expected %q to be in the workspace %q", stop.Path(), ws.Root())
+	}
+	c.Start()
+	c.AssertExitsAfter(stop.Trigger, 2*time.Second)
+}
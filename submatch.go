@@ -0,0 +1,166 @@
+package testcli
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// submatchTailLines is how many lines of output a failed submatch shows when
+// no part of the regex matches.
+const submatchTailLines = 10
+
+// StdoutFindSubmatch returns the leftmost match of regex in the command's
+// STDOUT followed by its submatches, as regexp.FindStringSubmatch() does,
+// e.g. to extract a generated ID for the next command, and whether it
+// matched. The match is case insensitive unless CaseSensitive() is passed,
+// but the submatches keep their case. On a running command, it retries as
+// StdoutMatches() does.
+func (c *Cmd) StdoutFindSubmatch(regex string, opts ...MatchOption) ([]string, bool) {
+	c.t.Helper()
+	match, _, err := c.findSubmatch(c.stdout, "stdout", regex, opts)
+	return match, err == nil
+}
+
+// StdoutFindSubmatch returns the leftmost match of regex in the command's
+// STDOUT followed by its submatches, and whether it matched.
+func StdoutFindSubmatch(regex string, opts ...MatchOption) ([]string, bool) {
+	c := pkgCmd()
+	c.t.Helper()
+	return c.StdoutFindSubmatch(regex, opts...)
+}
+
+// StderrFindSubmatch is StdoutFindSubmatch() for STDERR.
+func (c *Cmd) StderrFindSubmatch(regex string, opts ...MatchOption) ([]string, bool) {
+	c.t.Helper()
+	match, _, err := c.findSubmatch(c.stderr, "stderr", regex, opts)
+	return match, err == nil
+}
+
+// StderrFindSubmatch returns the leftmost match of regex in the command's
+// STDERR followed by its submatches, and whether it matched.
+func StderrFindSubmatch(regex string, opts ...MatchOption) ([]string, bool) {
+	c := pkgCmd()
+	c.t.Helper()
+	return c.StderrFindSubmatch(regex, opts...)
+}
+
+// StdoutFindNamedSubmatch is like StdoutFindSubmatch(), returning the named
+// groups of regex by name, e.g. "id" for `(?P<id>\d+)`.
+func (c *Cmd) StdoutFindNamedSubmatch(regex string, opts ...MatchOption) (map[string]string, bool) {
+	c.t.Helper()
+	match, re, err := c.findSubmatch(c.stdout, "stdout", regex, opts)
+	return namedSubmatches(re, match), err == nil
+}
+
+// StdoutFindNamedSubmatch returns the named groups of the leftmost match of
+// regex in the command's STDOUT, and whether it matched.
+func StdoutFindNamedSubmatch(regex string, opts ...MatchOption) (map[string]string, bool) {
+	c := pkgCmd()
+	c.t.Helper()
+	return c.StdoutFindNamedSubmatch(regex, opts...)
+}
+
+// StderrFindNamedSubmatch is StdoutFindNamedSubmatch() for STDERR.
+func (c *Cmd) StderrFindNamedSubmatch(regex string, opts ...MatchOption) (map[string]string, bool) {
+	c.t.Helper()
+	match, re, err := c.findSubmatch(c.stderr, "stderr", regex, opts)
+	return namedSubmatches(re, match), err == nil
+}
+
+// StderrFindNamedSubmatch returns the named groups of the leftmost match of
+// regex in the command's STDERR, and whether it matched.
+func StderrFindNamedSubmatch(regex string, opts ...MatchOption) (map[string]string, bool) {
+	c := pkgCmd()
+	c.t.Helper()
+	return c.StderrFindNamedSubmatch(regex, opts...)
+}
+
+// AssertStdoutFindSubmatch is StdoutFindSubmatch(), failing the test unless
+// regex matches. The failure shows the longest prefix of regex which
+// matches, to tell where the pattern stops matching.
+func (c *Cmd) AssertStdoutFindSubmatch(regex string, opts ...MatchOption) []string {
+	c.t.Helper()
+	match, _, err := c.findSubmatch(c.stdout, "stdout", regex, opts)
+	c.assert(err)
+	return match
+}
+
+// AssertStderrFindSubmatch is AssertStdoutFindSubmatch() for STDERR.
+func (c *Cmd) AssertStderrFindSubmatch(regex string, opts ...MatchOption) []string {
+	c.t.Helper()
+	match, _, err := c.findSubmatch(c.stderr, "stderr", regex, opts)
+	c.assert(err)
+	return match
+}
+
+// findSubmatch finds the leftmost match of regex in o, named stream in
+// messages, retrying while the command runs. It returns the regex as
+// compiled, and an error explaining the closest match if there's none.
+func (c *Cmd) findSubmatch(o *output, stream, regex string, opts []MatchOption) ([]string, *regexp.Regexp, error) {
+	c.t.Helper()
+	c.validateHasStarted()
+	cfg := matchOptions(opts)
+	// The content is matched as is, rather than lowered, so that the
+	// submatches keep their case.
+	pattern := regex
+	if !cfg.caseSensitive {
+		pattern = "(?i)" + regex
+	}
+	re := regexp.MustCompile(pattern)
+	var match []string
+	find := func() bool {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		match = re.FindStringSubmatch(o.text())
+		return match != nil
+	}
+	var err error
+	if c.awaitFinal(opts) {
+		err = c.waitFor(fmt.Sprintf("%s to match %q", stream, regex), find, defaultPollInterval, Scaled(defaultPollTimeout))
+	} else {
+		err = fmt.Errorf("timed out waiting for the command to exit")
+	}
+	if err != nil {
+		return nil, re, fmt.Errorf("Expected %s to match %q, %s", stream, regex, closestMatch(o, regex, !cfg.caseSensitive))
+	}
+	o.examineMatch(re, false)
+	return match, re, nil
+}
+
+// closestMatch describes the longest prefix of regex which matches the
+// content of o, or its last lines if none does.
+func closestMatch(o *output, regex string, fold bool) string {
+	o.mu.Lock()
+	content := o.text()
+	o.mu.Unlock()
+	for i := len(regex) - 1; i > 0; i-- {
+		prefix := regex[:i]
+		pattern := prefix
+		if fold {
+			pattern = "(?i)" + prefix
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			continue
+		}
+		if loc := re.FindStringIndex(content); loc != nil && loc[1] > loc[0] {
+			return fmt.Sprintf("the longest prefix which matches is %q, matching %q", prefix, content[loc[0]:loc[1]])
+		}
+	}
+	return fmt.Sprintf("no prefix of it matches the last lines:\n%s", tailLines(o, submatchTailLines))
+}
+
+// namedSubmatches maps the named groups of re to their submatches in match,
+// nil if there's no match.
+func namedSubmatches(re *regexp.Regexp, match []string) map[string]string {
+	if match == nil {
+		return nil
+	}
+	named := map[string]string{}
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			named[name] = match[i]
+		}
+	}
+	return named
+}
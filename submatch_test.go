@@ -0,0 +1,48 @@
+package testcli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindSubmatch(t *testing.T) {
+	c := Command(t, "sh", "-c", "echo 'Created user 4821 (Alice)'; echo 'warning: slow disk' >&2")
+	c.Run()
+	match, ok := c.StdoutFindSubmatch(`created user (\d+) \((\w+)\)`)
+	if want := []string{"Created user 4821 (Alice)", "4821", "Alice"}; !ok || !reflect.DeepEqual(match, want) {
+		t.Fatalf("Expected %q, got %q", want, match)
+	}
+	if _, ok := c.StdoutFindSubmatch(`created user (\d+)`, CaseSensitive()); ok {
+		t.Fatalf("Expected the case sensitive match to fail")
+	}
+	named, ok := c.StderrFindNamedSubmatch(`(?P<level>\w+): slow (?P<what>\w+)`)
+	if want := map[string]string{"level": "warning", "what": "disk"}; !ok || !reflect.DeepEqual(named, want) {
+		t.Fatalf("Expected %q, got %q", want, named)
+	}
+	if named, ok := c.StdoutFindNamedSubmatch(`(?P<id>\d+) \(Bob\)`); ok || named != nil {
+		t.Fatalf("Expected no match, got %q", named)
+	}
+}
+
+func TestFindSubmatchOnRunningCommand(t *testing.T) {
+	c := Command(t, "sh", "-c", "sleep 0.2; echo id=abc123; exec sleep 5")
+	c.Start()
+	defer c.Kill()
+	if id := c.AssertStdoutFindSubmatch(`id=(\w+)`)[1]; id != "abc123" {
+		t.Fatalf("Expected id %q, got %q", "abc123", id)
+	}
+}
+
+func TestClosestMatch(t *testing.T) {
+	c := Command(t, "echo", "created user 4821 (Alice)")
+	c.Run()
+	_, _, err := c.findSubmatch(c.stdout, "stdout", `created user (\d+) in (\w+)`, nil)
+	if err == nil || !strings.Contains(err.Error(), `the longest prefix which matches is "created user (\\d+) ", matching "created user 4821 "`) {
+		t.Fatalf("Expected the closest match to be explained, got %v", err)
+	}
+	_, _, err = c.findSubmatch(c.stdout, "stdout", `^deleted`, nil)
+	if err == nil || !strings.Contains(err.Error(), "no prefix of it matches the last lines:\ncreated user 4821 (Alice)") {
+		t.Fatalf("Expected the output to be shown, got %v", err)
+	}
+}
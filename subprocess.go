@@ -0,0 +1,147 @@
+package testcli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Separators of the subprocess log: each invocation is a record of fields,
+// the program name then its arguments.
+const (
+	subprocessFieldSep  = "\x1f"
+	subprocessRecordSep = "\x1e"
+)
+
+// RecordSubprocesses makes the command record its invocations of the
+// programs names, which it must look up through PATH: each one is shadowed
+// by a stub logging the invocation before running the real program. See
+// SubprocessCount() and AssertSubprocessBudget().
+//
+// Invocations by absolute path, or by a child resetting PATH, aren't
+// counted. The stubs are shell scripts, so the test is skipped on Windows.
+// It must be called before Run() or Start().
+func (c *Cmd) RecordSubprocesses(names ...string) {
+	c.t.Helper()
+	if runtime.GOOS == "windows" {
+		c.t.Skip("RecordSubprocesses isn't supported on Windows")
+	}
+	dir, err := ioutil.TempDir("", "testcli-stubs")
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	c.subprocessLog = filepath.Join(dir, "invocations")
+	if err := ioutil.WriteFile(c.subprocessLog, nil, 0644); err != nil {
+		c.t.Fatal(err)
+	}
+
+	for _, name := range names {
+		real, err := exec.LookPath(name)
+		if err != nil {
+			c.t.Fatalf("Can't record the invocations of %s: %s", name, err)
+		}
+		// A single printf, so that concurrent invocations append whole
+		// records.
+		script := "#!/bin/sh\n" +
+			"format=''\n" +
+			"for arg in " + shellQuote(name) + ` "$@"; do format="$format%s\037"; done` + "\n" +
+			`printf "$format\036" ` + shellQuote(name) + ` "$@" >> ` + shellQuote(c.subprocessLog) + "\n" +
+			"exec " + shellQuote(real) + ` "$@"` + "\n"
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			c.t.Fatal(err)
+		}
+		c.subprocessNames = append(c.subprocessNames, name)
+	}
+
+	c.pathPrefix = append([]string{dir}, c.pathPrefix...)
+}
+
+// subprocesses returns the recorded invocations, each one the program name
+// followed by its arguments.
+func (c *Cmd) subprocesses() [][]string {
+	c.t.Helper()
+	if c.subprocessLog == "" {
+		c.t.Fatal("Counting subprocesses requires RecordSubprocesses()")
+	}
+	content, err := ioutil.ReadFile(c.subprocessLog)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	var invocations [][]string
+	for _, record := range strings.Split(string(content), subprocessRecordSep) {
+		if record == "" {
+			continue
+		}
+		invocations = append(invocations, strings.Split(strings.TrimSuffix(record, subprocessFieldSep), subprocessFieldSep))
+	}
+	return invocations
+}
+
+// SubprocessCount returns how many times the finished command ran name,
+// which must have been passed to RecordSubprocesses().
+func (c *Cmd) SubprocessCount(name string) int {
+	c.t.Helper()
+	c.validateIsFinished()
+	if !containsString(c.subprocessNames, name) {
+		c.t.Fatalf("%s isn't recorded, see RecordSubprocesses()", name)
+	}
+	count := 0
+	for _, invocation := range c.subprocesses() {
+		if invocation[0] == name {
+			count++
+		}
+	}
+	return count
+}
+
+// AssertSubprocessBudget fails the test if the finished command ran any of
+// the programs in budget more times than allowed, listing the invocations of
+// the programs over budget. The programs must have been passed to
+// RecordSubprocesses(), it fails otherwise.
+func (c *Cmd) AssertSubprocessBudget(budget map[string]int) {
+	c.t.Helper()
+	c.validateIsFinished()
+	if err := c.checkSubprocessBudget(budget); err != nil {
+		c.fatalf("%s", err)
+	}
+}
+
+func (c *Cmd) checkSubprocessBudget(budget map[string]int) error {
+	c.t.Helper()
+	invocations := c.subprocesses()
+	names := make([]string, 0, len(budget))
+	for name := range budget {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	for _, name := range names {
+		if !containsString(c.subprocessNames, name) {
+			problems = append(problems, fmt.Sprintf("%s isn't recorded, see RecordSubprocesses()", name))
+			continue
+		}
+		var argvs []string
+		for _, invocation := range invocations {
+			if invocation[0] == name {
+				argvs = append(argvs, "  "+quoteArgs(invocation))
+			}
+		}
+		if len(argvs) > budget[name] {
+			problems = append(problems, fmt.Sprintf("Expected at most %d invocations of %s, got %d:\n%s",
+				budget[name], name, len(argvs), strings.Join(argvs, "\n")))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package testcli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSubprocessCount(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", `for i in 1 2 3; do echo "$i" | cat; done; ls "a b" 2>/dev/null; true`)
+	c.RecordSubprocesses("cat", "ls", "head")
+	c.Run()
+
+	for name, expected := range map[string]int{"cat": 3, "ls": 1, "head": 0} {
+		if got := c.SubprocessCount(name); got != expected {
+			t.Errorf("Expected %d invocations of %s, got %d", expected, name, got)
+		}
+	}
+	if c.Stdout() != "1\n2\n3\n" {
+		t.Fatalf("Expected the stubs to run the real programs, got %q", c.Stdout())
+	}
+	c.AssertSubprocessBudget(map[string]int{"cat": 3, "ls": 1})
+}
+
+func TestCheckSubprocessBudget(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", `ls "with space" "new
+line" 2>/dev/null; ls 2>/dev/null; true`)
+	c.RecordSubprocesses("ls")
+	c.Run()
+
+	err := c.checkSubprocessBudget(map[string]int{"ls": 1})
+	if err == nil {
+		t.Fatalf("Expected the budget to be exceeded")
+	}
+	if !strings.Contains(err.Error(), "got 2") || !strings.Contains(err.Error(), `ls 'with space' 'new
+line'`) {
+		t.Fatalf("Expected the invocations to be listed, got %q", err)
+	}
+}
+
+func TestCheckSubprocessBudgetUnrecorded(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", "ls >/dev/null")
+	c.RecordSubprocesses("ls")
+	c.Run()
+
+	err := c.checkSubprocessBudget(map[string]int{"ls": 1, "git": 3})
+	if err == nil || !strings.Contains(err.Error(), "git isn't recorded") {
+		t.Fatalf("Expected the unrecorded program to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package testcli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// KeepTail makes the command keep only the last lines of each output stream,
+// plus the line being written, for chatty commands whose output would
+// otherwise make every assertion slower than the previous one. Assertions
+// only see that window. It must be called before Run() or Start().
+//
+// Marks still work as long as they're within the window, see StdoutSince().
+// Chunks are dropped along with the lines, which AssertIncrementalOutput()
+// then doesn't count.
+func (c *Cmd) KeepTail(lines int) {
+	c.stdout.keep = lines
+	c.stderr.keep = lines
+}
+
+// SaveOutputTo writes the whole output of the command to the files stdout and
+// stderr in dir, regardless of KeepTail(). It must be called before Run() or
+// Start().
+func (c *Cmd) SaveOutputTo(dir string) {
+	c.t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		c.t.Fatal(err)
+	}
+	for name, o := range map[string]*output{"stdout": c.stdout, "stderr": c.stderr} {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		c.t.Cleanup(func() {
+			f.Close()
+		})
+		o.save = f
+	}
+}
+
+// trim drops the lines in excess of o.keep from the start of the content,
+// along with the chunks they were read in. Callers must hold o.mu.
+func (o *output) trim() {
+	excess := o.lines - o.keep
+	if excess <= 0 {
+		return
+	}
+	cut := 0
+	for i := 0; i < excess; i++ {
+		cut += strings.IndexByte(o.content[cut:], '\n') + 1
+	}
+	o.content = o.content[cut:]
+	o.lines = o.keep
+	o.dropped += cut
+
+	for cut > 0 && len(o.chunks) > 0 {
+		if o.chunks[0].n > cut {
+			o.chunks[0].n -= cut
+			break
+		}
+		cut -= o.chunks[0].n
+		o.chunks = o.chunks[1:]
+	}
+}
+
+// end returns the offset of the end of the content, counting the bytes
+// dropped by KeepTail(). Callers must hold o.mu.
+func (o *output) end() int {
+	return o.dropped + len(o.content)
+}
+
+// since returns the content past offset, counting the bytes dropped by
+// KeepTail(), filtered and collapsed as by text(). It fails if what's past offset was
+// dropped. Callers must hold o.mu.
+func (o *output) since(offset int) (string, error) {
+	if offset < o.dropped {
+		return "", fmt.Errorf("The output since byte %d was dropped, KeepTail(%d) only kept it from byte %d", offset, o.keep, o.dropped)
+	}
+	content := o.content[offset-o.dropped:]
+	if o.filter != nil {
+		content = o.filter(content)
+	}
+	if o.collapse {
+		content = collapseProgress(content)
+	}
+	return content, nil
+}
+
+// Mark is a position in the command's stdout.
+type Mark struct {
+	offset int
+}
+
+// MarkStdout returns the current end of stdout, to look at what comes next
+// with StdoutSince().
+func (c *Cmd) MarkStdout() Mark {
+	c.t.Helper()
+	c.validateHasStarted()
+	c.stdout.mu.Lock()
+	defer c.stdout.mu.Unlock()
+	return Mark{offset: c.stdout.end()}
+}
+
+// StdoutSince returns what the command printed to stdout since the mark.
+// It fails the test if that output was dropped by KeepTail().
+func (c *Cmd) StdoutSince(m Mark) string {
+	c.t.Helper()
+	c.validateHasStarted()
+	c.stdout.mu.Lock()
+	content, err := c.stdout.since(m.offset)
+	c.stdout.mu.Unlock()
+	if err != nil {
+		c.fatalf("%s", err)
+	}
+	return content
+}
+
+// ConsumeStdout returns what the command printed to stdout since the previous
+// call, or since it started. It fails the test if that output was dropped by
+// KeepTail().
+func (c *Cmd) ConsumeStdout() string {
+	c.t.Helper()
+	c.validateHasStarted()
+	c.stdout.mu.Lock()
+	content, err := c.stdout.since(c.consumed)
+	c.consumed = c.stdout.end()
+	c.stdout.mu.Unlock()
+	if err != nil {
+		c.fatalf("%s", err)
+	}
+	return content
+}
@@ -0,0 +1,75 @@
+package testcli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestKeepTail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testcli-tail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := Command(t, "seq", "10000")
+	c.KeepTail(3)
+	c.SaveOutputTo(dir)
+	c.Run()
+
+	if got := c.Stdout(); got != "9998\n9999\n10000\n" {
+		t.Fatalf("Expected only the last 3 lines, got %q", got)
+	}
+	full, err := ioutil.ReadFile(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(full), "\n") != 10000 {
+		t.Fatalf("Expected the whole output to be saved, got %d bytes", len(full))
+	}
+}
+
+func TestOutputTrim(t *testing.T) {
+	o := &output{mu: &sync.Mutex{}, keep: 2}
+	o.write([]byte("a\nb"))
+	o.write([]byte("\nc\nd"))
+
+	if o.content != "b\nc\nd" {
+		t.Fatalf("Expected %q, got %q", "b\nc\nd", o.content)
+	}
+	if o.chunks[0].n != 1 || o.chunks[1].n != 4 {
+		t.Fatalf("Expected the chunks to be trimmed along, got %+v", o.chunks)
+	}
+	if _, ok := o.arrival("d"); !ok {
+		t.Fatalf("Expected the arrival of the kept content to be known")
+	}
+
+	if _, err := o.since(1); err == nil {
+		t.Fatalf("Expected an error for a mark which fell off the tail")
+	}
+	if got, err := o.since(4); err != nil || got != "c\nd" {
+		t.Fatalf("Expected %q, got %q (%v)", "c\nd", got, err)
+	}
+}
+
+func TestConsumeStdout(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", "echo one; read x; echo two")
+	c.SetStdinSequence(WaitForPrompt("one"), FromString("\n"))
+	c.Start()
+	m := c.MarkStdout()
+	c.Wait()
+
+	if got := c.ConsumeStdout(); got != "one\ntwo\n" {
+		t.Fatalf("Expected %q, got %q", "one\ntwo\n", got)
+	}
+	if got := c.ConsumeStdout(); got != "" {
+		t.Fatalf("Expected nothing new, got %q", got)
+	}
+	if got := c.StdoutSince(m); !strings.HasSuffix(got, "two\n") {
+		t.Fatalf("Expected %q to end with %q", got, "two\n")
+	}
+}
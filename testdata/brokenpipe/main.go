@@ -0,0 +1,22 @@
+// Command brokenpipe prints lines forever, and panics when it fails to with
+// -panic, instead of being killed by SIGPIPE.
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	panicking := len(os.Args) > 1 && os.Args[1] == "-panic"
+	if panicking {
+		signal.Ignore(syscall.SIGPIPE)
+	}
+	for i := 0; ; i++ {
+		if _, err := fmt.Println("line", i); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+// Command fuzzme echoes its input, and crashes on "crash".
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+)
+
+func main() {
+	input, _ := ioutil.ReadAll(os.Stdin)
+	if bytes.Contains(input, []byte("crash")) {
+		panic("crashed on " + string(input))
+	}
+	os.Stdout.Write(input)
+}
@@ -0,0 +1,25 @@
+// Command memhog allocates memory until it's killed, or until it allocated
+// the number of megabytes given as argument.
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+func main() {
+	max := 1 << 20
+	if len(os.Args) > 1 {
+		max, _ = strconv.Atoi(os.Args[1])
+	}
+	var hog [][]byte
+	for i := 0; i < max; i++ {
+		chunk := make([]byte, 1<<20)
+		for j := range chunk {
+			chunk[j] = byte(j)
+		}
+		hog = append(hog, chunk)
+	}
+	fmt.Println(len(hog))
+}
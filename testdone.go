@@ -0,0 +1,51 @@
+package testcli
+
+import (
+	"errors"
+	"testing"
+)
+
+// errTestOver is returned by the waits which gave up because the test of
+// the command is over, so that nobody is left to report to.
+var errTestOver = errors.New("the test is over")
+
+// watchTest closes testDone once t is over. It's a cleanup rather than
+// t.Context(), which is done before the cleanups run: assertions made by
+// cleanups, e.g. on the output of a command stopped by one, must still
+// count. The cleanups registered after the command was created, such as
+// the ones stopping it, run first; the ones registered before run once
+// testDone is closed, on the goroutine of the test, see reportable().
+func (c *Cmd) watchTest(t *testing.T) {
+	t.Cleanup(func() {
+		close(c.testDone)
+	})
+}
+
+// testOver reports whether the test of the command is over, for the waits
+// to give up.
+func (c *Cmd) testOver() bool {
+	select {
+	case <-c.testDone:
+		return true
+	default:
+		return false
+	}
+}
+
+// reportable reports whether failures and logs can go to the test of the
+// command. They're dropped once it's over, for goroutines left behind, e.g.
+// still waiting on the command: the testing package panics when a finished
+// test is used. The test itself and its cleanups, which run on its goroutine,
+// always report.
+func (c *Cmd) reportable() bool {
+	return !c.testOver() || testFunc() != 0
+}
+
+// logf logs to the test of the command, unless it's over.
+func (c *Cmd) logf(format string, args ...interface{}) {
+	c.t.Helper()
+	if !c.reportable() {
+		return
+	}
+	c.t.Logf(format, args...)
+}
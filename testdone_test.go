@@ -0,0 +1,58 @@
+package testcli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaitsStopWhenTheTestIsOver(t *testing.T) {
+	var c *Cmd
+	returned := make(chan struct{})
+	t.Run("test", func(t *testing.T) {
+		c = Command(t, "sleep", "5")
+		c.Start()
+		go func() {
+			defer close(returned)
+			// Would fail, logging to the finished test, after a minute.
+			c.WaitForStdout("never", time.Minute)
+		}()
+	})
+	defer c.Kill()
+
+	select {
+	case <-returned:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected the wait to stop once its test was over")
+	}
+	if !c.testOver() {
+		t.Fatalf("Expected the test of the command to be over")
+	}
+}
+
+// TestCleanupAssertionHelperProcess asserts in a cleanup registered before
+// the command.
+func TestCleanupAssertionHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	var c *Cmd
+	t.Cleanup(func() {
+		c.AssertStdoutContains("definitely-not-there")
+	})
+	c = Command(t, "echo", "hello")
+	c.Run()
+}
+
+func TestCleanupAssertionsCount(t *testing.T) {
+	c := ReexecCommand(t, "TestCleanupAssertionHelperProcess")
+	c.Run()
+	if !c.Failure() {
+		t.Fatalf("Expected the assertion of the cleanup to fail the test, but it passed")
+	}
+
+	c.stdout.filter = nil
+	expected := `Expected stdout to contain "definitely-not-there"`
+	if !c.StdoutContains(expected) {
+		t.Fatalf("Expected %q to contain %q", c.Stdout(), expected)
+	}
+}
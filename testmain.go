@@ -0,0 +1,237 @@
+package testcli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+)
+
+// MainSession runs commands outside of any test, for the setup and teardown
+// of TestMain, e.g. building binaries or starting a database shared by the
+// tests. There's no test to fail then: failures are returned as errors.
+// Daemons started by the session are handed off to the tests asserting on
+// their output with Handoff():
+//
+//	var session *testcli.MainSession
+//
+//	func TestMain(m *testing.M) {
+//		session = testcli.ForTestMain()
+//		if err := session.Start("db", "mydb", "--port=5433"); err != nil {
+//			log.Fatal(err)
+//		}
+//		code := m.Run()
+//		if err := session.Close(); err != nil {
+//			log.Print(err)
+//		}
+//		os.Exit(code)
+//	}
+//
+//	func TestMigrate(t *testing.T) {
+//		db := session.Handoff(t, "db")
+//		testcli.Command(t, "mycli", "migrate").Run()
+//		db.AssertStdoutContains("schema version 3")
+//	}
+type MainSession struct {
+	mu      sync.Mutex
+	daemons map[string]*sharedDaemon
+	closed  bool
+	// stop is closed by Close() to stop the watchers of the handed off
+	// commands.
+	stop     chan struct{}
+	watchers sync.WaitGroup
+}
+
+// sharedDaemon is a command started by a MainSession. Its output is
+// captured as for any Cmd, and shared by the commands it's handed off as.
+type sharedDaemon struct {
+	cmd       *exec.Cmd
+	process   Process
+	stdout    *output
+	stderr    *output
+	notify    *notifier
+	seq       int64
+	capture   sync.WaitGroup
+	startedAt time.Time
+	// done is closed once the process has exited and its output captured,
+	// after which waitErr and exitedAt are set.
+	done     chan struct{}
+	waitErr  error
+	exitedAt time.Time
+}
+
+// ForTestMain returns a session to run commands from TestMain.
+func ForTestMain() *MainSession {
+	return &MainSession{daemons: map[string]*sharedDaemon{}, stop: make(chan struct{})}
+}
+
+// Run runs the command and returns its stdout. It returns an error showing
+// its output if it fails.
+func (m *MainSession) Run(name string, arg ...string) (string, error) {
+	d, err := m.start(name, arg)
+	if err != nil {
+		return "", err
+	}
+	<-d.done
+	stdout, stderr := d.text()
+	if d.waitErr != nil {
+		return stdout, fmt.Errorf("%s failed: %s\nstdout:\n%s\nstderr:\n%s", quoteArgs(d.cmd.Args), d.waitErr, stdout, stderr)
+	}
+	return stdout, nil
+}
+
+// Start starts the command in the background as a daemon shared by the
+// tests, see Handoff(), called daemon. It's stopped by Close().
+func (m *MainSession) Start(daemon, name string, arg ...string) error {
+	m.mu.Lock()
+	_, exists := m.daemons[daemon]
+	m.mu.Unlock()
+	if exists {
+		return fmt.Errorf("a daemon called %q was already started", daemon)
+	}
+	d, err := m.start(name, arg)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		d.process.Kill()
+		return fmt.Errorf("can't start %q: the session is closed", daemon)
+	}
+	m.daemons[daemon] = d
+	return nil
+}
+
+// start starts the command, capturing its output.
+func (m *MainSession) start(name string, arg []string) (*sharedDaemon, error) {
+	d := &sharedDaemon{
+		cmd:    exec.Command(name, arg...),
+		notify: newNotifier(),
+		done:   make(chan struct{}),
+	}
+	d.stdout = &output{mu: &sync.Mutex{}, seq: &d.seq, notify: d.notify}
+	d.stderr = &output{mu: &sync.Mutex{}, seq: &d.seq, notify: d.notify}
+
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stderrReader, stderrWriter, err := os.Pipe()
+	if err != nil {
+		stdoutReader.Close()
+		stdoutWriter.Close()
+		return nil, err
+	}
+	d.cmd.Stdout, d.cmd.Stderr = stdoutWriter, stderrWriter
+	d.startedAt = time.Now()
+	d.process, err = LocalExecutor().Start(d.cmd)
+	if err != nil {
+		stdoutReader.Close()
+		stderrReader.Close()
+		return nil, fmt.Errorf("can't start %s: %s", quoteArgs(d.cmd.Args), err)
+	}
+	d.capture.Add(2)
+	go func() {
+		defer d.capture.Done()
+		d.stdout.capture(stdoutReader)
+	}()
+	go func() {
+		defer d.capture.Done()
+		d.stderr.capture(stderrReader)
+	}()
+	go func() {
+		err := d.process.Wait()
+		d.capture.Wait()
+		d.waitErr = err
+		d.exitedAt = time.Now()
+		close(d.done)
+	}()
+	return d, nil
+}
+
+// text returns the output of the daemon captured so far.
+func (d *sharedDaemon) text() (string, string) {
+	d.stdout.mu.Lock()
+	stdout := d.stdout.text()
+	d.stdout.mu.Unlock()
+	d.stderr.mu.Lock()
+	defer d.stderr.mu.Unlock()
+	return stdout, d.stderr.text()
+}
+
+// Handoff returns the daemon started as daemon as a running command of the
+// test t, so that the assertions on its output fail t and show its output.
+// The output is shared: the command sees everything the daemon printed,
+// since it started. Stopping it, e.g. with Kill(), stops the daemon for the
+// tests which follow too. The command stops following the daemon once t is
+// over, or the session is closed.
+func (m *MainSession) Handoff(t *testing.T, daemon string) *Cmd {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		t.Fatalf("Can't hand off %q: the session is closed", daemon)
+	}
+	d, ok := m.daemons[daemon]
+	if !ok {
+		t.Fatalf("No daemon called %q was started by the session", daemon)
+	}
+
+	c := Command(t, d.cmd.Path, d.cmd.Args[1:]...)
+	c.cmd = d.cmd
+	c.recordExecutionInfo()
+	c.process = d.process
+	c.stdout, c.stderr = d.stdout, d.stderr
+	c.outputChanged = d.notify
+	c.startedAt = d.startedAt
+	c.status = Running
+
+	// The watcher finishes the command once the daemon exited.
+	m.watchers.Add(1)
+	go func() {
+		defer m.watchers.Done()
+		select {
+		case <-d.done:
+			c.waitErr = d.waitErr
+			c.exitedAt = d.exitedAt
+			close(c.done)
+		case <-c.testDone:
+		case <-m.stop:
+		}
+	}()
+	return c
+}
+
+// Close stops the watchers of the handed off commands, then the daemons,
+// and waits for them to exit. It returns an error if a daemon exited on its
+// own with an error before.
+func (m *MainSession) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	close(m.stop)
+	m.mu.Unlock()
+	m.watchers.Wait()
+
+	var failed error
+	for name, d := range m.daemons {
+		select {
+		case <-d.done:
+			if d.waitErr != nil && failed == nil {
+				_, stderr := d.text()
+				failed = fmt.Errorf("daemon %q exited early: %s\nstderr:\n%s", name, d.waitErr, stderr)
+			}
+			continue
+		default:
+		}
+		d.process.Kill()
+		<-d.done
+	}
+	return failed
+}
@@ -0,0 +1,91 @@
+package testcli
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMainSessionRun(t *testing.T) {
+	s := ForTestMain()
+	defer s.Close()
+	if out, err := s.Run("echo", "hello"); err != nil || out != "hello\n" {
+		t.Fatalf("Expected %q, got %q (%v)", "hello\n", out, err)
+	}
+	_, err := s.Run("sh", "-c", "echo boom >&2; exit 3")
+	if err == nil || !strings.Contains(err.Error(), "exit status 3") || !strings.Contains(err.Error(), "stderr:\nboom\n") {
+		t.Fatalf("Expected the failure to be returned with the output, got %v", err)
+	}
+	if _, err := s.Run("/nonexistent/testcli"); err == nil || !strings.Contains(err.Error(), "can't start") {
+		t.Fatalf("Expected the command not to start, got %v", err)
+	}
+}
+
+func TestMainSessionHandoff(t *testing.T) {
+	s := ForTestMain()
+	if err := s.Start("daemon", "sh", "-c", "echo ready; exec sleep 5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start("daemon", "true"); err == nil {
+		t.Fatalf("Expected a second daemon of the same name to be refused")
+	}
+	var first *Cmd
+	for _, name := range []string{"first", "second"} {
+		t.Run(name, func(t *testing.T) {
+			c := s.Handoff(t, "daemon")
+			c.WaitForStdout("ready", 5*time.Second)
+			if !c.IsRunning() {
+				t.Fatalf("Expected the daemon to be running")
+			}
+			if first == nil {
+				first = c
+			}
+		})
+	}
+
+	started := time.Now()
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Fatalf("Expected Close() to stop the daemon at once, took %s", elapsed)
+	}
+	// The watcher of the first test stopped with it, before the daemon.
+	if exited, _, _ := first.Exited(); exited {
+		t.Fatalf("Expected the command of the first test not to follow the daemon past its test")
+	}
+}
+
+func TestMainSessionDaemonExitedEarly(t *testing.T) {
+	s := ForTestMain()
+	if err := s.Start("daemon", "sh", "-c", "echo bad config >&2; exit 2"); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	d := s.daemons["daemon"]
+	s.mu.Unlock()
+	<-d.done
+	err := s.Close()
+	if err == nil || !strings.Contains(err.Error(), `daemon "daemon" exited early: exit status 2`) || !strings.Contains(err.Error(), "bad config") {
+		t.Fatalf("Expected the early exit to be reported, got %v", err)
+	}
+}
+
+// TestMainSessionHelperProcess hands off a daemon after the session is
+// closed.
+func TestMainSessionHelperProcess(t *testing.T) {
+	if !IsHelperProcess() {
+		return
+	}
+	s := ForTestMain()
+	s.Close()
+	s.Handoff(t, "daemon")
+}
+
+func TestMainSessionHandoffAfterClose(t *testing.T) {
+	c := ReexecCommand(t, "TestMainSessionHelperProcess")
+	c.Run()
+	if c.Success() || !strings.Contains(c.Stdout(), `Can't hand off "daemon": the session is closed`) {
+		t.Fatalf("Expected Handoff() to fail the test, got %q", c.Stdout())
+	}
+}
@@ -0,0 +1,178 @@
+package testcli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Termination describes how the package stops a command, e.g. when it times
+// out.
+type Termination struct {
+	// Signal is sent first, to let the command shut down cleanly. If nil, the
+	// command is killed right away.
+	Signal os.Signal
+	// Grace is how long the command has to exit after Signal before being
+	// killed.
+	Grace time.Duration
+}
+
+// Graceful sends sig, then kills the command if it's still running after
+// grace. On Windows, SIGTERM and os.Interrupt are delivered as a CTRL_BREAK
+// event to the command's process group.
+func Graceful(sig os.Signal, grace time.Duration) Termination {
+	return Termination{Signal: sig, Grace: grace}
+}
+
+// Immediate kills the command right away.
+func Immediate() Termination {
+	return Termination{}
+}
+
+// defaultTermination is SIGTERM, then SIGKILL 2 seconds later, scaled by
+// TimeMultiplier().
+func defaultTermination() Termination {
+	return Graceful(syscall.SIGTERM, Scaled(2*time.Second))
+}
+
+// TerminationReason tells whether and how the package stopped a command.
+type TerminationReason struct {
+	// TimedOut is set when the command was stopped by WithTimeout().
+	TimedOut bool
+	// Signal is the signal sent first, nil if the command was killed right
+	// away.
+	Signal os.Signal
+	// Killed is set when the command had to be killed, either right away or
+	// because it outlived the grace period, or by Kill().
+	Killed bool
+}
+
+// WithTimeout stops the command if it's still running d after it started, as
+// described by term: SIGTERM then SIGKILL 2 seconds later by default. The kill
+// reaches the whole process group of the command on Unix, e.g. the children
+// of a shell script. The output captured until then stays available, and
+// Error() matches ErrTimedOut, even if the command exited cleanly on SIGTERM.
+// See TimedOut() and TerminationReason() to tell how it went.
+func WithTimeout(d time.Duration, term ...Termination) Option {
+	return func(c *Cmd) {
+		c.timeout = d
+		c.termination = defaultTermination()
+		if len(term) > 0 {
+			c.termination = term[0]
+		}
+	}
+}
+
+// SetTimeout is WithTimeout() for an existing command. It must be called
+// before Run() or Start().
+func (c *Cmd) SetTimeout(d time.Duration, term ...Termination) {
+	c.Apply(WithTimeout(d, term...))
+}
+
+// RunWithTimeout runs the command, stopping it if it's still running after d,
+// see WithTimeout().
+func (c *Cmd) RunWithTimeout(d time.Duration, term ...Termination) {
+	c.t.Helper()
+	c.SetTimeout(d, term...)
+	c.Run()
+}
+
+// ErrTimedOut is matched by the error of a command stopped by WithTimeout(),
+// see errors.Is().
+var ErrTimedOut = errors.New("timed out")
+
+// timeoutError is the error of a command stopped by WithTimeout(), wrapping
+// the error it exited with, if any.
+type timeoutError struct {
+	timeout time.Duration
+	err     error
+}
+
+func (e *timeoutError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("timed out after %s", e.timeout)
+	}
+	return fmt.Sprintf("timed out after %s: %s", e.timeout, e.err)
+}
+
+func (e *timeoutError) Unwrap() error {
+	return e.err
+}
+
+func (e *timeoutError) Is(target error) bool {
+	return target == ErrTimedOut
+}
+
+// ExitCode is the exit status of the command, see exitCodeOf().
+func (e *timeoutError) ExitCode() int {
+	return exitCodeOf(e.err)
+}
+
+// TimedOut tells whether the finished command was stopped by WithTimeout(),
+// rather than exiting on its own.
+func (c *Cmd) TimedOut() bool {
+	c.t.Helper()
+	return c.TerminationReason().TimedOut
+}
+
+// finalError returns the error of the command once it exited, marked as timed
+// out if it was stopped by WithTimeout(), or as cancelled if it was killed by
+// the context of CommandContext().
+func (c *Cmd) finalError() error {
+	c.terminationMu.Lock()
+	timedOut, ctxErr := c.terminationReason.TimedOut, c.ctxErr
+	c.terminationMu.Unlock()
+	if timedOut {
+		return &timeoutError{timeout: c.timeout, err: c.waitErr}
+	}
+	if ctxErr != nil {
+		return &contextError{ctxErr: ctxErr, err: c.waitErr}
+	}
+	return c.waitErr
+}
+
+// TerminationReason returns how the package stopped the command, if it did.
+func (c *Cmd) TerminationReason() TerminationReason {
+	c.t.Helper()
+	c.validateIsFinished()
+	c.terminationMu.Lock()
+	defer c.terminationMu.Unlock()
+	return c.terminationReason
+}
+
+// watchTimeout stops the command once its timeout elapses.
+func (c *Cmd) watchTimeout() {
+	select {
+	case <-c.done:
+	case <-time.After(c.timeout):
+		c.terminate(c.termination, TerminationReason{TimedOut: true})
+	}
+}
+
+// terminate stops the command as described by term, recording it in reason.
+// The reason is recorded before acting, so it's known by the time the command
+// is seen to exit.
+func (c *Cmd) terminate(term Termination, reason TerminationReason) {
+	reason.Signal = term.Signal
+	if term.Signal != nil {
+		c.setTerminationReason(reason)
+		if c.signal(term.Signal) == nil {
+			select {
+			case <-c.done:
+				return
+			case <-time.After(term.Grace):
+			}
+		}
+	}
+	reason.Killed = true
+	c.setTerminationReason(reason)
+	c.kill()
+}
+
+func (c *Cmd) setTerminationReason(reason TerminationReason) {
+	c.terminationMu.Lock()
+	c.terminationReason = reason
+	c.terminationMu.Unlock()
+}
@@ -0,0 +1,93 @@
+package testcli
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestTimeoutGraceful(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", `trap 'echo flushing; exit 0' TERM; while :; do sleep 0.05; done`)
+	c.Apply(WithTimeout(200 * time.Millisecond))
+	c.Run()
+
+	reason := c.TerminationReason()
+	if !reason.TimedOut || reason.Signal != syscall.SIGTERM || reason.Killed {
+		t.Fatalf("Expected a graceful SIGTERM termination, got %+v", reason)
+	}
+
+	if !c.StdoutContains("flushing") {
+		t.Fatalf("Expected %q to contain %q", c.Stdout(), "flushing")
+	}
+	if !errors.Is(c.Error(), ErrTimedOut) || c.ExitCode() != 0 {
+		t.Fatalf("Expected a timeout error with exit code 0, got %v (%d)", c.Error(), c.ExitCode())
+	}
+}
+
+func TestTimeoutKillsAfterGrace(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", `trap '' TERM; while :; do sleep 0.05; done`)
+	c.Apply(WithTimeout(100*time.Millisecond, Graceful(syscall.SIGTERM, 200*time.Millisecond)))
+	start := time.Now()
+	c.Run()
+
+	reason := c.TerminationReason()
+	if !reason.TimedOut || reason.Signal != syscall.SIGTERM || !reason.Killed {
+		t.Fatalf("Expected SIGTERM then a kill, got %+v", reason)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Fatalf("Expected the grace period to be honored, took %s", elapsed)
+	}
+}
+
+func TestTimeoutImmediate(t *testing.T) {
+	c := Command(t, "sleep", "10")
+	c.Apply(WithTimeout(100*time.Millisecond, Immediate()))
+	c.Run()
+
+	reason := c.TerminationReason()
+	if !reason.TimedOut || reason.Signal != nil || !reason.Killed {
+		t.Fatalf("Expected an immediate kill, got %+v", reason)
+	}
+	if !c.Failure() {
+		t.Fatalf("Expected to fail, but succeeded")
+	}
+}
+
+func TestTimeoutNotReached(t *testing.T) {
+	c := Command(t, "true")
+	c.Apply(WithTimeout(time.Second))
+	c.Run()
+
+	if reason := c.TerminationReason(); reason != (TerminationReason{}) {
+		t.Fatalf("Expected no termination, got %+v", reason)
+	}
+}
+
+func TestRunWithTimeoutKillsProcessGroup(t *testing.T) {
+	c := Command(t, "/bin/sh", "-c", "echo started; sleep 10; echo done")
+	start := time.Now()
+	c.RunWithTimeout(100*time.Millisecond, Immediate())
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Expected the children of the command to be killed too, took %s", elapsed)
+	}
+	if !c.TimedOut() {
+		t.Fatalf("Expected the command to time out")
+	}
+	if !errors.Is(c.Error(), ErrTimedOut) || c.ExitCode() != -1 {
+		t.Fatalf("Expected a timeout error with exit code -1, got %v (%d)", c.Error(), c.ExitCode())
+	}
+	if c.Stdout() != "started\n" {
+		t.Fatalf("Expected the output until the timeout, got %q", c.Stdout())
+	}
+}
+
+func TestSetTimeoutNotReached(t *testing.T) {
+	c := Command(t, "true")
+	c.SetTimeout(time.Second)
+	c.Run()
+	if c.TimedOut() || c.Error() != nil {
+		t.Fatalf("Expected not to time out, got %v", c.Error())
+	}
+}
@@ -0,0 +1,57 @@
+package testcli
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TimeMultiplierEnvVar scales the default timeouts and grace periods of the
+// package when set to a positive number, e.g. 3 on a slow CI runner.
+const TimeMultiplierEnvVar = "TESTCLI_TIME_MULTIPLIER"
+
+var (
+	timeMultiplierMu sync.Mutex
+	timeMultiplier   float64
+)
+
+// SetTimeMultiplier scales the default timeouts and grace periods of the
+// package by m, overriding TESTCLI_TIME_MULTIPLIER, or resets it if m is 0.
+// See TimeMultiplier().
+func SetTimeMultiplier(m float64) {
+	timeMultiplierMu.Lock()
+	timeMultiplier = m
+	timeMultiplierMu.Unlock()
+}
+
+// TimeMultiplier returns how much the default timeouts and grace periods of
+// the package are scaled by: the one set by SetTimeMultiplier(), else the
+// one of TESTCLI_TIME_MULTIPLIER, else 2 when tests run with -race, 1
+// otherwise. It applies to the retries of assertions, the waits for prompts,
+// the grace periods of WithTimeout() and Wait(), and the other durations the
+// package picks by default. Timeouts passed explicitly are used as is, see
+// Scaled() to scale them too. Polling intervals aren't scaled.
+func TimeMultiplier() float64 {
+	timeMultiplierMu.Lock()
+	m := timeMultiplier
+	timeMultiplierMu.Unlock()
+	if m > 0 {
+		return m
+	}
+	if m, err := strconv.ParseFloat(os.Getenv(TimeMultiplierEnvVar), 64); err == nil && m > 0 {
+		return m
+	}
+	if raceEnabled {
+		return 2
+	}
+	return 1
+}
+
+// Scaled returns d scaled by TimeMultiplier(), for explicit timeouts which
+// should scale like the default ones, e.g.
+//
+//	c.WaitForStdout("ready", testcli.Scaled(5*time.Second))
+func Scaled(d time.Duration) time.Duration {
+	return time.Duration(float64(d) * TimeMultiplier())
+}
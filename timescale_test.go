@@ -0,0 +1,70 @@
+package testcli
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestTimeMultiplier(t *testing.T) {
+	defer os.Setenv(TimeMultiplierEnvVar, os.Getenv(TimeMultiplierEnvVar))
+	defer SetTimeMultiplier(0)
+
+	os.Unsetenv(TimeMultiplierEnvVar)
+	expected := 1.0
+	if raceEnabled {
+		expected = 2
+	}
+	if m := TimeMultiplier(); m != expected {
+		t.Fatalf("Expected a multiplier of %g by default, got %g", expected, m)
+	}
+
+	os.Setenv(TimeMultiplierEnvVar, "bogus")
+	if m := TimeMultiplier(); m != expected {
+		t.Fatalf("Expected an invalid multiplier to be ignored, got %g", m)
+	}
+
+	os.Setenv(TimeMultiplierEnvVar, "3")
+	if m := TimeMultiplier(); m != 3 {
+		t.Fatalf("Expected the environment to set a multiplier of 3, got %g", m)
+	}
+	if d := Scaled(time.Second); d != 3*time.Second {
+		t.Fatalf("Expected 1s to be scaled to 3s, got %s", d)
+	}
+
+	SetTimeMultiplier(1.5)
+	if m := TimeMultiplier(); m != 1.5 {
+		t.Fatalf("Expected the setter to override the environment, got %g", m)
+	}
+	SetTimeMultiplier(0)
+	if m := TimeMultiplier(); m != 3 {
+		t.Fatalf("Expected the setter to be reset, got %g", m)
+	}
+}
+
+func TestTimeMultiplierScalesDefaultsOnce(t *testing.T) {
+	defer SetTimeMultiplier(0)
+	SetTimeMultiplier(0.3)
+
+	c := Command(t, "sleep", "10")
+	c.Start()
+	defer c.Kill()
+	start := time.Now()
+	if c.StdoutContains("never") {
+		t.Fatalf("Expected %q not to contain %q", c.Stdout(), "never")
+	}
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond || elapsed > 700*time.Millisecond {
+		t.Fatalf("Expected the assertion to give up after about 300ms, took %s", elapsed)
+	}
+
+	c = Command(t, "true")
+	c.Apply(WithTimeout(time.Second))
+	if grace := c.termination.Grace; grace != 600*time.Millisecond {
+		t.Fatalf("Expected the default grace period to be scaled to 600ms, got %s", grace)
+	}
+	c.Apply(WithTimeout(time.Second, Graceful(syscall.SIGTERM, time.Second)))
+	if grace := c.termination.Grace; grace != time.Second {
+		t.Fatalf("Expected an explicit grace period not to be scaled, got %s", grace)
+	}
+}
@@ -0,0 +1,124 @@
+package testcli
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// nextSeq returns the next number of the counter seq, 0 if it's nil.
+func nextSeq(seq *int64) int64 {
+	if seq == nil {
+		return 0
+	}
+	return atomic.AddInt64(seq, 1)
+}
+
+// transcriptEvent is a piece of the session of a command.
+type transcriptEvent struct {
+	seq    int64
+	prefix string
+	data   string
+}
+
+// Prefixes of the lines of a transcript.
+const (
+	stdinPrefix  = "> "
+	stdoutPrefix = ""
+	stderrPrefix = "! "
+)
+
+// AssertTranscriptGolden fails the test unless the whole session of the
+// finished command matches the golden file at path, like
+// AssertStdoutGolden(): what was written to its stdin, prefixed with "> ",
+// stdout, and stderr, prefixed with "! ", in the order they were written or
+// captured. Named streams, see CaptureNamedPipe(), are prefixed with
+// "[name] ". Lines not ending with a newline, like prompts, are ended in the
+// transcript. Stdin requires RecordStdin(). The output is collapsed with
+// WithCollapsedProgress().
+//
+// The order is that of the events rather than of their timestamps: input is
+// numbered before being written, so it always comes before the output it
+// causes, and the session is stable as long as the command waits for its
+// input. Output written to stdout and stderr at the same time may be captured
+// in any order though.
+func (c *Cmd) AssertTranscriptGolden(path string) {
+	c.t.Helper()
+	if c.dryRun && !*updateGolden {
+		if _, err := os.Stat(path); err != nil {
+			c.t.Fatalf("Golden file %s is missing: %s", path, err)
+		}
+	}
+	c.validateIsFinished()
+	transcript, err := c.transcriptText()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := checkGolden(transcript, "the transcript", path, *updateGolden); err != nil {
+		c.fatalf("%s", err)
+	}
+}
+
+// transcriptText renders the session of the command.
+func (c *Cmd) transcriptText() (string, error) {
+	var events []transcriptEvent
+	outputs := []*output{c.stdout, c.stderr}
+	prefixes := []string{stdoutPrefix, stderrPrefix}
+	for _, s := range c.streams {
+		outputs = append(outputs, s.o)
+		prefixes = append(prefixes, "["+s.pipe.name+"] ")
+	}
+	for i, o := range outputs {
+		streamEvents, err := o.events(prefixes[i])
+		if err != nil {
+			return "", err
+		}
+		events = append(events, streamEvents...)
+	}
+	c.stdinMu.Lock()
+	for _, w := range c.transcript {
+		events = append(events, transcriptEvent{seq: w.seq, prefix: stdinPrefix, data: w.Data})
+	}
+	c.stdinMu.Unlock()
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].seq < events[j].seq
+	})
+
+	// Consecutive events of a stream are merged first, since lines may be
+	// split across chunks.
+	var b strings.Builder
+	for i := 0; i < len(events); {
+		prefix, data := events[i].prefix, ""
+		for ; i < len(events) && events[i].prefix == prefix; i++ {
+			data += events[i].data
+		}
+		if c.collapseProgress && prefix != stdinPrefix {
+			data = collapseProgress(data)
+		}
+		for _, line := range strings.SplitAfter(data, "\n") {
+			if line == "" {
+				continue
+			}
+			b.WriteString(prefix + strings.TrimSuffix(line, "\n") + "\n")
+		}
+	}
+	return b.String(), nil
+}
+
+// events returns the chunks of o as transcript events.
+func (o *output) events(prefix string) ([]transcriptEvent, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.dropped > 0 || o.spilled() || o.limit > 0 {
+		return nil, errors.New("A transcript requires the whole output in memory, it's incompatible with KeepTail(), SpillCaptureToDisk() and output limits")
+	}
+	var events []transcriptEvent
+	offset := 0
+	for _, ch := range o.chunks {
+		events = append(events, transcriptEvent{seq: ch.seq, prefix: prefix, data: o.content[offset : offset+ch.n]})
+		offset += ch.n
+	}
+	return events, nil
+}
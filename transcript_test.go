@@ -0,0 +1,47 @@
+package testcli
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+const transcriptScript = `printf 'Name? '; read name; echo "Hello $name"; printf 'Age? '; read age; echo "$age is fine" >&2`
+
+func TestAssertTranscriptGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.golden")
+	expected := "Name? \n> bob\nHello bob\nAge? \n> 42\n! 42 is fine\n"
+	if err := ioutil.WriteFile(path, []byte(expected), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		c := Command(t, "sh", "-c", transcriptScript)
+		c.RecordStdin()
+		c.SetStdinSequence(Interactive(Respond(`Name\? `, "bob\n"), Respond(`Age\? `, "42\n")))
+		c.Run()
+		c.AssertTranscriptGolden(path)
+	}
+}
+
+func TestTranscriptMergesChunks(t *testing.T) {
+	c := Command(t, "sh", "-c", "printf 'hel'; sleep 0.05; printf 'lo\\nworld'; sleep 0.05; echo oops >&2")
+	c.Run()
+	transcript, err := c.transcriptText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "hello\nworld\n! oops\n"
+	if transcript != expected {
+		t.Fatalf("Expected %q to be %q", transcript, expected)
+	}
+}
+
+func TestTranscriptRequiresWholeOutput(t *testing.T) {
+	c := Command(t, "sh", "-c", "seq 10")
+	c.KeepTail(2)
+	c.Run()
+	if _, err := c.transcriptText(); err == nil {
+		t.Fatalf("Expected KeepTail() to be rejected")
+	}
+}
@@ -0,0 +1,30 @@
+package testcli
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// processUmask returns the umask of the tests, -1 if it's unknown. It's read
+// from /proc, since umask(2) can only read it by changing it, racing with the
+// files created meanwhile.
+func processUmask() int {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return -1
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if value := strings.TrimPrefix(scanner.Text(), "Umask:"); value != scanner.Text() {
+			umask, err := strconv.ParseInt(strings.TrimSpace(value), 8, 32)
+			if err != nil {
+				return -1
+			}
+			return int(umask)
+		}
+	}
+	return -1
+}
@@ -0,0 +1,116 @@
+package testcli
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// unassertedSampleSize is how many unexamined lines WarnUnasserted() logs.
+const unassertedSampleSize = 5
+
+// examine records the lines of the content of o spanned by loc, the first
+// match of an assertion as returned by locate, as examined. The content is
+// lowered first if fold is set, which keeps its lines where they are.
+func (o *output) examine(fold bool, locate func(content string) []int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	content := o.text()
+	if fold {
+		content = strings.ToLower(content)
+	}
+	loc := locate(content)
+	if loc == nil {
+		return
+	}
+	if o.examined == nil {
+		o.examined = map[int]bool{}
+	}
+	end := loc[1]
+	if end > loc[0] && content[end-1] == '\n' {
+		end--
+	}
+	first := strings.Count(content[:loc[0]], "\n")
+	last := first + strings.Count(content[loc[0]:end], "\n")
+	for line := first; line <= last; line++ {
+		o.examined[line] = true
+	}
+}
+
+// examineContains records the first occurrence of str as examined.
+func (o *output) examineContains(str string, fold bool) {
+	o.examine(fold, func(content string) []int {
+		if i := strings.Index(content, str); i >= 0 {
+			return []int{i, i + len(str)}
+		}
+		return nil
+	})
+}
+
+// examineMatch records the first match of re as examined.
+func (o *output) examineMatch(re *regexp.Regexp, fold bool) {
+	o.examine(fold, re.FindStringIndex)
+}
+
+// examineAll records the whole content as examined, e.g. by a golden
+// comparison.
+func (o *output) examineAll() {
+	o.examine(false, func(content string) []int {
+		return []int{0, len(content)}
+	})
+}
+
+// UnassertedOutput returns the lines of stdout which no assertion looked at:
+// no Contains found its string there, no Matches its first match, and no
+// golden comparison covered it. It's a heuristic to find dead assertions,
+// see WarnUnasserted().
+func (c *Cmd) UnassertedOutput() []string {
+	c.t.Helper()
+	c.validateHasStarted()
+	lines := splitLines(c.Stdout())
+	c.stdout.mu.Lock()
+	defer c.stdout.mu.Unlock()
+	var unasserted []string
+	for i, line := range lines {
+		if !c.stdout.examined[i] {
+			unasserted = append(unasserted, line)
+		}
+	}
+	return unasserted
+}
+
+// WarnUnasserted logs, when the test finishes, the share of the lines of
+// stdout no assertion looked at and a sample of them, if it's more than
+// maxPercent. It doesn't fail the test. See UnassertedOutput().
+func WarnUnasserted(maxPercent float64) Option {
+	return func(c *Cmd) {
+		c.t.Cleanup(func() {
+			if c.status != Finished {
+				return
+			}
+			if message := c.unassertedWarning(maxPercent); message != "" {
+				c.t.Log(message)
+			}
+		})
+	}
+}
+
+// unassertedWarning describes the lines of stdout no assertion looked at, or
+// returns "" if they're no more than maxPercent of them.
+func (c *Cmd) unassertedWarning(maxPercent float64) string {
+	total := len(splitLines(c.Stdout()))
+	unasserted := c.UnassertedOutput()
+	if total == 0 {
+		return ""
+	}
+	percent := 100 * float64(len(unasserted)) / float64(total)
+	if percent <= maxPercent {
+		return ""
+	}
+	sample := unasserted
+	if len(sample) > unassertedSampleSize {
+		sample = sample[:unassertedSampleSize]
+	}
+	return fmt.Sprintf("%.0f%% of the stdout of %s (%d of %d lines) wasn't looked at by any assertion, e.g.:\n  %s",
+		percent, c.Repro(), len(unasserted), total, strings.Join(sample, "\n  "))
+}
@@ -0,0 +1,45 @@
+package testcli
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnassertedOutput(t *testing.T) {
+	c := Command(t, "printf", `header\nid: 1\nname: Ann\nwarning: stale\nfooter\n`)
+	c.Run()
+	if got := c.UnassertedOutput(); len(got) != 5 {
+		t.Fatalf("Expected all the lines to be unasserted, got %q", got)
+	}
+
+	c.AssertStdoutContains("ID: 1")
+	c.AssertStdoutMatches(`name: \w+\nwarning`)
+	if c.StdoutContains("missing") {
+		t.Fatalf("Expected not to find %q", "missing")
+	}
+	if want, got := []string{"header", "footer"}, c.UnassertedOutput(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %q to be unasserted, got %q", want, got)
+	}
+	if warning := c.unassertedWarning(10); !strings.Contains(warning, "40% of the stdout of printf") || !strings.Contains(warning, "(2 of 5 lines)") || !strings.HasSuffix(warning, "\n  header\n  footer") {
+		t.Fatalf("Expected a warning with the unasserted lines, got %q", warning)
+	}
+	if warning := c.unassertedWarning(50); warning != "" {
+		t.Fatalf("Expected no warning under the threshold, got %q", warning)
+	}
+}
+
+func TestUnassertedOutputGolden(t *testing.T) {
+	c := Command(t, "printf", `a\nb\n`)
+	c.Run()
+	path := filepath.Join(t.TempDir(), "golden")
+	if err := ioutil.WriteFile(path, []byte("a\nb\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c.AssertStdoutGolden(path)
+	if got := c.UnassertedOutput(); len(got) != 0 {
+		t.Fatalf("Expected the golden comparison to cover everything, got %q", got)
+	}
+}
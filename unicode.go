@@ -0,0 +1,100 @@
+package testcli
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// UnicodeEnvVar is set to the fixture under test in the environment of the
+// commands run by CheckUnicodeHandling.
+const UnicodeEnvVar = "TESTCLI_UNICODE"
+
+// UnicodeSpec describes how CheckUnicodeHandling runs a command.
+type UnicodeSpec struct {
+	Spec
+	// Placeholder is replaced by the fixture under test in Args, "{}" by
+	// default.
+	Placeholder string
+}
+
+type unicodeFixture struct {
+	name  string
+	value string
+	// skip maps a GOOS to why the fixture can't be used there.
+	skip map[string]string
+}
+
+var unicodeFixtures = []unicodeFixture{
+	{name: "cjk", value: "日本語のファイル"},
+	{name: "astral", value: "emoji 😀 𝄞"},
+	{name: "combining", value: "e\u0301cole"},
+	{name: "right-to-left", value: "שלום"},
+	{name: "zero-width", value: "a\u200db"},
+	{name: "trailing-space", value: "trailing ", skip: map[string]string{
+		"windows": "file names can't end with a space on Windows",
+	}},
+	{name: "invalid-utf8", value: "bad\xff\xfe", skip: map[string]string{
+		"windows": "arguments and file names are UTF-16 on Windows, invalid UTF-8 can't be represented",
+		"darwin":  "APFS rejects file names which aren't valid UTF-8",
+	}},
+}
+
+// CheckUnicodeHandling runs the command once per non-ASCII fixture (CJK,
+// astral-plane runes, combining characters, trailing spaces, invalid UTF-8,
+// ...), each in its own subtest. Every run happens in a fresh workspace
+// holding a file named after the fixture, unless spec.Dir is set, with the
+// placeholder in the arguments replaced by the fixture and UnicodeEnvVar set
+// to it. The other fields of spec.Spec apply as with Spec.Command(). The
+// command must print the fixture back to stdout, byte for byte.
+//
+// Fixtures which can't exist on the current platform are skipped, with the
+// reason why.
+func CheckUnicodeHandling(t *testing.T, spec UnicodeSpec) {
+	t.Helper()
+	for _, fixture := range unicodeFixtures {
+		fixture := fixture
+		t.Run(fixture.name, func(t *testing.T) {
+			if reason, ok := fixture.skip[runtime.GOOS]; ok {
+				t.Skip(reason)
+			}
+			c := spec.command(t, fixture.value)
+			c.Run()
+			if err := c.checkUnicodeRoundTrip(fixture.value); err != nil {
+				c.fatalf("%s", err)
+			}
+		})
+	}
+}
+
+// command constructs the command checking value, in a workspace holding a
+// file named after it.
+func (spec UnicodeSpec) command(t *testing.T, value string) *Cmd {
+	t.Helper()
+	placeholder := withDefault(spec.Placeholder, "{}")
+	args := make([]string, len(spec.Args))
+	for i, arg := range spec.Args {
+		args[i] = strings.ReplaceAll(arg, placeholder, value)
+	}
+
+	ws := NewWorkspace(t)
+	ws.WriteFile(value, []byte(value))
+	spec.Args = args
+	c := spec.Spec.Command(t)
+	if c.cmd.Dir == "" {
+		c.cmd.Dir = ws.Root()
+	}
+	c.SetEnvVar(UnicodeEnvVar, value)
+	return c
+}
+
+func (c *Cmd) checkUnicodeRoundTrip(value string) error {
+	if c.exitCode() != 0 {
+		return fmt.Errorf("Expected to succeed with %q, but failed with error: %v", value, c.Error())
+	}
+	if !strings.Contains(c.Stdout(), value) {
+		return fmt.Errorf("Expected the exact bytes of %q on stdout, got %q", value, c.Stdout())
+	}
+	return nil
+}
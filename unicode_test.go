@@ -0,0 +1,49 @@
+package testcli
+
+import (
+	"testing"
+)
+
+func TestCheckUnicodeHandling(t *testing.T) {
+	CheckUnicodeHandling(t, UnicodeSpec{Spec: Spec{
+		Name: "sh",
+		Args: []string{"-c", `printf '%s\n' "$1" "$` + UnicodeEnvVar + `"; ls`, "sh", "{}"},
+	}})
+}
+
+func TestCheckUnicodeHandlingFiles(t *testing.T) {
+	// Only lists the files, so it's the name of the fixture file which must
+	// round-trip.
+	CheckUnicodeHandling(t, UnicodeSpec{Spec: Spec{Name: "ls"}})
+}
+
+func TestCheckUnicodeRoundTripMangled(t *testing.T) {
+	spec := UnicodeSpec{
+		Spec: Spec{
+			Name: "sh",
+			Args: []string{"-c", `printf '%s' "$1" | tr -d '\200-\377'`, "sh", "<arg>"},
+		},
+		Placeholder: "<arg>",
+	}
+	c := spec.command(t, "日本語")
+	c.Run()
+	err := c.checkUnicodeRoundTrip("日本語")
+	if err == nil {
+		t.Fatalf("Expected the mangled output to be reported, got %q", c.Stdout())
+	}
+}
+
+func TestUnicodeSpecAppliesSpec(t *testing.T) {
+	dir := t.TempDir()
+	spec := UnicodeSpec{Spec: Spec{
+		Name:    "sh",
+		Args:    []string{"-c", `echo "$EXTRA"; pwd`},
+		EnvVars: []string{"EXTRA=set"},
+		Dir:     dir,
+	}}
+	c := spec.command(t, "日本語")
+	c.Run()
+	if !c.StdoutContains("set\n"+dir+"\n", CaseSensitive()) {
+		t.Fatalf("Expected EnvVars and Dir to apply, got %q", c.Stdout())
+	}
+}
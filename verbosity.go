@@ -0,0 +1,83 @@
+package testcli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// Verbosity is how much of the output failure messages include.
+type Verbosity string
+
+// The verbosity levels.
+const (
+	// VerbosityQuiet only names the command, and the attachment if any.
+	VerbosityQuiet Verbosity = "quiet"
+	// VerbosityExcerpt includes the last lines of each stream.
+	VerbosityExcerpt Verbosity = "excerpt"
+	// VerbosityFull includes the whole output, the default.
+	VerbosityFull Verbosity = "full"
+)
+
+// VerbosityEnvVar sets the verbosity of failure messages when the
+// -testcli.v flag isn't given.
+const VerbosityEnvVar = "TESTCLI_VERBOSITY"
+
+// excerptLines is how many lines of each stream VerbosityExcerpt includes.
+const excerptLines = 20
+
+func (v *Verbosity) String() string {
+	return string(*v)
+}
+
+// Set validates the level given to -testcli.v.
+func (v *Verbosity) Set(s string) error {
+	switch level := Verbosity(s); level {
+	case VerbosityQuiet, VerbosityExcerpt, VerbosityFull:
+		*v = level
+		return nil
+	}
+	return fmt.Errorf("unknown verbosity %q, expected quiet, excerpt or full", s)
+}
+
+// verbosityFlag is the -testcli.v flag, empty unless given. It's only read
+// when a failure is reported, after the flags are parsed.
+var verbosityFlag Verbosity
+
+func init() {
+	flag.Var(&verbosityFlag, "testcli.v", "verbosity of failure messages: quiet, excerpt or full")
+}
+
+// WithVerbosity sets how much of the output the failure messages about the
+// command include, overriding the -testcli.v flag and the TESTCLI_VERBOSITY
+// environment variable.
+func WithVerbosity(v Verbosity) Option {
+	return func(c *Cmd) {
+		c.verbosity = v
+	}
+}
+
+// effectiveVerbosity returns the verbosity of the failure messages about the
+// command: its own, the flag's, the environment's, or VerbosityFull.
+func (c *Cmd) effectiveVerbosity() Verbosity {
+	if c.verbosity != "" {
+		return c.verbosity
+	}
+	if verbosityFlag != "" {
+		return verbosityFlag
+	}
+	var v Verbosity
+	if err := v.Set(os.Getenv(VerbosityEnvVar)); err == nil {
+		return v
+	}
+	return VerbosityFull
+}
+
+// excerpt returns the last n lines of o, noting how many were left out.
+func excerpt(o *output, n int) string {
+	tail, omitted := lastLines(o, n)
+	if omitted == 0 {
+		return tail
+	}
+	return fmt.Sprintf("[... %d earlier lines ...]\n", omitted) + tail
+}
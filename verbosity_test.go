@@ -0,0 +1,71 @@
+package testcli
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestVerbosityLevels(t *testing.T) {
+	c := Command(t, "sh", "-c", "seq 30; echo oops >&2")
+	c.Run()
+
+	c.verbosity = VerbosityFull
+	full := c.report()
+	if !strings.Contains(full, "stdout:\n1\n2\n") || !strings.Contains(full, "30\n") || !strings.Contains(full, "stderr:\noops") {
+		t.Fatalf("Expected the full output, got %q", full)
+	}
+
+	c.verbosity = VerbosityExcerpt
+	excerpt := c.report()
+	if !strings.Contains(excerpt, "stdout:\n[... 10 earlier lines ...]\n11\n") || !strings.Contains(excerpt, "30\n") {
+		t.Fatalf("Expected the last 20 lines of stdout, got %q", excerpt)
+	}
+	if !strings.Contains(excerpt, "stderr:\noops") {
+		t.Fatalf("Expected all of the short stderr, got %q", excerpt)
+	}
+
+	c.verbosity = VerbosityQuiet
+	quiet := c.report()
+	if quiet != "command: sh -c 'seq 30; echo oops >&2'" {
+		t.Fatalf("Expected only the command, got %q", quiet)
+	}
+}
+
+func TestVerbosityPrecedence(t *testing.T) {
+	defer os.Setenv(VerbosityEnvVar, os.Getenv(VerbosityEnvVar))
+	defer func() { verbosityFlag = "" }()
+
+	c := Command(t, "true")
+	os.Unsetenv(VerbosityEnvVar)
+	if v := c.effectiveVerbosity(); v != VerbosityFull {
+		t.Fatalf("Expected %s by default, got %s", VerbosityFull, v)
+	}
+
+	os.Setenv(VerbosityEnvVar, "excerpt")
+	if v := c.effectiveVerbosity(); v != VerbosityExcerpt {
+		t.Fatalf("Expected the environment to set %s, got %s", VerbosityExcerpt, v)
+	}
+
+	if err := flag.Set("testcli.v", "quiet"); err != nil {
+		t.Fatal(err)
+	}
+	if v := c.effectiveVerbosity(); v != VerbosityQuiet {
+		t.Fatalf("Expected the flag to override the environment, got %s", v)
+	}
+
+	c.Apply(WithVerbosity(VerbosityFull))
+	if v := c.effectiveVerbosity(); v != VerbosityFull {
+		t.Fatalf("Expected the command to override the flag, got %s", v)
+	}
+}
+
+func TestVerbosityFlagRejectsUnknownLevels(t *testing.T) {
+	if err := flag.Set("testcli.v", "loud"); err == nil {
+		t.Fatalf("Expected an unknown level to be rejected")
+	}
+	if verbosityFlag != "" {
+		t.Fatalf("Expected the flag to stay unset, got %q", verbosityFlag)
+	}
+}
@@ -0,0 +1,290 @@
+package testcli
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// exitedError is returned by the waits which gave up because the command
+// exited, since what they wait for can't happen anymore.
+type exitedError struct {
+	what string
+	code int
+	// reason tells how the command ended, see endReason().
+	reason string
+}
+
+func (e *exitedError) Error() string {
+	if e.reason != "" {
+		return fmt.Sprintf("%s while waiting for %s", e.reason, e.what)
+	}
+	return fmt.Sprintf("process exited (code %d) while waiting for %s", e.code, e.what)
+}
+
+// exitedError returns the error of a wait for what on the exited command.
+func (c *Cmd) exitedError(what string) *exitedError {
+	_, code, _ := c.Exited()
+	reason, _ := c.endReason()
+	return &exitedError{what: what, code: code, reason: reason}
+}
+
+// endReason tells how the exited command ended, and so why it can't output
+// anything anymore, e.g. "process was killed by timeout after 5s", and
+// whether it ended otherwise than by exiting on its own.
+func (c *Cmd) endReason() (string, bool) {
+	_, code, err := c.Exited()
+	c.terminationMu.Lock()
+	reason, ctxErr := c.terminationReason, c.ctxErr
+	c.terminationMu.Unlock()
+	stopped := "stopped"
+	if reason.Killed {
+		stopped = "killed"
+	}
+	switch {
+	case c.process == nil && err != nil && !c.replayed:
+		return fmt.Sprintf("process failed to start: %s", err), true
+	case reason.TimedOut:
+		return fmt.Sprintf("process was %s by timeout after %s", stopped, c.timeout), true
+	case ctxErr != nil:
+		return fmt.Sprintf("process was killed by its context: %s", ctxErr), true
+	case reason.Killed:
+		return "process was killed", true
+	case reason.Signal != nil:
+		return fmt.Sprintf("process was stopped by %s", reason.Signal), true
+	case code == -1 && err != nil:
+		return fmt.Sprintf("process was killed: %s", err), true
+	}
+	return fmt.Sprintf("process exited (code %d)", code), false
+}
+
+// minPollInterval is the first interval between the checks of a wait, which
+// then backs off exponentially.
+const minPollInterval = 5 * time.Millisecond
+
+// SetMaxPollInterval caps the interval the waits on the command back off to
+// between checks, replacing their defaults, e.g. 100ms for the Contains
+// assertions. New output wakes them up regardless, so that a match is seen
+// as soon as it's captured.
+func (c *Cmd) SetMaxPollInterval(d time.Duration) {
+	c.maxPollInterval = d
+}
+
+// waitFor calls cond until it returns true, and returns nil, or until
+// timeout elapses. It's called at once, then at intervals backing off from
+// minPollInterval to interval, or SetMaxPollInterval(), and whenever the
+// command outputs something. If the command exits in the meantime, cond gets
+// a last chance once the output is captured, and an *exitedError is returned
+// if it still doesn't hold. Once the command exited, however it ended, e.g.
+// killed, timed out or failed to start, its output can't change anymore:
+// cond is called once, without waiting. If the test of the command is over,
+// e.g. for a wait left running by a goroutine, errTestOver is returned at
+// once. Every wait on a command goes through here.
+func (c *Cmd) waitFor(what string, cond func() bool, interval, timeout time.Duration) error {
+	deadline := time.After(timeout)
+	if exited, _, _ := c.Exited(); exited {
+		// Finished, the output is already captured.
+		if c.status != Finished {
+			c.waitCaptured(deadline)
+		}
+		if cond() {
+			return nil
+		}
+		return c.exitedError(what)
+	}
+	if c.maxPollInterval > 0 {
+		interval = c.maxPollInterval
+	}
+	backoff := newBackoff(interval)
+	defer backoff.stop()
+	started := time.Now()
+	progress, stopProgress := c.progressTicker()
+	defer stopProgress()
+	for {
+		// Taken before checking, so that output arriving during the check
+		// isn't missed.
+		changed := c.outputChanged.changed()
+		if cond() {
+			return nil
+		}
+		tick := backoff.next()
+		for tick != nil {
+			select {
+			case <-tick:
+				tick = nil
+			case <-changed:
+				tick, changed = backoff.reset(), nil
+			case <-c.done:
+				c.waitCaptured(deadline)
+				if cond() {
+					return nil
+				}
+				return c.exitedError(what)
+			case <-deadline:
+				return fmt.Errorf("timed out after %s waiting for %s", timeout, what)
+			case <-c.testDone:
+				return errTestOver
+			case <-progress:
+				c.logWaitProgress(what, started)
+			}
+		}
+	}
+}
+
+// backoff paces the checks of a wait: its intervals double from
+// minPollInterval up to max.
+type backoff struct {
+	interval time.Duration
+	max      time.Duration
+	timer    *time.Timer
+	// last is when the previous interval started, so that checks woken up
+	// by output are never closer than minPollInterval.
+	last time.Time
+}
+
+func newBackoff(max time.Duration) *backoff {
+	if max < minPollInterval {
+		max = minPollInterval
+	}
+	return &backoff{interval: minPollInterval, max: max}
+}
+
+// next returns a channel receiving once the current interval elapses, and
+// doubles the following one.
+func (b *backoff) next() <-chan time.Time {
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	b.timer = time.NewTimer(b.interval)
+	b.last = time.Now()
+	if b.interval *= 2; b.interval > b.max {
+		b.interval = b.max
+	}
+	return b.timer.C
+}
+
+// reset starts over from minPollInterval, and returns a channel receiving
+// once what's left of it since the previous interval started elapses: bursts
+// of output don't trigger a check per write.
+func (b *backoff) reset() <-chan time.Time {
+	b.interval = minPollInterval
+	b.timer.Stop()
+	b.timer = time.NewTimer(minPollInterval - time.Since(b.last))
+	return b.timer.C
+}
+
+func (b *backoff) stop() {
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+}
+
+// notifier wakes up the waits on the output of a command when it grows.
+type notifier struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+func newNotifier() *notifier {
+	return &notifier{ch: make(chan struct{})}
+}
+
+// changed returns a channel closed the next time the output grows. It's nil,
+// and never closed, on a nil notifier.
+func (n *notifier) changed() <-chan struct{} {
+	if n == nil {
+		return nil
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.ch
+}
+
+// broadcast wakes up the waits on the output.
+func (n *notifier) broadcast() {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	close(n.ch)
+	n.ch = make(chan struct{})
+}
+
+// waitCaptured waits for the output of the exited command to be captured, and
+// reports whether it was before deadline. Children may still hold the pipes,
+// they aren't waited for past it.
+func (c *Cmd) waitCaptured(deadline <-chan time.Time) bool {
+	captured := make(chan struct{})
+	go func() {
+		c.capture.Wait()
+		close(captured)
+	}()
+	select {
+	case <-captured:
+		return true
+	case <-deadline:
+		return false
+	}
+}
+
+// WaitForStdout waits up to timeout for stdout to contain str, case
+// insensitively like StdoutContains(). The test fails if it doesn't, or as
+// soon as the command exits without printing it.
+func (c *Cmd) WaitForStdout(str string, timeout time.Duration) {
+	c.t.Helper()
+	c.validateHasStarted()
+	if err := c.waitForStdout(str, timeout); err != nil {
+		c.fatalf("%s", err)
+	}
+}
+
+func (c *Cmd) waitForStdout(str string, timeout time.Duration) error {
+	str = strings.ToLower(str)
+	view := c.view(c.stdout, nil)
+	return c.waitFor(fmt.Sprintf("stdout to contain %q", str), func() bool {
+		return strings.Contains(view(), str)
+	}, 10*time.Millisecond, timeout)
+}
+
+// WaitForPort waits up to timeout for addr, a host:port, to accept TCP
+// connections. The test fails if it doesn't, or as soon as the command
+// exits.
+func (c *Cmd) WaitForPort(addr string, timeout time.Duration) {
+	c.t.Helper()
+	c.validateHasStarted()
+	if err := c.waitForPort(addr, timeout); err != nil {
+		c.fatalf("%s", err)
+	}
+}
+
+func (c *Cmd) waitForPort(addr string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	var dialErr error
+	err := c.waitFor(addr+" to accept connections", func() bool {
+		var conn net.Conn
+		conn, dialErr = net.DialTimeout("tcp", addr, time.Until(deadline))
+		if dialErr != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}, 10*time.Millisecond, timeout)
+	if err != nil && dialErr != nil {
+		return fmt.Errorf("%s: %v", err, dialErr)
+	}
+	return err
+}
+
+// Eventually waits up to timeout for cond to return true, e.g. for a file
+// the command writes to show up. The test fails if it doesn't, or as soon as
+// the command exits without cond becoming true.
+func (c *Cmd) Eventually(cond func() bool, timeout time.Duration) {
+	c.t.Helper()
+	c.validateHasStarted()
+	if err := c.waitFor("the condition", cond, 10*time.Millisecond, timeout); err != nil {
+		c.fatalf("%s", err)
+	}
+}
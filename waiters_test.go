@@ -0,0 +1,247 @@
+package testcli
+
+import (
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWaitersReturnWhenTheCommandExits checks every wait on a running command
+// gives up as soon as it exits, long before its timeout.
+func TestWaitersReturnWhenTheCommandExits(t *testing.T) {
+	waiters := map[string]func(c *Cmd) error{
+		"WaitForStdout": func(c *Cmd) error {
+			return c.waitForStdout("never", time.Minute)
+		},
+		"WaitForPort": func(c *Cmd) error {
+			return c.waitForPort("127.0.0.1:1", time.Minute)
+		},
+		"Eventually": func(c *Cmd) error {
+			return c.waitFor("the condition", func() bool { return false }, 10*time.Millisecond, time.Minute)
+		},
+		"StdoutContains": func(c *Cmd) error {
+			return boolWaiter(c.retryStringTest("stdout", strings.Contains, c.view(c.stdout, nil), "never"))
+		},
+		"SpilledMatches": func(c *Cmd) error {
+			return boolWaiter(c.spilledMatches(c.stdout, regexp.MustCompile("never"), true))
+		},
+	}
+	for name, wait := range waiters {
+		wait := wait
+		t.Run(name, func(t *testing.T) {
+			c := Command(t, "sh", "-c", "echo ready; sleep 0.2; exit 3")
+			if name == "SpilledMatches" {
+				c.SpillCaptureToDisk(t.TempDir(), 1)
+			}
+			c.Start()
+			started := time.Now()
+			err := wait(c)
+			if elapsed := time.Since(started); elapsed > 2*time.Second {
+				t.Fatalf("Expected to return when the command exited, took %s", elapsed)
+			}
+			if err == nil {
+				t.Fatalf("Expected to fail, but succeeded")
+			}
+			if err != errBoolWaiter && !strings.Contains(err.Error(), "process exited (code 3) while waiting for") {
+				t.Fatalf("Expected %q to mention the exit", err)
+			}
+			c.Wait()
+		})
+	}
+}
+
+func TestWaitForStdoutAfterExit(t *testing.T) {
+	c := Command(t, "echo", "ready")
+	c.Start()
+	if err := c.waitForStdout("ready", time.Minute); err != nil {
+		t.Fatalf("Expected the output printed before exiting to be found: %s", err)
+	}
+	c.Wait()
+}
+
+func TestWaitForStdoutTimesOut(t *testing.T) {
+	c := Command(t, "sleep", "5")
+	c.Start()
+	defer c.Kill()
+	err := c.waitForStdout("never", 50*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timed out after 50ms") {
+		t.Fatalf("Expected to time out, got %v", err)
+	}
+}
+
+var errBoolWaiter = &exitedError{what: "a matcher"}
+
+// boolWaiter turns the result of a waiter returning a bool into an error.
+func boolWaiter(ok bool) error {
+	if ok {
+		return nil
+	}
+	return errBoolWaiter
+}
+
+func TestWaitForWakesUpOnOutput(t *testing.T) {
+	c := Command(t, "cat")
+	c.SetMaxPollInterval(time.Hour)
+	c.StdinPipe()
+	c.Start()
+	defer c.Kill()
+
+	written := make(chan time.Time, 1)
+	go func() {
+		// Long enough for the back-off to reach intervals of seconds.
+		time.Sleep(1500 * time.Millisecond)
+		written <- time.Now()
+		c.WriteStdin("ready\n")
+	}()
+	if err := c.waitForStdout("ready", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(<-written); elapsed > 500*time.Millisecond {
+		t.Fatalf("Expected the output to be seen as soon as captured, took %s", elapsed)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := newBackoff(20 * time.Millisecond)
+	defer b.stop()
+	var intervals []time.Duration
+	for i := 0; i < 4; i++ {
+		intervals = append(intervals, b.interval)
+		b.next()
+	}
+	if want := []time.Duration{5 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond, 20 * time.Millisecond}; !reflect.DeepEqual(intervals, want) {
+		t.Fatalf("Expected intervals %v, got %v", want, intervals)
+	}
+	b.reset()
+	if b.interval != minPollInterval {
+		t.Fatalf("Expected reset() to start over from %s, got %s", minPollInterval, b.interval)
+	}
+}
+
+func TestAssertionsOnFinishedCommandDontPoll(t *testing.T) {
+	c := Command(t, "echo", "ready")
+	c.Run()
+
+	started := time.Now()
+	if !c.StdoutContains("ready") {
+		t.Fatalf("Expected %q to contain %q", c.Stdout(), "ready")
+	}
+	if c.StdoutContains("never") || c.StderrMatches("never") {
+		t.Fatalf("Expected not to find what wasn't printed")
+	}
+	if elapsed := time.Since(started); elapsed > 50*time.Millisecond {
+		t.Fatalf("Expected a single check of the finished command, took %s", elapsed)
+	}
+}
+
+func TestAssertionsOnRunningCommandPoll(t *testing.T) {
+	c := Command(t, "sh", "-c", "sleep 0.2; echo late; exec sleep 5")
+	c.Start()
+	defer c.Kill()
+	if !c.StdoutContains("late") {
+		t.Fatalf("Expected the output printed after starting to be found, got %q", c.Stdout())
+	}
+}
+
+// terminalStates start commands which end in each of the ways a command can
+// end, with why no further output can arrive.
+var terminalStates = []struct {
+	name   string
+	start  func(t *testing.T) *Cmd
+	reason string
+}{
+	{"Exited", func(t *testing.T) *Cmd {
+		c := Command(t, "echo", "ready")
+		c.Start()
+		return c
+	}, "process exited (code 0)"},
+	{"Killed", func(t *testing.T) *Cmd {
+		c := Command(t, "sh", "-c", "echo ready; exec sleep 5")
+		c.Start()
+		c.WaitForStdout("ready", 5*time.Second)
+		c.Kill()
+		return c
+	}, "process was killed"},
+	{"KilledExternally", func(t *testing.T) *Cmd {
+		c := Command(t, "sleep", "5")
+		c.Start()
+		p, err := os.FindProcess(c.PID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Kill()
+		return c
+	}, "process was killed: signal: killed"},
+	{"TimedOut", func(t *testing.T) *Cmd {
+		c := Command(t, "sleep", "5")
+		c.SetTimeout(50*time.Millisecond, Immediate())
+		c.Start()
+		return c
+	}, "process was killed by timeout after 50ms"},
+	{"FailedToStart", func(t *testing.T) *Cmd {
+		c := Command(t, "/nonexistent/testcli")
+		c.Start()
+		return c
+	}, "process failed to start:"},
+}
+
+func TestMatchersOnEndedCommandDontPoll(t *testing.T) {
+	matchers := map[string]func(c *Cmd) error{
+		"StdoutContains": func(c *Cmd) error {
+			return boolWaiter(c.StdoutContains("never"))
+		},
+		"StderrMatches": func(c *Cmd) error {
+			return boolWaiter(c.StderrMatches("never"))
+		},
+		"WaitForStdout": func(c *Cmd) error {
+			return c.waitForStdout("never", time.Minute)
+		},
+		"Eventually": func(c *Cmd) error {
+			return c.waitFor("the condition", func() bool { return false }, time.Second, time.Minute)
+		},
+	}
+	for _, state := range terminalStates {
+		state := state
+		t.Run(state.name, func(t *testing.T) {
+			c := state.start(t)
+			for c.IsRunning() {
+				time.Sleep(10 * time.Millisecond)
+			}
+			for name, match := range matchers {
+				started := time.Now()
+				err := match(c)
+				if elapsed := time.Since(started); elapsed > 200*time.Millisecond {
+					t.Errorf("Expected %s to evaluate at once, took %s", name, elapsed)
+				}
+				if err == nil {
+					t.Errorf("Expected %s to fail", name)
+				} else if err != errBoolWaiter && !(strings.Contains(err.Error(), state.reason) && strings.Contains(err.Error(), "while waiting for")) {
+					t.Errorf("Expected %s to explain %q, got %q", name, state.reason, err)
+				}
+			}
+		})
+	}
+}
+
+func TestReportExplainsAbnormalEnd(t *testing.T) {
+	for _, state := range terminalStates {
+		state := state
+		t.Run(state.name, func(t *testing.T) {
+			c := state.start(t)
+			for c.IsRunning() {
+				time.Sleep(10 * time.Millisecond)
+			}
+			report := c.report()
+			mentioned := strings.Contains(report, "no further output can arrive: "+state.reason)
+			if state.name == "Exited" && mentioned {
+				t.Fatalf("Expected the report not to explain a plain exit, got %q", report)
+			}
+			if state.name != "Exited" && !mentioned {
+				t.Fatalf("Expected the report to explain %q, got %q", state.reason, report)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package testcli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Workspace is a scratch directory for the commands of a test. It's removed,
+// along with any fault injected into it, when the test finishes.
+type Workspace struct {
+	t    *testing.T
+	root string
+}
+
+// NewWorkspace creates an empty workspace for the test.
+func NewWorkspace(t *testing.T) *Workspace {
+	t.Helper()
+	return &Workspace{t: t, root: t.TempDir()}
+}
+
+// Root returns the absolute path of the workspace.
+func (ws *Workspace) Root() string {
+	return ws.root
+}
+
+// Path returns the absolute path of rel, a slash-separated path relative to
+// the workspace root.
+func (ws *Workspace) Path(rel string) string {
+	return filepath.Join(ws.root, filepath.FromSlash(rel))
+}
+
+// WriteFile writes content to rel, creating the directories leading to it.
+func (ws *Workspace) WriteFile(rel string, content []byte) {
+	ws.t.Helper()
+	path := ws.Path(rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		ws.t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		ws.t.Fatal(err)
+	}
+}
+
+// Mkdir creates the directory rel and the directories leading to it.
+func (ws *Workspace) Mkdir(rel string) {
+	ws.t.Helper()
+	if err := os.MkdirAll(ws.Path(rel), 0755); err != nil {
+		ws.t.Fatal(err)
+	}
+}
+
+// Command constructs a *Cmd running in the workspace root.
+func (ws *Workspace) Command(name string, arg ...string) *Cmd {
+	c := Command(ws.t, name, arg...)
+	c.cmd.Dir = ws.root
+	c.workspace = ws
+	return c
+}
+
+// tempDir creates a new directory for the files created for the command,
+// e.g. its input files: within its workspace if it has one, so that they're
+// part of it, otherwise one removed when the test finishes.
+func (c *Cmd) tempDir(prefix string) string {
+	c.t.Helper()
+	if c.workspace == nil {
+		return c.t.TempDir()
+	}
+	dir, err := ioutil.TempDir(c.workspace.root, prefix)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return dir
+}
+
+// MakeReadOnly removes the write permissions of rel, a file or directory
+// created if missing, so that commands fail to write there. The permissions
+// are restored when the test finishes. The test is skipped if permissions
+// aren't enforced for the current user, e.g. when running as root.
+func (ws *Workspace) MakeReadOnly(rel string) {
+	ws.t.Helper()
+	path := ws.Path(rel)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		ws.Mkdir(rel)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		ws.t.Fatal(err)
+	}
+
+	mode := info.Mode().Perm()
+	if err := os.Chmod(path, mode&^0222); err != nil {
+		ws.t.Fatal(err)
+	}
+	ws.t.Cleanup(func() {
+		os.Chmod(path, mode)
+	})
+
+	if writable(path, info.IsDir()) {
+		ws.t.Skipf("%s is still writable after removing write permissions, are we root?", rel)
+	}
+}
+
+// writable reports whether path can be written despite its permissions.
+func writable(path string, isDir bool) bool {
+	if !isDir {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return false
+		}
+		f.Close()
+		return true
+	}
+
+	f, err := ioutil.TempFile(path, ".testcli")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return true
+}
+
+// MountTinyTmpfs mounts a tmpfs of size bytes on the directory rel, created
+// if missing, so that commands writing more than that there fail with ENOSPC.
+// It's unmounted when the test finishes. It's only supported on Linux, and
+// needs privileges to mount file systems; the test is skipped otherwise.
+func (ws *Workspace) MountTinyTmpfs(rel string, size int64) {
+	ws.t.Helper()
+	ws.Mkdir(rel)
+	path := ws.Path(rel)
+	if err := mountTmpfs(path, size); err != nil {
+		ws.t.Skipf("Can't mount a tmpfs on %s: %s", rel, err)
+	}
+	ws.t.Cleanup(func() {
+		unmount(path)
+	})
+}
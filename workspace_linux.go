@@ -0,0 +1,14 @@
+package testcli
+
+import (
+	"strconv"
+	"syscall"
+)
+
+func mountTmpfs(path string, size int64) error {
+	return syscall.Mount("tmpfs", path, "tmpfs", 0, "size="+strconv.FormatInt(size, 10))
+}
+
+func unmount(path string) error {
+	return syscall.Unmount(path, syscall.MNT_DETACH)
+}
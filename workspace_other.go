@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package testcli
+
+import "errors"
+
+func mountTmpfs(path string, size int64) error {
+	return errors.New("tmpfs mounts are only supported on Linux")
+}
+
+func unmount(path string) error {
+	return nil
+}
@@ -0,0 +1,56 @@
+package testcli
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestWorkspace(t *testing.T) {
+	ws := NewWorkspace(t)
+	ws.WriteFile("in/data.txt", []byte("hello\n"))
+
+	c := ws.Command("/bin/sh", "-c", "mkdir out && cp in/data.txt out/ && pwd")
+	c.Run()
+	if !c.Success() {
+		t.Fatalf("Expected to succeed, but failed with error: %s", c.Error())
+	}
+
+	if !c.StdoutContains(ws.Root()) {
+		t.Fatalf("Expected %q to contain %q", c.Stdout(), ws.Root())
+	}
+
+	content, err := ioutil.ReadFile(ws.Path("out/data.txt"))
+	if err != nil || string(content) != "hello\n" {
+		t.Fatalf("Expected out/data.txt to be a copy, got %q (%v)", content, err)
+	}
+}
+
+func TestWorkspaceMakeReadOnly(t *testing.T) {
+	ws := NewWorkspace(t)
+	ws.MakeReadOnly("out")
+
+	c := ws.Command("/bin/sh", "-c", "echo hello > out/data.txt")
+	c.Run()
+	if !c.Failure() {
+		t.Fatalf("Expected to fail, but succeeded")
+	}
+
+	if !c.StderrContains("permission denied") {
+		t.Fatalf("Expected %q to contain %q", c.Stderr(), "permission denied")
+	}
+}
+
+func TestWorkspaceMountTinyTmpfs(t *testing.T) {
+	ws := NewWorkspace(t)
+	ws.MountTinyTmpfs("out", 64*1024)
+
+	c := ws.Command("/bin/sh", "-c", "head -c 1000000 /dev/zero > out/data")
+	c.Run()
+	if !c.Failure() {
+		t.Fatalf("Expected to fail, but succeeded")
+	}
+
+	if !c.StderrContains("no space left") {
+		t.Fatalf("Expected %q to contain %q", c.Stderr(), "no space left")
+	}
+}